@@ -21,6 +21,8 @@ var (
 func handleShutdown(signalHandler chan os.Signal, done chan bool, conf *config.AppConfig) {
 	<-signalHandler
 	conf.RequestWait.Wait()
+	conf.DeleteService.Close()
+	conf.PreviewService.Close()
 	err := conf.Repo.Close()
 	if err != nil {
 		panic(err)
@@ -31,8 +33,19 @@ func handleShutdown(signalHandler chan os.Signal, done chan bool, conf *config.A
 			panic(err)
 		}
 	}
-	conf.DeleteService.Close()
-	conf.UserIDGenerator.Cancel()
+	if conf.GeoIP != nil {
+		if err = conf.GeoIP.Close(); err != nil {
+			panic(err)
+		}
+	}
+	if conf.Redis != nil {
+		if err = conf.Redis.Close(); err != nil {
+			panic(err)
+		}
+	}
+	if err = conf.UserIDGenerator.Close(); err != nil {
+		panic(err)
+	}
 	done <- true
 }
 
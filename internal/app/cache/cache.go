@@ -0,0 +1,17 @@
+// Package cache define key-value caches of serialized data: in-process LRU and shared Redis.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache stores values by keys for limited time.
+type Cache interface {
+	// Get returns value by key, false if key is not cached or its time to live is over.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value by key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes keys, missing keys are skipped.
+	Delete(ctx context.Context, keys ...string) error
+}
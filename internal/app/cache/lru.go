@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// lruEntry cached value with its key and time when it expires.
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU is in-process Cache of limited size, the least recently used key is evicted when cache is full.
+// Every replica of service has own LRU and invalidation of one replica doesn't reach others,
+// so LRU is for single replica only, several replicas must share Redis.
+type LRU struct {
+	mx      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+// NewLRU returns new LRU which stores at most size keys.
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+		now:     time.Now,
+	}
+}
+
+// Get returns value by key and marks key as recently used. Expired key is removed.
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*lruEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.remove(el)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+// Set stores value by key for ttl, the least recently used key is evicted if cache is full.
+func (c *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.size <= 0 || ttl <= 0 {
+		return nil
+	}
+	expiresAt := c.now().Add(ttl)
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(el)
+		return nil
+	}
+	if c.order.Len() >= c.size {
+		c.remove(c.order.Back())
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	return nil
+}
+
+// Delete removes keys.
+func (c *LRU) Delete(ctx context.Context, keys ...string) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for _, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+// Len returns count of cached keys including expired ones which are not removed yet.
+func (c *LRU) Len() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.order.Len()
+}
+
+// remove removes element of key, c must be locked.
+func (c *LRU) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*lruEntry).key)
+}
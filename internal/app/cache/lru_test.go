@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.TODO()
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+	value, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	// "b" is the least recently used key
+	require.NoError(t, c.Set(ctx, "c", []byte("3"), time.Minute))
+	assert.Equal(t, 2, c.Len())
+	_, ok, _ = c.Get(ctx, "b")
+	assert.False(t, ok)
+	_, ok, _ = c.Get(ctx, "a")
+	assert.True(t, ok)
+
+	// replaced value with new ttl
+	require.NoError(t, c.Set(ctx, "c", []byte("4"), 2*time.Minute))
+	now = now.Add(time.Minute)
+	_, ok, _ = c.Get(ctx, "a")
+	assert.False(t, ok)
+	value, ok, _ = c.Get(ctx, "c")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("4"), value)
+	assert.Equal(t, 1, c.Len())
+
+	require.NoError(t, c.Delete(ctx, "c", "missing"))
+	_, ok, _ = c.Get(ctx, "c")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestLRU_disabled(t *testing.T) {
+	ctx := context.TODO()
+	c := NewLRU(0)
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	_, ok, _ := c.Get(ctx, "a")
+	assert.False(t, ok)
+
+	c = NewLRU(10)
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), 0))
+	_, ok, _ = c.Get(ctx, "a")
+	assert.False(t, ok)
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRedis(t *testing.T) {
+	ctx := context.TODO()
+	server := miniredis.RunT(t)
+	c, err := OpenRedis(ctx, "redis://"+server.Addr(), "test:")
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+	value, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+	stored, err := server.Get("test:a")
+	require.NoError(t, err)
+	assert.Equal(t, "1", stored)
+
+	server.FastForward(time.Minute)
+	_, ok, err = c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+	require.NoError(t, c.Delete(ctx, "b", "missing"))
+	assert.False(t, server.Exists("test:b"))
+
+	server.SetError("server is down")
+	_, _, err = c.Get(ctx, "b")
+	assert.Error(t, err)
+}
+
+func TestOpenRedis(t *testing.T) {
+	_, err := OpenRedis(context.TODO(), "http://localhost", "")
+	assert.Error(t, err)
+}
@@ -3,10 +3,15 @@ package config
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"github.com/jackc/pgx/v5"
+	"go-axesthump-shortener/internal/app/cache"
 	"go-axesthump-shortener/internal/app/generator"
+	"go-axesthump-shortener/internal/app/geoip"
 	"go-axesthump-shortener/internal/app/repository"
 	"go-axesthump-shortener/internal/app/service"
 	"go-axesthump-shortener/internal/app/util"
@@ -15,6 +20,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
 )
 
 type ConfFile struct {
@@ -23,6 +29,79 @@ type ConfFile struct {
 	FileStoragePass string `json:"file_storage_pass"`
 	DBDsn           string `json:"database_dsn"`
 	EnableHTTPS     bool   `json:"enable_https"`
+	DeleteQueueSize int    `json:"delete_queue_size"`
+	DeleteJournal   string `json:"delete_journal_path"`
+	DeleteWorkers   int    `json:"delete_workers"`
+	DeleteFlushSize int    `json:"delete_flush_size"`
+	DeleteFlushTime string `json:"delete_flush_interval"`
+	PreviewQueue    int    `json:"preview_queue_size"`
+	PreviewWorkers  int    `json:"preview_workers"`
+	PreviewTimeout  string `json:"preview_timeout"`
+	PreviewMaxBody  int    `json:"preview_max_body_size"`
+	RedirectStatus  int    `json:"redirect_status"`
+	Passthrough     string `json:"passthrough"`
+	QueryConflict   string `json:"query_conflict"`
+	GeoIPPath       string `json:"geoip_db_path"`
+	ScheduledPage   bool   `json:"scheduled_page"`
+	IDAllocator     string `json:"id_allocator"`
+	IDNode          int    `json:"id_node"`
+	IDBlockSize     int    `json:"id_block_size"`
+	CodeStrategy    string `json:"code_strategy"`
+	CodeLength      int    `json:"code_length"`
+	CodeAlphabet    string `json:"code_alphabet"`
+	CacheSize       int    `json:"cache_size"`
+	CacheTTL        string `json:"cache_ttl"`
+	CacheNegTTL     string `json:"cache_negative_ttl"`
+	RedisURL        string `json:"redis_url"`
+	SecretKey       string `json:"secret_key"`
+}
+
+// Allocators of short url codes and user ids.
+const (
+	// IDAllocatorMemory - in-process counters, ids are unique only for one replica of service.
+	IDAllocatorMemory = "memory"
+	// IDAllocatorPostgres - codes and user ids are leased by blocks from db sequences, it requires db.
+	IDAllocatorPostgres = "postgres"
+	// IDAllocatorSnowflake - codes are created from time and node id of replica,
+	// user ids are 32-bit so they are leased from db sequence, it requires db.
+	IDAllocatorSnowflake = "snowflake"
+)
+
+// Strategies of short url codes.
+const (
+	// CodeStrategySequential - codes are sequential ids of id allocator formatted as decimal numbers.
+	CodeStrategySequential = "sequential"
+	// CodeStrategyRandom - codes are drawn randomly from alphabet, collided codes are retried.
+	// Sequential codes created before stay valid, random codes created before change of length don't.
+	CodeStrategyRandom = "random"
+)
+
+// Defaults of cache of redirect data.
+const (
+	defaultCacheTTL         = 5 * time.Minute
+	defaultCacheNegativeTTL = 10 * time.Second
+)
+
+// redisCachePrefix - prefix of keys of service in Redis.
+const redisCachePrefix = "shortener:"
+
+// defaultIDBlockSize - count of codes or user ids leased from db sequence at once.
+const defaultIDBlockSize = 100
+
+// Db sequences of id allocator.
+const (
+	userIDSequence = "shortener_user_id_seq"
+	codeSequence   = "shortener_code_seq"
+)
+
+// idAllocatorSetter repository where codes of new urls are created by allocator.
+type idAllocatorSetter interface {
+	SetIDAllocator(ids generator.Allocator)
+}
+
+// codeSequenceSource repository which knows first code of db sequence of codes.
+type codeSequenceSource interface {
+	GetFirstFreeCode() int64
 }
 
 // AppConfig contains data for configuration
@@ -32,25 +111,85 @@ type AppConfig struct {
 	Repo            repository.Repository
 	DBContext       context.Context
 	Conn            *pgx.Conn
-	UserIDGenerator *generator.IDGenerator
-	DeleteService   *service.DeleteService
-	IsHTTPS         bool
-	RequestWait     *sync.WaitGroup
+	UserIDGenerator generator.Allocator
+	// Codes - format of short url codes.
+	Codes generator.CodeFormat
+	// Redis - shared cache of redirect data, nil if Redis url is not set.
+	Redis          *cache.Redis
+	DeleteJobs     repository.DeleteJobStore
+	DeleteService  *service.DeleteService
+	PreviewService *service.PreviewService
+	IsHTTPS        bool
+	// RedirectStatus - status of redirect by short url without own redirect type.
+	RedirectStatus repository.RedirectType
+	// Passthrough - parts of request passed to destination of url without own mode.
+	Passthrough repository.PassthroughMode
+	// QueryConflict - policy of query params merge of url without own policy.
+	QueryConflict repository.QueryConflict
+	// GeoIP - country database for redirect rules, nil if database path is not set.
+	GeoIP *geoip.DB
+	// ScheduledPage - show page with activation time for urls which are not active yet instead of 404.
+	ScheduledPage bool
+	// SecretKey - key of signed cookies of urls protected by password.
+	SecretKey   []byte
+	RequestWait *sync.WaitGroup
 
-	storagePath string
-	dbConnURL   string
+	storagePath       string
+	dbConnURL         string
+	deleteConfig      service.DeleteConfig
+	previewConfig     service.PreviewConfig
+	deleteJournalPath string
+	geoIPPath         string
+	idAllocator       string
+	idNode            int
+	idBlockSize       int
+	codeStrategy      string
+	codeLength        int
+	codeAlphabet      string
+	cacheSize         int
+	cacheTTL          time.Duration
+	cacheNegativeTTL  time.Duration
+	redisURL          string
+	secret            string
 }
 
 // NewAppConfig returns new AppConfig or error if it fails to create
 // Creates and connects a repository based on the flags passed to the program.
 func NewAppConfig() (*AppConfig, error) {
 	appConfig := getServerConf()
+	if appConfig.RedirectStatus == repository.RedirectDefault || !appConfig.RedirectStatus.IsValid() {
+		return nil, fmt.Errorf("unsupported redirect status %d", appConfig.RedirectStatus)
+	}
+	if appConfig.Passthrough == repository.PassthroughDefault || !appConfig.Passthrough.IsValid() {
+		return nil, fmt.Errorf("unsupported passthrough mode %q", appConfig.Passthrough)
+	}
+	if appConfig.QueryConflict == repository.QueryConflictDefault || !appConfig.QueryConflict.IsValid() {
+		return nil, fmt.Errorf("unsupported query conflict policy %q", appConfig.QueryConflict)
+	}
+	if len(appConfig.geoIPPath) != 0 {
+		db, err := geoip.Open(appConfig.geoIPPath)
+		if err != nil {
+			return nil, fmt.Errorf("cant open geoip database: %w", err)
+		}
+		appConfig.GeoIP = db
+	}
+	if err := setSecretKey(appConfig); err != nil {
+		return nil, err
+	}
 	appConfig.RequestWait = &sync.WaitGroup{}
 	setDBConn(appConfig)
 	if err := setStorage(appConfig); err != nil {
 		return nil, err
 	}
-	appConfig.DeleteService = service.NewDeleteService(appConfig.Repo, appConfig.BaseURL)
+	if err := setURLCache(appConfig); err != nil {
+		return nil, err
+	}
+	appConfig.DeleteService = service.NewDeleteService(
+		appConfig.Repo,
+		appConfig.DeleteJobs,
+		appConfig.deleteConfig,
+	)
+	appConfig.PreviewService = service.NewPreviewService(appConfig.Repo, appConfig.previewConfig)
 	return appConfig, nil
 }
 
@@ -72,9 +211,36 @@ func setDBConn(config *AppConfig) {
 	createTable(config)
 }
 
-// createTable creates table shortener if not exists.
+// createTable creates tables for urls, url history, delete jobs, shares, workspaces, utm templates and domains if not exists.
 func createTable(config *AppConfig) {
-	query := "CREATE TABLE IF NOT EXISTS shortener (shortener_id SERIAL PRIMARY KEY, long_url varchar(255) NOT NULL UNIQUE, user_id int NOT NULL, is_deleted BOOLEAN DEFAULT FALSE NOT NULL); CREATE INDEX IF NOT EXISTS idx_shortener_user_id ON shortener(user_id);"
+	query := "CREATE TABLE IF NOT EXISTS shortener (shortener_id SERIAL PRIMARY KEY, long_url varchar(255) NOT NULL UNIQUE, user_id int NOT NULL, is_deleted BOOLEAN DEFAULT FALSE NOT NULL); CREATE INDEX IF NOT EXISTS idx_shortener_user_id ON shortener(user_id);" +
+		"CREATE TABLE IF NOT EXISTS deletion_jobs (job_id varchar(32) PRIMARY KEY, user_id int NOT NULL, urls text[] NOT NULL, status varchar(16) NOT NULL, total int NOT NULL, processed int NOT NULL, attempts int NOT NULL, last_error text NOT NULL, created_at timestamptz NOT NULL, updated_at timestamptz NOT NULL); CREATE INDEX IF NOT EXISTS idx_deletion_jobs_status ON deletion_jobs(status);" +
+		"CREATE TABLE IF NOT EXISTS url_shares (shortener_id int NOT NULL REFERENCES shortener(shortener_id), user_id int NOT NULL, permission varchar(16) NOT NULL, PRIMARY KEY (shortener_id, user_id)); CREATE INDEX IF NOT EXISTS idx_url_shares_user_id ON url_shares(user_id);" +
+		"CREATE TABLE IF NOT EXISTS workspaces (workspace_id SERIAL PRIMARY KEY, name varchar(255) NOT NULL);" +
+		"CREATE TABLE IF NOT EXISTS workspace_members (workspace_id int NOT NULL REFERENCES workspaces(workspace_id), user_id int NOT NULL, role varchar(16) NOT NULL, PRIMARY KEY (workspace_id, user_id)); CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members(user_id);" +
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS workspace_id int DEFAULT 0 NOT NULL; CREATE INDEX IF NOT EXISTS idx_shortener_workspace_id ON shortener(workspace_id);" +
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS created_at timestamptz DEFAULT now() NOT NULL, ADD COLUMN IF NOT EXISTS clicks bigint DEFAULT 0 NOT NULL, ADD COLUMN IF NOT EXISTS tags text[] DEFAULT '{}' NOT NULL;" +
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS title text DEFAULT '' NOT NULL, ADD COLUMN IF NOT EXISTS description text DEFAULT '' NOT NULL, ADD COLUMN IF NOT EXISTS updated_at timestamptz DEFAULT now() NOT NULL;" +
+		"CREATE INDEX IF NOT EXISTS idx_shortener_user_created ON shortener(user_id, created_at, shortener_id); CREATE INDEX IF NOT EXISTS idx_shortener_user_clicks ON shortener(user_id, clicks, shortener_id); CREATE INDEX IF NOT EXISTS idx_shortener_tags ON shortener USING GIN (tags);" +
+		"CREATE TABLE IF NOT EXISTS url_history (shortener_id int NOT NULL REFERENCES shortener(shortener_id), version int NOT NULL, long_url text NOT NULL, changed_at timestamptz NOT NULL, PRIMARY KEY (shortener_id, version));" +
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS preview jsonb, ADD COLUMN IF NOT EXISTS redirect_type int DEFAULT 0 NOT NULL;" +
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS passthrough varchar(16) DEFAULT '' NOT NULL, ADD COLUMN IF NOT EXISTS query_conflict varchar(16) DEFAULT '' NOT NULL;" +
+		"CREATE TABLE IF NOT EXISTS utm_templates (user_id int NOT NULL, name varchar(64) NOT NULL, utm_source text NOT NULL, utm_medium text NOT NULL, utm_campaign text NOT NULL, utm_term text NOT NULL, utm_content text NOT NULL, PRIMARY KEY (user_id, name));" +
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS rules jsonb DEFAULT '[]' NOT NULL;" +
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS variants jsonb DEFAULT '[]' NOT NULL;" +
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS password_hash text DEFAULT '' NOT NULL;" +
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS max_clicks bigint DEFAULT 0 NOT NULL, ADD COLUMN IF NOT EXISTS clicks_left bigint DEFAULT 0 NOT NULL;" +
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS activates_at timestamptz;" +
+		"CREATE TABLE IF NOT EXISTS domains (name varchar(253) PRIMARY KEY, owner_id int NOT NULL, created_at timestamptz NOT NULL); CREATE INDEX IF NOT EXISTS idx_domains_owner_id ON domains(owner_id);" +
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS short_domain varchar(253) DEFAULT '' NOT NULL;" +
+		"ALTER TABLE shortener DROP CONSTRAINT IF EXISTS shortener_long_url_key; CREATE UNIQUE INDEX IF NOT EXISTS idx_shortener_domain_long_url ON shortener(short_domain, long_url);" +
+		// host of long url is kept by db in indexed column for filter of user urls by domain
+		"ALTER TABLE shortener ADD COLUMN IF NOT EXISTS long_url_host text GENERATED ALWAYS AS (lower(substring(long_url from '^[^:/?#]+://(?:[^/?#@]*@)?([^/?#:]+)'))) STORED; CREATE INDEX IF NOT EXISTS idx_shortener_user_host ON shortener(user_id, long_url_host); CREATE INDEX IF NOT EXISTS idx_shortener_workspace_host ON shortener(workspace_id, long_url_host);" +
+		// codes columns are migrated to bigint once, altered tables are locked and rewritten
+		"DO $$ DECLARE t text; BEGIN FOR t IN SELECT table_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name IN ('shortener', 'url_shares', 'url_history') AND column_name = 'shortener_id' AND data_type <> 'bigint' LOOP " +
+		"EXECUTE format('ALTER TABLE %I ALTER COLUMN shortener_id TYPE bigint', t); " +
+		"IF t = 'shortener' THEN EXECUTE format('ALTER SEQUENCE %s AS bigint', pg_get_serial_sequence('shortener', 'shortener_id')); END IF; " +
+		"END LOOP; END $$;"
 	_, err := config.Conn.Exec(config.DBContext, query)
 	if err != nil {
 		panic(err)
@@ -82,13 +248,16 @@ func createTable(config *AppConfig) {
 }
 
 // setStorage a factory method that sets the required repository based on their configuration.
+// Delete jobs are stored in db or in journal file next to local storage.
 func setStorage(config *AppConfig) error {
 	var lastUserID uint32
+	journalPath := config.deleteJournalPath
 	switch {
 	case config.Conn != nil:
 		log.Printf("Use db repository!")
 		db := repository.NewDBStorage(config.DBContext, config.Conn)
 		config.Repo = db
+		config.DeleteJobs = db
 		lastUserID = uint32(db.GetLastUserID())
 	case len(config.storagePath) != 0:
 		log.Printf("Use localStorage repository!")
@@ -98,12 +267,157 @@ func setStorage(config *AppConfig) error {
 		}
 		config.Repo = localStorage
 		lastUserID = localStorage.GetUserLastID()
+		if len(journalPath) == 0 {
+			journalPath = config.storagePath + ".jobs"
+		}
 	default:
 		log.Printf("Use inMemory repository!")
 		config.Repo = repository.NewInMemoryStorage()
 		lastUserID = 0
 	}
-	config.UserIDGenerator = generator.NewIDGenerator(int64(lastUserID))
+	if config.DeleteJobs == nil {
+		journal, err := repository.NewDeleteJobJournal(journalPath)
+		if err != nil {
+			return err
+		}
+		config.DeleteJobs = journal
+	}
+	if err := setIDAllocators(config, lastUserID); err != nil {
+		return err
+	}
+	return setCodeStrategy(config)
+}
+
+// setIDAllocators sets allocators of short url codes and user ids by kind of allocator from configuration.
+// lastUserID is next user id after ids of storage, it starts new db sequence.
+// Allocators of several replicas require database, in-process ids of replicas would collide.
+func setIDAllocators(config *AppConfig, lastUserID uint32) error {
+	switch config.idAllocator {
+	case "", IDAllocatorMemory:
+		config.UserIDGenerator = generator.NewIDGenerator(int64(lastUserID))
+		return nil
+	case IDAllocatorPostgres, IDAllocatorSnowflake:
+	default:
+		return fmt.Errorf("unknown id allocator %q", config.idAllocator)
+	}
+	if config.Conn == nil {
+		return fmt.Errorf("%s id allocator requires database", config.idAllocator)
+	}
+	repo, ok := config.Repo.(idAllocatorSetter)
+	if !ok {
+		return errors.New("repository doesn't support id allocator")
+	}
+	blockSize := config.idBlockSize
+	if blockSize <= 0 {
+		blockSize = defaultIDBlockSize
+	}
+	if config.idAllocator == IDAllocatorSnowflake {
+		codes, err := generator.NewSnowflake(int64(config.idNode))
+		if err != nil {
+			return err
+		}
+		repo.SetIDAllocator(codes)
+	} else {
+		source, ok := config.Repo.(codeSequenceSource)
+		if !ok {
+			return errors.New("repository doesn't support db sequence of codes")
+		}
+		codes, err := generator.NewPostgresSequence(config.DBContext, config.Conn, codeSequence, int64(blockSize), source.GetFirstFreeCode())
+		if err != nil {
+			return err
+		}
+		repo.SetIDAllocator(generator.NewLeaseAllocator(codes))
+	}
+	users, err := generator.NewPostgresSequence(config.DBContext, config.Conn, userIDSequence, int64(blockSize), int64(lastUserID))
+	if err != nil {
+		return err
+	}
+	config.UserIDGenerator = generator.NewLeaseAllocator(users)
+	return nil
+}
+
+// setCodeStrategy sets format of short url codes and allocator of random codes by code strategy from configuration.
+func setCodeStrategy(config *AppConfig) error {
+	switch config.codeStrategy {
+	case "", CodeStrategySequential:
+		config.Codes = generator.DecimalCodes{}
+		return nil
+	case CodeStrategyRandom:
+	default:
+		return fmt.Errorf("unknown code strategy %q", config.codeStrategy)
+	}
+	if config.idAllocator == IDAllocatorSnowflake {
+		return errors.New("random codes can't be used with snowflake id allocator")
+	}
+	length := config.codeLength
+	if length == 0 {
+		length = generator.DefaultCodeLength
+	}
+	alphabet := config.codeAlphabet
+	if len(alphabet) == 0 {
+		alphabet = generator.DefaultCodeAlphabet
+	}
+	codes, err := generator.NewRandomCodes(alphabet, length)
+	if err != nil {
+		return err
+	}
+	repo, ok := config.Repo.(idAllocatorSetter)
+	if !ok {
+		return errors.New("repository doesn't support id allocator")
+	}
+	repo.SetIDAllocator(codes)
+	config.Codes = codes
+	return nil
+}
+
+// secretKeySize - size of random secret key used if key is not configured.
+const secretKeySize = 32
+
+// setSecretKey sets key of signed cookies from config.
+// Random key is used if key is not set, cookies signed by it are valid only for this process.
+func setSecretKey(config *AppConfig) error {
+	if len(config.secret) != 0 {
+		config.SecretKey = []byte(config.secret)
+		return nil
+	}
+	log.Println("Secret key is not set, password cookies are valid until restart of this replica")
+	config.SecretKey = make([]byte, secretKeySize)
+	if _, err := rand.Read(config.SecretKey); err != nil {
+		return fmt.Errorf("cant generate secret key: %w", err)
+	}
+	return nil
+}
+
+// setURLCache puts read-through cache of redirect data in front of repository if cache is configured.
+// Redis is used if its url is set, otherwise in-process LRU of cacheSize urls.
+// LRU is for single replica of service only, changes of urls by other replicas are not seen until ttl is over.
+// Zero ttls are replaced by defaults, negative ttl of unknown codes disables negative caching.
+func setURLCache(config *AppConfig) error {
+	var store cache.Cache
+	switch {
+	case len(config.redisURL) != 0:
+		log.Printf("Use redis cache!")
+		redis, err := cache.OpenRedis(context.Background(), config.redisURL, redisCachePrefix)
+		if err != nil {
+			return fmt.Errorf("cant connect to redis: %w", err)
+		}
+		config.Redis = redis
+		store = redis
+	case config.cacheSize > 0:
+		log.Printf("Use in-process cache of %d urls, it is valid for single replica only!", config.cacheSize)
+		store = cache.NewLRU(config.cacheSize)
+	default:
+		return nil
+	}
+	ttl := config.cacheTTL
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	negativeTTL := config.cacheNegativeTTL
+	if negativeTTL == 0 {
+		negativeTTL = defaultCacheNegativeTTL
+	}
+	config.Repo = repository.NewCachedStorage(config.Repo, store, ttl, negativeTTL)
 	return nil
 }
 
@@ -177,6 +491,8 @@ func getServerConf() *AppConfig {
 			} else {
 				appConfig.ServerAddr = confFile.ServerAddr
 			}
+		} else {
+			appConfig.ServerAddr = servAddr
 		}
 	} else {
 		appConfig.ServerAddr = *serverAddr
@@ -190,6 +506,8 @@ func getServerConf() *AppConfig {
 			} else {
 				appConfig.BaseURL = confFile.BaseURL
 			}
+		} else {
+			appConfig.BaseURL = envBaseURL
 		}
 	} else {
 		appConfig.BaseURL = *baseURL
@@ -197,24 +515,20 @@ func getServerConf() *AppConfig {
 
 	if *storagePath == "" {
 		envStoragePath := os.Getenv("FILE_STORAGE_PATH")
-		if envStoragePath == "" {
-			if confFile.FileStoragePass != "" {
-				envStoragePath = appConfig.storagePath
-			}
-			appConfig.storagePath = envStoragePath
+		if envStoragePath == "" && confFile.FileStoragePass != "" {
+			envStoragePath = confFile.FileStoragePass
 		}
+		appConfig.storagePath = envStoragePath
 	} else {
 		appConfig.storagePath = *storagePath
 	}
 
 	if *dbConnect == "" {
 		envDBConnect := os.Getenv("DATABASE_DSN")
-		if envDBConnect == "" {
-			if confFile.DBDsn != "" {
-				envDBConnect = appConfig.storagePath
-			}
-			appConfig.dbConnURL = envDBConnect
+		if envDBConnect == "" && confFile.DBDsn != "" {
+			envDBConnect = confFile.DBDsn
 		}
+		appConfig.dbConnURL = envDBConnect
 	} else {
 		appConfig.dbConnURL = *dbConnect
 	}
@@ -233,6 +547,77 @@ func getServerConf() *AppConfig {
 		}
 	}
 
+	flushInterval, err := time.ParseDuration(confFile.DeleteFlushTime)
+	if err != nil {
+		flushInterval = 0
+	}
+	appConfig.deleteConfig = service.DeleteConfig{
+		QueueSize:     util.GetEnvIntOrDefault("DELETE_QUEUE_SIZE", confFile.DeleteQueueSize),
+		Workers:       util.GetEnvIntOrDefault("DELETE_WORKERS", confFile.DeleteWorkers),
+		FlushSize:     util.GetEnvIntOrDefault("DELETE_FLUSH_SIZE", confFile.DeleteFlushSize),
+		FlushInterval: util.GetEnvDurationOrDefault("DELETE_FLUSH_INTERVAL", flushInterval),
+	}
+
+	appConfig.deleteJournalPath = util.GetEnvOrDefault("DELETE_JOURNAL_PATH", confFile.DeleteJournal)
+
+	redirectStatus := confFile.RedirectStatus
+	if redirectStatus == 0 {
+		redirectStatus = int(repository.RedirectTemporary)
+	}
+	appConfig.RedirectStatus = repository.RedirectType(util.GetEnvIntOrDefault("REDIRECT_STATUS", redirectStatus))
+
+	passthrough := confFile.Passthrough
+	if len(passthrough) == 0 {
+		passthrough = string(repository.PassthroughNone)
+	}
+	appConfig.Passthrough = repository.PassthroughMode(util.GetEnvOrDefault("PASSTHROUGH", passthrough))
+	queryConflict := confFile.QueryConflict
+	if len(queryConflict) == 0 {
+		queryConflict = string(repository.QueryConflictKeep)
+	}
+	appConfig.QueryConflict = repository.QueryConflict(util.GetEnvOrDefault("QUERY_CONFLICT", queryConflict))
+
+	previewTimeout, err := time.ParseDuration(confFile.PreviewTimeout)
+	if err != nil {
+		previewTimeout = 0
+	}
+	appConfig.previewConfig = service.PreviewConfig{
+		QueueSize:   util.GetEnvIntOrDefault("PREVIEW_QUEUE_SIZE", confFile.PreviewQueue),
+		Workers:     util.GetEnvIntOrDefault("PREVIEW_WORKERS", confFile.PreviewWorkers),
+		Timeout:     util.GetEnvDurationOrDefault("PREVIEW_TIMEOUT", previewTimeout),
+		MaxBodySize: int64(util.GetEnvIntOrDefault("PREVIEW_MAX_BODY_SIZE", confFile.PreviewMaxBody)),
+	}
+
+	appConfig.geoIPPath = util.GetEnvOrDefault("GEOIP_DB_PATH", confFile.GeoIPPath)
+
+	appConfig.idAllocator = util.GetEnvOrDefault("ID_ALLOCATOR", confFile.IDAllocator)
+	appConfig.idNode = util.GetEnvIntOrDefault("ID_NODE", confFile.IDNode)
+	appConfig.idBlockSize = util.GetEnvIntOrDefault("ID_BLOCK_SIZE", confFile.IDBlockSize)
+	appConfig.codeStrategy = util.GetEnvOrDefault("CODE_STRATEGY", confFile.CodeStrategy)
+	appConfig.codeLength = util.GetEnvIntOrDefault("CODE_LENGTH", confFile.CodeLength)
+	appConfig.codeAlphabet = util.GetEnvOrDefault("CODE_ALPHABET", confFile.CodeAlphabet)
+
+	cacheTTL, err := time.ParseDuration(confFile.CacheTTL)
+	if err != nil {
+		cacheTTL = 0
+	}
+	cacheNegativeTTL, err := time.ParseDuration(confFile.CacheNegTTL)
+	if err != nil {
+		cacheNegativeTTL = 0
+	}
+	appConfig.cacheSize = util.GetEnvIntOrDefault("CACHE_SIZE", confFile.CacheSize)
+	appConfig.cacheTTL = util.GetEnvDurationOrDefault("CACHE_TTL", cacheTTL)
+	appConfig.cacheNegativeTTL = util.GetEnvDurationOrDefault("CACHE_NEGATIVE_TTL", cacheNegativeTTL)
+	appConfig.redisURL = util.GetEnvOrDefault("REDIS_URL", confFile.RedisURL)
+	appConfig.secret = util.GetEnvOrDefault("SECRET_KEY", confFile.SecretKey)
+
+	appConfig.ScheduledPage = confFile.ScheduledPage
+	if envScheduledPage := os.Getenv("SCHEDULED_PAGE"); envScheduledPage != "" {
+		if b, err := strconv.ParseBool(envScheduledPage); err == nil {
+			appConfig.ScheduledPage = b
+		}
+	}
+
 	return appConfig
 }
 
@@ -1,9 +1,14 @@
 package config
 
 import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
 	"go-axesthump-shortener/internal/app/repository"
 	"os"
+	"strconv"
 	"testing"
 )
 
@@ -120,8 +125,221 @@ func Test_setStorage(t *testing.T) {
 			if tt.td.needRemoveFile {
 				err = os.Remove(tt.td.conf.storagePath[2:])
 				assert.NoError(t, err)
+				err = os.Remove(tt.td.conf.storagePath[2:] + ".jobs")
+				assert.NoError(t, err)
 			}
 		})
 	}
 
 }
+
+func Test_setIDAllocators(t *testing.T) {
+	tests := []struct {
+		name        string
+		idAllocator string
+		idNode      int
+		needError   bool
+		isGenerator bool
+	}{
+		{
+			name:        "default allocator",
+			isGenerator: true,
+		},
+		{
+			name:        "memory allocator",
+			idAllocator: IDAllocatorMemory,
+			isGenerator: true,
+		},
+		{
+			name:        "snowflake allocator without db",
+			idAllocator: IDAllocatorSnowflake,
+			idNode:      7,
+			needError:   true,
+		},
+		{
+			name:        "snowflake allocator with invalid node",
+			idAllocator: IDAllocatorSnowflake,
+			idNode:      generator.MaxSnowflakeNode + 1,
+			needError:   true,
+		},
+		{
+			name:        "postgres allocator without db",
+			idAllocator: IDAllocatorPostgres,
+			needError:   true,
+		},
+		{
+			name:        "unknown allocator",
+			idAllocator: "uuid",
+			needError:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewInMemoryStorage()
+			defer repo.Close()
+			conf := &AppConfig{Repo: repo, idAllocator: tt.idAllocator, idNode: tt.idNode}
+			err := setIDAllocators(conf, 5)
+			if tt.needError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			_, ok := conf.UserIDGenerator.(*generator.IDGenerator)
+			assert.Equal(t, tt.isGenerator, ok)
+			id, err := conf.UserIDGenerator.NextID(context.TODO())
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), id)
+			code, err := repo.CreateShortURL(context.TODO(), "http://google.com", 5, repository.URLOptions{})
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), code)
+		})
+	}
+}
+
+func Test_setCodeStrategy(t *testing.T) {
+	tests := []struct {
+		name         string
+		codeStrategy string
+		codeLength   int
+		codeAlphabet string
+		idAllocator  string
+		needError    bool
+		wantCode     string
+	}{
+		{
+			name:     "default strategy",
+			wantCode: `^0$`,
+		},
+		{
+			name:         "sequential strategy",
+			codeStrategy: CodeStrategySequential,
+			wantCode:     `^0$`,
+		},
+		{
+			name:         "random strategy with defaults",
+			codeStrategy: CodeStrategyRandom,
+			wantCode:     `^[0-9A-Za-z]{8}$`,
+		},
+		{
+			name:         "random strategy with own alphabet",
+			codeStrategy: CodeStrategyRandom,
+			codeLength:   12,
+			codeAlphabet: "abcdef",
+			wantCode:     `^[a-f]{12}$`,
+		},
+		{
+			name:         "random strategy with digits alphabet",
+			codeStrategy: CodeStrategyRandom,
+			codeAlphabet: "0123456789",
+			needError:    true,
+		},
+		{
+			name:         "random strategy with snowflake allocator",
+			codeStrategy: CodeStrategyRandom,
+			idAllocator:  IDAllocatorSnowflake,
+			needError:    true,
+		},
+		{
+			name:         "unknown strategy",
+			codeStrategy: "uuid",
+			needError:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewInMemoryStorage()
+			defer repo.Close()
+			conf := &AppConfig{
+				Repo:         repo,
+				idAllocator:  tt.idAllocator,
+				codeStrategy: tt.codeStrategy,
+				codeLength:   tt.codeLength,
+				codeAlphabet: tt.codeAlphabet,
+			}
+			err := setCodeStrategy(conf)
+			if tt.needError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			code, err := repo.CreateShortURL(context.TODO(), "http://google.com", 5, repository.URLOptions{})
+			require.NoError(t, err)
+			text := conf.Codes.Format(code)
+			assert.Regexp(t, tt.wantCode, text)
+			parsed, err := conf.Codes.Parse(text)
+			require.NoError(t, err)
+			assert.Equal(t, code, parsed)
+		})
+	}
+}
+
+func Test_setURLCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	tests := []struct {
+		name      string
+		cacheSize int
+		redisURL  string
+		needError bool
+		isCached  bool
+		isRedis   bool
+	}{
+		{
+			name: "cache is not configured",
+		},
+		{
+			name:      "in-process cache",
+			cacheSize: 100,
+			isCached:  true,
+		},
+		{
+			name:      "redis cache",
+			cacheSize: 100,
+			redisURL:  "redis://" + server.Addr(),
+			isCached:  true,
+			isRedis:   true,
+		},
+		{
+			name:      "bad redis url",
+			redisURL:  "http://" + server.Addr(),
+			needError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewInMemoryStorage()
+			defer repo.Close()
+			conf := &AppConfig{Repo: repo, cacheSize: tt.cacheSize, redisURL: tt.redisURL}
+			err := setURLCache(conf)
+			if tt.needError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			_, ok := conf.Repo.(*repository.CachedStorage)
+			assert.Equal(t, tt.isCached, ok)
+			assert.Equal(t, tt.isRedis, conf.Redis != nil)
+			if conf.Redis == nil {
+				return
+			}
+			defer conf.Redis.Close()
+			code, err := conf.Repo.CreateShortURL(context.TODO(), "http://google.com", 1, repository.URLOptions{})
+			require.NoError(t, err)
+			_, err = conf.Repo.GetFullURL(context.TODO(), code)
+			require.NoError(t, err)
+			assert.True(t, server.Exists(redisCachePrefix+"url:"+strconv.FormatInt(code, 10)))
+		})
+	}
+}
+
+func Test_setSecretKey(t *testing.T) {
+	conf := &AppConfig{secret: "configured key"}
+	require.NoError(t, setSecretKey(conf))
+	assert.Equal(t, []byte("configured key"), conf.SecretKey)
+
+	conf = &AppConfig{}
+	require.NoError(t, setSecretKey(conf))
+	other := &AppConfig{}
+	require.NoError(t, setSecretKey(other))
+	assert.Len(t, conf.SecretKey, secretKeySize)
+	assert.NotEqual(t, conf.SecretKey, other.SecretKey)
+}
@@ -0,0 +1,56 @@
+package generator
+
+import (
+	"context"
+)
+
+// Allocator allocates unique ids for short urls and users.
+// Implementations differ in how ids stay unique between replicas of service.
+type Allocator interface {
+	// NextID returns new unique id.
+	NextID(ctx context.Context) (int64, error)
+	// IsAllocated checks that id could be returned by allocator.
+	IsAllocated(id int64) bool
+	// Close stops allocation of ids.
+	Close() error
+}
+
+// AllocatorClosedError an error that occurs when id is requested from closed allocator.
+type AllocatorClosedError struct {
+}
+
+// Error return AllocatorClosedError description.
+func (e *AllocatorClosedError) Error() string {
+	return "Id allocator closed"
+}
+
+// BlockAllocator allocator which reserves blocks of sequential ids at once.
+type BlockAllocator interface {
+	Allocator
+	// NextIDs reserves block of n sequential unique ids and returns first of them.
+	NextIDs(ctx context.Context, n int) (int64, error)
+}
+
+// NextIDs returns n new unique ids of allocator.
+// Ids are reserved by one block if allocator is BlockAllocator, otherwise they are requested one by one.
+func NextIDs(ctx context.Context, a Allocator, n int) ([]int64, error) {
+	ids := make([]int64, n)
+	if blocks, ok := a.(BlockAllocator); ok && n > 0 {
+		first, err := blocks.NextIDs(ctx, n)
+		if err != nil {
+			return nil, err
+		}
+		for i := range ids {
+			ids[i] = first + int64(i)
+		}
+		return ids, nil
+	}
+	for i := range ids {
+		id, err := a.NextID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
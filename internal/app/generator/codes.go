@@ -0,0 +1,254 @@
+package generator
+
+import (
+	"context"
+	"crypto/rand"
+	"expvar"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// RandomCodeBase - first id of random codes, ids below it are sequential and formatted as decimal numbers.
+const RandomCodeBase int64 = 1 << 62
+
+// Default settings of RandomCodes.
+const (
+	DefaultCodeAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	DefaultCodeLength   = 8
+)
+
+// MaxCodeAttempts - count of codes tried for one new url before CodeCollisionError.
+const MaxCodeAttempts = 5
+
+// Counters of codes tried for new urls, published by expvar as "short_codes" at /debug/vars.
+var (
+	codeAttempts   = new(expvar.Int)
+	codeCollisions = new(expvar.Int)
+)
+
+func init() {
+	stats := expvar.NewMap("short_codes")
+	stats.Set("attempts", codeAttempts)
+	stats.Set("collisions", codeCollisions)
+	stats.Set("collision_rate", expvar.Func(func() any {
+		return CodeCollisionRate()
+	}))
+}
+
+// CountCodeAttempt counts attempt to store new url by code, collided is true if code was already used.
+func CountCodeAttempt(collided bool) {
+	codeAttempts.Add(1)
+	if collided {
+		codeCollisions.Add(1)
+	}
+}
+
+// CodeCollisionRate returns part of attempts which collided with used code.
+func CodeCollisionRate() float64 {
+	attempts := codeAttempts.Value()
+	if attempts == 0 {
+		return 0
+	}
+	return float64(codeCollisions.Value()) / float64(attempts)
+}
+
+// CodeCollisionError an error that occurs when every attempt to store new url collided with used code.
+type CodeCollisionError struct {
+}
+
+// Error return CodeCollisionError description.
+func (e *CodeCollisionError) Error() string {
+	return "Code of url collided " + strconv.Itoa(MaxCodeAttempts) + " times"
+}
+
+// InvalidCodeError an error that occurs when text of short url is not code.
+type InvalidCodeError struct {
+}
+
+// Error return InvalidCodeError description.
+func (e *InvalidCodeError) Error() string {
+	return "Invalid code of url"
+}
+
+// InvalidCodeSpaceError an error that occurs when alphabet or length of random codes are unsupported.
+type InvalidCodeSpaceError struct {
+}
+
+// Error return InvalidCodeSpaceError description.
+func (e *InvalidCodeSpaceError) Error() string {
+	return "Random codes need alphabet of unique letters, digits, '-' or '_' with at least one not digit " +
+		"and positive length with no more than 2^62 codes"
+}
+
+// UniqueIDs returns n ids of allocator which are free in storage.
+// reserve reports whether id is free and marks it used, so ids of one call are unique too.
+// Collided id is replaced by new id of allocator, CodeCollisionError is returned after MaxCodeAttempts collisions of one id.
+func UniqueIDs(ctx context.Context, a Allocator, n int, reserve func(id int64) bool) ([]int64, error) {
+	ids, err := NextIDs(ctx, a, n)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ids {
+		for attempt := 1; !reserve(ids[i]); attempt++ {
+			CountCodeAttempt(true)
+			if attempt == MaxCodeAttempts {
+				return nil, &CodeCollisionError{}
+			}
+			if ids[i], err = a.NextID(ctx); err != nil {
+				return nil, err
+			}
+		}
+		CountCodeAttempt(false)
+	}
+	return ids, nil
+}
+
+// CodeFormat converts codes of urls to text of short urls and back.
+type CodeFormat interface {
+	// Format returns text of code in short url.
+	Format(code int64) string
+	// Parse returns code by text of short url or InvalidCodeError.
+	Parse(s string) (int64, error)
+}
+
+// DecimalCodes formats codes as decimal numbers.
+type DecimalCodes struct {
+}
+
+// Format returns decimal number of code.
+func (DecimalCodes) Format(code int64) string {
+	return strconv.FormatInt(code, 10)
+}
+
+// Parse returns code of decimal number.
+func (DecimalCodes) Parse(s string) (int64, error) {
+	code, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || code < 0 {
+		return 0, &InvalidCodeError{}
+	}
+	return code, nil
+}
+
+// RandomCodes allocates codes drawn by crypto/rand from alphabet^length codes, it implements Allocator and CodeFormat.
+// Random ids are RandomCodeBase plus number of code, so they never meet sequential ids.
+// Codes of digits only are never drawn, such text of short url is always parsed as sequential id.
+type RandomCodes struct {
+	alphabet string
+	length   int
+	// space - count of codes of length.
+	space  *big.Int
+	index  map[rune]int64
+	closed atomic.Bool
+	rand   io.Reader
+}
+
+// NewRandomCodes returns new RandomCodes of length chars from alphabet.
+func NewRandomCodes(alphabet string, length int) (*RandomCodes, error) {
+	if len(alphabet) < 2 || length <= 0 || isDigits(alphabet) {
+		return nil, &InvalidCodeSpaceError{}
+	}
+	index := make(map[rune]int64, len(alphabet))
+	for i, c := range alphabet {
+		if _, ok := index[c]; ok || !isCodeChar(c) {
+			return nil, &InvalidCodeSpaceError{}
+		}
+		index[c] = int64(i)
+	}
+	space := new(big.Int).Exp(big.NewInt(int64(len(alphabet))), big.NewInt(int64(length)), nil)
+	if space.Cmp(big.NewInt(math.MaxInt64-RandomCodeBase+1)) > 0 {
+		return nil, &InvalidCodeSpaceError{}
+	}
+	return &RandomCodes{
+		alphabet: alphabet,
+		length:   length,
+		space:    space,
+		index:    index,
+		rand:     rand.Reader,
+	}, nil
+}
+
+// NextID returns new random id, codes of digits only are skipped.
+// Id is not checked in storage, caller must retry collided id.
+func (c *RandomCodes) NextID(ctx context.Context) (int64, error) {
+	for {
+		if c.closed.Load() {
+			return 0, &AllocatorClosedError{}
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		n, err := rand.Int(c.rand, c.space)
+		if err != nil {
+			return 0, err
+		}
+		id := RandomCodeBase + n.Int64()
+		if !isDigits(c.Format(id)) {
+			return id, nil
+		}
+	}
+}
+
+// IsAllocated checks that id is random id of alphabet^length codes.
+func (c *RandomCodes) IsAllocated(id int64) bool {
+	return id >= RandomCodeBase && big.NewInt(id-RandomCodeBase).Cmp(c.space) < 0
+}
+
+// Close stops allocation of ids.
+func (c *RandomCodes) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// Format returns code of random id padded to length, decimal number of sequential id.
+func (c *RandomCodes) Format(code int64) string {
+	if code < RandomCodeBase {
+		return strconv.FormatInt(code, 10)
+	}
+	n := code - RandomCodeBase
+	base := int64(len(c.alphabet))
+	chars := make([]byte, 0, c.length)
+	for n > 0 || len(chars) < c.length {
+		chars = append(chars, c.alphabet[n%base])
+		n /= base
+	}
+	for i, j := 0, len(chars)-1; i < j; i, j = i+1, j-1 {
+		chars[i], chars[j] = chars[j], chars[i]
+	}
+	return string(chars)
+}
+
+// Parse returns sequential id of decimal number or random id of code from alphabet.
+// Code of other length is rejected, so every random id has only one short url.
+// Random short urls created before change of length are not valid anymore.
+func (c *RandomCodes) Parse(s string) (int64, error) {
+	if len(s) == 0 || isDigits(s) {
+		return DecimalCodes{}.Parse(s)
+	}
+	if len(s) != c.length {
+		return 0, &InvalidCodeError{}
+	}
+	base := int64(len(c.alphabet))
+	var n int64
+	for _, ch := range s {
+		i, ok := c.index[ch]
+		if !ok || n > (math.MaxInt64-RandomCodeBase-i)/base {
+			return 0, &InvalidCodeError{}
+		}
+		n = n*base + i
+	}
+	return RandomCodeBase + n, nil
+}
+
+// isDigits checks that s is not empty and contains only decimal digits.
+func isDigits(s string) bool {
+	return len(s) != 0 && strings.Trim(s, "0123456789") == ""
+}
+
+// isCodeChar checks that c is allowed in code without escaping in url.
+func isCodeChar(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
@@ -0,0 +1,130 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestNewRandomCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		alphabet string
+		length   int
+		wantErr  bool
+	}{
+		{name: "default", alphabet: DefaultCodeAlphabet, length: DefaultCodeLength},
+		{name: "max length of default alphabet", alphabet: DefaultCodeAlphabet, length: 10},
+		{name: "too many codes", alphabet: DefaultCodeAlphabet, length: 11, wantErr: true},
+		{name: "url safe chars", alphabet: "ab-_", length: 4},
+		{name: "digits only", alphabet: "0123456789", length: 6, wantErr: true},
+		{name: "one char", alphabet: "a", length: 6, wantErr: true},
+		{name: "duplicate char", alphabet: "abca", length: 6, wantErr: true},
+		{name: "not url safe char", alphabet: "ab/", length: 6, wantErr: true},
+		{name: "zero length", alphabet: "ab", length: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewRandomCodes(tt.alphabet, tt.length)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, &InvalidCodeSpaceError{})
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, c)
+		})
+	}
+}
+
+func TestRandomCodes_FormatParse(t *testing.T) {
+	c, err := NewRandomCodes("ab1", 4)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		id   int64
+		code string
+	}{
+		{name: "sequential id", id: 42, code: "42"},
+		{name: "first random id", id: RandomCodeBase, code: "aaaa"},
+		{name: "random id", id: RandomCodeBase + 2*27 + 1, code: "1aab"},
+		{name: "random id with digits", id: RandomCodeBase + 27 + 2*9 + 2*3 + 1, code: "b11b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.code, c.Format(tt.id))
+			id, err := c.Parse(tt.code)
+			require.NoError(t, err)
+			assert.Equal(t, tt.id, id)
+		})
+	}
+
+	// codes of other length are not canonical codes of random ids
+	for _, code := range []string{"", "abc!", "-1", "x", "bb", "aabbb", strings.Repeat("1", 40) + "a"} {
+		_, err = c.Parse(code)
+		assert.ErrorIs(t, err, &InvalidCodeError{}, code)
+	}
+}
+
+func TestRandomCodes_NextID(t *testing.T) {
+	ctx := context.TODO()
+	c, err := NewRandomCodes("0a", 1)
+	require.NoError(t, err)
+	// first draw is code "0" of digits only, it is skipped
+	c.rand = bytes.NewReader([]byte{0, 1})
+
+	id, err := c.NextID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RandomCodeBase+1, id)
+	assert.Equal(t, "a", c.Format(id))
+	assert.True(t, c.IsAllocated(id))
+	assert.False(t, c.IsAllocated(RandomCodeBase+2))
+	assert.False(t, c.IsAllocated(1))
+
+	require.NoError(t, c.Close())
+	_, err = c.NextID(ctx)
+	assert.ErrorIs(t, err, &AllocatorClosedError{})
+}
+
+func TestRandomCodes_NextIDNotDigits(t *testing.T) {
+	ctx := context.TODO()
+	c, err := NewRandomCodes("0123456789a", 2)
+	require.NoError(t, err)
+	for i := 0; i < 1000; i++ {
+		id, err := c.NextID(ctx)
+		require.NoError(t, err)
+		assert.False(t, isDigits(c.Format(id)))
+	}
+}
+
+func TestUniqueIDs(t *testing.T) {
+	ctx := context.TODO()
+	attempts, collisions := codeAttempts.Value(), codeCollisions.Value()
+	used := map[int64]bool{1: true, 2: true}
+	reserve := func(id int64) bool {
+		if used[id] {
+			return false
+		}
+		used[id] = true
+		return true
+	}
+
+	ids, err := UniqueIDs(ctx, NewIDGenerator(0), 3, reserve)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{0, 3, 4}, ids)
+	assert.Equal(t, attempts+5, codeAttempts.Value())
+	assert.Equal(t, collisions+2, codeCollisions.Value())
+	assert.Greater(t, CodeCollisionRate(), 0.0)
+
+	// every id collides
+	_, err = UniqueIDs(ctx, NewIDGenerator(0), 1, func(id int64) bool { return false })
+	assert.ErrorIs(t, err, &CodeCollisionError{})
+
+	gen := NewIDGenerator(10)
+	gen.Cancel()
+	_, err = UniqueIDs(ctx, gen, 1, reserve)
+	assert.ErrorIs(t, err, &AllocatorClosedError{})
+}
@@ -1,57 +1,74 @@
-// Package generator define IDGenerator to generate a unique id.
+// Package generator define allocators of unique ids: in-process IDGenerator, LeaseAllocator of db sequence blocks, Snowflake and RandomCodes.
 package generator
 
 import (
 	"context"
+	"sync/atomic"
 )
 
 // IDGenerator contains data for generate a unique id.
+// Ids are taken from atomic counter, so IDGenerator is safe for concurrent use without locks.
 type IDGenerator struct {
-	id     int64
-	idCh   chan int64
-	ctx    context.Context
-	cancel context.CancelFunc
+	// next - next unique id.
+	next   atomic.Int64
+	closed atomic.Bool
 }
 
 // NewIDGenerator returns new IDGenerator where unique id start with startID.
 func NewIDGenerator(startID int64) *IDGenerator {
-	ctx, cancel := context.WithCancel(context.Background())
-	idGenerator := &IDGenerator{
-		id:     startID,
-		idCh:   make(chan int64),
-		ctx:    ctx,
-		cancel: cancel,
-	}
-	go idGenerator.start()
+	idGenerator := &IDGenerator{}
+	idGenerator.next.Store(startID)
 	return idGenerator
 }
 
 // GetID returns new unique id.
 func (g *IDGenerator) GetID() int64 {
-	return <-g.idCh
+	return g.next.Add(1) - 1
+}
+
+// GetIDs reserves block of n sequential unique ids and returns first of them.
+// Ids of block are first, first+1, ..., first+n-1. Nothing is reserved if n is not positive.
+func (g *IDGenerator) GetIDs(n int) int64 {
+	if n <= 0 {
+		return g.next.Load()
+	}
+	return g.next.Add(int64(n)) - int64(n)
 }
 
-// Cancel stopping process id generation.
+// NextID returns new unique id, it implements Allocator.
+// IDGenerator is in-process allocator, replicas of service with own IDGenerator return the same ids.
+func (g *IDGenerator) NextID(ctx context.Context) (int64, error) {
+	return g.NextIDs(ctx, 1)
+}
+
+// NextIDs reserves block of n sequential unique ids and returns first of them, it implements BlockAllocator.
+func (g *IDGenerator) NextIDs(ctx context.Context, n int) (int64, error) {
+	if g.closed.Load() {
+		return 0, &AllocatorClosedError{}
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return g.GetIDs(n), nil
+}
+
+// IsAllocated checks id is created.
+func (g *IDGenerator) IsAllocated(id int64) bool {
+	return id >= 0 && id < g.next.Load()
+}
+
+// Close stopping process id generation.
+func (g *IDGenerator) Close() error {
+	g.Cancel()
+	return nil
+}
+
+// Cancel stopping process id generation, NextID returns AllocatorClosedError after it.
 func (g *IDGenerator) Cancel() {
-	g.cancel()
+	g.closed.Store(true)
 }
 
 // IsCreatedID checks id is created.
 func (g *IDGenerator) IsCreatedID(id uint32) bool {
-	return int64(id) < g.id
-}
-
-// start process generation unique id.
-func (g *IDGenerator) start() {
-	for {
-		select {
-		case <-g.ctx.Done():
-			close(g.idCh)
-			return
-		default:
-			id := g.id
-			g.id++
-			g.idCh <- id
-		}
-	}
+	return g.IsAllocated(int64(id))
 }
@@ -1,14 +1,16 @@
 package generator
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
 )
 
 func TestNewIDGenerator(t *testing.T) {
 	g := NewIDGenerator(0)
 	defer g.Cancel()
-	assert.NotNil(t, g.idCh)
 	assert.Equal(t, int64(0), g.GetID())
 	g.Cancel()
 }
@@ -24,6 +26,17 @@ func TestIDGenerator_GetID(t *testing.T) {
 
 }
 
+func TestIDGenerator_GetIDs(t *testing.T) {
+	g := NewIDGenerator(5)
+	defer g.Cancel()
+
+	assert.Equal(t, int64(5), g.GetIDs(3))
+	assert.Equal(t, int64(8), g.GetID())
+	assert.Equal(t, int64(9), g.GetIDs(0))
+	assert.Equal(t, int64(9), g.GetIDs(1))
+	assert.Equal(t, int64(10), g.GetID())
+}
+
 func TestIDGenerator_IsCreatedID(t *testing.T) {
 	g := NewIDGenerator(0)
 	defer g.Cancel()
@@ -35,3 +48,177 @@ func TestIDGenerator_IsCreatedID(t *testing.T) {
 	assert.False(t, g.IsCreatedID(2))
 
 }
+
+func TestIDGenerator_NextID(t *testing.T) {
+	g := NewIDGenerator(3)
+	id, err := g.NextID(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = g.NextID(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	g.Cancel()
+	_, err = g.NextID(context.TODO())
+	assert.ErrorIs(t, err, &AllocatorClosedError{})
+}
+
+func TestIDGenerator_concurrent(t *testing.T) {
+	g := NewIDGenerator(0)
+	defer g.Cancel()
+	const workers, perWorker = 8, 1000
+
+	var wg sync.WaitGroup
+	results := make([][]int64, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				if j%10 == 0 {
+					first := g.GetIDs(5)
+					for k := 0; k < 5; k++ {
+						results[i] = append(results[i], first+int64(k))
+					}
+				} else {
+					results[i] = append(results[i], g.GetID())
+				}
+				// concurrent reads of counter
+				g.IsCreatedID(uint32(j))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[int64]bool)
+	for _, ids := range results {
+		for _, id := range ids {
+			require.False(t, seen[id], "duplicate id %d", id)
+			seen[id] = true
+		}
+	}
+	// ids are dense: every id from 0 to count of ids is returned once
+	count := int64(len(seen))
+	for id := int64(0); id < count; id++ {
+		require.True(t, seen[id], "missed id %d", id)
+	}
+	assert.True(t, g.IsAllocated(count-1))
+	assert.False(t, g.IsAllocated(count))
+}
+
+func TestNextIDs(t *testing.T) {
+	g := NewIDGenerator(10)
+	ids, err := NextIDs(context.TODO(), g, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{10, 11, 12}, ids)
+
+	source := &blockSource{last: 0, size: 2}
+	ids, err = NextIDs(context.TODO(), NewLeaseAllocator(source), 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+
+	ids, err = NextIDs(context.TODO(), g, 0)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+// chanIDGenerator - previous IDGenerator which sends ids from goroutine to unbuffered channel, it is kept for benchmarks.
+type chanIDGenerator struct {
+	id     int64
+	idCh   chan int64
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newChanIDGenerator(startID int64) *chanIDGenerator {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &chanIDGenerator{
+		id:     startID,
+		idCh:   make(chan int64),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	go g.start()
+	return g
+}
+
+func (g *chanIDGenerator) GetID() int64 {
+	return <-g.idCh
+}
+
+func (g *chanIDGenerator) start() {
+	for {
+		select {
+		case <-g.ctx.Done():
+			close(g.idCh)
+			return
+		default:
+			id := g.id
+			g.id++
+			g.idCh <- id
+		}
+	}
+}
+
+func BenchmarkIDGenerator_GetID(b *testing.B) {
+	b.Run("atomic", func(b *testing.B) {
+		g := NewIDGenerator(0)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			g.GetID()
+		}
+	})
+	b.Run("channel", func(b *testing.B) {
+		g := newChanIDGenerator(0)
+		defer g.cancel()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			g.GetID()
+		}
+	})
+}
+
+func BenchmarkIDGenerator_GetIDParallel(b *testing.B) {
+	b.Run("atomic", func(b *testing.B) {
+		g := NewIDGenerator(0)
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				g.GetID()
+			}
+		})
+	})
+	b.Run("channel", func(b *testing.B) {
+		g := newChanIDGenerator(0)
+		defer g.cancel()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				g.GetID()
+			}
+		})
+	})
+}
+
+func BenchmarkIDGenerator_GetIDs(b *testing.B) {
+	const batch = 100
+	b.Run("atomic block", func(b *testing.B) {
+		g := NewIDGenerator(0)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			g.GetIDs(batch)
+		}
+	})
+	b.Run("channel one by one", func(b *testing.B) {
+		g := newChanIDGenerator(0)
+		defer g.cancel()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < batch; j++ {
+				g.GetID()
+			}
+		}
+	})
+}
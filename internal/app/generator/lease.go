@@ -0,0 +1,86 @@
+package generator
+
+import (
+	"context"
+	"sync"
+)
+
+// BlockSource leases blocks of ids shared by all replicas of service, every block is leased only once.
+type BlockSource interface {
+	// LeaseBlock returns first and last id of new block.
+	LeaseBlock(ctx context.Context) (first int64, last int64, err error)
+	// LastID returns last id leased by any replica.
+	LastID(ctx context.Context) (int64, error)
+}
+
+// LeaseAllocator allocates ids from blocks leased from BlockSource, so replicas never return the same id.
+// Ids left in block are lost when allocator is closed, ids are unique but not dense.
+type LeaseAllocator struct {
+	mx     sync.Mutex
+	source BlockSource
+	// next - next id of current block, block is used when next > last.
+	next int64
+	last int64
+	// leased - last id known to be leased by any replica.
+	leased int64
+	closed bool
+}
+
+// NewLeaseAllocator returns new LeaseAllocator, first block is leased on first id request.
+func NewLeaseAllocator(source BlockSource) *LeaseAllocator {
+	return &LeaseAllocator{
+		source: source,
+		next:   0,
+		last:   -1,
+		leased: -1,
+	}
+}
+
+// NextID returns next id of current block, new block is leased if current block is used.
+func (a *LeaseAllocator) NextID(ctx context.Context) (int64, error) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	if a.closed {
+		return 0, &AllocatorClosedError{}
+	}
+	if a.next > a.last {
+		first, last, err := a.source.LeaseBlock(ctx)
+		if err != nil {
+			return 0, err
+		}
+		a.next, a.last = first, last
+		if last > a.leased {
+			a.leased = last
+		}
+	}
+	id := a.next
+	a.next++
+	return id, nil
+}
+
+// IsAllocated checks that id is in blocks leased by any replica.
+// Last leased id is requested from source only for ids after known blocks.
+func (a *LeaseAllocator) IsAllocated(id int64) bool {
+	if id < 0 {
+		return false
+	}
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	if id <= a.leased {
+		return true
+	}
+	leased, err := a.source.LastID(context.Background())
+	if err != nil {
+		return false
+	}
+	a.leased = leased
+	return id <= leased
+}
+
+// Close stops allocation of ids, rest of current block is not used.
+func (a *LeaseAllocator) Close() error {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.closed = true
+	return nil
+}
@@ -0,0 +1,96 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+// blockSource leases blocks of size ids from shared counter like db sequence.
+type blockSource struct {
+	mx   sync.Mutex
+	last int64
+	size int64
+	err  error
+}
+
+func (s *blockSource) LeaseBlock(ctx context.Context) (int64, int64, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.err != nil {
+		return 0, 0, s.err
+	}
+	s.last += s.size
+	return s.last - s.size + 1, s.last, nil
+}
+
+func (s *blockSource) LastID(ctx context.Context) (int64, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.last, s.err
+}
+
+func TestLeaseAllocator_NextID(t *testing.T) {
+	ctx := context.TODO()
+	source := &blockSource{last: 9, size: 3}
+	first := NewLeaseAllocator(source)
+	second := NewLeaseAllocator(source)
+
+	ids := make([]int64, 0, 8)
+	for _, a := range []*LeaseAllocator{first, second, first, first, second, first, first, second} {
+		id, err := a.NextID(ctx)
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	// first leases 10-12 and 16-18, second leases 13-15
+	assert.Equal(t, []int64{10, 13, 11, 12, 14, 16, 17, 15}, ids)
+
+	source.err = errors.New("db is down")
+	_, err := second.NextID(ctx)
+	assert.Error(t, err)
+
+	require.NoError(t, first.Close())
+	_, err = first.NextID(ctx)
+	assert.ErrorIs(t, err, &AllocatorClosedError{})
+}
+
+func TestLeaseAllocator_IsAllocated(t *testing.T) {
+	source := &blockSource{last: 9, size: 10}
+	first := NewLeaseAllocator(source)
+	second := NewLeaseAllocator(source)
+	_, err := first.NextID(context.TODO())
+	require.NoError(t, err)
+
+	assert.True(t, second.IsAllocated(0))
+	// id of block leased by other replica
+	assert.True(t, second.IsAllocated(19))
+	assert.False(t, second.IsAllocated(20))
+	assert.False(t, second.IsAllocated(-1))
+}
+
+func TestLeaseAllocator_concurrent(t *testing.T) {
+	source := &blockSource{last: -1, size: 7}
+	allocators := []*LeaseAllocator{NewLeaseAllocator(source), NewLeaseAllocator(source), NewLeaseAllocator(source)}
+	var mx sync.Mutex
+	seen := make(map[int64]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(a *LeaseAllocator) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				id, err := a.NextID(context.TODO())
+				assert.NoError(t, err)
+				mx.Lock()
+				assert.False(t, seen[id], "duplicate id %d", id)
+				seen[id] = true
+				mx.Unlock()
+			}
+		}(allocators[i%len(allocators)])
+	}
+	wg.Wait()
+	assert.Len(t, seen, 600)
+}
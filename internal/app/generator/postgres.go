@@ -0,0 +1,61 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+)
+
+// PostgresSequence leases blocks of ids from postgres sequence, increment of sequence is size of block.
+// Every nextval of sequence returns last id of new block.
+type PostgresSequence struct {
+	conn *pgx.Conn
+	name string
+	size int64
+}
+
+// NewPostgresSequence returns PostgresSequence, sequence is created if it not exists and its first block starts with start.
+// Size of block is set on creation of sequence, all replicas use block size of existing sequence.
+func NewPostgresSequence(ctx context.Context, conn *pgx.Conn, name string, size int64, start int64) (*PostgresSequence, error) {
+	if size <= 0 {
+		size = 1
+	}
+	ident := pgx.Identifier{name}.Sanitize()
+	query := fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %s AS bigint MINVALUE 0 INCREMENT BY %d START WITH %d;", ident, size, start+size-1)
+	if _, err := conn.Exec(ctx, query); err != nil {
+		return nil, err
+	}
+	row := conn.QueryRow(ctx, "SELECT increment_by FROM pg_sequences WHERE schemaname = current_schema() AND sequencename = $1;", name)
+	if err := row.Scan(&size); err != nil {
+		return nil, err
+	}
+	return &PostgresSequence{
+		conn: conn,
+		name: ident,
+		size: size,
+	}, nil
+}
+
+// LeaseBlock returns first and last id of new block.
+func (s *PostgresSequence) LeaseBlock(ctx context.Context) (int64, int64, error) {
+	var last int64
+	if err := s.conn.QueryRow(ctx, "SELECT nextval($1::regclass);", s.name).Scan(&last); err != nil {
+		return 0, 0, err
+	}
+	return last - s.size + 1, last, nil
+}
+
+// LastID returns last id of last leased block or id before first block if no block is leased.
+func (s *PostgresSequence) LastID(ctx context.Context) (int64, error) {
+	var last int64
+	var isCalled bool
+	query := fmt.Sprintf("SELECT last_value, is_called FROM %s;", s.name)
+	if err := s.conn.QueryRow(ctx, query).Scan(&last, &isCalled); err != nil {
+		return 0, err
+	}
+	if !isCalled {
+		// last_value is last id of first block, ids before it were allocated before creation of sequence
+		return last - s.size, nil
+	}
+	return last, nil
+}
@@ -0,0 +1,100 @@
+package generator
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Layout of snowflake id: 41 bits of milliseconds since snowflakeEpoch, 10 bits of node and 12 bits of sequence.
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	// MaxSnowflakeNode - max node id of Snowflake.
+	MaxSnowflakeNode = 1<<snowflakeNodeBits - 1
+	maxSnowflakeSeq  = 1<<snowflakeSequenceBits - 1
+)
+
+// snowflakeEpoch - start of time part of snowflake ids.
+var snowflakeEpoch = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// InvalidNodeError an error that occurs when snowflake node id is out of range.
+type InvalidNodeError struct {
+}
+
+// Error return InvalidNodeError description.
+func (e *InvalidNodeError) Error() string {
+	return "Snowflake node must be in range from 0 to 1023"
+}
+
+// Snowflake allocates time ordered ids without coordination, every replica of service must have own node id.
+type Snowflake struct {
+	mx       sync.Mutex
+	node     int64
+	millis   int64
+	sequence int64
+	closed   bool
+	now      func() time.Time
+}
+
+// NewSnowflake returns new Snowflake for node.
+func NewSnowflake(node int64) (*Snowflake, error) {
+	if node < 0 || node > MaxSnowflakeNode {
+		return nil, &InvalidNodeError{}
+	}
+	return &Snowflake{
+		node: node,
+		now:  time.Now,
+	}, nil
+}
+
+// NextID returns new id. If sequence of current millisecond is exhausted, it waits next millisecond.
+// If clock goes back, ids continue from last used millisecond.
+func (s *Snowflake) NextID(ctx context.Context) (int64, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return 0, &AllocatorClosedError{}
+	}
+	millis := s.sinceEpoch()
+	if millis < s.millis {
+		millis = s.millis
+	}
+	if millis == s.millis {
+		s.sequence++
+		if s.sequence > maxSnowflakeSeq {
+			for millis <= s.millis {
+				select {
+				case <-ctx.Done():
+					s.sequence--
+					return 0, ctx.Err()
+				case <-time.After(time.Millisecond):
+				}
+				millis = s.sinceEpoch()
+			}
+			s.sequence = 0
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.millis = millis
+	return millis<<(snowflakeNodeBits+snowflakeSequenceBits) | s.node<<snowflakeSequenceBits | s.sequence, nil
+}
+
+// IsAllocated checks that id is snowflake id created before now.
+func (s *Snowflake) IsAllocated(id int64) bool {
+	return id >= 0 && id>>(snowflakeNodeBits+snowflakeSequenceBits) <= s.sinceEpoch()
+}
+
+// Close stops allocation of ids.
+func (s *Snowflake) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.closed = true
+	return nil
+}
+
+// sinceEpoch returns milliseconds from snowflakeEpoch to now.
+func (s *Snowflake) sinceEpoch() int64 {
+	return s.now().Sub(snowflakeEpoch).Milliseconds()
+}
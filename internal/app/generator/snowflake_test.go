@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestNewSnowflake(t *testing.T) {
+	_, err := NewSnowflake(-1)
+	assert.ErrorIs(t, err, &InvalidNodeError{})
+	_, err = NewSnowflake(MaxSnowflakeNode + 1)
+	assert.ErrorIs(t, err, &InvalidNodeError{})
+	s, err := NewSnowflake(MaxSnowflakeNode)
+	require.NoError(t, err)
+	assert.NotNil(t, s)
+}
+
+func TestSnowflake_NextID(t *testing.T) {
+	ctx := context.TODO()
+	now := snowflakeEpoch.Add(5 * time.Millisecond)
+	s, err := NewSnowflake(3)
+	require.NoError(t, err)
+	s.now = func() time.Time { return now }
+
+	id, err := s.NextID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5<<22|3<<12), id)
+	id, err = s.NextID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5<<22|3<<12|1), id)
+
+	// clock goes back, ids continue from last millisecond
+	now = snowflakeEpoch.Add(2 * time.Millisecond)
+	id, err = s.NextID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5<<22|3<<12|2), id)
+
+	now = snowflakeEpoch.Add(6 * time.Millisecond)
+	id, err = s.NextID(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6<<22|3<<12), id)
+	assert.True(t, s.IsAllocated(id))
+	assert.False(t, s.IsAllocated(7<<22))
+
+	require.NoError(t, s.Close())
+	_, err = s.NextID(ctx)
+	assert.ErrorIs(t, err, &AllocatorClosedError{})
+}
+
+func TestSnowflake_NextIDSequenceExhausted(t *testing.T) {
+	s, err := NewSnowflake(1)
+	require.NoError(t, err)
+	now := snowflakeEpoch.Add(time.Second)
+	s.now = func() time.Time { return now }
+	for i := 0; i <= maxSnowflakeSeq; i++ {
+		_, err = s.NextID(context.TODO())
+		require.NoError(t, err)
+	}
+
+	// sequence of millisecond is exhausted and time stands still
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = s.NextID(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	now = now.Add(time.Millisecond)
+	id, err := s.NextID(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1001<<22|1<<12), id)
+}
+
+func TestSnowflake_unique(t *testing.T) {
+	first, err := NewSnowflake(1)
+	require.NoError(t, err)
+	second, err := NewSnowflake(2)
+	require.NoError(t, err)
+	seen := make(map[int64]bool)
+	for i := 0; i < 10000; i++ {
+		for _, s := range []*Snowflake{first, second} {
+			id, err := s.NextID(context.TODO())
+			require.NoError(t, err)
+			require.False(t, seen[id])
+			seen[id] = true
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package geoip define lookup of visitor country in local MaxMind GeoIP database.
+package geoip
+
+import (
+	"github.com/oschwald/maxminddb-golang"
+	"net"
+)
+
+// Locator returns country of ip address.
+type Locator interface {
+	// Country returns ISO 3166-1 alpha-2 code of ip country, empty if country is unknown.
+	Country(ip net.IP) (string, error)
+}
+
+// countryRecord part of GeoIP2/GeoLite2 Country and City record with country code.
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// DB GeoIP2 or GeoLite2 database in MaxMind DB format.
+type DB struct {
+	reader *maxminddb.Reader
+}
+
+// Open returns DB from database file path.
+func Open(path string) (*DB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{reader: reader}, nil
+}
+
+// FromBytes returns DB from database file content.
+func FromBytes(buffer []byte) (*DB, error) {
+	reader, err := maxminddb.FromBytes(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{reader: reader}, nil
+}
+
+// Country returns ISO 3166-1 alpha-2 code of ip country, empty if ip is not in database.
+func (db *DB) Country(ip net.IP) (string, error) {
+	var record countryRecord
+	if err := db.reader.Lookup(ip, &record); err != nil {
+		return "", err
+	}
+	return record.Country.ISOCode, nil
+}
+
+// Close closes database file.
+func (db *DB) Close() error {
+	return db.reader.Close()
+}
@@ -0,0 +1,142 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+// testNode node of search tree of test database.
+type testNode struct {
+	children [2]*testNode
+	country  [2]string
+}
+
+// buildTestDB returns IPv4 MaxMind DB with country records of networks.
+func buildTestDB(t *testing.T, networks map[string]string) []byte {
+	root := &testNode{}
+	for cidr, country := range networks {
+		_, network, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		ones, _ := network.Mask.Size()
+		ip := network.IP.To4()
+		node := root
+		for i := 0; i < ones; i++ {
+			bit := ip[i/8] >> (7 - i%8) & 1
+			if i == ones-1 {
+				node.country[bit] = country
+				break
+			}
+			if node.children[bit] == nil {
+				node.children[bit] = &testNode{}
+			}
+			node = node.children[bit]
+		}
+	}
+	nodes := []*testNode{root}
+	for i := 0; i < len(nodes); i++ {
+		for _, child := range nodes[i].children {
+			if child != nil {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+	ids := make(map[*testNode]int, len(nodes))
+	for i, node := range nodes {
+		ids[node] = i
+	}
+
+	var tree, data bytes.Buffer
+	offsets := make(map[string]int)
+	for _, node := range nodes {
+		for bit := 0; bit < 2; bit++ {
+			record := len(nodes)
+			switch {
+			case node.children[bit] != nil:
+				record = ids[node.children[bit]]
+			case len(node.country[bit]) != 0:
+				offset, ok := offsets[node.country[bit]]
+				if !ok {
+					offset = data.Len()
+					offsets[node.country[bit]] = offset
+					writeCountry(&data, node.country[bit])
+				}
+				record = len(nodes) + 16 + offset
+			}
+			tree.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+		}
+	}
+
+	var db bytes.Buffer
+	db.Write(tree.Bytes())
+	db.Write(make([]byte, 16))
+	db.Write(data.Bytes())
+	db.WriteString("\xAB\xCD\xEFMaxMind.com")
+	db.WriteByte(7<<5 | 4)
+	writeUint(&db, "node_count", 6<<5, uint32(len(nodes)))
+	writeUint(&db, "record_size", 5<<5, 24)
+	writeUint(&db, "ip_version", 5<<5, 4)
+	writeUint(&db, "binary_format_major_version", 5<<5, 2)
+	return db.Bytes()
+}
+
+// writeString writes MaxMind DB utf8 string.
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte(2<<5 | byte(len(s)))
+	buf.WriteString(s)
+}
+
+// writeUint writes map key with MaxMind DB unsigned int value of type typeBits.
+func writeUint(buf *bytes.Buffer, key string, typeBits byte, value uint32) {
+	writeString(buf, key)
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, value)
+	b = bytes.TrimLeft(b, "\x00")
+	buf.WriteByte(typeBits | byte(len(b)))
+	buf.Write(b)
+}
+
+// writeCountry writes MaxMind DB record {"country": {"iso_code": country}}.
+func writeCountry(buf *bytes.Buffer, country string) {
+	buf.WriteByte(7<<5 | 1)
+	writeString(buf, "country")
+	buf.WriteByte(7<<5 | 1)
+	writeString(buf, "iso_code")
+	writeString(buf, country)
+}
+
+func TestDB_Country(t *testing.T) {
+	db, err := FromBytes(buildTestDB(t, map[string]string{
+		"81.0.0.0/8":    "DE",
+		"5.45.192.0/18": "RU",
+	}))
+	require.NoError(t, err)
+	defer db.Close()
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{ip: "81.2.69.142", want: "DE"},
+		{ip: "5.45.207.1", want: "RU"},
+		{ip: "5.45.128.1", want: ""},
+		{ip: "8.8.8.8", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			country, err := db.Country(net.ParseIP(tt.ip))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, country)
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	_, err := Open("not_exists.mmdb")
+	assert.Error(t, err)
+	_, err = FromBytes([]byte("not a database"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	myMiddleware "go-axesthump-shortener/internal/app/middleware"
+	"go-axesthump-shortener/internal/app/repository"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Limits of domain names.
+const (
+	maxDomainLength      = 253
+	maxDomainLabelLength = 63
+)
+
+// listDomains handles a request to get all custom domains of a specific user.
+func (a *AppHandler) listDomains(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	domains, err := a.repo.GetDomains(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(domains) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	resp, err := json.Marshal(&domains)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sendResponse(w, resp, http.StatusOK)
+}
+
+// addDomain handles a request to register custom domain for a specific user.
+// Returns 409 if domain is registered by other user, domain of user is returned as is.
+func (a *AppHandler) addDomain(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	name, err := a.normalizeDomain(chi.URLParam(r, "name"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	status := http.StatusCreated
+	domain := repository.Domain{Name: name, OwnerID: userID, CreatedAt: time.Now()}
+	if err = a.repo.AddDomain(r.Context(), domain); err != nil {
+		if !errors.Is(err, &repository.DomainConflictError{}) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if domain, err = a.repo.GetDomain(r.Context(), name); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if domain.OwnerID != userID {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		status = http.StatusOK
+	}
+	resp, err := json.Marshal(&domain)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sendResponse(w, resp, status)
+}
+
+// removeDomain handles a request to remove custom domain of a specific user.
+// Returns 409 if domain has urls.
+func (a *AppHandler) removeDomain(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	name := strings.ToLower(chi.URLParam(r, "name"))
+	if err := a.repo.RemoveDomain(r.Context(), name, userID); err != nil {
+		switch {
+		case errors.Is(err, &repository.DomainNotFoundError{}):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, &repository.DomainInUseError{}):
+			w.WriteHeader(http.StatusConflict)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkDomain checks that custom domain for new url is registered by user and returns its normalized name.
+// Empty name means domain of service base url. Writes error response if domain can't be used.
+func (a *AppHandler) checkDomain(w http.ResponseWriter, r *http.Request, name string, userID uint32) (string, error) {
+	if len(name) == 0 {
+		return "", nil
+	}
+	domain, err := a.repo.GetDomain(r.Context(), strings.ToLower(name))
+	if err != nil {
+		if errors.Is(err, &repository.DomainNotFoundError{}) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return "", err
+	}
+	if domain.OwnerID != userID {
+		w.WriteHeader(http.StatusForbidden)
+		return "", errors.New("domain is owned by other user")
+	}
+	return domain.Name, nil
+}
+
+// servesDomain checks that url with custom domain shortDomain is opened on its domain.
+// Urls without domain are served on every host except registered custom domains.
+// Domain of host is looked up on every request, so it is served from cache of repository if cache is configured.
+func (a *AppHandler) servesDomain(r *http.Request, shortDomain string) (bool, error) {
+	host := requestHost(r)
+	if len(shortDomain) != 0 {
+		return host == shortDomain, nil
+	}
+	if host == a.baseHost {
+		return true, nil
+	}
+	_, err := a.repo.GetDomain(r.Context(), host)
+	if errors.Is(err, &repository.DomainNotFoundError{}) {
+		return true, nil
+	}
+	return false, err
+}
+
+// checkServedDomain checks that url is opened on its domain, otherwise writes 404.
+func (a *AppHandler) checkServedDomain(w http.ResponseWriter, r *http.Request, shortDomain string) bool {
+	ok, err := a.servesDomain(r, shortDomain)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	return ok
+}
+
+// normalizeDomain returns lowercase domain name or error if name is not valid host name of custom domain.
+func (a *AppHandler) normalizeDomain(name string) (string, error) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if len(name) == 0 || len(name) > maxDomainLength || net.ParseIP(name) != nil || name == a.baseHost {
+		return "", errors.New("bad domain")
+	}
+	labels := strings.Split(name, ".")
+	if len(labels) < 2 {
+		return "", errors.New("domain must have at least two labels")
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > maxDomainLabelLength || label[0] == '-' || label[len(label)-1] == '-' {
+			return "", errors.New("bad domain label")
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return "", errors.New("bad domain label")
+			}
+		}
+	}
+	return name, nil
+}
+
+// requestHost returns lowercase host of request without port.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// hostOf returns lowercase host of url without port.
+func hostOf(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
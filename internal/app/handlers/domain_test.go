@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	myMiddleware "go-axesthump-shortener/internal/app/middleware"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAppHandler_domains(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		baseURL:         "http://localhost:8080/",
+		baseHost:        "localhost",
+		wg:              &sync.WaitGroup{},
+		passthrough:     repository.PassthroughNone,
+		queryConflict:   repository.QueryConflictKeep,
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		handler    http.HandlerFunc
+		userID     uint32
+		domain     string
+		body       string
+		statusCode int
+	}{
+		{
+			name:       "add domain",
+			method:     http.MethodPut,
+			handler:    a.addDomain,
+			userID:     1,
+			domain:     "Go.Example.com",
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "add domain again",
+			method:     http.MethodPut,
+			handler:    a.addDomain,
+			userID:     1,
+			domain:     "go.example.com",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "add domain of other user",
+			method:     http.MethodPut,
+			handler:    a.addDomain,
+			userID:     2,
+			domain:     "go.example.com",
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "add base domain",
+			method:     http.MethodPut,
+			handler:    a.addDomain,
+			userID:     2,
+			domain:     "localhost",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "create url on unknown domain",
+			method:     http.MethodPost,
+			handler:    a.addURLRest,
+			userID:     1,
+			body:       `{"url":"http://google.com/brand","short_domain":"other.example.com"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "create url on domain of other user",
+			method:     http.MethodPost,
+			handler:    a.addURLRest,
+			userID:     2,
+			body:       `{"url":"http://google.com/brand","short_domain":"go.example.com"}`,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "create url on domain",
+			method:     http.MethodPost,
+			handler:    a.addURLRest,
+			userID:     1,
+			body:       `{"url":"http://google.com/brand","short_domain":"GO.example.com"}`,
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "remove domain of other user",
+			method:     http.MethodDelete,
+			handler:    a.removeDomain,
+			userID:     2,
+			domain:     "go.example.com",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "remove domain with urls",
+			method:     http.MethodDelete,
+			handler:    a.removeDomain,
+			userID:     1,
+			domain:     "go.example.com",
+			statusCode: http.StatusConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(context.TODO(), myMiddleware.UserIDKey, tt.userID),
+				tt.method,
+				"/api/user/domains",
+				strings.NewReader(tt.body),
+			)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("name", tt.domain)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+		})
+	}
+
+	page, err := repo.GetAllURLs(context.TODO(), 1, repository.URLFilter{ShortDomain: "go.example.com"})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 1)
+	assert.Equal(t, "go.example.com", page.URLs[0].ShortDomain)
+	assert.Equal(t, "http://go.example.com/0", a.withShortURL(page.URLs[0]).ShortURL)
+	_, err = repo.CreateShortURL(context.TODO(), "http://google.com/plain", 1, repository.URLOptions{})
+	require.NoError(t, err)
+
+	hostTests := []struct {
+		name       string
+		host       string
+		path       string
+		statusCode int
+	}{
+		{name: "url on its domain", host: "go.example.com", path: "/0", statusCode: http.StatusTemporaryRedirect},
+		{name: "url on its domain with port", host: "GO.example.com:443", path: "/0", statusCode: http.StatusTemporaryRedirect},
+		{name: "url on base domain", host: "localhost:8080", path: "/0", statusCode: http.StatusNotFound},
+		{name: "preview on base domain", host: "localhost:8080", path: "/0+", statusCode: http.StatusNotFound},
+		{name: "base url on base domain", host: "localhost:8080", path: "/1", statusCode: http.StatusTemporaryRedirect},
+		{name: "base url on custom domain", host: "go.example.com", path: "/1", statusCode: http.StatusNotFound},
+		{name: "base url on unknown host", host: "127.0.0.1", path: "/1", statusCode: http.StatusTemporaryRedirect},
+	}
+	router := NewRouter(a)
+	for _, tt := range hostTests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Host = tt.host
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+		})
+	}
+}
+
+func TestAppHandler_normalizeDomain(t *testing.T) {
+	a := &AppHandler{baseHost: "short.example.com"}
+	tests := []struct {
+		name    string
+		domain  string
+		want    string
+		wantErr bool
+	}{
+		{name: "lower case", domain: "Go.Example.COM.", want: "go.example.com"},
+		{name: "hyphen", domain: "my-brand.co", want: "my-brand.co"},
+		{name: "base domain", domain: "short.example.com", wantErr: true},
+		{name: "single label", domain: "localhost", wantErr: true},
+		{name: "ip", domain: "127.0.0.1", wantErr: true},
+		{name: "port", domain: "example.com:8080", wantErr: true},
+		{name: "empty label", domain: "example..com", wantErr: true},
+		{name: "hyphen at label start", domain: "-brand.com", wantErr: true},
+		{name: "underscore", domain: "my_brand.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := a.normalizeDomain(tt.domain)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"go-axesthump-shortener/internal/app/config"
 	"go-axesthump-shortener/internal/app/generator"
+	"go-axesthump-shortener/internal/app/geoip"
 	myMiddleware "go-axesthump-shortener/internal/app/middleware"
 	"go-axesthump-shortener/internal/app/repository"
 	"go-axesthump-shortener/internal/app/service"
@@ -25,25 +26,72 @@ import (
 // AppHandler contains tools to work with requests.
 type AppHandler struct {
 	repo            repository.Repository
-	userIDGenerator *generator.IDGenerator
+	userIDGenerator generator.Allocator
 	baseURL         string
+	baseHost        string
 	dbConn          *pgx.Conn
 	deleteService   *service.DeleteService
+	previewService  *service.PreviewService
+	redirectStatus  repository.RedirectType
+	passthrough     repository.PassthroughMode
+	queryConflict   repository.QueryConflict
+	geo             geoip.Locator
+	scheduledPage   bool
+	codes           generator.CodeFormat
+	secretKey       []byte
 	Router          chi.Router
 	wg              *sync.WaitGroup
 }
 
 type (
+	// urlOptionsRequest settings of new url shared by requests of one and several urls.
+	urlOptionsRequest struct {
+		// WorkspaceID - workspace for new url, user must be its owner or editor.
+		WorkspaceID int64 `json:"workspace_id,omitempty"`
+		// Tags - free-form url tags.
+		Tags []string `json:"tags,omitempty"`
+		// Title - url title.
+		Title string `json:"title,omitempty"`
+		// Description - url notes.
+		Description string `json:"description,omitempty"`
+		// RedirectType - redirect status of url: 301, 302, 307 or 308, service default if absent.
+		RedirectType repository.RedirectType `json:"redirect_type,omitempty"`
+		// Passthrough - parts of request passed to destination: none, query, path or all.
+		Passthrough repository.PassthroughMode `json:"passthrough,omitempty"`
+		// QueryConflict - policy of query params merge: keep, override or append.
+		QueryConflict repository.QueryConflict `json:"query_conflict,omitempty"`
+		// Rules - ordered conditional destinations, the first matched rule replaces url.
+		Rules []repository.RedirectRule `json:"rules,omitempty"`
+		// Variants - weighted destinations of A/B split, they replace url if not empty.
+		Variants []repository.URLVariant `json:"variants,omitempty"`
+		// Password - password required to open url.
+		Password string `json:"password,omitempty"`
+		// MaxClicks - count of redirects after which url stops working.
+		MaxClicks int64 `json:"max_clicks,omitempty"`
+		// ActivatesAt - time from which url redirects, url is active at once if absent.
+		ActivatesAt *time.Time `json:"activates_at,omitempty"`
+		// ShortDomain - registered custom domain of user for url, domain of service base url if absent.
+		ShortDomain string `json:"short_domain,omitempty"`
+		// UTMTemplate - name of user utm template added to url.
+		UTMTemplate string `json:"utm_template,omitempty"`
+		// UTMParams - utm params added to url, they replace params of template.
+		repository.UTMParams
+	}
+
 	// arrURLRequest url shortening request data.
 	arrURLRequest struct {
 		// URL - url for shortening.
 		URL string `json:"url"`
+		// urlOptionsRequest - settings of new url.
+		urlOptionsRequest
 	}
 
 	// addURLResponse url shortening response.
 	addURLResponse struct {
 		// Result - shorten url.
 		Result string `json:"result"`
+		// QRURL - url of QR code of shorten url.
+		QRURL string `json:"qr_url"`
 	}
 
 	// addListURLsRequest urls shortening request data.
@@ -52,6 +100,8 @@ type (
 		CorrelationID string `json:"correlation_id"`
 		// OriginalURL - url for shortening.
 		OriginalURL string `json:"original_url"`
+		// urlOptionsRequest - settings of new url.
+		urlOptionsRequest
 	}
 
 	// addListURLsResponse urls shortening response.
@@ -60,6 +110,34 @@ type (
 		CorrelationID string `json:"correlation_id"`
 		// Result - shorten url.
 		ShortURL string `json:"short_url"`
+		// QRURL - url of QR code of shorten url.
+		QRURL string `json:"qr_url"`
+	}
+
+	// invalidURLsResponse bad delete request response.
+	invalidURLsResponse struct {
+		// Error - error description.
+		Error string `json:"error"`
+		// Invalid - offending items from request.
+		Invalid []string `json:"invalid,omitempty"`
+	}
+
+	// transferURLRequest url transfer request data.
+	transferURLRequest struct {
+		// UserID - id of new url owner.
+		UserID uint32 `json:"user_id"`
+	}
+
+	// shareURLRequest url share request data.
+	shareURLRequest struct {
+		// Permission - repository.PermissionRead, repository.PermissionStats or repository.PermissionManage.
+		Permission repository.Permission `json:"permission"`
+	}
+
+	// deleteURLsResponse accepted delete request response.
+	deleteURLsResponse struct {
+		// JobID - id for check delete status.
+		JobID string `json:"job_id"`
 	}
 )
 
@@ -68,11 +146,22 @@ func NewAppHandler(config *config.AppConfig) *AppHandler {
 	h := &AppHandler{
 		repo:            config.Repo,
 		baseURL:         config.BaseURL + "/",
+		baseHost:        hostOf(config.BaseURL),
 		dbConn:          config.Conn,
 		userIDGenerator: config.UserIDGenerator,
 		deleteService:   config.DeleteService,
+		previewService:  config.PreviewService,
+		redirectStatus:  config.RedirectStatus,
+		passthrough:     config.Passthrough,
+		queryConflict:   config.QueryConflict,
+		scheduledPage:   config.ScheduledPage,
+		codes:           config.Codes,
+		secretKey:       config.SecretKey,
 		wg:              config.RequestWait,
 	}
+	if config.GeoIP != nil {
+		h.geo = config.GeoIP
+	}
 	h.Router = NewRouter(h)
 	return h
 }
@@ -90,6 +179,13 @@ func NewRouter(appHandler *AppHandler) chi.Router {
 
 	r.Post("/", appHandler.addURL)
 	r.Get("/{shortURL}", appHandler.getURL)
+	r.Head("/{shortURL}", appHandler.getURL)
+	// rest of path is passed to destination of urls with path passthrough
+	r.Get("/{shortURL}/*", appHandler.getURL)
+	r.Head("/{shortURL}/*", appHandler.getURL)
+	r.Post("/{shortURL}/unlock", appHandler.unlockURL)
+	r.Get("/{shortURL}+", appHandler.previewURL)
+	r.Get("/{shortURL}/qr", appHandler.qrCode)
 	r.Get("/ping", appHandler.ping)
 
 	r.Route("/api", func(r chi.Router) {
@@ -100,6 +196,31 @@ func NewRouter(appHandler *AppHandler) chi.Router {
 		r.Route("/user/urls", func(r chi.Router) {
 			r.Get("/", appHandler.listURLs)
 			r.Delete("/", appHandler.deleteListURLs)
+			r.Get("/delete-status/{jobID}", appHandler.deleteStatus)
+			r.Patch("/{shortURL}", appHandler.updateURL)
+			r.Get("/{shortURL}/history", appHandler.urlHistory)
+			r.Post("/{shortURL}/rollback", appHandler.rollbackURL)
+			r.Post("/{shortURL}/transfer", appHandler.transferURL)
+			r.Put("/{shortURL}/shares/{userID}", appHandler.shareURL)
+			r.Delete("/{shortURL}/shares/{userID}", appHandler.unshareURL)
+		})
+		r.Route("/user/utm-templates", func(r chi.Router) {
+			r.Get("/", appHandler.listUTMTemplates)
+			r.Get("/{name}", appHandler.getUTMTemplate)
+			r.Put("/{name}", appHandler.saveUTMTemplate)
+			r.Delete("/{name}", appHandler.deleteUTMTemplate)
+		})
+		r.Route("/user/domains", func(r chi.Router) {
+			r.Get("/", appHandler.listDomains)
+			r.Put("/{name}", appHandler.addDomain)
+			r.Delete("/{name}", appHandler.removeDomain)
+		})
+		r.Route("/user/workspaces", func(r chi.Router) {
+			r.Post("/", appHandler.createWorkspace)
+			r.Get("/", appHandler.listWorkspaces)
+			r.Get("/{workspaceID}/members", appHandler.listWorkspaceMembers)
+			r.Put("/{workspaceID}/members/{userID}", appHandler.setWorkspaceMember)
+			r.Delete("/{workspaceID}/members/{userID}", appHandler.removeWorkspaceMember)
 		})
 	})
 
@@ -121,19 +242,43 @@ func (a *AppHandler) addURLRest(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if err = validateURLMeta(requestURL.meta()); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	opts, err := requestURL.options()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 
 	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	requestURL.URL, err = a.withUTM(r.Context(), userID, requestURL.UTMParams, requestURL.UTMTemplate, requestURL.URL)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if requestURL.WorkspaceID != 0 {
+		if _, err = a.checkWorkspaceRole(w, r, requestURL.WorkspaceID, userID, repository.RoleOwner, repository.RoleEditor); err != nil {
+			return
+		}
+	}
+	if opts.ShortDomain, err = a.checkDomain(w, r, requestURL.ShortDomain, userID); err != nil {
+		return
+	}
 	status := http.StatusCreated
-	var shortURL string
-	if shortURL, err = a.repo.CreateShortURL(r.Context(), a.baseURL, requestURL.URL, userID); err != nil {
+	code, err := a.repo.CreateShortURL(r.Context(), requestURL.URL, userID, opts)
+	if err != nil {
 		if errors.Is(err, &repository.LongURLConflictError{}) {
 			status = http.StatusConflict
 		} else {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
+	} else {
+		a.fetchPreview(code, requestURL.URL)
 	}
-	buf, err := a.createAddURLResponse(w, shortURL)
+	buf, err := a.createAddURLResponse(w, a.shortURL(opts.ShortDomain, code))
 	if err != nil {
 		return
 	}
@@ -156,24 +301,57 @@ func (a *AppHandler) addListURLRest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	convertedURLs := make([]repository.URLWithID, len(urlsForShort))
+	checkedWorkspaces := make(map[int64]bool)
 	for i, url := range urlsForShort {
+		if err = validateURLMeta(url.meta()); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		opts, err := url.options()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		urlsForShort[i].OriginalURL, err = a.withUTM(r.Context(), userID, url.UTMParams, url.UTMTemplate, url.OriginalURL)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if url.WorkspaceID != 0 && !checkedWorkspaces[url.WorkspaceID] {
+			if _, err = a.checkWorkspaceRole(w, r, url.WorkspaceID, userID, repository.RoleOwner, repository.RoleEditor); err != nil {
+				return
+			}
+			checkedWorkspaces[url.WorkspaceID] = true
+		}
+		if opts.ShortDomain, err = a.checkDomain(w, r, url.ShortDomain, userID); err != nil {
+			return
+		}
 		convertedURLs[i] = repository.URLWithID{
 			CorrelationID: url.CorrelationID,
-			URL:           url.OriginalURL,
+			URL:           urlsForShort[i].OriginalURL,
+			Options:       opts,
 		}
 	}
 
-	shortenURLs, err := a.repo.CreateShortURLs(r.Context(), a.baseURL, convertedURLs, userID)
+	shortenURLs, err := a.repo.CreateShortURLs(r.Context(), convertedURLs, userID)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	requests := make(map[string]repository.URLWithID, len(convertedURLs))
+	for _, url := range convertedURLs {
+		requests[url.CorrelationID] = url
+	}
 	shortenURLsResponse := make([]addListURLsResponse, len(shortenURLs))
 	for i, shortenURL := range shortenURLs {
+		request := requests[shortenURL.CorrelationID]
+		a.fetchPreview(shortenURL.Code, request.URL)
+		shortURL := a.shortURL(request.Options.ShortDomain, shortenURL.Code)
 		shortenURLsResponse[i] = addListURLsResponse{
 			CorrelationID: shortenURL.CorrelationID,
-			ShortURL:      shortenURL.URL,
+			ShortURL:      shortURL,
+			QRURL:         qrURL(shortURL),
 		}
 	}
 
@@ -185,7 +363,46 @@ func (a *AppHandler) addListURLRest(w http.ResponseWriter, r *http.Request) {
 	sendResponse(w, resBody, http.StatusCreated)
 }
 
+// meta returns settings of new url as metadata update for validation.
+func (o *urlOptionsRequest) meta() repository.URLMetaUpdate {
+	return repository.URLMetaUpdate{
+		Title:         &o.Title,
+		Description:   &o.Description,
+		Tags:          &o.Tags,
+		RedirectType:  &o.RedirectType,
+		Passthrough:   &o.Passthrough,
+		QueryConflict: &o.QueryConflict,
+		Rules:         &o.Rules,
+		Variants:      &o.Variants,
+		MaxClicks:     &o.MaxClicks,
+	}
+}
+
+// options returns options of new url with hash of password.
+func (o *urlOptionsRequest) options() (repository.URLOptions, error) {
+	passwordHash, err := hashPassword(o.Password)
+	if err != nil {
+		return repository.URLOptions{}, err
+	}
+	return repository.URLOptions{
+		WorkspaceID:   o.WorkspaceID,
+		Tags:          o.Tags,
+		Title:         o.Title,
+		Description:   o.Description,
+		RedirectType:  o.RedirectType,
+		Passthrough:   o.Passthrough,
+		QueryConflict: o.QueryConflict,
+		Rules:         o.Rules,
+		Variants:      o.Variants,
+		PasswordHash:  passwordHash,
+		MaxClicks:     o.MaxClicks,
+		ActivatesAt:   o.ActivatesAt,
+		ShortDomain:   o.ShortDomain,
+	}, nil
+}
+
 // addURL handles a request to create a short url in text/plain format.
+// Url is created in workspace from workspace_id query param, user must be its owner or editor.
 func (a *AppHandler) addURL(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/plain")
 
@@ -195,7 +412,17 @@ func (a *AppHandler) addURL(w http.ResponseWriter, r *http.Request) {
 	}
 	url := string(body)
 	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
-	shortURL, err := a.repo.CreateShortURL(r.Context(), a.baseURL, url, userID)
+	var opts repository.URLOptions
+	if workspaceID := r.URL.Query().Get("workspace_id"); len(workspaceID) != 0 {
+		if opts.WorkspaceID, err = strconv.ParseInt(workspaceID, 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if _, err = a.checkWorkspaceRole(w, r, opts.WorkspaceID, userID, repository.RoleOwner, repository.RoleEditor); err != nil {
+			return
+		}
+	}
+	code, err := a.repo.CreateShortURL(r.Context(), url, userID, opts)
 	status := http.StatusCreated
 	if err != nil {
 		if errors.Is(err, &repository.LongURLConflictError{}) {
@@ -204,20 +431,22 @@ func (a *AppHandler) addURL(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
+	} else {
+		a.fetchPreview(code, url)
 	}
-	sendResponse(w, []byte(shortURL), status)
+	sendResponse(w, []byte(a.shortURL("", code)), status)
 }
 
 // getURL handles a request to get full url by short url in query param.
 func (a *AppHandler) getURL(w http.ResponseWriter, r *http.Request) {
 	url := chi.URLParam(r, "shortURL")
 
-	shortURL, err := strconv.ParseInt(url, 10, 64)
+	shortURL, err := a.parseCode(url)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	fullURL, err := a.repo.GetFullURL(r.Context(), shortURL)
+	redirect, err := a.repo.GetRedirect(r.Context(), shortURL)
 	if err != nil {
 		if errors.Is(err, &repository.DeletedURLError{}) {
 			w.WriteHeader(http.StatusGone)
@@ -227,26 +456,120 @@ func (a *AppHandler) getURL(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	w.Header().Set("Location", fullURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	if !a.checkServedDomain(w, r, redirect.ShortDomain) {
+		return
+	}
+	if !redirect.IsActive(time.Now()) {
+		a.notActive(w, r, *redirect.ActivatesAt)
+		return
+	}
+	if redirect.IsExhausted() {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	if len(redirect.PasswordHash) != 0 && !a.checkPassword(w, r, url, redirect.PasswordHash) {
+		return
+	}
+	if len(redirect.PasswordHash) != 0 || redirect.MaxClicks > 0 {
+		// redirect of protected or limited url must not be reused from browser cache
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+	// rules are checked before split, so visitors matched by rule are not counted in variants
+	variant := -1
+	if destination, ok := repository.MatchRules(redirect.Rules, a.visitor(r, redirect.Rules)); ok {
+		redirect.OriginalURL = destination
+	} else if len(redirect.Variants) != 0 {
+		variant = chooseVariant(w, r, url, redirect.Variants)
+		redirect.OriginalURL = redirect.Variants[variant].URL
+	}
+	location, err := a.redirectLocation(redirect, chi.URLParam(r, "*"), r.URL.Query())
+	if err != nil {
+		if errors.Is(err, errPathNotAllowed) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		return
+	}
+	// HEAD requests of link checkers are not clicks
+	if r.Method != http.MethodHead {
+		if redirect.MaxClicks > 0 {
+			if err = a.repo.ConsumeClick(r.Context(), shortURL); err != nil {
+				if errors.Is(err, &repository.ClicksExhaustedError{}) {
+					w.WriteHeader(http.StatusGone)
+				} else {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				return
+			}
+		}
+		if variant >= 0 {
+			err = a.repo.AddVariantClick(r.Context(), shortURL, variant)
+		} else {
+			err = a.repo.AddClick(r.Context(), shortURL)
+		}
+		if err != nil {
+			log.Printf("Cant count click - %s\n", err)
+		}
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(a.redirectStatusOf(redirect.Type))
+}
+
+// redirectStatusOf returns http status of redirect with type t, default type uses status from config.
+func (a *AppHandler) redirectStatusOf(t repository.RedirectType) int {
+	if t == repository.RedirectDefault {
+		t = a.redirectStatus
+	}
+	if t == repository.RedirectDefault {
+		return http.StatusTemporaryRedirect
+	}
+	return int(t)
 }
 
-// listURLs handles a request to get all the shortened urls of a specific user.
+// listURLs handles a request to get page of the shortened urls of a specific user.
+// Filter, sort and page are set by query params, see parseURLFilter.
+// Total count of urls is returned in X-Total-Count header of the first page,
+// cursor of next page in X-Next-Cursor header.
 func (a *AppHandler) listURLs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
-	urls := a.repo.GetAllURLs(r.Context(), a.baseURL, userID)
+	filter, err := parseURLFilter(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	page, err := a.repo.GetAllURLs(r.Context(), userID, filter)
+	if err != nil {
+		if errors.Is(err, &repository.InvalidCursorError{}) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
 
-	log.Printf("Urls len - %d\n", len(urls))
+	log.Printf("Urls len - %d\n", len(page.URLs))
+	for i := range page.URLs {
+		page.URLs[i] = a.withShortURL(page.URLs[i])
+		if !page.URLs[i].AllowsStats() {
+			page.URLs[i] = page.URLs[i].WithoutStats()
+		}
+	}
 
-	if len(urls) == 0 {
+	if len(filter.Cursor) == 0 {
+		w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	}
+	if len(page.NextCursor) != 0 {
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+	}
+	if len(page.URLs) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
 	var resp []byte
-	var err error
-	if resp, err = json.Marshal(&urls); err != nil {
+	if resp, err = json.Marshal(&page.URLs); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
@@ -254,14 +577,122 @@ func (a *AppHandler) listURLs(w http.ResponseWriter, r *http.Request) {
 }
 
 // deleteListURLs handles a request to delete urls owned by a specific user.
+// Body is json array of short urls or their codes, if some of them are invalid returns 400 with these items.
+// Returns job id for check delete status or 503 if delete queue is full.
 func (a *AppHandler) deleteListURLs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	body, err := readBody(w, r.Body)
+	if err != nil {
+		return
+	}
+	codes, err := a.deleteService.ParseURLs(body, func(shortURL string) (string, bool) {
+		return a.codeOf(r.Context(), shortURL)
+	})
+	if err != nil {
+		resp := invalidURLsResponse{Error: err.Error()}
+		var invalidErr *service.InvalidURLsError
+		if errors.As(err, &invalidErr) {
+			resp.Invalid = invalidErr.URLs
+		}
+		buf, err := json.Marshal(&resp)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		sendResponse(w, buf, http.StatusBadRequest)
+		return
+	}
+	jobID, err := a.deleteService.AddURLs(codes, userID)
+	if err != nil {
+		if errors.Is(err, &service.QueueFullError{}) {
+			w.Header().Set("Retry-After", "1")
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	resp, err := json.Marshal(&deleteURLsResponse{JobID: jobID})
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sendResponse(w, resp, http.StatusAccepted)
+}
+
+// deleteStatus handles a request to get delete job progress.
+func (a *AppHandler) deleteStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	jobID := chi.URLParam(r, "jobID")
+	job, err := a.deleteService.GetJob(r.Context(), jobID, userID)
+	if err != nil {
+		if errors.Is(err, &repository.DeleteJobNotFoundError{}) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	resp, err := json.Marshal(&job)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sendResponse(w, resp, http.StatusOK)
+}
+
+// transferURL handles a request to transfer url owned by a specific user to another user.
+func (a *AppHandler) transferURL(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	shortURL, err := a.parseCode(chi.URLParam(r, "shortURL"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
 	body, err := readBody(w, r.Body)
 	if err != nil {
 		return
 	}
-	a.deleteService.AddURLs(string(body), userID)
-	w.WriteHeader(http.StatusAccepted)
+	var req transferURLRequest
+	if err = json.Unmarshal(body, &req); err != nil || req.UserID == 0 || req.UserID == userID {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = a.repo.TransferURL(r.Context(), shortURL, userID, req.UserID)
+	writeAccessChangeStatus(w, err)
+}
+
+// shareURL handles a request to grant access to url owned by a specific user for another user.
+func (a *AppHandler) shareURL(w http.ResponseWriter, r *http.Request) {
+	ownerID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	shortURL, userID, err := a.parseShareParams(r, ownerID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	body, err := readBody(w, r.Body)
+	if err != nil {
+		return
+	}
+	var req shareURLRequest
+	if err = json.Unmarshal(body, &req); err != nil || !req.Permission.IsValid() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = a.repo.ShareURL(r.Context(), shortURL, ownerID, userID, req.Permission)
+	writeAccessChangeStatus(w, err)
+}
+
+// unshareURL handles a request to revoke access to url owned by a specific user from another user.
+func (a *AppHandler) unshareURL(w http.ResponseWriter, r *http.Request) {
+	ownerID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	shortURL, userID, err := a.parseShareParams(r, ownerID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = a.repo.UnshareURL(r.Context(), shortURL, ownerID, userID)
+	writeAccessChangeStatus(w, err)
 }
 
 // ping checks the database connection
@@ -284,7 +715,7 @@ func (a *AppHandler) createAddURLResponse(w http.ResponseWriter, shortURL string
 	buf := bytes.NewBuffer([]byte{})
 	encoder := json.NewEncoder(buf)
 	encoder.SetEscapeHTML(false)
-	resp := addURLResponse{Result: shortURL}
+	resp := addURLResponse{Result: shortURL, QRURL: qrURL(shortURL)}
 	if err := encoder.Encode(resp); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return nil, err
@@ -292,6 +723,35 @@ func (a *AppHandler) createAddURLResponse(w http.ResponseWriter, shortURL string
 	return buf.Bytes(), nil
 }
 
+// parseShareParams returns short url and user id from share request path.
+// Owner can't share url with himself.
+func (a *AppHandler) parseShareParams(r *http.Request, ownerID uint32) (int64, uint32, error) {
+	shortURL, err := a.parseCode(chi.URLParam(r, "shortURL"))
+	if err != nil {
+		return 0, 0, err
+	}
+	userID, err := strconv.ParseUint(chi.URLParam(r, "userID"), 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	if uint32(userID) == ownerID {
+		return 0, 0, errors.New("owner can't share url with himself")
+	}
+	return shortURL, uint32(userID), nil
+}
+
+// writeAccessChangeStatus writes status of url transfer or share request by repository error.
+func writeAccessChangeStatus(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, &repository.URLNotFoundError{}):
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
 // sendResponse writes res in w with status and handle errors.
 func sendResponse(w http.ResponseWriter, res []byte, status int) {
 	w.WriteHeader(status)
@@ -20,15 +20,22 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 const (
-	shortURL = "http://shortURL/short"
-	longURL  = "http://shortURL/looooooooong"
+	shortBaseURL = "http://shortURL/"
+	shortCode    = 1
+	shortURL     = shortBaseURL + "1"
+	longURL      = "http://shortURL/looooooooong"
 )
 
 type mockStorage struct {
+	repository.WorkspaceStore
+	repository.UTMTemplateStore
+	repository.DomainStore
 	needError bool
 }
 
@@ -36,11 +43,16 @@ func (m *mockStorage) DeleteURLs(urlsForDelete []repository.DeleteURL) error {
 	return nil
 }
 
-func (m *mockStorage) CreateShortURL(ctx context.Context, beginURL string, originalURL string, userID uint32) (string, error) {
+func (m *mockStorage) CreateShortURL(
+	ctx context.Context,
+	originalURL string,
+	userID uint32,
+	opts repository.URLOptions,
+) (int64, error) {
 	if m.needError {
-		return "", &repository.LongURLConflictError{}
+		return 0, &repository.LongURLConflictError{}
 	}
-	return shortURL, nil
+	return shortCode, nil
 }
 
 func (m *mockStorage) GetFullURL(ctx context.Context, shortURL int64) (string, error) {
@@ -51,8 +63,99 @@ func (m *mockStorage) GetFullURL(ctx context.Context, shortURL int64) (string, e
 	}
 }
 
-func (m *mockStorage) GetAllURLs(ctx context.Context, beginURL string, userID uint32) []repository.URLInfo {
-	return make([]repository.URLInfo, 0)
+func (m *mockStorage) GetRedirect(ctx context.Context, shortURL int64) (repository.Redirect, error) {
+	if m.needError {
+		return repository.Redirect{}, errors.New("error")
+	}
+	return repository.Redirect{OriginalURL: longURL}, nil
+}
+
+func (m *mockStorage) UpdateURLMeta(
+	ctx context.Context,
+	shortURL int64,
+	userID uint32,
+	update repository.URLMetaUpdate,
+) (repository.URLInfo, error) {
+	return repository.URLInfo{}, nil
+}
+
+func (m *mockStorage) AddClick(ctx context.Context, shortURL int64) error {
+	return nil
+}
+
+func (m *mockStorage) ConsumeClick(ctx context.Context, shortURL int64) error {
+	return nil
+}
+
+func (m *mockStorage) GetDomain(ctx context.Context, name string) (repository.Domain, error) {
+	return repository.Domain{}, &repository.DomainNotFoundError{}
+}
+
+func (m *mockStorage) AddVariantClick(ctx context.Context, shortURL int64, variant int) error {
+	return nil
+}
+
+func (m *mockStorage) GetAllURLs(
+	ctx context.Context,
+	userID uint32,
+	filter repository.URLFilter,
+) (repository.URLPage, error) {
+	return repository.URLPage{URLs: make([]repository.URLInfo, 0)}, nil
+}
+
+func (m *mockStorage) TransferURL(ctx context.Context, shortURL int64, ownerID uint32, newOwnerID uint32) error {
+	return nil
+}
+
+func (m *mockStorage) ShareURL(
+	ctx context.Context,
+	shortURL int64,
+	ownerID uint32,
+	userID uint32,
+	permission repository.Permission,
+) error {
+	return nil
+}
+
+func (m *mockStorage) UnshareURL(ctx context.Context, shortURL int64, ownerID uint32, userID uint32) error {
+	return nil
+}
+
+func (m *mockStorage) ChangeURLDestination(
+	ctx context.Context,
+	shortURL int64,
+	ownerID uint32,
+	originalURL string,
+) error {
+	return nil
+}
+
+func (m *mockStorage) GetURLHistory(
+	ctx context.Context,
+	shortURL int64,
+	ownerID uint32,
+) ([]repository.URLVersion, error) {
+	return nil, nil
+}
+
+func (m *mockStorage) RollbackURL(ctx context.Context, shortURL int64, ownerID uint32, version int) error {
+	return nil
+}
+
+func (m *mockStorage) SetURLPreview(
+	ctx context.Context,
+	shortURL int64,
+	originalURL string,
+	preview repository.URLPreview,
+) error {
+	return nil
+}
+
+func (m *mockStorage) GetURLInfo(
+	ctx context.Context,
+	shortURL int64,
+) (repository.URLInfo, error) {
+	return repository.URLInfo{}, &repository.URLNotFoundError{}
 }
 
 func (m *mockStorage) Close() error {
@@ -61,7 +164,6 @@ func (m *mockStorage) Close() error {
 
 func (m *mockStorage) CreateShortURLs(
 	ctx context.Context,
-	beginURL string,
 	urls []repository.URLWithID,
 	userID uint32,
 ) ([]repository.URLWithID, error) {
@@ -154,6 +256,7 @@ func TestAppHandler_getURL(t *testing.T) {
 			a := &AppHandler{
 				repo:            tt.fields.storage,
 				userIDGenerator: generator.NewIDGenerator(0),
+				wg:              &sync.WaitGroup{},
 			}
 			r := NewRouter(a)
 			ts := httptest.NewServer(r)
@@ -257,11 +360,12 @@ func TestAppHandler_addURL(t *testing.T) {
 			a := &AppHandler{
 				repo:            tt.fields.storage,
 				userIDGenerator: generator.NewIDGenerator(0),
+				wg:              &sync.WaitGroup{},
 			}
 			r := NewRouter(a)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
-			a.baseURL = ts.URL
+			a.baseURL = shortBaseURL
 			request, err := http.NewRequest(http.MethodPost, ts.URL+tt.fields.requestURL, bytes.NewBuffer(tt.fields.body))
 			require.NoError(t, err)
 			res, err := http.DefaultClient.Do(request)
@@ -308,7 +412,7 @@ func TestAppHandler_addURLRest(t *testing.T) {
 			},
 			want: want{
 				statusCode:  http.StatusCreated,
-				body:        `{"result":"` + shortURL + `"}`,
+				body:        `{"result":"` + shortURL + `","qr_url":"` + shortURL + `/qr"}`,
 				contentType: "application/json",
 			},
 		},
@@ -377,11 +481,12 @@ func TestAppHandler_addURLRest(t *testing.T) {
 			a := &AppHandler{
 				repo:            tt.fields.storage,
 				userIDGenerator: generator.NewIDGenerator(0),
+				wg:              &sync.WaitGroup{},
 			}
 			r := NewRouter(a)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
-			a.baseURL = ts.URL
+			a.baseURL = shortBaseURL
 			request, err := http.NewRequest(http.MethodPost, ts.URL+tt.fields.requestURL, bytes.NewBuffer(tt.fields.body))
 			require.NoError(t, err)
 			res, err := http.DefaultClient.Do(request)
@@ -415,7 +520,7 @@ func TestAppHandler_listURLs(t *testing.T) {
 			name: "check not empty",
 			want: want{
 				statusCode: http.StatusOK,
-				urls:       `[{"short_url":"short","original_url":"original"}]`,
+				urls:       `[{"short_url":"http://shortURL/1","original_url":"original","created_at":"0001-01-01T00:00:00Z","clicks":0,"updated_at":"0001-01-01T00:00:00Z"}]`,
 				needEmpty:  false,
 			},
 		},
@@ -435,21 +540,25 @@ func TestAppHandler_listURLs(t *testing.T) {
 			a := &AppHandler{
 				repo:            repo,
 				userIDGenerator: generator.NewIDGenerator(0),
+				wg:              &sync.WaitGroup{},
 			}
 			r := NewRouter(a)
 			ts := httptest.NewServer(r)
 			defer ts.Close()
-			a.baseURL = ts.URL
+			a.baseURL = shortBaseURL
 
 			if tt.want.needEmpty {
-				repo.EXPECT().GetAllURLs(gomock.Any(), gomock.Any(), gomock.Any()).Return([]repository.URLInfo{})
+				repo.EXPECT().GetAllURLs(gomock.Any(), gomock.Any(), gomock.Any()).Return(repository.URLPage{}, nil)
 			} else {
-				repo.EXPECT().GetAllURLs(gomock.Any(), gomock.Any(), gomock.Any()).Return([]repository.URLInfo{
-					{
-						ShortURL:    "short",
-						OriginalURL: "original",
+				repo.EXPECT().GetAllURLs(gomock.Any(), gomock.Any(), gomock.Any()).Return(repository.URLPage{
+					URLs: []repository.URLInfo{
+						{
+							Code:        shortCode,
+							OriginalURL: "original",
+						},
 					},
-				})
+					Total: 1,
+				}, nil)
 			}
 
 			request, err := http.NewRequest(http.MethodGet, ts.URL+"/api/user/urls", nil)
@@ -472,6 +581,7 @@ func BenchmarkAppHandler_addURLRest(b *testing.B) {
 		a := &AppHandler{
 			repo:            &mockStorage{},
 			userIDGenerator: generator.NewIDGenerator(0),
+			wg:              &sync.WaitGroup{},
 		}
 		r, _ := http.NewRequestWithContext(
 			context.WithValue(context.TODO(), myMiddleware.UserIDKey, uint32(1)),
@@ -499,6 +609,7 @@ func BenchmarkAppHandler_getURL(b *testing.B) {
 		a := &AppHandler{
 			repo:            repo,
 			userIDGenerator: generator.NewIDGenerator(0),
+			wg:              &sync.WaitGroup{},
 		}
 		r, _ := http.NewRequest(
 			http.MethodGet,
@@ -512,7 +623,8 @@ func BenchmarkAppHandler_getURL(b *testing.B) {
 		w := httptest.NewRecorder()
 		handler := http.HandlerFunc(a.getURL)
 
-		repo.EXPECT().GetFullURL(gomock.Any(), int64(1)).Return("fullURL", nil).AnyTimes()
+		repo.EXPECT().GetRedirect(gomock.Any(), int64(1)).Return(repository.Redirect{OriginalURL: "fullURL"}, nil).AnyTimes()
+		repo.EXPECT().AddClick(gomock.Any(), int64(1)).Return(nil).AnyTimes()
 
 		b.ReportAllocs()
 		b.ResetTimer()
@@ -528,6 +640,7 @@ func TestAppHandler_ping(t *testing.T) {
 		dbConn:          nil,
 		repo:            &mockStorage{},
 		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
 	}
 	r, _ := http.NewRequest(
 		http.MethodGet,
@@ -546,7 +659,7 @@ func TestNewAppHandler(t *testing.T) {
 		BaseURL:         "baseURL",
 		Conn:            nil,
 		UserIDGenerator: generator.NewIDGenerator(0),
-		DeleteService:   service.NewDeleteService(&repo, "baseURL"),
+		DeleteService:   service.NewDeleteService(&repo, newJournal(t), service.DeleteConfig{}),
 	}
 
 	appHandler := NewAppHandler(&conf)
@@ -554,7 +667,9 @@ func TestNewAppHandler(t *testing.T) {
 	assert.Equal(t, appHandler.baseURL, "baseURL/")
 	assert.Nil(t, appHandler.dbConn)
 	assert.Equal(t, appHandler.repo, &repo)
-	assert.Equal(t, appHandler.userIDGenerator.GetID(), int64(0))
+	id, err := appHandler.userIDGenerator.NextID(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), id)
 }
 
 func TestAppHandler_addListURLRest(t *testing.T) {
@@ -627,35 +742,38 @@ func TestAppHandler_addListURLRest(t *testing.T) {
 				userIDGenerator: tt.fields.userIDGenerator,
 				baseURL:         tt.fields.baseURL,
 				dbConn:          tt.fields.dbConn,
-				deleteService:   service.NewDeleteService(repo, tt.fields.baseURL),
+				deleteService:   service.NewDeleteService(repo, newJournal(t), service.DeleteConfig{}),
 			}
 
-			repo.EXPECT().CreateShortURLs(gomock.Any(), a.baseURL, gomock.Any(), uint32(1)).Return([]repository.URLWithID{
+			repo.EXPECT().CreateShortURLs(gomock.Any(), gomock.Any(), uint32(1)).Return([]repository.URLWithID{
 				{
 					CorrelationID: "first",
-					URL:           "http://localhost:8080/1",
+					Code:          1,
 				},
 				{
 					CorrelationID: "second",
-					URL:           "http://localhost:8080/2",
+					Code:          2,
 				},
 				{
 					CorrelationID: "last",
-					URL:           "http://localhost:8080/3",
+					Code:          3,
 				},
 			}, nil).AnyTimes()
 			expected := []addListURLsResponse{
 				{
 					CorrelationID: "first",
 					ShortURL:      "http://localhost:8080/1",
+					QRURL:         "http://localhost:8080/1/qr",
 				},
 				{
 					CorrelationID: "second",
 					ShortURL:      "http://localhost:8080/2",
+					QRURL:         "http://localhost:8080/2/qr",
 				},
 				{
 					CorrelationID: "last",
 					ShortURL:      "http://localhost:8080/3",
+					QRURL:         "http://localhost:8080/3/qr",
 				},
 			}
 			w := httptest.NewRecorder()
@@ -679,3 +797,721 @@ func TestAppHandler_addListURLRest(t *testing.T) {
 		})
 	}
 }
+
+func TestAppHandler_deleteListURLs(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		closed     bool
+		fillQueue  bool
+		statusCode int
+		retryAfter string
+		invalid    []string
+	}{
+		{
+			name:       "delete accepted",
+			body:       `["http://localhost:8080/1", "2"]`,
+			statusCode: http.StatusAccepted,
+		},
+		{
+			name:       "delete with stopped service",
+			body:       `["http://localhost:8080/1", "2"]`,
+			closed:     true,
+			statusCode: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "delete with full queue",
+			body:       `["http://localhost:8080/1", "2"]`,
+			fillQueue:  true,
+			statusCode: http.StatusServiceUnavailable,
+			retryAfter: "1",
+		},
+		{
+			name:       "delete with malformed body",
+			body:       `["http://localhost:8080/1",`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "delete with urls of custom domain",
+			body:       `["http://localhost:8080/1", "https://go.example.com/2"]`,
+			statusCode: http.StatusAccepted,
+		},
+		{
+			name:       "delete with urls of another service",
+			body:       `["http://localhost:8080/1", "http://example.com/2", "https://go.example.com/2?q=1"]`,
+			statusCode: http.StatusBadRequest,
+			invalid:    []string{"http://example.com/2", "https://go.example.com/2?q=1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewMockRepository(ctrl)
+			defer ctrl.Finish()
+			block := make(chan struct{})
+			repo.EXPECT().DeleteURLs(gomock.Any()).DoAndReturn(func(urls []repository.DeleteURL) error {
+				<-block
+				return nil
+			}).AnyTimes()
+			repo.EXPECT().GetDomain(gomock.Any(), "go.example.com").Return(repository.Domain{Name: "go.example.com"}, nil).AnyTimes()
+			repo.EXPECT().GetDomain(gomock.Any(), gomock.Any()).Return(repository.Domain{}, &repository.DomainNotFoundError{}).AnyTimes()
+			conf := service.DeleteConfig{}
+			if tt.fillQueue {
+				conf = service.DeleteConfig{QueueSize: 1, Workers: 1, FlushSize: 1}
+			} else {
+				close(block)
+			}
+			ds := service.NewDeleteService(repo, newJournal(t), conf)
+			defer ds.Close()
+			a := &AppHandler{
+				repo:            repo,
+				userIDGenerator: generator.NewIDGenerator(0),
+				baseURL:         "http://localhost:8080/",
+				deleteService:   ds,
+			}
+			if tt.closed {
+				ds.Close()
+			}
+			if tt.fillQueue {
+				// jobs block worker and coalescer until queue is full
+				defer close(block)
+				require.Eventually(t, func() bool {
+					_, err := ds.AddURLs([]string{"1"}, 1)
+					return errors.Is(err, &service.QueueFullError{})
+				}, time.Second, time.Millisecond)
+			}
+
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(context.TODO(), myMiddleware.UserIDKey, uint32(1)),
+				http.MethodDelete,
+				"/api/user/urls",
+				bytes.NewReader([]byte(tt.body)),
+			)
+			w := httptest.NewRecorder()
+			handler := http.HandlerFunc(a.deleteListURLs)
+			handler.ServeHTTP(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			assert.Equal(t, tt.retryAfter, res.Header.Get("Retry-After"))
+			if tt.statusCode == http.StatusAccepted {
+				var resp deleteURLsResponse
+				err := json.NewDecoder(res.Body).Decode(&resp)
+				require.NoError(t, err)
+				assert.NotEmpty(t, resp.JobID)
+			}
+			if tt.statusCode == http.StatusBadRequest {
+				var resp invalidURLsResponse
+				err := json.NewDecoder(res.Body).Decode(&resp)
+				require.NoError(t, err)
+				assert.Equal(t, tt.invalid, resp.Invalid)
+			}
+		})
+	}
+}
+
+func TestAppHandler_deleteStatus(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	ds := service.NewDeleteService(repo, newJournal(t), service.DeleteConfig{})
+	defer ds.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		deleteService:   ds,
+		wg:              &sync.WaitGroup{},
+	}
+	jobID, err := ds.AddURLs([]string{"0"}, 1)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		jobID      string
+		userID     uint32
+		statusCode int
+	}{
+		{
+			name:       "status of own job",
+			jobID:      jobID,
+			userID:     1,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "status of another user job",
+			jobID:      jobID,
+			userID:     2,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "status of unknown job",
+			jobID:      "unknown",
+			userID:     1,
+			statusCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(context.TODO(), myMiddleware.UserIDKey, tt.userID),
+				http.MethodGet,
+				"/api/user/urls/delete-status/"+tt.jobID,
+				nil,
+			)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("jobID", tt.jobID)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			handler := http.HandlerFunc(a.deleteStatus)
+			handler.ServeHTTP(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			if tt.statusCode == http.StatusOK {
+				var job repository.DeleteJob
+				err := json.NewDecoder(res.Body).Decode(&job)
+				require.NoError(t, err)
+				assert.Equal(t, jobID, job.ID)
+				assert.Equal(t, 1, job.Total)
+			}
+		})
+	}
+}
+
+func newJournal(t testing.TB) *repository.DeleteJobJournal {
+	journal, err := repository.NewDeleteJobJournal("")
+	require.NoError(t, err)
+	return journal
+}
+
+func TestAppHandler_shareURL(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+	}
+	_, err := repo.CreateShortURL(context.TODO(), "http://google.com/shared", 1, repository.URLOptions{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		method     string
+		handler    http.HandlerFunc
+		shortURL   string
+		userID     string
+		ownerID    uint32
+		body       string
+		statusCode int
+		ownership  map[uint32]string
+	}{
+		{
+			name:       "share with read permission",
+			method:     http.MethodPut,
+			handler:    a.shareURL,
+			shortURL:   "0",
+			userID:     "2",
+			ownerID:    1,
+			body:       `{"permission":"read"}`,
+			statusCode: http.StatusNoContent,
+			ownership:  map[uint32]string{1: repository.OwnershipOwned, 2: repository.OwnershipShared},
+		},
+		{
+			name:       "share with unknown permission",
+			method:     http.MethodPut,
+			handler:    a.shareURL,
+			shortURL:   "0",
+			userID:     "3",
+			ownerID:    1,
+			body:       `{"permission":"write"}`,
+			statusCode: http.StatusBadRequest,
+			ownership:  map[uint32]string{1: repository.OwnershipOwned, 2: repository.OwnershipShared, 3: ""},
+		},
+		{
+			name:       "share with himself",
+			method:     http.MethodPut,
+			handler:    a.shareURL,
+			shortURL:   "0",
+			userID:     "1",
+			ownerID:    1,
+			body:       `{"permission":"read"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "share not owned url",
+			method:     http.MethodPut,
+			handler:    a.shareURL,
+			shortURL:   "0",
+			userID:     "3",
+			ownerID:    2,
+			body:       `{"permission":"manage"}`,
+			statusCode: http.StatusNotFound,
+			ownership:  map[uint32]string{3: ""},
+		},
+		{
+			name:       "unshare",
+			method:     http.MethodDelete,
+			handler:    a.unshareURL,
+			shortURL:   "0",
+			userID:     "2",
+			ownerID:    1,
+			statusCode: http.StatusNoContent,
+			ownership:  map[uint32]string{1: repository.OwnershipOwned, 2: ""},
+		},
+		{
+			name:       "transfer",
+			method:     http.MethodPost,
+			handler:    a.transferURL,
+			shortURL:   "0",
+			ownerID:    1,
+			body:       `{"user_id":4}`,
+			statusCode: http.StatusNoContent,
+			ownership:  map[uint32]string{1: "", 4: repository.OwnershipOwned},
+		},
+		{
+			name:       "transfer not owned url",
+			method:     http.MethodPost,
+			handler:    a.transferURL,
+			shortURL:   "0",
+			ownerID:    1,
+			body:       `{"user_id":5}`,
+			statusCode: http.StatusNotFound,
+			ownership:  map[uint32]string{4: repository.OwnershipOwned, 5: ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(context.TODO(), myMiddleware.UserIDKey, tt.ownerID),
+				tt.method,
+				"/api/user/urls/"+tt.shortURL,
+				strings.NewReader(tt.body),
+			)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("shortURL", tt.shortURL)
+			rctx.URLParams.Add("userID", tt.userID)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			for userID, ownership := range tt.ownership {
+				urls := allURLs(t, repo, userID)
+				if ownership == "" {
+					assert.Empty(t, urls)
+					continue
+				}
+				require.Len(t, urls, 1)
+				assert.Equal(t, ownership, urls[0].Ownership)
+			}
+		})
+	}
+}
+
+func allURLs(t *testing.T, repo repository.Repository, userID uint32) []repository.URLInfo {
+	page, err := repo.GetAllURLs(context.TODO(), userID, repository.URLFilter{})
+	require.NoError(t, err)
+	return page.URLs
+}
+
+func TestAppHandler_listURLsPage(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+	}
+	for _, url := range []string{"http://google.com/1", "http://google.com/2", "http://yandex.ru/3"} {
+		_, err := repo.CreateShortURL(context.TODO(), url, 1, repository.URLOptions{Tags: []string{"search"}})
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		statusCode int
+		total      string
+		count      int
+		hasNext    bool
+	}{
+		{
+			name:       "first page",
+			query:      "?limit=2&sort=created&order=asc",
+			statusCode: http.StatusOK,
+			total:      "3",
+			count:      2,
+			hasNext:    true,
+		},
+		{
+			name:       "filter by domain and tag",
+			query:      "?domain=google.com&tag=search&status=active",
+			statusCode: http.StatusOK,
+			total:      "2",
+			count:      2,
+		},
+		{
+			name:       "nothing found",
+			query:      "?tag=unknown",
+			statusCode: http.StatusNoContent,
+			total:      "0",
+		},
+		{
+			name:       "bad limit",
+			query:      "?limit=100000",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "bad cursor",
+			query:      "?cursor=bad",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "bad created_from",
+			query:      "?created_from=yesterday",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(context.TODO(), myMiddleware.UserIDKey, uint32(1)),
+				http.MethodGet,
+				"/api/user/urls"+tt.query,
+				nil,
+			)
+			w := httptest.NewRecorder()
+			a.listURLs(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			assert.Equal(t, tt.total, res.Header.Get("X-Total-Count"))
+			assert.Equal(t, tt.hasNext, len(res.Header.Get("X-Next-Cursor")) != 0)
+			if tt.statusCode == http.StatusOK {
+				var urls []repository.URLInfo
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&urls))
+				assert.Len(t, urls, tt.count)
+			}
+		})
+	}
+}
+
+func TestAppHandler_listURLsStats(t *testing.T) {
+	ctx := context.TODO()
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+	}
+	code, err := repo.CreateShortURL(ctx, "http://google.com", 1, repository.URLOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.AddClick(ctx, code))
+	require.NoError(t, repo.ShareURL(ctx, code, 1, 2, repository.PermissionRead))
+	require.NoError(t, repo.ShareURL(ctx, code, 1, 3, repository.PermissionStats))
+
+	for userID, clicks := range map[uint32]int64{1: 1, 2: 0, 3: 1} {
+		r, _ := http.NewRequestWithContext(
+			context.WithValue(ctx, myMiddleware.UserIDKey, userID),
+			http.MethodGet,
+			"/api/user/urls",
+			nil,
+		)
+		w := httptest.NewRecorder()
+		a.listURLs(w, r)
+		res := w.Result()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var urls []repository.URLInfo
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&urls))
+		res.Body.Close()
+		require.Len(t, urls, 1)
+		assert.Equal(t, clicks, urls[0].Clicks, userID)
+	}
+}
+
+func TestAppHandler_updateURL(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+	}
+	_, err := repo.CreateShortURL(context.TODO(), "http://google.com/meta", 1, repository.URLOptions{Title: "Google"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		shortURL   string
+		userID     uint32
+		body       string
+		statusCode int
+		title      string
+	}{
+		{
+			name:       "update description",
+			shortURL:   "0",
+			userID:     1,
+			body:       `{"description":"flyer"}`,
+			statusCode: http.StatusOK,
+			title:      "Google",
+		},
+		{
+			name:       "update title",
+			shortURL:   "0",
+			userID:     1,
+			body:       `{"title":"Search"}`,
+			statusCode: http.StatusOK,
+			title:      "Search",
+		},
+		{
+			name:       "too long title",
+			shortURL:   "0",
+			userID:     1,
+			body:       `{"title":"` + strings.Repeat("a", maxTitleLength+1) + `"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "empty tag",
+			shortURL:   "0",
+			userID:     1,
+			body:       `{"tags":["ok", ""]}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "url of another user",
+			shortURL:   "0",
+			userID:     2,
+			body:       `{"title":"Mine"}`,
+			statusCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(context.TODO(), myMiddleware.UserIDKey, tt.userID),
+				http.MethodPatch,
+				"/api/user/urls/"+tt.shortURL,
+				strings.NewReader(tt.body),
+			)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("shortURL", tt.shortURL)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			a.updateURL(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			if tt.statusCode == http.StatusOK {
+				var info repository.URLInfo
+				require.NoError(t, json.NewDecoder(res.Body).Decode(&info))
+				assert.Equal(t, tt.title, info.Title)
+			}
+		})
+	}
+}
+
+func TestAppHandler_changeURLDestination(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+	}
+	_, err := repo.CreateShortURL(context.TODO(), "http://google.com/flyer", 1, repository.URLOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.ShareURL(context.TODO(), 0, 1, 3, repository.PermissionManage))
+
+	request := func(method string, userID uint32, target string, body string) *http.Request {
+		r, _ := http.NewRequestWithContext(
+			context.WithValue(context.TODO(), myMiddleware.UserIDKey, userID),
+			method,
+			target,
+			strings.NewReader(body),
+		)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("shortURL", "0")
+		return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	tests := []struct {
+		name       string
+		userID     uint32
+		body       string
+		statusCode int
+	}{
+		{
+			name:       "relative url",
+			userID:     1,
+			body:       `{"original_url":"/flyer"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "url of another user",
+			userID:     2,
+			body:       `{"original_url":"http://yandex.ru/flyer"}`,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "destination and title by user with manage permission",
+			userID:     3,
+			body:       `{"original_url":"http://yandex.ru/flyer","title":"Flyer"}`,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "title by user with manage permission",
+			userID:     3,
+			body:       `{"title":"Flyer"}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "change destination",
+			userID:     1,
+			body:       `{"original_url":"http://yandex.ru/flyer"}`,
+			statusCode: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			a.updateURL(w, request(http.MethodPatch, tt.userID, "/api/user/urls/0", tt.body))
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	a.rollbackURL(w, request(http.MethodPost, 1, "/api/user/urls/0/rollback", `{"version":5}`))
+	assert.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	a.rollbackURL(w, request(http.MethodPost, 1, "/api/user/urls/0/rollback", `{"version":1}`))
+	assert.Equal(t, http.StatusNoContent, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	a.urlHistory(w, request(http.MethodGet, 1, "/api/user/urls/0/history", ""))
+	res := w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var history []repository.URLVersion
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&history))
+	require.Len(t, history, 3)
+	assert.Equal(t, "http://google.com/flyer", history[0].OriginalURL)
+	assert.Equal(t, "http://yandex.ru/flyer", history[1].OriginalURL)
+	assert.Equal(t, repository.URLVersion{Version: 3, OriginalURL: "http://google.com/flyer"},
+		repository.URLVersion{Version: history[2].Version, OriginalURL: history[2].OriginalURL})
+
+	fullURL, err := repo.GetFullURL(context.TODO(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "http://google.com/flyer", fullURL)
+}
+
+func TestAppHandler_redirectType(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+		redirectStatus:  repository.RedirectFound,
+	}
+	ts := httptest.NewServer(NewRouter(a))
+	defer ts.Close()
+	a.baseURL = ts.URL + "/"
+	ctx := context.TODO()
+	_, err := repo.CreateShortURL(ctx, "http://google.com/default", 1, repository.URLOptions{})
+	require.NoError(t, err)
+	_, err = repo.CreateShortURL(ctx, "http://google.com/seo", 1, repository.URLOptions{
+		RedirectType: repository.RedirectPermanent,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		method     string
+		shortURL   string
+		statusCode int
+		location   string
+	}{
+		{
+			name:       "status from config",
+			method:     http.MethodGet,
+			shortURL:   "0",
+			statusCode: http.StatusFound,
+			location:   "http://google.com/default",
+		},
+		{
+			name:       "status of url",
+			method:     http.MethodGet,
+			shortURL:   "1",
+			statusCode: http.StatusPermanentRedirect,
+			location:   "http://google.com/seo",
+		},
+		{
+			name:       "head request",
+			method:     http.MethodHead,
+			shortURL:   "1",
+			statusCode: http.StatusPermanentRedirect,
+			location:   "http://google.com/seo",
+		},
+	}
+	transport := http.Transport{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := http.NewRequest(tt.method, ts.URL+"/"+tt.shortURL, nil)
+			require.NoError(t, err)
+			res, err := transport.RoundTrip(request)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			assert.Equal(t, tt.location, res.Header.Get("Location"))
+		})
+	}
+
+	// head request is not counted as click
+	info, err := repo.GetURLInfo(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), info.Clicks)
+
+	request, err := http.NewRequest(
+		http.MethodPost,
+		ts.URL+"/api/shorten",
+		strings.NewReader(`{"url":"http://google.com/bad","redirect_type":303}`),
+	)
+	require.NoError(t, err)
+	res, err := transport.RoundTrip(request)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func Test_urlOptionsRequest(t *testing.T) {
+	settings := `"workspace_id":3,"tags":["a"],"title":"t","max_clicks":5,"short_domain":"go.example.com","utm_source":"x"`
+	var one arrURLRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"url":"http://google.com",`+settings+`}`), &one))
+	var list []addListURLsRequest
+	require.NoError(t, json.Unmarshal([]byte(`[{"correlation_id":"1","original_url":"http://google.com",`+settings+`}]`), &list))
+	require.Len(t, list, 1)
+	assert.Equal(t, one.urlOptionsRequest, list[0].urlOptionsRequest)
+	assert.Equal(t, "x", one.Source)
+
+	opts, err := one.options()
+	require.NoError(t, err)
+	assert.Equal(t, repository.URLOptions{
+		WorkspaceID: 3,
+		Tags:        []string{"a"},
+		Title:       "t",
+		MaxClicks:   5,
+		ShortDomain: "go.example.com",
+	}, opts)
+	assert.NoError(t, validateURLMeta(one.meta()))
+}
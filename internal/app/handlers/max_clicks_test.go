@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestAppHandler_getURLMaxClicks(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+		passthrough:     repository.PassthroughNone,
+		queryConflict:   repository.QueryConflictKeep,
+	}
+	router := NewRouter(a)
+	_, err := repo.CreateShortURL(context.TODO(), "http://google.com/file", 1, repository.URLOptions{MaxClicks: 2})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		method     string
+		statusCode int
+	}{
+		{name: "head is not click", method: http.MethodHead, statusCode: http.StatusTemporaryRedirect},
+		{name: "first click", method: http.MethodGet, statusCode: http.StatusTemporaryRedirect},
+		{name: "last click", method: http.MethodGet, statusCode: http.StatusTemporaryRedirect},
+		{name: "exhausted url", method: http.MethodGet, statusCode: http.StatusGone},
+		{name: "head of exhausted url", method: http.MethodHead, statusCode: http.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, "/0", nil))
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			if res.StatusCode == http.StatusTemporaryRedirect {
+				assert.Equal(t, "private, no-store", res.Header.Get("Cache-Control"))
+			}
+		})
+	}
+
+	info, err := repo.GetURLInfo(context.TODO(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), info.Clicks)
+	assert.Equal(t, int64(2), info.MaxClicks)
+	require.NotNil(t, info.ClicksLeft)
+	assert.Equal(t, int64(0), *info.ClicksLeft)
+}
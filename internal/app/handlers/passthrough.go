@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/url"
+	"strings"
+)
+
+// errPathNotAllowed an error that occurs when request to short url has path but url doesn't pass it.
+var errPathNotAllowed = errors.New("path passthrough is not allowed")
+
+// redirectLocation returns destination of redirect with extra path and query params of request
+// passed according to passthrough settings of url or service.
+func (a *AppHandler) redirectLocation(redirect repository.Redirect, extraPath string, query url.Values) (string, error) {
+	mode := redirect.Passthrough
+	if mode == repository.PassthroughDefault {
+		mode = a.passthrough
+	}
+	conflict := redirect.QueryConflict
+	if conflict == repository.QueryConflictDefault {
+		conflict = a.queryConflict
+	}
+	if len(extraPath) != 0 && !mode.PassPath() {
+		return "", errPathNotAllowed
+	}
+	passQuery := mode.PassQuery() && len(query) != 0
+	if len(extraPath) == 0 && !passQuery {
+		return redirect.OriginalURL, nil
+	}
+
+	destination, err := url.Parse(redirect.OriginalURL)
+	if err != nil {
+		return "", err
+	}
+	if len(extraPath) != 0 {
+		for _, segment := range strings.Split(extraPath, "/") {
+			// extra path can't leave destination path
+			if segment == "." || segment == ".." {
+				return "", errors.New("relative path segment")
+			}
+		}
+		destination = destination.JoinPath(extraPath)
+	}
+	if passQuery {
+		destination.RawQuery = mergeQuery(destination.Query(), query, conflict).Encode()
+	}
+	return destination.String(), nil
+}
+
+// mergeQuery adds request query params to destination params,
+// params with the same name are merged by conflict policy, default policy keeps destination values.
+func mergeQuery(destination url.Values, request url.Values, conflict repository.QueryConflict) url.Values {
+	for name, values := range request {
+		if _, ok := destination[name]; !ok {
+			destination[name] = values
+			continue
+		}
+		switch conflict {
+		case repository.QueryConflictOverride:
+			destination[name] = values
+		case repository.QueryConflictAppend:
+			destination[name] = append(destination[name], values...)
+		}
+	}
+	return destination
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestAppHandler_redirectLocation(t *testing.T) {
+	a := &AppHandler{
+		passthrough:   repository.PassthroughQuery,
+		queryConflict: repository.QueryConflictKeep,
+	}
+	tests := []struct {
+		name      string
+		redirect  repository.Redirect
+		extraPath string
+		query     string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:     "without query",
+			redirect: repository.Redirect{OriginalURL: "http://google.com/a?q=1"},
+			want:     "http://google.com/a?q=1",
+		},
+		{
+			name:     "service mode keeps destination value",
+			redirect: repository.Redirect{OriginalURL: "http://google.com/a?q=1"},
+			query:    "q=2&utm_source=x",
+			want:     "http://google.com/a?q=1&utm_source=x",
+		},
+		{
+			name: "override conflict",
+			redirect: repository.Redirect{
+				OriginalURL:   "http://google.com/a?q=1",
+				QueryConflict: repository.QueryConflictOverride,
+			},
+			query: "q=2",
+			want:  "http://google.com/a?q=2",
+		},
+		{
+			name: "append conflict",
+			redirect: repository.Redirect{
+				OriginalURL:   "http://google.com/a?q=1",
+				QueryConflict: repository.QueryConflictAppend,
+			},
+			query: "q=2",
+			want:  "http://google.com/a?q=1&q=2",
+		},
+		{
+			name:     "url without passthrough",
+			redirect: repository.Redirect{OriginalURL: "http://google.com/a", Passthrough: repository.PassthroughNone},
+			query:    "q=2",
+			want:     "http://google.com/a",
+		},
+		{
+			name:      "path not allowed",
+			redirect:  repository.Redirect{OriginalURL: "http://google.com/a"},
+			extraPath: "b/c",
+			wantErr:   true,
+		},
+		{
+			name:      "path and query",
+			redirect:  repository.Redirect{OriginalURL: "http://google.com/a/", Passthrough: repository.PassthroughAll},
+			extraPath: "b/c",
+			query:     "utm_source=x",
+			want:      "http://google.com/a/b/c?utm_source=x",
+		},
+		{
+			name:      "path leaving destination",
+			redirect:  repository.Redirect{OriginalURL: "http://google.com/a", Passthrough: repository.PassthroughPath},
+			extraPath: "../admin",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			got, err := a.redirectLocation(tt.redirect, tt.extraPath, query)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAppHandler_getURLPassthrough(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+		passthrough:     repository.PassthroughNone,
+		queryConflict:   repository.QueryConflictKeep,
+	}
+	ts := httptest.NewServer(NewRouter(a))
+	defer ts.Close()
+	a.baseURL = ts.URL + "/"
+	ctx := context.TODO()
+	_, err := repo.CreateShortURL(ctx, "http://google.com/docs", 1, repository.URLOptions{
+		Passthrough: repository.PassthroughAll,
+	})
+	require.NoError(t, err)
+	_, err = repo.CreateShortURL(ctx, "http://google.com/plain", 1, repository.URLOptions{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		path       string
+		statusCode int
+		location   string
+	}{
+		{
+			name:       "path and query of url with passthrough",
+			path:       "/0/guide/intro?utm_source=flyer",
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "http://google.com/docs/guide/intro?utm_source=flyer",
+		},
+		{
+			name:       "query of url without passthrough",
+			path:       "/1?utm_source=flyer",
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "http://google.com/plain",
+		},
+		{
+			name:       "path of url without passthrough",
+			path:       "/1/guide",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "qr code route is not passed",
+			path:       "/0/qr",
+			statusCode: http.StatusOK,
+		},
+	}
+	transport := http.Transport{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := http.NewRequest(http.MethodGet, ts.URL+tt.path, nil)
+			require.NoError(t, err)
+			res, err := transport.RoundTrip(request)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			assert.Equal(t, tt.location, res.Header.Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	myMiddleware "go-axesthump-shortener/internal/app/middleware"
+	"go-axesthump-shortener/internal/app/repository"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Settings of url passwords.
+const (
+	// maxPasswordLength - bcrypt uses only first 72 bytes of password.
+	maxPasswordLength    = 72
+	passwordHeader       = "X-Link-Password"
+	passwordCookiePrefix = "pw_"
+	passwordCookieTTL    = time.Hour
+	passwordTagLength    = 8
+)
+
+// passwordPage data of password form.
+type passwordPage struct {
+	// Code - code of protected url, form is submitted to its unlock path.
+	Code string
+	// Next - path of url opened after unlock.
+	Next string
+	// Wrong - previous password was wrong.
+	Wrong bool
+}
+
+// unlockURL handles password form of protected url.
+// Right password sets cookie of url and redirects back to requested path of url.
+func (a *AppHandler) unlockURL(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "shortURL")
+	shortURL, err := a.parseCode(code)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	redirect, err := a.repo.GetRedirect(r.Context(), shortURL)
+	if err != nil {
+		switch {
+		case errors.Is(err, &repository.DeletedURLError{}):
+			w.WriteHeader(http.StatusGone)
+		case errors.Is(err, &repository.URLNotFoundError{}):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	if !a.checkServedDomain(w, r, redirect.ShortDomain) {
+		return
+	}
+	next := r.PostFormValue("next")
+	if !isURLPath(next, code) {
+		next = "/" + code
+	}
+	if len(redirect.PasswordHash) != 0 && !isPassword(redirect.PasswordHash, r.PostFormValue("password")) {
+		renderPage(w, "password.html", passwordPage{Code: code, Next: next, Wrong: true}, http.StatusUnauthorized)
+		return
+	}
+	if len(redirect.PasswordHash) != 0 {
+		a.setPasswordCookie(w, code, redirect.PasswordHash)
+	}
+	w.Header().Set("Location", next)
+	w.WriteHeader(http.StatusSeeOther)
+}
+
+// checkPassword checks access to url protected by password with hash.
+// Password is taken from signed cookie of url or header.
+// Returns false and writes password form if access is denied.
+func (a *AppHandler) checkPassword(w http.ResponseWriter, r *http.Request, code string, hash string) bool {
+	if a.hasPasswordCookie(r, code, hash) {
+		return true
+	}
+	password := r.Header.Get(passwordHeader)
+	if isPassword(hash, password) {
+		a.setPasswordCookie(w, code, hash)
+		return true
+	}
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	page := passwordPage{Code: code, Next: r.URL.RequestURI(), Wrong: len(password) != 0}
+	renderPage(w, "password.html", page, http.StatusUnauthorized)
+	return false
+}
+
+// hashPassword returns bcrypt hash of password, empty password returns empty hash.
+func hashPassword(password string) (string, error) {
+	if len(password) == 0 {
+		return "", nil
+	}
+	if len(password) > maxPasswordLength {
+		return "", errors.New("password is too long")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// isPassword compares password with hash, bcrypt compares hashes in constant time.
+func isPassword(hash string, password string) bool {
+	return len(password) != 0 && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// isURLPath checks that path is path of url or preview page of url with code, so unlock can't redirect to another site.
+func isURLPath(path string, code string) bool {
+	prefix := "/" + code
+	return path == prefix || path == prefix+"+" ||
+		strings.HasPrefix(path, prefix+"/") || strings.HasPrefix(path, prefix+"?")
+}
+
+// setPasswordCookie sets short-lived cookie signed by secret key of service which opens url without password.
+// Cookie is set for path of url and path of its preview page, cookie is secure if service is served by https.
+func (a *AppHandler) setPasswordCookie(w http.ResponseWriter, code string, hash string) {
+	expires := time.Now().Add(passwordCookieTTL)
+	payload := make([]byte, 8, 8+passwordTagLength+len(code))
+	binary.BigEndian.PutUint64(payload, uint64(expires.Unix()))
+	payload = append(payload, passwordTag(hash)...)
+	payload = append(payload, code...)
+	value := myMiddleware.SignToken(a.secretKey, payload)
+	for _, path := range []string{"/" + code, "/" + code + "+"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     passwordCookiePrefix + code,
+			Value:    value,
+			Path:     path,
+			Expires:  expires,
+			MaxAge:   int(passwordCookieTTL.Seconds()),
+			Secure:   strings.HasPrefix(a.baseURL, "https://"),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// hasPasswordCookie checks that request has not expired cookie of url signed for current password.
+func (a *AppHandler) hasPasswordCookie(r *http.Request, code string, hash string) bool {
+	cookie, err := r.Cookie(passwordCookiePrefix + code)
+	if err != nil {
+		return false
+	}
+	payload, ok := myMiddleware.VerifyToken(a.secretKey, cookie.Value)
+	if !ok || len(payload) < 8+passwordTagLength {
+		return false
+	}
+	expires := time.Unix(int64(binary.BigEndian.Uint64(payload)), 0)
+	return time.Now().Before(expires) &&
+		bytes.Equal(payload[8:8+passwordTagLength], passwordTag(hash)) &&
+		string(payload[8+passwordTagLength:]) == code
+}
+
+// passwordTag returns short digest of password hash, cookie of old password is invalid after password change.
+func passwordTag(hash string) []byte {
+	sum := sha256.Sum256([]byte(hash))
+	return sum[:passwordTagLength]
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	myMiddleware "go-axesthump-shortener/internal/app/middleware"
+	"go-axesthump-shortener/internal/app/repository"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAppHandler_getURLPassword(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		baseURL:         "https://short.example/",
+		wg:              &sync.WaitGroup{},
+		passthrough:     repository.PassthroughNone,
+		queryConflict:   repository.QueryConflictKeep,
+		secretKey:       []byte("test key"),
+	}
+	router := NewRouter(a)
+
+	r, _ := http.NewRequestWithContext(
+		context.WithValue(context.TODO(), myMiddleware.UserIDKey, uint32(1)),
+		http.MethodPost,
+		"/api/shorten",
+		strings.NewReader(`{"url":"http://google.com/internal","password":"secret"}`),
+	)
+	w := httptest.NewRecorder()
+	a.addURLRest(w, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+	info, err := repo.GetURLInfo(context.TODO(), 0)
+	require.NoError(t, err)
+	assert.True(t, info.HasPassword)
+	redirect, err := repo.GetRedirect(context.TODO(), 0)
+	require.NoError(t, err)
+	w = httptest.NewRecorder()
+	(&AppHandler{secretKey: []byte("other key")}).setPasswordCookie(w, "0", redirect.PasswordHash)
+	otherKeyCookie := w.Result().Cookies()[0]
+
+	send := func(method string, path string, password string, form url.Values, cookie *http.Cookie) *http.Response {
+		var body io.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		}
+		r := httptest.NewRequest(method, path, body)
+		if form != nil {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if len(password) != 0 {
+			r.Header.Set(passwordHeader, password)
+		}
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Result()
+	}
+	passwordCookie := func(res *http.Response) *http.Cookie {
+		for _, cookie := range res.Cookies() {
+			if cookie.Name == "pw_0" {
+				return cookie
+			}
+		}
+		return nil
+	}
+	cookiePaths := func(res *http.Response) []string {
+		var paths []string
+		for _, cookie := range res.Cookies() {
+			if cookie.Name == "pw_0" {
+				assert.True(t, cookie.Secure)
+				assert.True(t, cookie.HttpOnly)
+				paths = append(paths, cookie.Path)
+			}
+		}
+		return paths
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		password   string
+		form       url.Values
+		cookie     *http.Cookie
+		statusCode int
+		location   string
+		unlocked   bool
+	}{
+		{
+			name:       "without password",
+			method:     http.MethodGet,
+			path:       "/0",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "head without password",
+			method:     http.MethodHead,
+			path:       "/0",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong password in header",
+			method:     http.MethodGet,
+			path:       "/0",
+			password:   "guess",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "password in header",
+			method:     http.MethodGet,
+			path:       "/0",
+			password:   "secret",
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "http://google.com/internal",
+			unlocked:   true,
+		},
+		{
+			name:       "password in form",
+			method:     http.MethodPost,
+			path:       "/0/unlock",
+			form:       url.Values{"password": {"secret"}, "next": {"/0/docs?page=2"}},
+			statusCode: http.StatusSeeOther,
+			location:   "/0/docs?page=2",
+			unlocked:   true,
+		},
+		{
+			name:       "form redirects only to url path",
+			method:     http.MethodPost,
+			path:       "/0/unlock",
+			form:       url.Values{"password": {"secret"}, "next": {"//evil.com/0"}},
+			statusCode: http.StatusSeeOther,
+			location:   "/0",
+			unlocked:   true,
+		},
+		{
+			name:       "form redirects to preview page",
+			method:     http.MethodPost,
+			path:       "/0/unlock",
+			form:       url.Values{"password": {"secret"}, "next": {"/0+"}},
+			statusCode: http.StatusSeeOther,
+			location:   "/0+",
+			unlocked:   true,
+		},
+		{
+			name:       "wrong password in form",
+			method:     http.MethodPost,
+			path:       "/0/unlock",
+			form:       url.Values{"password": {"guess"}},
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "forged cookie",
+			method:     http.MethodGet,
+			path:       "/0",
+			cookie:     &http.Cookie{Name: "pw_0", Value: myMiddleware.SignToken(a.secretKey, []byte("forged cookie payload"))},
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "cookie signed by other key",
+			method:     http.MethodGet,
+			path:       "/0",
+			cookie:     otherKeyCookie,
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "preview without password",
+			method:     http.MethodGet,
+			path:       "/0+",
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+	var cookie *http.Cookie
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := send(tt.method, tt.path, tt.password, tt.form, tt.cookie)
+			defer res.Body.Close()
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			assert.Equal(t, tt.location, res.Header.Get("Location"))
+			if tt.unlocked {
+				cookie = passwordCookie(res)
+				require.NotNil(t, cookie)
+				assert.Equal(t, []string{"/0", "/0+"}, cookiePaths(res))
+			} else {
+				assert.Nil(t, passwordCookie(res))
+			}
+		})
+	}
+
+	// cookie opens url and its preview page without password until password is changed
+	require.NotNil(t, cookie)
+	res := send(http.MethodGet, "/0", "", nil, cookie)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+	res = send(http.MethodGet, "/0+", "", nil, cookie)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "http://google.com/internal")
+	res = send(http.MethodGet, "/0+", "secret", nil, nil)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	newHash, err := hashPassword("new secret")
+	require.NoError(t, err)
+	_, err = repo.UpdateURLMeta(context.TODO(), 0, 1, repository.URLMetaUpdate{PasswordHash: &newHash})
+	require.NoError(t, err)
+	res = send(http.MethodGet, "/0", "", nil, cookie)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
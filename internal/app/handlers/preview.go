@@ -0,0 +1,9 @@
+package handlers
+
+// fetchPreview adds created or re-pointed url in preview fetch queue.
+func (a *AppHandler) fetchPreview(shortURL int64, originalURL string) {
+	if a.previewService == nil {
+		return
+	}
+	a.previewService.AddURL(shortURL, originalURL)
+}
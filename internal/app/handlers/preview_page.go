@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"go-axesthump-shortener/internal/app/repository"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+// pageTemplates html pages of service, values are escaped by html/template.
+var pageTemplates = template.Must(template.ParseFS(templateFiles, "templates/*.html"))
+
+// pageCSP forbids scripts and frames on html pages, images of destination pages are allowed.
+const pageCSP = "default-src 'none'; img-src http: https:; style-src 'unsafe-inline'; form-action 'self'"
+
+// previewURL handles a request to show page with info about short url instead of redirect.
+func (a *AppHandler) previewURL(w http.ResponseWriter, r *http.Request) {
+	shortURL, err := a.parseCode(chi.URLParam(r, "shortURL"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	info, err := a.repo.GetURLInfo(r.Context(), shortURL)
+	if err != nil {
+		switch {
+		case errors.Is(err, &repository.DeletedURLError{}):
+			w.WriteHeader(http.StatusGone)
+		case errors.Is(err, &repository.URLNotFoundError{}):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	info = a.withShortURL(info)
+	if !a.checkServedDomain(w, r, info.ShortDomain) {
+		return
+	}
+	if info.Scheduled {
+		a.notActive(w, r, *info.ActivatesAt)
+		return
+	}
+	if info.HasPassword {
+		// destination of protected url is shown only with cookie or password of url
+		redirect, err := a.repo.GetRedirect(r.Context(), shortURL)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !a.checkPassword(w, r, chi.URLParam(r, "shortURL"), redirect.PasswordHash) {
+			return
+		}
+		w.Header().Set("Cache-Control", "private, no-store")
+	}
+	renderPage(w, "preview.html", info, http.StatusOK)
+}
+
+// renderPage writes html page from template with data.
+func renderPage(w http.ResponseWriter, name string, data interface{}, status int) {
+	var buf bytes.Buffer
+	if err := pageTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		log.Printf("Cant render page %s: %s", name, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", pageCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	if _, err := buf.WriteTo(w); err != nil {
+		log.Printf("Cant write page %s: %s", name, err)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	"go-axesthump-shortener/internal/app/repository"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestAppHandler_previewURL(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		baseURL:         "http://localhost:8080/",
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+	}
+	ctx := context.TODO()
+	_, err := repo.CreateShortURL(ctx, "http://google.com/?q=<b>", 1, repository.URLOptions{
+		Title: "<script>alert(1)</script>",
+		Tags:  []string{"search", "flyer"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.SetURLPreview(ctx, 0, "http://google.com/?q=<b>", repository.URLPreview{
+		OGTitle: "Google",
+		OGImage: "javascript:alert(1)",
+	}))
+	_, err = repo.CreateShortURL(ctx, "http://yandex.ru", 1, repository.URLOptions{})
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteURLs([]repository.DeleteURL{{URL: "1", UserID: 1}}))
+
+	tests := []struct {
+		name       string
+		shortURL   string
+		statusCode int
+		contains   []string
+		notContain []string
+	}{
+		{
+			name:       "preview page",
+			shortURL:   "0",
+			statusCode: http.StatusOK,
+			contains: []string{
+				"http://localhost:8080/0",
+				`href="http://google.com/?q=%3cb%3e"`,
+				"&lt;script&gt;alert(1)&lt;/script&gt;",
+				"search, flyer",
+				"Google",
+				"#ZgotmplZ",
+			},
+			notContain: []string{"<script>", "javascript:"},
+		},
+		{
+			name:       "deleted url",
+			shortURL:   "1",
+			statusCode: http.StatusGone,
+		},
+		{
+			name:       "not existing url",
+			shortURL:   "10",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "bad short url",
+			shortURL:   "abc",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/"+tt.shortURL+"+", nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("shortURL", tt.shortURL)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			a.previewURL(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+			assert.NotEmpty(t, res.Header.Get("Content-Security-Policy"))
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			for _, s := range tt.contains {
+				assert.Contains(t, string(body), s)
+			}
+			for _, s := range tt.notContain {
+				assert.NotContains(t, string(body), s)
+			}
+		})
+	}
+
+	// preview is not counted as click
+	info, err := repo.GetURLInfo(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Clicks)
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"go-axesthump-shortener/internal/app/qrcode"
+	"go-axesthump-shortener/internal/app/repository"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// qrCacheControl QR code is served only while url exists and isn't deleted, so it's cached by client
+// for a short time and then revalidated by ETag, shared caches don't keep it.
+const qrCacheControl = "private, max-age=300"
+
+// qrCode handles a request to get QR code of short url.
+// Format, size, margin, error correction level and colors are set by query params, see parseQROptions.
+func (a *AppHandler) qrCode(w http.ResponseWriter, r *http.Request) {
+	shortURL, err := a.parseCode(chi.URLParam(r, "shortURL"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	opts, err := parseQROptions(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	info, err := a.repo.GetURLInfo(r.Context(), shortURL)
+	if err != nil {
+		switch {
+		case errors.Is(err, &repository.DeletedURLError{}):
+			w.WriteHeader(http.StatusGone)
+		case errors.Is(err, &repository.URLNotFoundError{}):
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	info = a.withShortURL(info)
+
+	etag := qrETag(info.ShortURL, opts)
+	w.Header().Set("Cache-Control", qrCacheControl)
+	w.Header().Set("ETag", etag)
+	if matchETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	var buf bytes.Buffer
+	if err = qrcode.Render(&buf, info.ShortURL, opts); err != nil {
+		log.Printf("Cant render qr code of url %d: %s", shortURL, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", opts.ContentType())
+	w.WriteHeader(http.StatusOK)
+	if _, err = buf.WriteTo(w); err != nil {
+		log.Printf("Cant write qr code of url %d: %s", shortURL, err)
+	}
+}
+
+// parseQROptions returns QR code options from query params:
+// format (png or svg), size in pixels, margin in modules, ecc (L, M, Q or H), fg and bg colors in RRGGBB.
+func parseQROptions(r *http.Request) (qrcode.Options, error) {
+	query := r.URL.Query()
+	opts := qrcode.DefaultOptions()
+	if format := query.Get("format"); len(format) != 0 {
+		opts.Format = qrcode.Format(strings.ToLower(format))
+	}
+	if size := query.Get("size"); len(size) != 0 {
+		value, err := strconv.Atoi(size)
+		if err != nil {
+			return qrcode.Options{}, err
+		}
+		opts.Size = value
+	}
+	if margin := query.Get("margin"); len(margin) != 0 {
+		value, err := strconv.Atoi(margin)
+		if err != nil {
+			return qrcode.Options{}, err
+		}
+		opts.Margin = value
+	}
+	if level := query.Get("ecc"); len(level) != 0 {
+		opts.Level = qrcode.Level(strings.ToUpper(level))
+	}
+	var err error
+	if fg := query.Get("fg"); len(fg) != 0 {
+		if opts.Foreground, err = qrcode.ParseColor(fg); err != nil {
+			return qrcode.Options{}, err
+		}
+	}
+	if bg := query.Get("bg"); len(bg) != 0 {
+		if opts.Background, err = qrcode.ParseColor(bg); err != nil {
+			return qrcode.Options{}, err
+		}
+	}
+	return opts, opts.Validate()
+}
+
+// qrETag returns entity tag of QR code, it depends only on short url and options.
+func qrETag(shortURL string, opts qrcode.Options) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s|%+v", shortURL, opts)))
+	return `"` + hex.EncodeToString(hash[:16]) + `"`
+}
+
+// matchETag checks that If-None-Match header contains etag.
+func matchETag(ifNoneMatch string, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// qrURL returns url of QR code of short url.
+func qrURL(shortURL string) string {
+	return shortURL + "/qr"
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	"go-axesthump-shortener/internal/app/repository"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestAppHandler_qrCode(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		baseURL:         "http://localhost:8080/",
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+	}
+	_, err := repo.CreateShortURL(context.TODO(), "http://google.com/qr", 1, repository.URLOptions{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		shortURL    string
+		query       string
+		statusCode  int
+		contentType string
+	}{
+		{
+			name:        "default png",
+			shortURL:    "0",
+			statusCode:  http.StatusOK,
+			contentType: "image/png",
+		},
+		{
+			name:        "svg with options",
+			shortURL:    "0",
+			query:       "?format=svg&size=512&margin=2&ecc=h&fg=%23336699&bg=ffffff",
+			statusCode:  http.StatusOK,
+			contentType: "image/svg+xml",
+		},
+		{
+			name:       "bad size",
+			shortURL:   "0",
+			query:      "?size=big",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "bad color",
+			shortURL:   "0",
+			query:      "?fg=red",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "not existing url",
+			shortURL:   "10",
+			statusCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			a.qrCode(w, qrRequest(tt.shortURL, tt.query, ""))
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.contentType, res.Header.Get("Content-Type"))
+			assert.Equal(t, qrCacheControl, res.Header.Get("Cache-Control"))
+			assert.NotEmpty(t, res.Header.Get("ETag"))
+			if tt.contentType == "image/png" {
+				img, err := png.Decode(res.Body)
+				require.NoError(t, err)
+				assert.Equal(t, 256, img.Bounds().Dx())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	a.qrCode(w, qrRequest("0", "", ""))
+	etag := w.Result().Header.Get("ETag")
+	w = httptest.NewRecorder()
+	a.qrCode(w, qrRequest("0", "", etag))
+	assert.Equal(t, http.StatusNotModified, w.Result().StatusCode)
+	w = httptest.NewRecorder()
+	a.qrCode(w, qrRequest("0", "?format=svg", etag))
+	assert.Equal(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func qrRequest(shortURL string, query string, ifNoneMatch string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/"+shortURL+"/qr"+query, nil)
+	if len(ifNoneMatch) != 0 {
+		r.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("shortURL", shortURL)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"go-axesthump-shortener/internal/app/repository"
+	"golang.org/x/text/language"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Limits of redirect rules.
+const (
+	maxRedirectRules     = 20
+	maxRuleValuesCount   = 50
+	countryCodeLength    = 2
+	maxLanguageTagLength = 35
+)
+
+// visitor returns properties of request checked by rules.
+// Country is looked up only if some rule checks it.
+func (a *AppHandler) visitor(r *http.Request, rules []repository.RedirectRule) repository.Visitor {
+	v := repository.Visitor{Time: time.Now()}
+	if len(rules) == 0 {
+		return v
+	}
+	v.Platform = userAgentPlatform(r.UserAgent())
+	v.Language = preferredLanguage(r.Header.Get("Accept-Language"))
+	if a.geo != nil && repository.NeedCountry(rules) {
+		if ip := remoteIP(r); ip != nil {
+			country, err := a.geo.Country(ip)
+			if err != nil {
+				log.Printf("Cant find country of %s - %s\n", ip, err)
+			}
+			v.Country = country
+		}
+	}
+	return v
+}
+
+// userAgentPlatform returns platform of User-Agent header.
+// iOS and Android are checked first because their agents also contain macOS and Linux.
+func userAgentPlatform(userAgent string) repository.Platform {
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"),
+		strings.Contains(userAgent, "iPod"):
+		return repository.PlatformIOS
+	case strings.Contains(userAgent, "Android"):
+		return repository.PlatformAndroid
+	case strings.Contains(userAgent, "Windows"):
+		return repository.PlatformWindows
+	case strings.Contains(userAgent, "Macintosh"), strings.Contains(userAgent, "Mac OS X"):
+		return repository.PlatformMacOS
+	case strings.Contains(userAgent, "Linux"), strings.Contains(userAgent, "X11"):
+		return repository.PlatformLinux
+	}
+	return repository.PlatformUnknown
+}
+
+// preferredLanguage returns language with the highest quality from Accept-Language header.
+func preferredLanguage(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return ""
+	}
+	return tags[0].String()
+}
+
+// remoteIP returns ip address of request client.
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// validateRedirectRules checks count, conditions and destinations of rules.
+func validateRedirectRules(rules []repository.RedirectRule) error {
+	if len(rules) > maxRedirectRules {
+		return errors.New("too many rules")
+	}
+	for _, rule := range rules {
+		if !rule.HasConditions() {
+			return errors.New("rule without conditions")
+		}
+		if !isAbsoluteURL(rule.URL) {
+			return errors.New("bad rule url")
+		}
+		if len(rule.Platforms) > maxRuleValuesCount || len(rule.Languages) > maxRuleValuesCount ||
+			len(rule.Countries) > maxRuleValuesCount {
+			return errors.New("too many rule values")
+		}
+		for _, platform := range rule.Platforms {
+			if !platform.IsValid() {
+				return errors.New("unsupported platform")
+			}
+		}
+		for _, tag := range rule.Languages {
+			if len(tag) > maxLanguageTagLength {
+				return errors.New("bad language")
+			}
+			if _, err := language.Parse(tag); err != nil {
+				return errors.New("bad language")
+			}
+		}
+		for _, country := range rule.Countries {
+			if len(country) != countryCodeLength {
+				return errors.New("bad country")
+			}
+		}
+		if rule.StartsAt != nil && rule.EndsAt != nil && !rule.StartsAt.Before(*rule.EndsAt) {
+			return errors.New("empty time window")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	"go-axesthump-shortener/internal/app/repository"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLocator geoip.Locator with countries of ip addresses.
+type testLocator map[string]string
+
+// Country returns country of ip.
+func (l testLocator) Country(ip net.IP) (string, error) {
+	return l[ip.String()], nil
+}
+
+func TestAppHandler_getURLRules(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+		passthrough:     repository.PassthroughNone,
+		queryConflict:   repository.QueryConflictKeep,
+		geo:             testLocator{"81.2.69.142": "DE"},
+	}
+	router := NewRouter(a)
+	_, err := repo.CreateShortURL(context.TODO(), "https://example.com/en", 1, repository.URLOptions{
+		Rules: []repository.RedirectRule{
+			{Platforms: []repository.Platform{repository.PlatformIOS}, URL: "https://apps.apple.com/app"},
+			{Platforms: []repository.Platform{repository.PlatformAndroid}, URL: "https://play.google.com/app"},
+			{Countries: []string{"DE"}, URL: "https://example.com/de"},
+			{Languages: []string{"fr"}, URL: "https://example.com/fr"},
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		userAgent      string
+		acceptLanguage string
+		remoteAddr     string
+		location       string
+	}{
+		{
+			name:      "iphone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) AppleWebKit/605.1.15",
+			location:  "https://apps.apple.com/app",
+		},
+		{
+			name:       "android in germany",
+			userAgent:  "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36",
+			remoteAddr: "81.2.69.142:4000",
+			location:   "https://play.google.com/app",
+		},
+		{
+			name:       "desktop in germany",
+			userAgent:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64)",
+			remoteAddr: "81.2.69.142:4000",
+			location:   "https://example.com/de",
+		},
+		{
+			name:           "french language",
+			userAgent:      "Mozilla/5.0 (X11; Linux x86_64)",
+			acceptLanguage: "en;q=0.5, fr-CA, fr;q=0.9",
+			location:       "https://example.com/fr",
+		},
+		{
+			name:           "not preferred french language",
+			acceptLanguage: "en-US,en;q=0.9,fr;q=0.8",
+			location:       "https://example.com/en",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/0", nil)
+			r.Header.Set("User-Agent", tt.userAgent)
+			r.Header.Set("Accept-Language", tt.acceptLanguage)
+			if len(tt.remoteAddr) != 0 {
+				r.RemoteAddr = tt.remoteAddr
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+			assert.Equal(t, tt.location, res.Header.Get("Location"))
+		})
+	}
+}
+
+func Test_validateRedirectRules(t *testing.T) {
+	startsAt := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		rule    repository.RedirectRule
+		wantErr bool
+	}{
+		{
+			name: "valid rule",
+			rule: repository.RedirectRule{
+				Platforms: []repository.Platform{repository.PlatformMacOS},
+				Languages: []string{"pt-BR"},
+				Countries: []string{"BR"},
+				StartsAt:  &startsAt,
+				URL:       "https://example.com/br",
+			},
+		},
+		{
+			name:    "rule without conditions",
+			rule:    repository.RedirectRule{URL: "https://example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "relative url",
+			rule:    repository.RedirectRule{Countries: []string{"BR"}, URL: "/br"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown platform",
+			rule:    repository.RedirectRule{Platforms: []repository.Platform{"symbian"}, URL: "https://example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "bad language",
+			rule:    repository.RedirectRule{Languages: []string{"not a language"}, URL: "https://example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "bad country",
+			rule:    repository.RedirectRule{Countries: []string{"BRA"}, URL: "https://example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "empty time window",
+			rule:    repository.RedirectRule{StartsAt: &startsAt, EndsAt: &startsAt, URL: "https://example.com"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRedirectRules([]repository.RedirectRule{tt.rule})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
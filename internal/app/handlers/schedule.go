@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+)
+
+// scheduledPage data of page of url which is not active yet.
+type scheduledPage struct {
+	// ActivatesAt - time from which url redirects.
+	ActivatesAt time.Time
+}
+
+// notActive writes response to url which is not active yet,
+// it's page with activation time if page is enabled in config, otherwise 404.
+func (a *AppHandler) notActive(w http.ResponseWriter, r *http.Request, activatesAt time.Time) {
+	// response must not be reused from cache after url activation
+	w.Header().Set("Cache-Control", "no-store")
+	if !a.scheduledPage || r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	renderPage(w, "scheduled.html", scheduledPage{ActivatesAt: activatesAt.UTC()}, http.StatusNotFound)
+}
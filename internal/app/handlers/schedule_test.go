@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	"go-axesthump-shortener/internal/app/repository"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppHandler_getURLScheduled(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	_, err := repo.CreateShortURL(context.TODO(), "http://google.com/soon", 1, repository.URLOptions{ActivatesAt: &future})
+	require.NoError(t, err)
+	_, err = repo.CreateShortURL(context.TODO(), "http://google.com/now", 1, repository.URLOptions{ActivatesAt: &past})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		scheduledPage bool
+		method        string
+		path          string
+		statusCode    int
+		page          bool
+	}{
+		{name: "not active url", method: http.MethodGet, path: "/0", statusCode: http.StatusNotFound},
+		{name: "not active url page", scheduledPage: true, method: http.MethodGet, path: "/0", statusCode: http.StatusNotFound, page: true},
+		{name: "head of not active url", scheduledPage: true, method: http.MethodHead, path: "/0", statusCode: http.StatusNotFound},
+		{name: "preview of not active url", scheduledPage: true, method: http.MethodGet, path: "/0+", statusCode: http.StatusNotFound, page: true},
+		{name: "activated url", scheduledPage: true, method: http.MethodGet, path: "/1", statusCode: http.StatusTemporaryRedirect},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &AppHandler{
+				repo:            repo,
+				userIDGenerator: generator.NewIDGenerator(0),
+				wg:              &sync.WaitGroup{},
+				passthrough:     repository.PassthroughNone,
+				queryConflict:   repository.QueryConflictKeep,
+				scheduledPage:   tt.scheduledPage,
+			}
+			w := httptest.NewRecorder()
+			NewRouter(a).ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			res := w.Result()
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+			if tt.statusCode == http.StatusNotFound {
+				assert.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+			}
+			assert.Equal(t, tt.page, len(body) != 0)
+			if tt.page {
+				assert.Contains(t, string(body), future.UTC().Format("2006-01-02T15:04:05Z07:00"))
+				assert.NotContains(t, string(body), "google.com")
+			}
+		})
+	}
+
+	info, err := repo.GetURLInfo(context.TODO(), 0)
+	require.NoError(t, err)
+	assert.True(t, info.Scheduled)
+	assert.Equal(t, int64(0), info.Clicks)
+}
+
+func TestAppHandler_addURLRestScheduled(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+	}
+	router := NewRouter(a)
+	activatesAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	body, err := json.Marshal(map[string]interface{}{"url": "http://google.com/launch", "activates_at": activatesAt})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewReader(body)))
+	res := w.Result()
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	var auth *http.Cookie
+	for _, cookie := range res.Cookies() {
+		if cookie.Name == "auth" {
+			auth = cookie
+		}
+	}
+	require.NotNil(t, auth)
+
+	tests := []struct {
+		name   string
+		status string
+		want   int
+	}{
+		{name: "scheduled urls", status: "scheduled", want: 1},
+		{name: "active urls", status: "active", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/user/urls?status="+tt.status, nil)
+			request.AddCookie(auth)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			if tt.want == 0 {
+				assert.Equal(t, http.StatusNoContent, res.StatusCode)
+				return
+			}
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			var urls []repository.URLInfo
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&urls))
+			require.Len(t, urls, tt.want)
+			assert.True(t, urls[0].Scheduled)
+			require.NotNil(t, urls[0].ActivatesAt)
+			assert.True(t, activatesAt.Equal(*urls[0].ActivatesAt))
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"go-axesthump-shortener/internal/app/generator"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// shortURL returns short url of code on custom domain shortDomain, on domain of service base url if shortDomain is empty.
+func (a *AppHandler) shortURL(shortDomain string, code int64) string {
+	return domainURL(a.baseURL, shortDomain) + a.formatCode(code)
+}
+
+// formatCode returns text of code in short url.
+func (a *AppHandler) formatCode(code int64) string {
+	if a.codes == nil {
+		return generator.DecimalCodes{}.Format(code)
+	}
+	return a.codes.Format(code)
+}
+
+// parseCode returns code by text of short url.
+func (a *AppHandler) parseCode(s string) (int64, error) {
+	if a.codes == nil {
+		return generator.DecimalCodes{}.Parse(s)
+	}
+	return a.codes.Parse(s)
+}
+
+// withShortURL returns info with short url set by its code and domain.
+func (a *AppHandler) withShortURL(info repository.URLInfo) repository.URLInfo {
+	info.ShortURL = a.shortURL(info.ShortDomain, info.Code)
+	return info
+}
+
+// codeOf returns code of short url of this service as decimal id.
+// Short url must be code itself, start with base url of service or be opened on registered custom domain.
+func (a *AppHandler) codeOf(ctx context.Context, shortURL string) (string, bool) {
+	text, ok := a.codeText(ctx, shortURL)
+	if !ok {
+		return "", false
+	}
+	code, err := a.parseCode(text)
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatInt(code, 10), true
+}
+
+// codeText returns text of code in short url of this service.
+func (a *AppHandler) codeText(ctx context.Context, shortURL string) (string, bool) {
+	if _, err := a.parseCode(shortURL); err == nil {
+		return shortURL, true
+	}
+	prefix := strings.TrimRight(a.baseURL, "/") + "/"
+	if strings.HasPrefix(shortURL, prefix) {
+		return strings.TrimPrefix(shortURL, prefix), true
+	}
+	parsed, err := url.Parse(shortURL)
+	if err != nil || len(parsed.Host) == 0 || len(parsed.RawQuery) != 0 || len(parsed.Fragment) != 0 {
+		return "", false
+	}
+	if _, err = a.repo.GetDomain(ctx, strings.ToLower(parsed.Hostname())); err != nil {
+		return "", false
+	}
+	return strings.TrimPrefix(parsed.Path, "/"), true
+}
+
+// domainURL returns beginURL with host replaced by custom domain, beginURL itself for url without domain.
+func domainURL(beginURL string, domain string) string {
+	if len(domain) == 0 {
+		return beginURL
+	}
+	parsed, err := url.Parse(beginURL)
+	if err != nil || len(parsed.Host) == 0 {
+		return "https://" + domain + "/"
+	}
+	parsed.Host = domain
+	return parsed.String()
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	"go-axesthump-shortener/internal/app/repository"
+	"strconv"
+	"testing"
+)
+
+func Test_domainURL(t *testing.T) {
+	assert.Equal(t, "http://localhost:8080/", domainURL("http://localhost:8080/", ""))
+	assert.Equal(t, "http://go.brand.com/", domainURL("http://localhost:8080/", "go.brand.com"))
+	assert.Equal(t, "https://go.brand.com/s/", domainURL("https://short.com/s/", "go.brand.com"))
+	assert.Equal(t, "https://go.brand.com/", domainURL("", "go.brand.com"))
+}
+
+func TestAppHandler_withShortURL(t *testing.T) {
+	a := &AppHandler{baseURL: "http://localhost:8080/"}
+	tests := []struct {
+		name string
+		info repository.URLInfo
+		want string
+	}{
+		{
+			name: "url on base domain",
+			info: repository.URLInfo{Code: 12},
+			want: "http://localhost:8080/12",
+		},
+		{
+			name: "url on custom domain",
+			info: repository.URLInfo{Code: 7, ShortDomain: "go.brand.com"},
+			want: "http://go.brand.com/7",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, a.withShortURL(tt.info).ShortURL)
+		})
+	}
+}
+
+func TestAppHandler_randomCodes(t *testing.T) {
+	codes, err := generator.NewRandomCodes("abc", 4)
+	require.NoError(t, err)
+	a := &AppHandler{baseURL: "http://localhost:8080/", codes: codes}
+	id := generator.RandomCodeBase + 1
+
+	assert.Equal(t, "http://localhost:8080/aaab", a.withShortURL(repository.URLInfo{Code: id}).ShortURL)
+	assert.Equal(t, "http://localhost:8080/12", a.withShortURL(repository.URLInfo{Code: 12}).ShortURL)
+	parsed, err := a.parseCode("aaab")
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+
+	tests := []struct {
+		name     string
+		shortURL string
+		want     string
+		wantOk   bool
+	}{
+		{name: "short url", shortURL: "http://localhost:8080/aaab", want: strconv.FormatInt(id, 10), wantOk: true},
+		{name: "random code", shortURL: "aaab", want: strconv.FormatInt(id, 10), wantOk: true},
+		{name: "sequential code", shortURL: "http://localhost:8080/12", want: "12", wantOk: true},
+		{name: "not code", shortURL: "aa!b"},
+		{name: "not code in short url", shortURL: "http://localhost:8080/aaxb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := a.codeOf(context.TODO(), tt.shortURL)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page size of user urls.
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// parseURLFilter returns filter of user urls from request query.
+// Supported params: cursor, limit, domain, short_domain, created_from, created_to (RFC 3339), status (active, scheduled, deleted),
+// tag, sort (created, clicks), order (asc, desc).
+func parseURLFilter(r *http.Request) (repository.URLFilter, error) {
+	query := r.URL.Query()
+	filter := repository.URLFilter{
+		Cursor: query.Get("cursor"),
+		Limit:  defaultPageSize,
+		Domain: query.Get("domain"),
+		// short domain is stored in lower case
+		ShortDomain: strings.ToLower(query.Get("short_domain")),
+		Status:      repository.URLStatus(query.Get("status")),
+		Tag:         query.Get("tag"),
+		SortBy:      repository.URLSort(query.Get("sort")),
+	}
+	var err error
+	if limit := query.Get("limit"); len(limit) != 0 {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit <= 0 || filter.Limit > maxPageSize {
+			return repository.URLFilter{}, errors.New("bad limit")
+		}
+	}
+	if from := query.Get("created_from"); len(from) != 0 {
+		if filter.CreatedFrom, err = time.Parse(time.RFC3339, from); err != nil {
+			return repository.URLFilter{}, err
+		}
+	}
+	if to := query.Get("created_to"); len(to) != 0 {
+		if filter.CreatedTo, err = time.Parse(time.RFC3339, to); err != nil {
+			return repository.URLFilter{}, err
+		}
+	}
+	switch filter.Status {
+	case repository.StatusAny, repository.StatusActive, repository.StatusScheduled, repository.StatusDeleted:
+	default:
+		return repository.URLFilter{}, errors.New("bad status")
+	}
+	switch filter.SortBy {
+	case "", repository.SortByCreated, repository.SortByClicks:
+	default:
+		return repository.URLFilter{}, errors.New("bad sort")
+	}
+	switch query.Get("order") {
+	case "", "desc":
+		filter.Desc = true
+	case "asc":
+	default:
+		return repository.URLFilter{}, errors.New("bad order")
+	}
+	return filter, nil
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	myMiddleware "go-axesthump-shortener/internal/app/middleware"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+)
+
+// Limits of url metadata.
+const (
+	maxTitleLength       = 255
+	maxDescriptionLength = 2048
+	maxTagsCount         = 20
+	maxTagLength         = 64
+)
+
+// updateURLRequest url update request data, absent fields are not changed.
+type updateURLRequest struct {
+	// Title - new url title.
+	Title *string `json:"title"`
+	// Description - new url notes.
+	Description *string `json:"description"`
+	// Tags - new url tags.
+	Tags *[]string `json:"tags"`
+	// RedirectType - new redirect status of url, 0 resets it to service default.
+	RedirectType *repository.RedirectType `json:"redirect_type"`
+	// Passthrough - new parts of request passed to destination, empty resets it to service default.
+	Passthrough *repository.PassthroughMode `json:"passthrough"`
+	// QueryConflict - new policy of query params merge, empty resets it to service default.
+	QueryConflict *repository.QueryConflict `json:"query_conflict"`
+	// Rules - new ordered conditional destinations, empty removes all rules.
+	Rules *[]repository.RedirectRule `json:"rules"`
+	// Variants - new weighted destinations of A/B split, empty disables split, clicks of variants are reset.
+	Variants *[]repository.URLVariant `json:"variants"`
+	// Password - new password required to open url, empty removes password.
+	Password *string `json:"password"`
+	// MaxClicks - new count of redirects left, 0 removes limit.
+	MaxClicks *int64 `json:"max_clicks"`
+	// OriginalURL - new destination of url, only url owner can change it.
+	OriginalURL *string `json:"original_url"`
+}
+
+// rollbackURLRequest url rollback request data.
+type rollbackURLRequest struct {
+	// Version - version of destination from url history.
+	Version int `json:"version"`
+}
+
+// updateURL handles a request to edit metadata of url, user must have manage permission to url.
+// Destination of url is changed only by its owner.
+func (a *AppHandler) updateURL(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	shortURL, err := a.parseCode(chi.URLParam(r, "shortURL"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	body, err := readBody(w, r.Body)
+	if err != nil {
+		return
+	}
+	var req updateURLRequest
+	if err = json.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	update := repository.URLMetaUpdate{
+		Title:         req.Title,
+		Description:   req.Description,
+		Tags:          req.Tags,
+		RedirectType:  req.RedirectType,
+		Passthrough:   req.Passthrough,
+		QueryConflict: req.QueryConflict,
+		Rules:         req.Rules,
+		Variants:      req.Variants,
+		MaxClicks:     req.MaxClicks,
+	}
+	if err = validateURLMeta(update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if req.Password != nil {
+		passwordHash, err := hashPassword(*req.Password)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		update.PasswordHash = &passwordHash
+	}
+	if req.OriginalURL != nil {
+		if !isAbsoluteURL(*req.OriginalURL) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		update.OriginalURL = req.OriginalURL
+	}
+	// destination and metadata are changed at once, so failed request changes nothing
+	info, err := a.repo.UpdateURLMeta(r.Context(), shortURL, userID, update)
+	if err != nil {
+		writeDestinationChangeError(w, err)
+		return
+	}
+	if req.OriginalURL != nil {
+		a.fetchPreview(shortURL, *req.OriginalURL)
+	}
+	info = a.withShortURL(info)
+	resp, err := json.Marshal(&info)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sendResponse(w, resp, http.StatusOK)
+}
+
+// urlHistory handles a request to get all destinations of url by its owner or user with stats permission.
+func (a *AppHandler) urlHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	shortURL, err := a.parseCode(chi.URLParam(r, "shortURL"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	history, err := a.repo.GetURLHistory(r.Context(), shortURL, userID)
+	if err != nil {
+		writeDestinationChangeError(w, err)
+		return
+	}
+	resp, err := json.Marshal(history)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sendResponse(w, resp, http.StatusOK)
+}
+
+// rollbackURL handles a request to re-point url owned by a specific user to destination from its history.
+func (a *AppHandler) rollbackURL(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	shortURL, err := a.parseCode(chi.URLParam(r, "shortURL"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	body, err := readBody(w, r.Body)
+	if err != nil {
+		return
+	}
+	var req rollbackURLRequest
+	if err = json.Unmarshal(body, &req); err != nil || req.Version <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err = a.repo.RollbackURL(r.Context(), shortURL, userID, req.Version)
+	if err != nil {
+		writeDestinationChangeError(w, err)
+		return
+	}
+	if fullURL, err := a.repo.GetFullURL(r.Context(), shortURL); err == nil {
+		a.fetchPreview(shortURL, fullURL)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeDestinationChangeError writes status of failed url destination change by repository error.
+func writeDestinationChangeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, &repository.URLNotFoundError{}), errors.Is(err, &repository.URLVersionNotFoundError{}):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, &repository.LongURLConflictError{}):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// isAbsoluteURL returns true if rawURL is absolute url with host.
+func isAbsoluteURL(rawURL string) bool {
+	parsed, err := url.ParseRequestURI(rawURL)
+	return err == nil && parsed.Scheme != "" && parsed.Host != ""
+}
+
+// validateURLMeta checks limits of not nil url metadata fields.
+func validateURLMeta(meta repository.URLMetaUpdate) error {
+	if meta.Title != nil && utf8.RuneCountInString(*meta.Title) > maxTitleLength {
+		return errors.New("title is too long")
+	}
+	if meta.Description != nil && utf8.RuneCountInString(*meta.Description) > maxDescriptionLength {
+		return errors.New("description is too long")
+	}
+	if meta.RedirectType != nil && !meta.RedirectType.IsValid() {
+		return errors.New("unsupported redirect type")
+	}
+	if meta.Passthrough != nil && !meta.Passthrough.IsValid() {
+		return errors.New("unsupported passthrough mode")
+	}
+	if meta.QueryConflict != nil && !meta.QueryConflict.IsValid() {
+		return errors.New("unsupported query conflict policy")
+	}
+	if meta.Rules != nil {
+		if err := validateRedirectRules(*meta.Rules); err != nil {
+			return err
+		}
+	}
+	if meta.Variants != nil {
+		if err := validateVariants(*meta.Variants); err != nil {
+			return err
+		}
+	}
+	if meta.MaxClicks != nil && *meta.MaxClicks < 0 {
+		return errors.New("negative max clicks")
+	}
+	if meta.Tags == nil {
+		return nil
+	}
+	if len(*meta.Tags) > maxTagsCount {
+		return errors.New("too many tags")
+	}
+	for _, tag := range *meta.Tags {
+		if len(tag) == 0 || utf8.RuneCountInString(tag) > maxTagLength {
+			return errors.New("bad tag")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	myMiddleware "go-axesthump-shortener/internal/app/middleware"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+)
+
+// Limits of utm templates.
+const (
+	maxUTMTemplateNameLength = 64
+	maxUTMParamLength        = 255
+)
+
+// listUTMTemplates handles a request to get all utm templates of a specific user.
+func (a *AppHandler) listUTMTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	templates, err := a.repo.GetUTMTemplates(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(templates) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	resp, err := json.Marshal(&templates)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sendResponse(w, resp, http.StatusOK)
+}
+
+// getUTMTemplate handles a request to get utm template of a specific user by name.
+func (a *AppHandler) getUTMTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	template, err := a.repo.GetUTMTemplate(r.Context(), userID, chi.URLParam(r, "name"))
+	if err != nil {
+		writeUTMTemplateError(w, err)
+		return
+	}
+	resp, err := json.Marshal(&template)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sendResponse(w, resp, http.StatusOK)
+}
+
+// saveUTMTemplate handles a request to create or replace utm template of a specific user.
+func (a *AppHandler) saveUTMTemplate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	name := chi.URLParam(r, "name")
+	body, err := readBody(w, r.Body)
+	if err != nil {
+		return
+	}
+	var params repository.UTMParams
+	if err = json.Unmarshal(body, &params); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(name) == 0 || utf8.RuneCountInString(name) > maxUTMTemplateNameLength ||
+		params.IsEmpty() || validateUTMParams(params) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	template := repository.UTMTemplate{Name: name, UTMParams: params}
+	if err = a.repo.SaveUTMTemplate(r.Context(), userID, template); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp, err := json.Marshal(&template)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sendResponse(w, resp, http.StatusOK)
+}
+
+// deleteUTMTemplate handles a request to delete utm template of a specific user.
+func (a *AppHandler) deleteUTMTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	if err := a.repo.DeleteUTMTemplate(r.Context(), userID, chi.URLParam(r, "name")); err != nil {
+		writeUTMTemplateError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// withUTM returns original url with utm params from request and user template added in its query.
+// Params from request replace params from template, both replace utm params of original url.
+func (a *AppHandler) withUTM(
+	ctx context.Context,
+	userID uint32,
+	params repository.UTMParams,
+	templateName string,
+	originalURL string,
+) (string, error) {
+	if len(templateName) != 0 {
+		template, err := a.repo.GetUTMTemplate(ctx, userID, templateName)
+		if err != nil {
+			return "", err
+		}
+		params = params.Merge(template.UTMParams)
+	}
+	if params.IsEmpty() {
+		return originalURL, nil
+	}
+	if err := validateUTMParams(params); err != nil {
+		return "", err
+	}
+	parsed, err := url.Parse(originalURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	for name, value := range params.Values() {
+		query.Set(name, value)
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+// validateUTMParams checks length of utm params.
+func validateUTMParams(params repository.UTMParams) error {
+	for _, value := range params.Values() {
+		if utf8.RuneCountInString(value) > maxUTMParamLength {
+			return errors.New("utm param is too long")
+		}
+	}
+	return nil
+}
+
+// writeUTMTemplateError writes status of failed utm template request by repository error.
+func writeUTMTemplateError(w http.ResponseWriter, err error) {
+	if errors.Is(err, &repository.UTMTemplateNotFoundError{}) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	myMiddleware "go-axesthump-shortener/internal/app/middleware"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAppHandler_utmTemplates(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		handler    http.HandlerFunc
+		userID     uint32
+		template   string
+		body       string
+		statusCode int
+	}{
+		{
+			name:       "empty templates list",
+			method:     http.MethodGet,
+			handler:    a.listUTMTemplates,
+			userID:     1,
+			statusCode: http.StatusNoContent,
+		},
+		{
+			name:       "save template",
+			method:     http.MethodPut,
+			handler:    a.saveUTMTemplate,
+			userID:     1,
+			template:   "newsletter",
+			body:       `{"utm_source":"mail","utm_medium":"email"}`,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "save template without params",
+			method:     http.MethodPut,
+			handler:    a.saveUTMTemplate,
+			userID:     1,
+			template:   "empty",
+			body:       `{}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "save template with long name",
+			method:     http.MethodPut,
+			handler:    a.saveUTMTemplate,
+			userID:     1,
+			template:   strings.Repeat("a", maxUTMTemplateNameLength+1),
+			body:       `{"utm_source":"mail"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "get template",
+			method:     http.MethodGet,
+			handler:    a.getUTMTemplate,
+			userID:     1,
+			template:   "newsletter",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "get template of another user",
+			method:     http.MethodGet,
+			handler:    a.getUTMTemplate,
+			userID:     2,
+			template:   "newsletter",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "create url with template",
+			method:     http.MethodPost,
+			handler:    a.addURLRest,
+			userID:     1,
+			body:       `{"url":"http://google.com/a?q=1&utm_source=site","utm_template":"newsletter","utm_campaign":"sale"}`,
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "create url with unknown template",
+			method:     http.MethodPost,
+			handler:    a.addURLRest,
+			userID:     2,
+			body:       `{"url":"http://google.com/b","utm_template":"newsletter"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "create url with too long param",
+			method:     http.MethodPost,
+			handler:    a.addURLRest,
+			userID:     1,
+			body:       `{"url":"http://google.com/c","utm_source":"` + strings.Repeat("a", maxUTMParamLength+1) + `"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "delete unknown template",
+			method:     http.MethodDelete,
+			handler:    a.deleteUTMTemplate,
+			userID:     1,
+			template:   "ads",
+			statusCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(context.TODO(), myMiddleware.UserIDKey, tt.userID),
+				tt.method,
+				"/api/user/utm-templates",
+				strings.NewReader(tt.body),
+			)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("name", tt.template)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+		})
+	}
+
+	urls := allURLs(t, repo, 1)
+	require.Len(t, urls, 1)
+	assert.Equal(
+		t,
+		"http://google.com/a?q=1&utm_campaign=sale&utm_medium=email&utm_source=mail",
+		urls[0].OriginalURL,
+	)
+
+	r, _ := http.NewRequestWithContext(
+		context.WithValue(context.TODO(), myMiddleware.UserIDKey, uint32(1)),
+		http.MethodGet,
+		"/api/user/utm-templates",
+		nil,
+	)
+	w := httptest.NewRecorder()
+	a.listUTMTemplates(w, r)
+	res := w.Result()
+	defer res.Body.Close()
+	var templates []repository.UTMTemplate
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&templates))
+	assert.Equal(
+		t,
+		[]repository.UTMTemplate{{Name: "newsletter", UTMParams: repository.UTMParams{Source: "mail", Medium: "email"}}},
+		templates,
+	)
+}
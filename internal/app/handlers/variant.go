@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"go-axesthump-shortener/internal/app/repository"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Limits and settings of url variants.
+const (
+	minVariantsCount    = 2
+	maxVariantsCount    = 10
+	maxVariantWeight    = 1000
+	variantCookiePrefix = "ab_"
+	variantCookieMaxAge = 30 * 24 * time.Hour
+)
+
+// chooseVariant returns index of variant for visitor and remembers it in cookie of url path.
+// Visitor with cookie of variant keeps it while variant exists and has weight.
+func chooseVariant(w http.ResponseWriter, r *http.Request, code string, variants []repository.URLVariant) int {
+	name := variantCookiePrefix + code
+	if cookie, err := r.Cookie(name); err == nil {
+		variant, err := strconv.Atoi(cookie.Value)
+		if err == nil && variant >= 0 && variant < len(variants) && variants[variant].Weight > 0 {
+			return variant
+		}
+	}
+	variant := weightedVariant(variants)
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    strconv.Itoa(variant),
+		Path:     "/" + code,
+		MaxAge:   int(variantCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return variant
+}
+
+// weightedVariant returns index of random variant with probability proportional to its weight.
+func weightedVariant(variants []repository.URLVariant) int {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return 0
+	}
+	n := rand.Intn(total)
+	for i, variant := range variants {
+		if n < variant.Weight {
+			return i
+		}
+		n -= variant.Weight
+	}
+	return len(variants) - 1
+}
+
+// validateVariants checks count, weights and destinations of variants, empty variants disable split.
+func validateVariants(variants []repository.URLVariant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	if len(variants) < minVariantsCount || len(variants) > maxVariantsCount {
+		return errors.New("bad variants count")
+	}
+	total := 0
+	for _, variant := range variants {
+		if !isAbsoluteURL(variant.URL) {
+			return errors.New("bad variant url")
+		}
+		if variant.Weight < 0 || variant.Weight > maxVariantWeight {
+			return errors.New("bad variant weight")
+		}
+		total += variant.Weight
+	}
+	if total == 0 {
+		return errors.New("variants without weight")
+	}
+	return nil
+}
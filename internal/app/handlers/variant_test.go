@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestAppHandler_getURLVariants(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+		passthrough:     repository.PassthroughNone,
+		queryConflict:   repository.QueryConflictKeep,
+	}
+	router := NewRouter(a)
+	ctx := context.TODO()
+	_, err := repo.CreateShortURL(ctx, "https://example.com/a", 1, repository.URLOptions{
+		Variants: []repository.URLVariant{
+			{URL: "https://example.com/a", Weight: 0},
+			{URL: "https://example.com/b", Weight: 1},
+			{URL: "https://example.com/c", Weight: 1},
+		},
+	})
+	require.NoError(t, err)
+
+	variantCookie := func(res *http.Response) *http.Cookie {
+		for _, cookie := range res.Cookies() {
+			if cookie.Name == "ab_0" {
+				return cookie
+			}
+		}
+		return nil
+	}
+	get := func(cookie *http.Cookie) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, "/0", nil)
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	// the first visit chooses variant with weight and remembers it
+	res := get(nil)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusTemporaryRedirect, res.StatusCode)
+	location := res.Header.Get("Location")
+	assert.Contains(t, []string{"https://example.com/b", "https://example.com/c"}, location)
+	cookie := variantCookie(res)
+	require.NotNil(t, cookie)
+	assert.Equal(t, "/0", cookie.Path)
+
+	// visitor with cookie keeps variant
+	for i := 0; i < 5; i++ {
+		res = get(cookie)
+		defer res.Body.Close()
+		assert.Equal(t, location, res.Header.Get("Location"))
+		assert.Nil(t, variantCookie(res))
+	}
+
+	// cookie of variant without weight is replaced
+	res = get(&http.Cookie{Name: "ab_0", Value: "0"})
+	defer res.Body.Close()
+	assert.NotEqual(t, "https://example.com/a", res.Header.Get("Location"))
+	assert.NotNil(t, variantCookie(res))
+
+	info, err := repo.GetURLInfo(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), info.Clicks)
+	assert.Equal(t, int64(0), info.Variants[0].Clicks)
+	assert.Equal(t, int64(7), info.Variants[1].Clicks+info.Variants[2].Clicks)
+}
+
+func Test_validateVariants(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []repository.URLVariant
+		wantErr  bool
+	}{
+		{
+			name: "split disabled",
+		},
+		{
+			name: "valid variants",
+			variants: []repository.URLVariant{
+				{URL: "https://example.com/a", Weight: 70},
+				{URL: "https://example.com/b", Weight: 30},
+			},
+		},
+		{
+			name:     "single variant",
+			variants: []repository.URLVariant{{URL: "https://example.com/a", Weight: 1}},
+			wantErr:  true,
+		},
+		{
+			name: "negative weight",
+			variants: []repository.URLVariant{
+				{URL: "https://example.com/a", Weight: -1},
+				{URL: "https://example.com/b", Weight: 2},
+			},
+			wantErr: true,
+		},
+		{
+			name: "without weights",
+			variants: []repository.URLVariant{
+				{URL: "https://example.com/a"},
+				{URL: "https://example.com/b"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative url",
+			variants: []repository.URLVariant{
+				{URL: "https://example.com/a", Weight: 1},
+				{URL: "/b", Weight: 1},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateVariants(tt.variants)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	myMiddleware "go-axesthump-shortener/internal/app/middleware"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/http"
+	"strconv"
+)
+
+type (
+	// createWorkspaceRequest workspace creation request data.
+	createWorkspaceRequest struct {
+		// Name - workspace name.
+		Name string `json:"name"`
+	}
+
+	// setWorkspaceMemberRequest workspace member request data.
+	setWorkspaceMemberRequest struct {
+		// Role - repository.RoleOwner, repository.RoleEditor or repository.RoleViewer.
+		Role repository.WorkspaceRole `json:"role"`
+	}
+)
+
+// createWorkspace handles a request to create workspace owned by a specific user.
+func (a *AppHandler) createWorkspace(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	body, err := readBody(w, r.Body)
+	if err != nil {
+		return
+	}
+	var req createWorkspaceRequest
+	if err = json.Unmarshal(body, &req); err != nil || len(req.Name) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	workspace, err := a.repo.CreateWorkspace(r.Context(), req.Name, userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp, err := json.Marshal(&workspace)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sendResponse(w, resp, http.StatusCreated)
+}
+
+// listWorkspaces handles a request to get all workspaces of a specific user.
+func (a *AppHandler) listWorkspaces(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	workspaces, err := a.repo.GetWorkspaces(r.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(workspaces) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	resp, err := json.Marshal(&workspaces)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sendResponse(w, resp, http.StatusOK)
+}
+
+// listWorkspaceMembers handles a request to get members of workspace, user must be its member.
+func (a *AppHandler) listWorkspaceMembers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	workspaceID, err := strconv.ParseInt(chi.URLParam(r, "workspaceID"), 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err = a.checkWorkspaceRole(w, r, workspaceID, userID); err != nil {
+		return
+	}
+	members, err := a.repo.GetWorkspaceMembers(r.Context(), workspaceID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	resp, err := json.Marshal(&members)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	sendResponse(w, resp, http.StatusOK)
+}
+
+// setWorkspaceMember handles a request to invite member in workspace or change his role.
+// Only workspace owner can do it, owner can't change his own role.
+func (a *AppHandler) setWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	workspaceID, memberID, err := parseWorkspaceMemberParams(r)
+	if err != nil || memberID == userID {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	body, err := readBody(w, r.Body)
+	if err != nil {
+		return
+	}
+	var req setWorkspaceMemberRequest
+	if err = json.Unmarshal(body, &req); err != nil || !req.Role.IsValid() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err = a.checkWorkspaceRole(w, r, workspaceID, userID, repository.RoleOwner); err != nil {
+		return
+	}
+	if err = a.repo.SetWorkspaceMember(r.Context(), workspaceID, memberID, req.Role); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeWorkspaceMember handles a request to remove member from workspace.
+// Owner removes any member except himself, other members can only leave workspace.
+func (a *AppHandler) removeWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(myMiddleware.UserIDKey).(uint32)
+	workspaceID, memberID, err := parseWorkspaceMemberParams(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	role, err := a.checkWorkspaceRole(w, r, workspaceID, userID)
+	if err != nil {
+		return
+	}
+	switch {
+	case role == repository.RoleOwner && memberID == userID:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	case role != repository.RoleOwner && memberID != userID:
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if err = a.repo.RemoveWorkspaceMember(r.Context(), workspaceID, memberID); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkWorkspaceRole returns role of user in workspace.
+// Writes 404 if user is not member and 403 if his role is not one of roles, roles can be empty for any member.
+func (a *AppHandler) checkWorkspaceRole(
+	w http.ResponseWriter,
+	r *http.Request,
+	workspaceID int64,
+	userID uint32,
+	roles ...repository.WorkspaceRole,
+) (repository.WorkspaceRole, error) {
+	role, err := a.repo.GetWorkspaceRole(r.Context(), workspaceID, userID)
+	if err != nil {
+		if errors.Is(err, &repository.WorkspaceNotFoundError{}) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return "", err
+	}
+	if len(roles) == 0 {
+		return role, nil
+	}
+	for _, allowed := range roles {
+		if role == allowed {
+			return role, nil
+		}
+	}
+	w.WriteHeader(http.StatusForbidden)
+	return "", errors.New("not enough rights in workspace")
+}
+
+// parseWorkspaceMemberParams returns workspace id and member id from request path.
+func parseWorkspaceMemberParams(r *http.Request) (int64, uint32, error) {
+	workspaceID, err := strconv.ParseInt(chi.URLParam(r, "workspaceID"), 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	memberID, err := strconv.ParseUint(chi.URLParam(r, "userID"), 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return workspaceID, uint32(memberID), nil
+}
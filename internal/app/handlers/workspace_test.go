@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/generator"
+	myMiddleware "go-axesthump-shortener/internal/app/middleware"
+	"go-axesthump-shortener/internal/app/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAppHandler_workspaces(t *testing.T) {
+	repo := repository.NewInMemoryStorage()
+	defer repo.Close()
+	a := &AppHandler{
+		repo:            repo,
+		userIDGenerator: generator.NewIDGenerator(0),
+		wg:              &sync.WaitGroup{},
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		handler     http.HandlerFunc
+		userID      uint32
+		workspaceID string
+		memberID    string
+		query       string
+		body        string
+		statusCode  int
+	}{
+		{
+			name:       "create workspace",
+			method:     http.MethodPost,
+			handler:    a.createWorkspace,
+			userID:     1,
+			body:       `{"name":"team"}`,
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "create workspace without name",
+			method:     http.MethodPost,
+			handler:    a.createWorkspace,
+			userID:     1,
+			body:       `{}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:        "invite viewer",
+			method:      http.MethodPut,
+			handler:     a.setWorkspaceMember,
+			userID:      1,
+			workspaceID: "1",
+			memberID:    "2",
+			body:        `{"role":"viewer"}`,
+			statusCode:  http.StatusNoContent,
+		},
+		{
+			name:        "invite with unknown role",
+			method:      http.MethodPut,
+			handler:     a.setWorkspaceMember,
+			userID:      1,
+			workspaceID: "1",
+			memberID:    "3",
+			body:        `{"role":"admin"}`,
+			statusCode:  http.StatusBadRequest,
+		},
+		{
+			name:        "invite by viewer",
+			method:      http.MethodPut,
+			handler:     a.setWorkspaceMember,
+			userID:      2,
+			workspaceID: "1",
+			memberID:    "3",
+			body:        `{"role":"editor"}`,
+			statusCode:  http.StatusForbidden,
+		},
+		{
+			name:        "invite in unknown workspace",
+			method:      http.MethodPut,
+			handler:     a.setWorkspaceMember,
+			userID:      1,
+			workspaceID: "2",
+			memberID:    "3",
+			body:        `{"role":"editor"}`,
+			statusCode:  http.StatusNotFound,
+		},
+		{
+			name:        "viewer creates url",
+			method:      http.MethodPost,
+			handler:     a.addURLRest,
+			userID:      2,
+			body:        `{"url":"http://google.com/team","workspace_id":1}`,
+			statusCode:  http.StatusForbidden,
+			workspaceID: "1",
+		},
+		{
+			name:       "owner creates url",
+			method:     http.MethodPost,
+			handler:    a.addURLRest,
+			userID:     1,
+			body:       `{"url":"http://google.com/team","workspace_id":1}`,
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "viewer creates urls batch",
+			method:     http.MethodPost,
+			handler:    a.addListURLRest,
+			userID:     2,
+			body:       `[{"correlation_id":"1","original_url":"http://google.com/batch","workspace_id":1}]`,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "owner creates urls batch",
+			method:     http.MethodPost,
+			handler:    a.addListURLRest,
+			userID:     1,
+			body:       `[{"correlation_id":"1","original_url":"http://google.com/batch","workspace_id":1}]`,
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "viewer creates text url",
+			method:     http.MethodPost,
+			handler:    a.addURL,
+			userID:     2,
+			query:      "?workspace_id=1",
+			body:       "http://google.com/text",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "text url with bad workspace",
+			method:     http.MethodPost,
+			handler:    a.addURL,
+			userID:     1,
+			query:      "?workspace_id=team",
+			body:       "http://google.com/text",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "owner creates text url",
+			method:     http.MethodPost,
+			handler:    a.addURL,
+			userID:     1,
+			query:      "?workspace_id=1",
+			body:       "http://google.com/text",
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:        "members list",
+			method:      http.MethodGet,
+			handler:     a.listWorkspaceMembers,
+			userID:      2,
+			workspaceID: "1",
+			statusCode:  http.StatusOK,
+		},
+		{
+			name:        "owner leaves workspace",
+			method:      http.MethodDelete,
+			handler:     a.removeWorkspaceMember,
+			userID:      1,
+			workspaceID: "1",
+			memberID:    "1",
+			statusCode:  http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := http.NewRequestWithContext(
+				context.WithValue(context.TODO(), myMiddleware.UserIDKey, tt.userID),
+				tt.method,
+				"/api/user/workspaces"+tt.query,
+				strings.NewReader(tt.body),
+			)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("workspaceID", tt.workspaceID)
+			rctx.URLParams.Add("userID", tt.memberID)
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+			res := w.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.statusCode, res.StatusCode)
+		})
+	}
+
+	// urls of workspace are visible for every member
+	urls := allURLs(t, repo, 2)
+	require.Len(t, urls, 3)
+	for _, url := range urls {
+		assert.Equal(t, repository.OwnershipWorkspace, url.Ownership)
+		assert.Equal(t, int64(1), url.WorkspaceID)
+	}
+
+	r, _ := http.NewRequestWithContext(
+		context.WithValue(context.TODO(), myMiddleware.UserIDKey, uint32(2)),
+		http.MethodGet,
+		"/api/user/workspaces",
+		nil,
+	)
+	w := httptest.NewRecorder()
+	a.listWorkspaces(w, r)
+	res := w.Result()
+	defer res.Body.Close()
+	var workspaces []repository.Workspace
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&workspaces))
+	assert.Equal(t, []repository.Workspace{{ID: 1, Name: "team", Role: repository.RoleViewer}}, workspaces)
+}
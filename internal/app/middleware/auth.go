@@ -8,6 +8,7 @@ import (
 	"encoding/hex"
 	"go-axesthump-shortener/internal/app/generator"
 	"log"
+	"math"
 	"net/http"
 )
 
@@ -17,34 +18,50 @@ type userKeyID string
 // UserIDKey key for store user id in context.
 const UserIDKey userKeyID = "id"
 
+// UserIDOverflowError an error that occurs when allocated user id doesn't fit 32 bits.
+type UserIDOverflowError struct {
+}
+
+// Error return UserIDOverflowError description.
+func (e *UserIDOverflowError) Error() string {
+	return "User id overflows 32 bits"
+}
+
+// secretKey - secret key for hash of cookies.
+var secretKey = []byte("secret_key")
+
 // authService contains data for auth.
 type authService struct {
-	// idGenerator - service for generation unique id.
-	idGenerator *generator.IDGenerator
+	// idGenerator - allocator of user ids.
+	idGenerator generator.Allocator
 	// secretKey - secret key for hash.
 	secretKey []byte
 }
 
 // NewAuthService returns new authService
-func NewAuthService(generator *generator.IDGenerator) *authService {
+func NewAuthService(generator generator.Allocator) *authService {
 	as := &authService{
 		idGenerator: generator,
-		secretKey:   []byte("secret_key"),
+		secretKey:   secretKey,
 	}
 	return as
 }
 
 // Auth middleware for auth. Returns handler with user id in context.
+// Returns 503 if new user id can't be allocated.
 func (a *authService) Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie("auth")
 		var userID uint32
 		var ok bool
-		if err != nil {
-			userID = a.generateCookie(w)
-		} else {
-			if ok, userID = a.validateCookie(cookie); !ok {
-				userID = a.generateCookie(w)
+		if err == nil {
+			ok, userID = a.validateCookie(cookie)
+		}
+		if !ok {
+			if userID, err = a.generateCookie(r.Context(), w); err != nil {
+				log.Printf("Cant allocate user id - %s\n", err)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
 			}
 		}
 		ctx := context.WithValue(r.Context(), UserIDKey, userID)
@@ -54,41 +71,69 @@ func (a *authService) Auth(next http.Handler) http.Handler {
 
 // generateCookie generates new cookie.
 // Hash new user id with secret key, then concatenate user id and hash and convert in to hex.
-func (a *authService) generateCookie(w http.ResponseWriter) uint32 {
-	newUserID := a.idGenerator.GetID()
+func (a *authService) generateCookie(ctx context.Context, w http.ResponseWriter) (uint32, error) {
+	newUserID, err := a.idGenerator.NextID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if newUserID > math.MaxUint32 {
+		return 0, &UserIDOverflowError{}
+	}
 	log.Printf("Generate new user id - %d\n", newUserID)
 	newUserIDBytes := make([]byte, 4)
 	binary.BigEndian.PutUint32(newUserIDBytes, uint32(newUserID))
-
-	h := hmac.New(sha256.New, a.secretKey)
-	h.Write(newUserIDBytes)
-	hash := h.Sum(nil)
-	newUserIDBytes = append(newUserIDBytes, hash...)
-	token := hex.EncodeToString(newUserIDBytes)
+	token := signToken(a.secretKey, newUserIDBytes)
 	newCookie := &http.Cookie{
 		Name:  "auth",
 		Value: token,
 	}
 	http.SetCookie(w, newCookie)
-	return uint32(newUserID)
+	return uint32(newUserID), nil
 }
 
 // validateCookie validates cookie.
 func (a *authService) validateCookie(cookie *http.Cookie) (bool, uint32) {
-	data, err := hex.DecodeString(cookie.Value)
-	if err != nil {
+	data, ok := verifyToken(a.secretKey, cookie.Value)
+	if !ok || len(data) != 4 {
 		return false, 0
 	}
-	userID := binary.BigEndian.Uint32(data[:4])
-	h := hmac.New(sha256.New, a.secretKey)
+	userID := binary.BigEndian.Uint32(data)
 	log.Printf("User id - %d\n", userID)
-	h.Write(data[:4])
-	hash := h.Sum(nil)
-	if !hmac.Equal(hash, data[4:]) {
-		return false, 0
-	}
-	if !a.idGenerator.IsCreatedID(userID) {
+	if !a.idGenerator.IsAllocated(int64(userID)) {
 		return false, 0
 	}
 	return true, userID
 }
+
+// SignToken returns token of payload signed by key.
+func SignToken(key []byte, payload []byte) string {
+	return signToken(key, payload)
+}
+
+// VerifyToken returns payload of token signed by key.
+// Returns false if token is malformed or its signature is wrong.
+func VerifyToken(key []byte, token string) ([]byte, bool) {
+	return verifyToken(key, token)
+}
+
+// signToken concatenates payload and its hmac hash and converts it in hex.
+func signToken(key []byte, payload []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(payload[:len(payload):len(payload)]))
+}
+
+// verifyToken returns payload of hex token if its hmac hash is valid.
+func verifyToken(key []byte, token string) ([]byte, bool) {
+	data, err := hex.DecodeString(token)
+	if err != nil || len(data) < sha256.Size {
+		return nil, false
+	}
+	payload := data[:len(data)-sha256.Size]
+	h := hmac.New(sha256.New, key)
+	h.Write(payload)
+	if !hmac.Equal(h.Sum(nil), data[len(payload):]) {
+		return nil, false
+	}
+	return payload, true
+}
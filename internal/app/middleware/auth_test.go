@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go-axesthump-shortener/internal/app/generator"
 	"net/http"
 	"net/http/httptest"
@@ -21,7 +23,8 @@ func Test_authService_generateCookie(t *testing.T) {
 	authService := NewAuthService(gen)
 	writer := httptest.NewRecorder()
 	expected := "00000000013fd79b1f129e8734c9c4d34828a3cc4b170e964910a7d662ea3d63ac387a56"
-	id := authService.generateCookie(writer)
+	id, err := authService.generateCookie(context.TODO(), writer)
+	require.NoError(t, err)
 
 	assert.Equal(t, id, uint32(0))
 	assert.Equal(t, expected, writer.Header().Get("Set-Cookie")[5:])
@@ -124,3 +127,18 @@ func Test_authService_Auth(t *testing.T) {
 		})
 	}
 }
+
+func TestSignToken(t *testing.T) {
+	key := []byte("key")
+	token := SignToken(key, []byte("payload"))
+	payload, ok := VerifyToken(key, token)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("payload"), payload)
+
+	_, ok = VerifyToken(key, "00"+token[2:])
+	assert.False(t, ok)
+	_, ok = VerifyToken(key, "not hex")
+	assert.False(t, ok)
+	_, ok = VerifyToken(key, "0102")
+	assert.False(t, ok)
+}
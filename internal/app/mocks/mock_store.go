@@ -35,6 +35,62 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddClick mocks base method.
+func (m *MockRepository) AddClick(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddClick", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddClick indicates an expected call of AddClick.
+func (mr *MockRepositoryMockRecorder) AddClick(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddClick", reflect.TypeOf((*MockRepository)(nil).AddClick), arg0, arg1)
+}
+
+// AddDomain mocks base method.
+func (m *MockRepository) AddDomain(arg0 context.Context, arg1 repository.Domain) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDomain", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDomain indicates an expected call of AddDomain.
+func (mr *MockRepositoryMockRecorder) AddDomain(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDomain", reflect.TypeOf((*MockRepository)(nil).AddDomain), arg0, arg1)
+}
+
+// AddVariantClick mocks base method.
+func (m *MockRepository) AddVariantClick(arg0 context.Context, arg1 int64, arg2 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddVariantClick", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddVariantClick indicates an expected call of AddVariantClick.
+func (mr *MockRepositoryMockRecorder) AddVariantClick(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddVariantClick", reflect.TypeOf((*MockRepository)(nil).AddVariantClick), arg0, arg1, arg2)
+}
+
+// ChangeURLDestination mocks base method.
+func (m *MockRepository) ChangeURLDestination(arg0 context.Context, arg1 int64, arg2 uint32, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeURLDestination", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangeURLDestination indicates an expected call of ChangeURLDestination.
+func (mr *MockRepositoryMockRecorder) ChangeURLDestination(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeURLDestination", reflect.TypeOf((*MockRepository)(nil).ChangeURLDestination), arg0, arg1, arg2, arg3)
+}
+
 // Close mocks base method.
 func (m *MockRepository) Close() error {
 	m.ctrl.T.Helper()
@@ -49,11 +105,25 @@ func (mr *MockRepositoryMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockRepository)(nil).Close))
 }
 
+// ConsumeClick mocks base method.
+func (m *MockRepository) ConsumeClick(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeClick", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConsumeClick indicates an expected call of ConsumeClick.
+func (mr *MockRepositoryMockRecorder) ConsumeClick(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeClick", reflect.TypeOf((*MockRepository)(nil).ConsumeClick), arg0, arg1)
+}
+
 // CreateShortURL mocks base method.
-func (m *MockRepository) CreateShortURL(arg0 context.Context, arg1, arg2 string, arg3 uint32) (string, error) {
+func (m *MockRepository) CreateShortURL(arg0 context.Context, arg1 string, arg2 uint32, arg3 repository.URLOptions) (int64, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "CreateShortURL", arg0, arg1, arg2, arg3)
-	ret0, _ := ret[0].(string)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -65,24 +135,39 @@ func (mr *MockRepositoryMockRecorder) CreateShortURL(arg0, arg1, arg2, arg3 inte
 }
 
 // CreateShortURLs mocks base method.
-func (m *MockRepository) CreateShortURLs(arg0 context.Context, arg1 string, arg2 []repository.URLWithID, arg3 uint32) ([]repository.URLWithID, error) {
+func (m *MockRepository) CreateShortURLs(arg0 context.Context, arg1 []repository.URLWithID, arg2 uint32) ([]repository.URLWithID, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateShortURLs", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "CreateShortURLs", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]repository.URLWithID)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateShortURLs indicates an expected call of CreateShortURLs.
-func (mr *MockRepositoryMockRecorder) CreateShortURLs(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) CreateShortURLs(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateShortURLs", reflect.TypeOf((*MockRepository)(nil).CreateShortURLs), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateShortURLs", reflect.TypeOf((*MockRepository)(nil).CreateShortURLs), arg0, arg1, arg2)
+}
+
+// CreateWorkspace mocks base method.
+func (m *MockRepository) CreateWorkspace(arg0 context.Context, arg1 string, arg2 uint32) (repository.Workspace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWorkspace", arg0, arg1, arg2)
+	ret0, _ := ret[0].(repository.Workspace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateWorkspace indicates an expected call of CreateWorkspace.
+func (mr *MockRepositoryMockRecorder) CreateWorkspace(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWorkspace", reflect.TypeOf((*MockRepository)(nil).CreateWorkspace), arg0, arg1, arg2)
 }
 
 // DeleteURLs mocks base method.
 func (m *MockRepository) DeleteURLs(arg0 []repository.DeleteURL) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "deleteURLs", arg0)
+	ret := m.ctrl.Call(m, "DeleteURLs", arg0)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
@@ -90,15 +175,30 @@ func (m *MockRepository) DeleteURLs(arg0 []repository.DeleteURL) error {
 // DeleteURLs indicates an expected call of DeleteURLs.
 func (mr *MockRepositoryMockRecorder) DeleteURLs(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "deleteURLs", reflect.TypeOf((*MockRepository)(nil).DeleteURLs), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteURLs", reflect.TypeOf((*MockRepository)(nil).DeleteURLs), arg0)
+}
+
+// DeleteUTMTemplate mocks base method.
+func (m *MockRepository) DeleteUTMTemplate(arg0 context.Context, arg1 uint32, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUTMTemplate", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUTMTemplate indicates an expected call of DeleteUTMTemplate.
+func (mr *MockRepositoryMockRecorder) DeleteUTMTemplate(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUTMTemplate", reflect.TypeOf((*MockRepository)(nil).DeleteUTMTemplate), arg0, arg1, arg2)
 }
 
 // GetAllURLs mocks base method.
-func (m *MockRepository) GetAllURLs(arg0 context.Context, arg1 string, arg2 uint32) []repository.URLInfo {
+func (m *MockRepository) GetAllURLs(arg0 context.Context, arg1 uint32, arg2 repository.URLFilter) (repository.URLPage, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetAllURLs", arg0, arg1, arg2)
-	ret0, _ := ret[0].([]repository.URLInfo)
-	return ret0
+	ret0, _ := ret[0].(repository.URLPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // GetAllURLs indicates an expected call of GetAllURLs.
@@ -107,6 +207,36 @@ func (mr *MockRepositoryMockRecorder) GetAllURLs(arg0, arg1, arg2 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllURLs", reflect.TypeOf((*MockRepository)(nil).GetAllURLs), arg0, arg1, arg2)
 }
 
+// GetDomain mocks base method.
+func (m *MockRepository) GetDomain(arg0 context.Context, arg1 string) (repository.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDomain", arg0, arg1)
+	ret0, _ := ret[0].(repository.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDomain indicates an expected call of GetDomain.
+func (mr *MockRepositoryMockRecorder) GetDomain(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDomain", reflect.TypeOf((*MockRepository)(nil).GetDomain), arg0, arg1)
+}
+
+// GetDomains mocks base method.
+func (m *MockRepository) GetDomains(arg0 context.Context, arg1 uint32) ([]repository.Domain, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDomains", arg0, arg1)
+	ret0, _ := ret[0].([]repository.Domain)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDomains indicates an expected call of GetDomains.
+func (mr *MockRepositoryMockRecorder) GetDomains(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDomains", reflect.TypeOf((*MockRepository)(nil).GetDomains), arg0, arg1)
+}
+
 // GetFullURL mocks base method.
 func (m *MockRepository) GetFullURL(arg0 context.Context, arg1 int64) (string, error) {
 	m.ctrl.T.Helper()
@@ -121,3 +251,264 @@ func (mr *MockRepositoryMockRecorder) GetFullURL(arg0, arg1 interface{}) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFullURL", reflect.TypeOf((*MockRepository)(nil).GetFullURL), arg0, arg1)
 }
+
+// GetRedirect mocks base method.
+func (m *MockRepository) GetRedirect(arg0 context.Context, arg1 int64) (repository.Redirect, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRedirect", arg0, arg1)
+	ret0, _ := ret[0].(repository.Redirect)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRedirect indicates an expected call of GetRedirect.
+func (mr *MockRepositoryMockRecorder) GetRedirect(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRedirect", reflect.TypeOf((*MockRepository)(nil).GetRedirect), arg0, arg1)
+}
+
+// GetURLHistory mocks base method.
+func (m *MockRepository) GetURLHistory(arg0 context.Context, arg1 int64, arg2 uint32) ([]repository.URLVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLHistory", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]repository.URLVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURLHistory indicates an expected call of GetURLHistory.
+func (mr *MockRepositoryMockRecorder) GetURLHistory(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLHistory", reflect.TypeOf((*MockRepository)(nil).GetURLHistory), arg0, arg1, arg2)
+}
+
+// GetURLInfo mocks base method.
+func (m *MockRepository) GetURLInfo(arg0 context.Context, arg1 int64) (repository.URLInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetURLInfo", arg0, arg1)
+	ret0, _ := ret[0].(repository.URLInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetURLInfo indicates an expected call of GetURLInfo.
+func (mr *MockRepositoryMockRecorder) GetURLInfo(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetURLInfo", reflect.TypeOf((*MockRepository)(nil).GetURLInfo), arg0, arg1)
+}
+
+// GetUTMTemplate mocks base method.
+func (m *MockRepository) GetUTMTemplate(arg0 context.Context, arg1 uint32, arg2 string) (repository.UTMTemplate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUTMTemplate", arg0, arg1, arg2)
+	ret0, _ := ret[0].(repository.UTMTemplate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUTMTemplate indicates an expected call of GetUTMTemplate.
+func (mr *MockRepositoryMockRecorder) GetUTMTemplate(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUTMTemplate", reflect.TypeOf((*MockRepository)(nil).GetUTMTemplate), arg0, arg1, arg2)
+}
+
+// GetUTMTemplates mocks base method.
+func (m *MockRepository) GetUTMTemplates(arg0 context.Context, arg1 uint32) ([]repository.UTMTemplate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUTMTemplates", arg0, arg1)
+	ret0, _ := ret[0].([]repository.UTMTemplate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUTMTemplates indicates an expected call of GetUTMTemplates.
+func (mr *MockRepositoryMockRecorder) GetUTMTemplates(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUTMTemplates", reflect.TypeOf((*MockRepository)(nil).GetUTMTemplates), arg0, arg1)
+}
+
+// GetWorkspaceMembers mocks base method.
+func (m *MockRepository) GetWorkspaceMembers(arg0 context.Context, arg1 int64) ([]repository.WorkspaceMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaceMembers", arg0, arg1)
+	ret0, _ := ret[0].([]repository.WorkspaceMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaceMembers indicates an expected call of GetWorkspaceMembers.
+func (mr *MockRepositoryMockRecorder) GetWorkspaceMembers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceMembers", reflect.TypeOf((*MockRepository)(nil).GetWorkspaceMembers), arg0, arg1)
+}
+
+// GetWorkspaceRole mocks base method.
+func (m *MockRepository) GetWorkspaceRole(arg0 context.Context, arg1 int64, arg2 uint32) (repository.WorkspaceRole, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaceRole", arg0, arg1, arg2)
+	ret0, _ := ret[0].(repository.WorkspaceRole)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaceRole indicates an expected call of GetWorkspaceRole.
+func (mr *MockRepositoryMockRecorder) GetWorkspaceRole(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaceRole", reflect.TypeOf((*MockRepository)(nil).GetWorkspaceRole), arg0, arg1, arg2)
+}
+
+// GetWorkspaces mocks base method.
+func (m *MockRepository) GetWorkspaces(arg0 context.Context, arg1 uint32) ([]repository.Workspace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorkspaces", arg0, arg1)
+	ret0, _ := ret[0].([]repository.Workspace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWorkspaces indicates an expected call of GetWorkspaces.
+func (mr *MockRepositoryMockRecorder) GetWorkspaces(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorkspaces", reflect.TypeOf((*MockRepository)(nil).GetWorkspaces), arg0, arg1)
+}
+
+// RemoveDomain mocks base method.
+func (m *MockRepository) RemoveDomain(arg0 context.Context, arg1 string, arg2 uint32) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveDomain", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveDomain indicates an expected call of RemoveDomain.
+func (mr *MockRepositoryMockRecorder) RemoveDomain(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveDomain", reflect.TypeOf((*MockRepository)(nil).RemoveDomain), arg0, arg1, arg2)
+}
+
+// RemoveWorkspaceMember mocks base method.
+func (m *MockRepository) RemoveWorkspaceMember(arg0 context.Context, arg1 int64, arg2 uint32) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveWorkspaceMember", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveWorkspaceMember indicates an expected call of RemoveWorkspaceMember.
+func (mr *MockRepositoryMockRecorder) RemoveWorkspaceMember(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveWorkspaceMember", reflect.TypeOf((*MockRepository)(nil).RemoveWorkspaceMember), arg0, arg1, arg2)
+}
+
+// RollbackURL mocks base method.
+func (m *MockRepository) RollbackURL(arg0 context.Context, arg1 int64, arg2 uint32, arg3 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RollbackURL", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RollbackURL indicates an expected call of RollbackURL.
+func (mr *MockRepositoryMockRecorder) RollbackURL(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollbackURL", reflect.TypeOf((*MockRepository)(nil).RollbackURL), arg0, arg1, arg2, arg3)
+}
+
+// SaveUTMTemplate mocks base method.
+func (m *MockRepository) SaveUTMTemplate(arg0 context.Context, arg1 uint32, arg2 repository.UTMTemplate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveUTMTemplate", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveUTMTemplate indicates an expected call of SaveUTMTemplate.
+func (mr *MockRepositoryMockRecorder) SaveUTMTemplate(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveUTMTemplate", reflect.TypeOf((*MockRepository)(nil).SaveUTMTemplate), arg0, arg1, arg2)
+}
+
+// SetURLPreview mocks base method.
+func (m *MockRepository) SetURLPreview(arg0 context.Context, arg1 int64, arg2 string, arg3 repository.URLPreview) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetURLPreview", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetURLPreview indicates an expected call of SetURLPreview.
+func (mr *MockRepositoryMockRecorder) SetURLPreview(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetURLPreview", reflect.TypeOf((*MockRepository)(nil).SetURLPreview), arg0, arg1, arg2, arg3)
+}
+
+// SetWorkspaceMember mocks base method.
+func (m *MockRepository) SetWorkspaceMember(arg0 context.Context, arg1 int64, arg2 uint32, arg3 repository.WorkspaceRole) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetWorkspaceMember", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetWorkspaceMember indicates an expected call of SetWorkspaceMember.
+func (mr *MockRepositoryMockRecorder) SetWorkspaceMember(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetWorkspaceMember", reflect.TypeOf((*MockRepository)(nil).SetWorkspaceMember), arg0, arg1, arg2, arg3)
+}
+
+// ShareURL mocks base method.
+func (m *MockRepository) ShareURL(arg0 context.Context, arg1 int64, arg2, arg3 uint32, arg4 repository.Permission) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShareURL", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ShareURL indicates an expected call of ShareURL.
+func (mr *MockRepositoryMockRecorder) ShareURL(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShareURL", reflect.TypeOf((*MockRepository)(nil).ShareURL), arg0, arg1, arg2, arg3, arg4)
+}
+
+// TransferURL mocks base method.
+func (m *MockRepository) TransferURL(arg0 context.Context, arg1 int64, arg2, arg3 uint32) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferURL", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TransferURL indicates an expected call of TransferURL.
+func (mr *MockRepositoryMockRecorder) TransferURL(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferURL", reflect.TypeOf((*MockRepository)(nil).TransferURL), arg0, arg1, arg2, arg3)
+}
+
+// UnshareURL mocks base method.
+func (m *MockRepository) UnshareURL(arg0 context.Context, arg1 int64, arg2, arg3 uint32) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnshareURL", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnshareURL indicates an expected call of UnshareURL.
+func (mr *MockRepositoryMockRecorder) UnshareURL(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnshareURL", reflect.TypeOf((*MockRepository)(nil).UnshareURL), arg0, arg1, arg2, arg3)
+}
+
+// UpdateURLMeta mocks base method.
+func (m *MockRepository) UpdateURLMeta(arg0 context.Context, arg1 int64, arg2 uint32, arg3 repository.URLMetaUpdate) (repository.URLInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateURLMeta", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(repository.URLInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateURLMeta indicates an expected call of UpdateURLMeta.
+func (mr *MockRepositoryMockRecorder) UpdateURLMeta(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateURLMeta", reflect.TypeOf((*MockRepository)(nil).UpdateURLMeta), arg0, arg1, arg2, arg3)
+}
@@ -0,0 +1,225 @@
+// Package qrcode define rendering of QR codes in PNG and SVG formats.
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/boombuler/barcode/qr"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format of rendered QR code.
+type Format string
+
+// Formats of QR code.
+const (
+	FormatPNG Format = "png"
+	FormatSVG Format = "svg"
+)
+
+// Level error correction level of QR code.
+type Level string
+
+// Error correction levels, share of code which can be restored.
+const (
+	LevelLow     Level = "L" // 7%
+	LevelMedium  Level = "M" // 15%
+	LevelQuartil Level = "Q" // 25%
+	LevelHigh    Level = "H" // 30%
+)
+
+// Limits and defaults of Options.
+const (
+	DefaultSize   = 256
+	MinSize       = 32
+	MaxSize       = 2048
+	DefaultMargin = 4
+	MaxMargin     = 16
+)
+
+// Options contains settings of rendered QR code.
+type Options struct {
+	// Format - FormatPNG or FormatSVG.
+	Format Format
+	// Size - width and height of image in pixels.
+	Size int
+	// Margin - width of quiet zone around code in modules.
+	Margin int
+	// Level - error correction level.
+	Level Level
+	// Foreground - color of dark modules.
+	Foreground color.RGBA
+	// Background - color of light modules and margin.
+	Background color.RGBA
+}
+
+// DefaultOptions returns options of black on white PNG code with medium error correction.
+func DefaultOptions() Options {
+	return Options{
+		Format:     FormatPNG,
+		Size:       DefaultSize,
+		Margin:     DefaultMargin,
+		Level:      LevelMedium,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+// Validate checks that options are within limits.
+func (o Options) Validate() error {
+	if o.Format != FormatPNG && o.Format != FormatSVG {
+		return fmt.Errorf("unknown format %q", o.Format)
+	}
+	if o.Size < MinSize || o.Size > MaxSize {
+		return fmt.Errorf("size must be from %d to %d", MinSize, MaxSize)
+	}
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return fmt.Errorf("margin must be from 0 to %d", MaxMargin)
+	}
+	if _, err := o.Level.qrLevel(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ContentType returns media type of rendered code.
+func (o Options) ContentType() string {
+	if o.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// qrLevel returns error correction level of encoder.
+func (l Level) qrLevel() (qr.ErrorCorrectionLevel, error) {
+	switch l {
+	case LevelLow:
+		return qr.L, nil
+	case LevelMedium:
+		return qr.M, nil
+	case LevelQuartil:
+		return qr.Q, nil
+	case LevelHigh:
+		return qr.H, nil
+	}
+	return 0, fmt.Errorf("unknown error correction level %q", l)
+}
+
+// ParseColor returns color from hex string RRGGBB or #RRGGBB.
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return color.RGBA{}, errors.New("color must be RRGGBB")
+	}
+	value, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 0xff}, nil
+}
+
+// Render writes QR code of content in w.
+func Render(w io.Writer, content string, opts Options) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	modules, err := encode(content, opts.Level)
+	if err != nil {
+		return err
+	}
+	if opts.Format == FormatSVG {
+		return renderSVG(w, modules, opts)
+	}
+	return renderPNG(w, modules, opts)
+}
+
+// encode returns matrix of code modules, true is dark module.
+func encode(content string, level Level) ([][]bool, error) {
+	qrLevel, err := level.qrLevel()
+	if err != nil {
+		return nil, err
+	}
+	code, err := qr.Encode(content, qrLevel, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+	bounds := code.Bounds()
+	modules := make([][]bool, bounds.Dy())
+	for y := range modules {
+		modules[y] = make([]bool, bounds.Dx())
+		for x := range modules[y] {
+			r, _, _, _ := code.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			modules[y][x] = r == 0
+		}
+	}
+	return modules, nil
+}
+
+// renderPNG writes code as PNG image of opts.Size pixels.
+// Modules are scaled to whole pixels, rest of size is added to margin.
+func renderPNG(w io.Writer, modules [][]bool, opts Options) error {
+	total := len(modules) + 2*opts.Margin
+	scale := opts.Size / total
+	if scale == 0 {
+		return fmt.Errorf("size %d is too small for code with %d modules", opts.Size, total)
+	}
+	offset := (opts.Size - len(modules)*scale) / 2
+	img := image.NewPaletted(
+		image.Rect(0, 0, opts.Size, opts.Size),
+		color.Palette{opts.Background, opts.Foreground},
+	)
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(offset+x*scale+dx, offset+y*scale+dy, 1)
+				}
+			}
+		}
+	}
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	return encoder.Encode(w, img)
+}
+
+// renderSVG writes code as SVG image of opts.Size pixels, dark modules are drawn by one path.
+func renderSVG(w io.Writer, modules [][]bool, opts Options) error {
+	total := len(modules) + 2*opts.Margin
+	var buf bytes.Buffer
+	fmt.Fprintf(
+		&buf,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, total, total,
+	)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, total, total, hexColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, hexColor(opts.Foreground))
+	for y, row := range modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			// neighbour dark modules of row are drawn by one rectangle
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start+opts.Margin, y+opts.Margin, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// hexColor returns color in #rrggbb format.
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
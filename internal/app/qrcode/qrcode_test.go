@@ -0,0 +1,95 @@
+package qrcode
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	red := color.RGBA{R: 0xff, A: 0xff}
+	tests := []struct {
+		name    string
+		opts    func(o *Options)
+		wantErr bool
+	}{
+		{
+			name: "default png",
+			opts: func(o *Options) {},
+		},
+		{
+			name: "colored png without margin",
+			opts: func(o *Options) {
+				o.Margin = 0
+				o.Foreground = red
+				o.Level = LevelHigh
+			},
+		},
+		{
+			name: "svg",
+			opts: func(o *Options) { o.Format = FormatSVG },
+		},
+		{
+			name:    "unknown format",
+			opts:    func(o *Options) { o.Format = "gif" },
+			wantErr: true,
+		},
+		{
+			name:    "too small size",
+			opts:    func(o *Options) { o.Size = MinSize - 1 },
+			wantErr: true,
+		},
+		{
+			name:    "unknown level",
+			opts:    func(o *Options) { o.Level = "X" },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			tt.opts(&opts)
+			var buf bytes.Buffer
+			err := Render(&buf, "http://localhost:8080/12", opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if opts.Format == FormatSVG {
+				svg := buf.String()
+				assert.True(t, strings.HasPrefix(svg, "<svg "))
+				assert.Contains(t, svg, `width="256" height="256"`)
+				assert.Contains(t, svg, `fill="#ffffff"`)
+				assert.Contains(t, svg, `fill="#000000"`)
+				return
+			}
+			img, err := png.Decode(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, opts.Size, img.Bounds().Dx())
+			assert.Equal(t, opts.Size, img.Bounds().Dy())
+			colors := make(map[color.RGBA]bool)
+			for y := 0; y < opts.Size; y++ {
+				for x := 0; x < opts.Size; x++ {
+					r, g, b, a := img.At(x, y).RGBA()
+					colors[color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)}] = true
+				}
+			}
+			assert.Equal(t, map[color.RGBA]bool{opts.Foreground: true, opts.Background: true}, colors)
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#1a2B3c")
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}, c)
+	_, err = ParseColor("fff")
+	assert.Error(t, err)
+	_, err = ParseColor("gggggg")
+	assert.Error(t, err)
+}
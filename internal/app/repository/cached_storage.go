@@ -0,0 +1,410 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"go-axesthump-shortener/internal/app/cache"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Counters of lookups of redirect data in cache, published by expvar as "url_cache" at /debug/vars.
+var (
+	urlCacheHits   = new(expvar.Int)
+	urlCacheMisses = new(expvar.Int)
+)
+
+func init() {
+	stats := expvar.NewMap("url_cache")
+	stats.Set("hits", urlCacheHits)
+	stats.Set("misses", urlCacheMisses)
+}
+
+// cachedRedirect redirect data of url stored in cache.
+// Redirect is nil for deleted and unknown urls.
+type cachedRedirect struct {
+	Redirect *Redirect `json:"redirect,omitempty"`
+	Deleted  bool      `json:"deleted,omitempty"`
+}
+
+// result returns redirect data or error of repository which was cached.
+func (c cachedRedirect) result() (Redirect, error) {
+	switch {
+	case c.Redirect != nil:
+		return *c.Redirect, nil
+	case c.Deleted:
+		return Redirect{}, &DeletedURLError{}
+	default:
+		return Redirect{}, &URLNotFoundError{}
+	}
+}
+
+// cachedDomain custom domain stored in cache, Domain is nil for not registered domain.
+type cachedDomain struct {
+	Domain *Domain `json:"domain,omitempty"`
+}
+
+// ClickCount count of redirects by url which are not saved in repository yet.
+type ClickCount struct {
+	Code int64
+	// Clicks - count of redirects by url, redirects to variants are counted too.
+	Clicks int64
+	// Variants - count of redirects to variant by index of variant.
+	Variants map[int]int64
+}
+
+// ClickBatcher repository which saves counted redirects of many urls at once.
+type ClickBatcher interface {
+	// AddClicks adds counted redirects to urls, unknown urls are skipped.
+	AddClicks(ctx context.Context, counts []ClickCount) error
+}
+
+// CachedStorage is read-through cache of redirect data in front of repository.
+// GetFullURL and GetRedirect are served from cache, deleted urls are cached as well as existing ones,
+// unknown codes are cached for negativeTTL. GetDomain is served from cache too, so host of every redirect
+// is not checked in repository, not registered domains are cached for negativeTTL.
+// Cache of url or domain is invalidated after every change of it by CachedStorage,
+// so every replica of service must use it. Value read before concurrent change can be cached, it stays until ttl is over.
+// Invalidation reaches other replicas only by shared cache like Redis, in-process cache is for single replica only.
+// Clicks are counted in memory and saved every clicksFlushInterval and on Close if repository is ClickBatcher.
+// Errors of cache are logged and requests go to repository.
+type CachedStorage struct {
+	Repository
+	cache       cache.Cache
+	ttl         time.Duration
+	negativeTTL time.Duration
+	// batcher - repository which saves counted clicks, nil if clicks are passed to repository at once.
+	batcher   ClickBatcher
+	mx        sync.Mutex
+	clicks    map[int64]*ClickCount
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewCachedStorage returns new CachedStorage of repo which caches urls for ttl and unknown codes for negativeTTL.
+// Not positive negativeTTL disables negative caching.
+func NewCachedStorage(repo Repository, store cache.Cache, ttl time.Duration, negativeTTL time.Duration) *CachedStorage {
+	c := &CachedStorage{
+		Repository:  repo,
+		cache:       store,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		clicks:      make(map[int64]*ClickCount),
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	if batcher, ok := repo.(ClickBatcher); ok {
+		c.batcher = batcher
+		go c.flushClicksLoop()
+	} else {
+		close(c.stopped)
+	}
+	return c
+}
+
+// GetFullURL returns full url by short url from cache or repository.
+func (c *CachedStorage) GetFullURL(ctx context.Context, shortURL int64) (string, error) {
+	redirect, err := c.GetRedirect(ctx, shortURL)
+	if err != nil {
+		return "", err
+	}
+	return redirect.OriginalURL, nil
+}
+
+// GetRedirect returns data for redirect by not deleted short url from cache or repository.
+func (c *CachedStorage) GetRedirect(ctx context.Context, shortURL int64) (Redirect, error) {
+	key := urlCacheKey(shortURL)
+	data, ok, err := c.cache.Get(ctx, key)
+	if err != nil {
+		log.Printf("Cant get url %d from cache: %s\n", shortURL, err)
+	}
+	if ok {
+		var cached cachedRedirect
+		if err = json.Unmarshal(data, &cached); err == nil {
+			urlCacheHits.Add(1)
+			return cached.result()
+		}
+		log.Printf("Bad cached url %d: %s\n", shortURL, err)
+	}
+	urlCacheMisses.Add(1)
+
+	redirect, err := c.Repository.GetRedirect(ctx, shortURL)
+	var cached cachedRedirect
+	ttl := c.ttl
+	switch {
+	case err == nil:
+		cached.Redirect = &redirect
+	case errors.Is(err, &DeletedURLError{}):
+		cached.Deleted = true
+	case errors.Is(err, &URLNotFoundError{}):
+		ttl = c.negativeTTL
+	default:
+		return redirect, err
+	}
+	if ttl > 0 {
+		if data, err := json.Marshal(cached); err == nil {
+			if err = c.cache.Set(ctx, key, data, ttl); err != nil {
+				log.Printf("Cant cache url %d: %s\n", shortURL, err)
+			}
+		}
+	}
+	return redirect, err
+}
+
+// GetDomain returns registered domain by name from cache or repository.
+func (c *CachedStorage) GetDomain(ctx context.Context, name string) (Domain, error) {
+	key := domainCacheKey(name)
+	data, ok, err := c.cache.Get(ctx, key)
+	if err != nil {
+		log.Printf("Cant get domain %s from cache: %s\n", name, err)
+	}
+	if ok {
+		var cached cachedDomain
+		if err = json.Unmarshal(data, &cached); err == nil {
+			if cached.Domain == nil {
+				return Domain{}, &DomainNotFoundError{}
+			}
+			return *cached.Domain, nil
+		}
+		log.Printf("Bad cached domain %s: %s\n", name, err)
+	}
+
+	domain, err := c.Repository.GetDomain(ctx, name)
+	var cached cachedDomain
+	ttl := c.ttl
+	switch {
+	case err == nil:
+		cached.Domain = &domain
+	case errors.Is(err, &DomainNotFoundError{}):
+		ttl = c.negativeTTL
+	default:
+		return domain, err
+	}
+	if ttl > 0 {
+		if data, err := json.Marshal(cached); err == nil {
+			if err = c.cache.Set(ctx, key, data, ttl); err != nil {
+				log.Printf("Cant cache domain %s: %s\n", name, err)
+			}
+		}
+	}
+	return domain, err
+}
+
+// AddDomain registers domain and removes cached not registered domain.
+func (c *CachedStorage) AddDomain(ctx context.Context, domain Domain) error {
+	defer c.invalidateDomain(domain.Name)
+	return c.Repository.AddDomain(ctx, domain)
+}
+
+// RemoveDomain removes domain of owner and removes it from cache.
+func (c *CachedStorage) RemoveDomain(ctx context.Context, name string, ownerID uint32) error {
+	defer c.invalidateDomain(name)
+	return c.Repository.RemoveDomain(ctx, name, ownerID)
+}
+
+// CreateShortURL creates short url and removes cached unknown code of it.
+func (c *CachedStorage) CreateShortURL(
+	ctx context.Context,
+	originalURL string,
+	userID uint32,
+	opts URLOptions,
+) (int64, error) {
+	code, err := c.Repository.CreateShortURL(ctx, originalURL, userID, opts)
+	if err == nil {
+		c.invalidate(code)
+	}
+	return code, err
+}
+
+// CreateShortURLs creates short urls and removes cached unknown codes of them.
+func (c *CachedStorage) CreateShortURLs(ctx context.Context, urls []URLWithID, userID uint32) ([]URLWithID, error) {
+	res, err := c.Repository.CreateShortURLs(ctx, urls, userID)
+	codes := make([]int64, len(res))
+	for i, url := range res {
+		codes[i] = url.Code
+	}
+	c.invalidate(codes...)
+	return res, err
+}
+
+// ConsumeClick decrements count of redirects left by url in repository, cached url is removed when no redirects are left.
+// Repository checks limit of every redirect, so cached url with stale count of redirects left is still served from cache.
+func (c *CachedStorage) ConsumeClick(ctx context.Context, shortURL int64) error {
+	err := c.Repository.ConsumeClick(ctx, shortURL)
+	if err != nil {
+		c.invalidate(shortURL)
+	}
+	return err
+}
+
+// AddClick counts redirect by short url in memory if repository is ClickBatcher, otherwise adds it to repository.
+func (c *CachedStorage) AddClick(ctx context.Context, shortURL int64) error {
+	if c.batcher == nil {
+		return c.Repository.AddClick(ctx, shortURL)
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.count(shortURL).Clicks++
+	return nil
+}
+
+// AddVariantClick counts redirect by short url to its variant in memory if repository is ClickBatcher,
+// otherwise adds it to repository.
+func (c *CachedStorage) AddVariantClick(ctx context.Context, shortURL int64, variant int) error {
+	if c.batcher == nil {
+		return c.Repository.AddVariantClick(ctx, shortURL, variant)
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	count := c.count(shortURL)
+	count.Clicks++
+	if count.Variants == nil {
+		count.Variants = make(map[int]int64)
+	}
+	count.Variants[variant]++
+	return nil
+}
+
+// Close saves counted clicks and closes repository.
+func (c *CachedStorage) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	<-c.stopped
+	if c.batcher != nil {
+		c.flushClicks()
+	}
+	return c.Repository.Close()
+}
+
+// count returns count of clicks of url which are not saved, c.mx must be locked.
+func (c *CachedStorage) count(shortURL int64) *ClickCount {
+	count, ok := c.clicks[shortURL]
+	if !ok {
+		count = &ClickCount{Code: shortURL}
+		c.clicks[shortURL] = count
+	}
+	return count
+}
+
+// flushClicksLoop saves counted clicks every clicksFlushInterval until storage is closed.
+func (c *CachedStorage) flushClicksLoop() {
+	defer close(c.stopped)
+	ticker := time.NewTicker(clicksFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.flushClicks()
+		}
+	}
+}
+
+// flushClicks saves counted clicks to repository by one batch, clicks which are not saved are counted again.
+func (c *CachedStorage) flushClicks() {
+	c.mx.Lock()
+	clicks := c.clicks
+	c.clicks = make(map[int64]*ClickCount)
+	c.mx.Unlock()
+	if len(clicks) == 0 {
+		return
+	}
+	counts := make([]ClickCount, 0, len(clicks))
+	for _, count := range clicks {
+		counts = append(counts, *count)
+	}
+	if err := c.batcher.AddClicks(context.Background(), counts); err != nil {
+		log.Printf("Cant save clicks: %s\n", err)
+		c.mx.Lock()
+		defer c.mx.Unlock()
+		for _, failed := range counts {
+			count := c.count(failed.Code)
+			count.Clicks += failed.Clicks
+			for variant, n := range failed.Variants {
+				if count.Variants == nil {
+					count.Variants = make(map[int]int64)
+				}
+				count.Variants[variant] += n
+			}
+		}
+	}
+}
+
+// UpdateURLMeta updates metadata of url and removes cached url.
+func (c *CachedStorage) UpdateURLMeta(
+	ctx context.Context,
+	shortURL int64,
+	userID uint32,
+	update URLMetaUpdate,
+) (URLInfo, error) {
+	defer c.invalidate(shortURL)
+	return c.Repository.UpdateURLMeta(ctx, shortURL, userID, update)
+}
+
+// ChangeURLDestination re-points url to originalURL and removes cached url.
+func (c *CachedStorage) ChangeURLDestination(
+	ctx context.Context,
+	shortURL int64,
+	ownerID uint32,
+	originalURL string,
+) error {
+	defer c.invalidate(shortURL)
+	return c.Repository.ChangeURLDestination(ctx, shortURL, ownerID, originalURL)
+}
+
+// RollbackURL re-points url to destination of version from url history and removes cached url.
+func (c *CachedStorage) RollbackURL(ctx context.Context, shortURL int64, ownerID uint32, version int) error {
+	defer c.invalidate(shortURL)
+	return c.Repository.RollbackURL(ctx, shortURL, ownerID, version)
+}
+
+// DeleteURLs deletes urls and removes them from cache.
+func (c *CachedStorage) DeleteURLs(urlsForDelete []DeleteURL) error {
+	codes := make([]int64, 0, len(urlsForDelete))
+	for _, url := range urlsForDelete {
+		if code, err := strconv.ParseInt(url.URL, 10, 64); err == nil {
+			codes = append(codes, code)
+		}
+	}
+	defer c.invalidate(codes...)
+	return c.Repository.DeleteURLs(urlsForDelete)
+}
+
+// invalidate removes cached urls, url changed by failed request is removed too.
+// Urls are removed even if request is canceled after change of url.
+func (c *CachedStorage) invalidate(codes ...int64) {
+	if len(codes) == 0 {
+		return
+	}
+	keys := make([]string, len(codes))
+	for i, code := range codes {
+		keys[i] = urlCacheKey(code)
+	}
+	if err := c.cache.Delete(context.Background(), keys...); err != nil {
+		log.Printf("Cant remove urls %v from cache: %s\n", codes, err)
+	}
+}
+
+// invalidateDomain removes cached domain.
+func (c *CachedStorage) invalidateDomain(name string) {
+	if err := c.cache.Delete(context.Background(), domainCacheKey(name)); err != nil {
+		log.Printf("Cant remove domain %s from cache: %s\n", name, err)
+	}
+}
+
+// domainCacheKey returns cache key of custom domain.
+func domainCacheKey(name string) string {
+	return "domain:" + name
+}
+
+// urlCacheKey returns cache key of redirect data of url.
+func urlCacheKey(shortURL int64) string {
+	return "url:" + strconv.FormatInt(shortURL, 10)
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/cache"
+	"testing"
+	"time"
+)
+
+// countingStorage InMemoryStorage which counts lookups of redirect data.
+type countingStorage struct {
+	*InMemoryStorage
+	lookups int
+	err     error
+}
+
+// GetRedirect counts lookup and returns err if it is set.
+func (s *countingStorage) GetRedirect(ctx context.Context, shortURL int64) (Redirect, error) {
+	s.lookups++
+	if s.err != nil {
+		return Redirect{}, s.err
+	}
+	return s.InMemoryStorage.GetRedirect(ctx, shortURL)
+}
+
+// failingCache cache which is not available.
+type failingCache struct {
+}
+
+// Get returns error.
+func (failingCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, errors.New("cache is down")
+}
+
+// Set returns error.
+func (failingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("cache is down")
+}
+
+// Delete returns error.
+func (failingCache) Delete(ctx context.Context, keys ...string) error {
+	return errors.New("cache is down")
+}
+
+func TestCachedStorage_GetRedirect(t *testing.T) {
+	ctx := context.TODO()
+	repo := &countingStorage{InMemoryStorage: NewInMemoryStorage()}
+	c := NewCachedStorage(repo, cache.NewLRU(10), time.Minute, time.Minute)
+	defer c.Close()
+	code, err := c.CreateShortURL(ctx, "http://google.com", 1, URLOptions{})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		fullURL, err := c.GetFullURL(ctx, code)
+		require.NoError(t, err)
+		assert.Equal(t, "http://google.com", fullURL)
+		redirect, err := c.GetRedirect(ctx, code)
+		require.NoError(t, err)
+		assert.Equal(t, "http://google.com", redirect.OriginalURL)
+	}
+	assert.Equal(t, 1, repo.lookups)
+
+	// unknown code is cached
+	for i := 0; i < 2; i++ {
+		_, err = c.GetRedirect(ctx, code+1)
+		assert.ErrorIs(t, err, &URLNotFoundError{})
+	}
+	assert.Equal(t, 2, repo.lookups)
+
+	// new url replaces cached unknown code
+	created, err := c.CreateShortURL(ctx, "http://google.com/new", 1, URLOptions{})
+	require.NoError(t, err)
+	require.Equal(t, code+1, created)
+	fullURL, err := c.GetFullURL(ctx, created)
+	require.NoError(t, err)
+	assert.Equal(t, "http://google.com/new", fullURL)
+	assert.Equal(t, 3, repo.lookups)
+
+	// errors of repository are not cached
+	repo.err = errors.New("db is down")
+	_, err = c.GetRedirect(ctx, code+5)
+	assert.Error(t, err)
+	repo.err = nil
+	_, err = c.GetRedirect(ctx, code+5)
+	assert.ErrorIs(t, err, &URLNotFoundError{})
+	assert.Equal(t, 5, repo.lookups)
+}
+
+func TestCachedStorage_invalidate(t *testing.T) {
+	ctx := context.TODO()
+	repo := &countingStorage{InMemoryStorage: NewInMemoryStorage()}
+	c := NewCachedStorage(repo, cache.NewLRU(10), time.Minute, 0)
+	defer c.Close()
+	urls, err := c.CreateShortURLs(ctx, []URLWithID{
+		{CorrelationID: "1", URL: "http://google.com/1"},
+		{CorrelationID: "2", URL: "http://google.com/2"},
+	}, 1)
+	require.NoError(t, err)
+	code := urls[0].Code
+
+	_, err = c.GetRedirect(ctx, code)
+	require.NoError(t, err)
+	require.NoError(t, c.ChangeURLDestination(ctx, code, 1, "http://google.com/changed"))
+	redirect, err := c.GetRedirect(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, "http://google.com/changed", redirect.OriginalURL)
+
+	permanent := RedirectPermanent
+	_, err = c.UpdateURLMeta(ctx, code, 1, URLMetaUpdate{RedirectType: &permanent})
+	require.NoError(t, err)
+	redirect, err = c.GetRedirect(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, RedirectPermanent, redirect.Type)
+
+	require.NoError(t, c.RollbackURL(ctx, code, 1, 1))
+	redirect, err = c.GetRedirect(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, "http://google.com/1", redirect.OriginalURL)
+
+	require.NoError(t, c.DeleteURLs([]DeleteURL{{UserID: 1, URL: "0"}}))
+	for i := 0; i < 2; i++ {
+		_, err = c.GetFullURL(ctx, code)
+		assert.ErrorIs(t, err, &DeletedURLError{})
+	}
+	assert.Equal(t, 5, repo.lookups)
+
+	// negative caching is disabled
+	for i := 0; i < 2; i++ {
+		_, err = c.GetRedirect(ctx, 100)
+		assert.ErrorIs(t, err, &URLNotFoundError{})
+	}
+	assert.Equal(t, 7, repo.lookups)
+}
+
+func TestCachedStorage_failingCache(t *testing.T) {
+	ctx := context.TODO()
+	repo := &countingStorage{InMemoryStorage: NewInMemoryStorage()}
+	c := NewCachedStorage(repo, failingCache{}, time.Minute, time.Minute)
+	defer c.Close()
+	code, err := c.CreateShortURL(ctx, "http://google.com", 1, URLOptions{})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		fullURL, err := c.GetFullURL(ctx, code)
+		require.NoError(t, err)
+		assert.Equal(t, "http://google.com", fullURL)
+	}
+	assert.Equal(t, 2, repo.lookups)
+}
+
+// countingDomains InMemoryStorage which counts lookups of domains.
+type countingDomains struct {
+	*InMemoryStorage
+	lookups int
+}
+
+// GetDomain counts lookup of domain.
+func (s *countingDomains) GetDomain(ctx context.Context, name string) (Domain, error) {
+	s.lookups++
+	return s.InMemoryStorage.GetDomain(ctx, name)
+}
+
+func TestCachedStorage_GetDomain(t *testing.T) {
+	ctx := context.TODO()
+	repo := &countingDomains{InMemoryStorage: NewInMemoryStorage()}
+	c := NewCachedStorage(repo, cache.NewLRU(10), time.Minute, time.Minute)
+	defer c.Close()
+
+	// not registered domain is cached until it is added
+	for i := 0; i < 2; i++ {
+		_, err := c.GetDomain(ctx, "go.example.com")
+		assert.ErrorIs(t, err, &DomainNotFoundError{})
+	}
+	assert.Equal(t, 1, repo.lookups)
+	require.NoError(t, c.AddDomain(ctx, Domain{Name: "go.example.com", OwnerID: 1}))
+	for i := 0; i < 2; i++ {
+		domain, err := c.GetDomain(ctx, "go.example.com")
+		require.NoError(t, err)
+		assert.Equal(t, uint32(1), domain.OwnerID)
+	}
+	assert.Equal(t, 2, repo.lookups)
+
+	require.NoError(t, c.RemoveDomain(ctx, "go.example.com", 1))
+	_, err := c.GetDomain(ctx, "go.example.com")
+	assert.ErrorIs(t, err, &DomainNotFoundError{})
+	assert.Equal(t, 3, repo.lookups)
+}
+
+// batchingStorage InMemoryStorage which saves clicks by batches.
+type batchingStorage struct {
+	*InMemoryStorage
+	batches int
+	err     error
+}
+
+// AddClicks adds counted clicks to InMemoryStorage or returns err if it is set.
+func (s *batchingStorage) AddClicks(ctx context.Context, counts []ClickCount) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.batches++
+	for _, count := range counts {
+		for variant, clicks := range count.Variants {
+			for i := int64(0); i < clicks; i++ {
+				if err := s.InMemoryStorage.AddVariantClick(ctx, count.Code, variant); err != nil {
+					return err
+				}
+			}
+			count.Clicks -= clicks
+		}
+		for i := int64(0); i < count.Clicks; i++ {
+			if err := s.InMemoryStorage.AddClick(ctx, count.Code); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func TestCachedStorage_AddClick(t *testing.T) {
+	ctx := context.TODO()
+	repo := &batchingStorage{InMemoryStorage: NewInMemoryStorage()}
+	c := NewCachedStorage(repo, cache.NewLRU(10), time.Minute, time.Minute)
+	code, err := c.CreateShortURL(ctx, "http://google.com", 1, URLOptions{
+		Variants: []URLVariant{{URL: "http://google.com/a", Weight: 1}, {URL: "http://google.com/b", Weight: 1}},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, c.AddClick(ctx, code))
+	}
+	require.NoError(t, c.AddVariantClick(ctx, code, 1))
+	info, err := repo.GetURLInfo(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Clicks)
+
+	// failed batch is saved by next flush
+	repo.err = errors.New("db is down")
+	c.flushClicks()
+	repo.err = nil
+	require.NoError(t, c.AddClick(ctx, code))
+	c.flushClicks()
+	assert.Equal(t, 1, repo.batches)
+	info, err = repo.GetURLInfo(ctx, code)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), info.Clicks)
+	assert.Equal(t, int64(1), info.Variants[1].Clicks)
+
+	// clicks are saved on close
+	require.NoError(t, c.AddClick(ctx, code))
+	require.NoError(t, c.Close())
+	assert.Equal(t, 2, repo.batches)
+}
+
+func TestCachedStorage_ConsumeClick(t *testing.T) {
+	ctx := context.TODO()
+	repo := &countingStorage{InMemoryStorage: NewInMemoryStorage()}
+	c := NewCachedStorage(repo, cache.NewLRU(10), time.Minute, time.Minute)
+	defer c.Close()
+	code, err := c.CreateShortURL(ctx, "http://google.com", 1, URLOptions{MaxClicks: 2})
+	require.NoError(t, err)
+
+	// url with max clicks is served from cache until it is exhausted
+	for i := 0; i < 2; i++ {
+		redirect, err := c.GetRedirect(ctx, code)
+		require.NoError(t, err)
+		assert.False(t, redirect.IsExhausted())
+		require.NoError(t, c.ConsumeClick(ctx, code))
+	}
+	assert.Equal(t, 1, repo.lookups)
+	assert.ErrorIs(t, c.ConsumeClick(ctx, code), &ClicksExhaustedError{})
+	redirect, err := c.GetRedirect(ctx, code)
+	require.NoError(t, err)
+	assert.True(t, redirect.IsExhausted())
+	assert.Equal(t, 2, repo.lookups)
+}
@@ -3,10 +3,15 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/lib/pq"
+	"go-axesthump-shortener/internal/app/generator"
 	"log"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // LongURLConflictError an error that occurs when the original urls conflict.
@@ -18,10 +23,18 @@ func (e *LongURLConflictError) Error() string {
 	return "LongURL conflict"
 }
 
+// codeValue sql value of code of new url, code from allocator or next value of serial column.
+const codeValue = "COALESCE($16::bigint, nextval(pg_get_serial_sequence('shortener', 'shortener_id')))"
+
+// uniqueViolationCode postgres error code of unique constraint violation.
+const uniqueViolationCode = "23505"
+
 // DBStorage contains data for db.
 type DBStorage struct {
 	conn *pgx.Conn
 	ctx  context.Context
+	// ids - allocator of codes of new urls, nil if codes are set by serial column.
+	ids generator.Allocator
 }
 
 // NewDBStorage returns new DBStorage.
@@ -33,6 +46,23 @@ func NewDBStorage(ctx context.Context, conn *pgx.Conn) *DBStorage {
 	return db
 }
 
+// SetIDAllocator sets allocator of codes of new urls instead of serial column.
+func (db *DBStorage) SetIDAllocator(ids generator.Allocator) {
+	db.ids = ids
+}
+
+// nextCode returns code of new url from id allocator, nil if code is set by serial column.
+func (db *DBStorage) nextCode(ctx context.Context) (*int64, error) {
+	if db.ids == nil {
+		return nil, nil
+	}
+	code, err := db.ids.NextID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
 // GetLastUserID return last created user id.
 func (db *DBStorage) GetLastUserID() int64 {
 	query := "SELECT MAX(user_id) FROM shortener;"
@@ -47,42 +77,104 @@ func (db *DBStorage) GetLastUserID() int64 {
 	return int64(lastID)
 }
 
-// CreateShortURL create short url. Returns short url if operations success or error.
+// GetFirstFreeCode returns code after sequential codes of stored urls, random codes are not counted.
+func (db *DBStorage) GetFirstFreeCode() int64 {
+	query := "SELECT COALESCE(MAX(shortener_id) + 1, 0) FROM shortener WHERE shortener_id < $1;"
+	var code int64
+	if err := db.conn.QueryRow(db.ctx, query, generator.RandomCodeBase).Scan(&code); err != nil {
+		return 0
+	}
+	return code
+}
+
+// CreateShortURL create short url. Returns code of url if operations success or error.
 func (db *DBStorage) CreateShortURL(
 	ctx context.Context,
-	beginURL string,
 	originalURL string,
 	userID uint32,
-) (string, error) {
-	var shortEndpoint int64
-	query := "INSERT INTO shortener (long_url, user_id) VALUES ($1, $2) ON CONFLICT (long_url) DO NOTHING RETURNING shortener_id;"
-	row := db.conn.QueryRow(ctx, query, originalURL, userID)
-	err := row.Scan(&shortEndpoint)
-	shortURL := ""
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			var short int64
-			short, err = db.GetShortURLByFullURL(ctx, originalURL)
-			if err != nil {
-				return "", err
+	opts URLOptions,
+) (int64, error) {
+	query := "INSERT INTO shortener " +
+		"(long_url, user_id, workspace_id, tags, title, description, redirect_type, passthrough, query_conflict, rules, variants, " +
+		"password_hash, max_clicks, clicks_left, activates_at, short_domain, shortener_id) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13, $14, $15, " + codeValue + ") " +
+		"ON CONFLICT (short_domain, long_url) DO NOTHING RETURNING shortener_id;"
+	for attempt := 1; ; attempt++ {
+		var shortEndpoint int64
+		code, err := db.nextCode(ctx)
+		if err != nil {
+			return 0, err
+		}
+		err = db.conn.QueryRow(ctx, query, urlArgs(originalURL, userID, opts, code)...).Scan(&shortEndpoint)
+		retry, err := retryCode(code, attempt, err)
+		if retry {
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				shortEndpoint, err = db.GetShortURLByFullURL(ctx, opts.ShortDomain, originalURL)
+				if err != nil {
+					return 0, err
+				}
+				return shortEndpoint, &LongURLConflictError{}
 			}
-			strShort := strconv.FormatInt(short, 10)
-			shortURL = beginURL + strShort
-			err = &LongURLConflictError{}
-		} else {
-			return "", err
+			return 0, err
 		}
-	} else {
-		strShort := strconv.FormatInt(shortEndpoint, 10)
-		shortURL = beginURL + strShort
+		return shortEndpoint, nil
+	}
+}
+
+// urlArgs returns arguments of insert of new url with code, code is nil if it is set by serial column.
+func urlArgs(originalURL string, userID uint32, opts URLOptions, code *int64) []any {
+	return []any{
+		originalURL,
+		userID,
+		opts.WorkspaceID,
+		notNilTags(opts.Tags),
+		opts.Title,
+		opts.Description,
+		opts.RedirectType,
+		opts.Passthrough,
+		opts.QueryConflict,
+		notNilRules(opts.Rules),
+		notNilVariants(withoutClicks(opts.Variants)),
+		opts.PasswordHash,
+		opts.MaxClicks,
+		opts.ActivatesAt,
+		opts.ShortDomain,
+		code,
+	}
+}
+
+// isCodeCollision checks that err is violation of primary key of shortener by code from id allocator.
+func isCodeCollision(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == "shortener_pkey"
+}
+
+// retryCode counts attempt of insert by code from id allocator and reports whether insert collided with used code
+// and should be retried by new code. CodeCollisionError is returned after generator.MaxCodeAttempts collisions.
+// Insert by serial column is not counted, err is returned as is.
+func retryCode(code *int64, attempt int, err error) (bool, error) {
+	if code == nil {
+		return false, err
+	}
+	collided := isCodeCollision(err)
+	generator.CountCodeAttempt(collided)
+	switch {
+	case !collided:
+		return false, err
+	case attempt < generator.MaxCodeAttempts:
+		return true, nil
+	default:
+		return false, &generator.CodeCollisionError{}
 	}
-	return shortURL, err
 }
 
-// GetShortURLByFullURL return short url from full url.
-func (db *DBStorage) GetShortURLByFullURL(ctx context.Context, fullURL string) (int64, error) {
-	query := "SELECT shortener_id FROM shortener WHERE long_url = $1"
-	row := db.conn.QueryRow(ctx, query, fullURL)
+// GetShortURLByFullURL return short url from full url on custom domain, empty domain is domain of service base url.
+func (db *DBStorage) GetShortURLByFullURL(ctx context.Context, shortDomain string, fullURL string) (int64, error) {
+	query := "SELECT shortener_id FROM shortener WHERE short_domain = $1 AND long_url = $2"
+	row := db.conn.QueryRow(ctx, query, shortDomain, fullURL)
 	var shortURL = new(int64)
 	err := row.Scan(shortURL)
 	if err != nil {
@@ -99,6 +191,9 @@ func (db *DBStorage) GetFullURL(ctx context.Context, shortURL int64) (string, er
 	var isDeleted = new(bool)
 	err := row.Scan(longURL, isDeleted)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", &URLNotFoundError{}
+		}
 		return "", err
 	}
 	if *isDeleted {
@@ -107,34 +202,310 @@ func (db *DBStorage) GetFullURL(ctx context.Context, shortURL int64) (string, er
 	return *longURL, nil
 }
 
-// GetAllURLs returns all urls owned specific user.
-func (db *DBStorage) GetAllURLs(ctx context.Context, beginURL string, userID uint32) []URLInfo {
-	query := "SELECT shortener_id, long_url FROM shortener WHERE user_id = $1"
-	row, err := db.conn.Query(ctx, query, userID)
+// GetRedirect returns data for redirect by not deleted short url.
+func (db *DBStorage) GetRedirect(ctx context.Context, shortURL int64) (Redirect, error) {
+	query := "SELECT long_url, is_deleted, redirect_type, passthrough, query_conflict, rules, variants, password_hash, " +
+		"max_clicks, clicks_left, activates_at, short_domain " +
+		"FROM shortener WHERE shortener_id = $1;"
+	var redirect Redirect
+	var isDeleted bool
+	err := db.conn.QueryRow(ctx, query, shortURL).Scan(
+		&redirect.OriginalURL,
+		&isDeleted,
+		&redirect.Type,
+		&redirect.Passthrough,
+		&redirect.QueryConflict,
+		&redirect.Rules,
+		&redirect.Variants,
+		&redirect.PasswordHash,
+		&redirect.MaxClicks,
+		&redirect.ClicksLeft,
+		&redirect.ActivatesAt,
+		&redirect.ShortDomain,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Redirect{}, &URLNotFoundError{}
+		}
+		return Redirect{}, err
+	}
+	if isDeleted {
+		return Redirect{}, &DeletedURLError{}
+	}
+	return redirect, nil
+}
+
+// GetURLInfo returns info of not deleted url without its access settings.
+func (db *DBStorage) GetURLInfo(ctx context.Context, shortURL int64) (URLInfo, error) {
+	query := "SELECT long_url, is_deleted, workspace_id, created_at, updated_at, clicks, tags, title, description, preview, " +
+		"redirect_type, passthrough, query_conflict, rules, variants, password_hash <> '', max_clicks, " +
+		"CASE WHEN max_clicks > 0 THEN clicks_left END, activates_at, COALESCE(activates_at > now(), false), short_domain " +
+		"FROM shortener WHERE shortener_id = $1;"
+	info := URLInfo{Code: shortURL}
+	err := db.conn.QueryRow(ctx, query, shortURL).Scan(
+		&info.OriginalURL,
+		&info.IsDeleted,
+		&info.WorkspaceID,
+		&info.CreatedAt,
+		&info.UpdatedAt,
+		&info.Clicks,
+		&info.Tags,
+		&info.Title,
+		&info.Description,
+		&info.Preview,
+		&info.RedirectType,
+		&info.Passthrough,
+		&info.QueryConflict,
+		&info.Rules,
+		&info.Variants,
+		&info.HasPassword,
+		&info.MaxClicks,
+		&info.ClicksLeft,
+		&info.ActivatesAt,
+		&info.Scheduled,
+		&info.ShortDomain,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return URLInfo{}, &URLNotFoundError{}
+		}
+		return URLInfo{}, err
+	}
+	if info.IsDeleted {
+		return URLInfo{}, &DeletedURLError{}
+	}
+	return info, nil
+}
+
+// AddClick increments count of redirects by short url.
+func (db *DBStorage) AddClick(ctx context.Context, shortURL int64) error {
+	tag, err := db.conn.Exec(ctx, "UPDATE shortener SET clicks = clicks + 1 WHERE shortener_id = $1;", shortURL)
 	if err != nil {
-		return []URLInfo{}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return &URLNotFoundError{}
+	}
+	return nil
+}
+
+// ConsumeClick atomically decrements count of redirects left by url with max clicks.
+// Conditional update is atomic, so concurrent redirects can't exceed max clicks.
+func (db *DBStorage) ConsumeClick(ctx context.Context, shortURL int64) error {
+	var left int64
+	err := db.conn.QueryRow(
+		ctx,
+		"UPDATE shortener SET clicks_left = clicks_left - 1 "+
+			"WHERE shortener_id = $1 AND max_clicks > 0 AND clicks_left > 0 AND NOT is_deleted RETURNING clicks_left;",
+		shortURL,
+	).Scan(&left)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	var maxClicks int64
+	err = db.conn.QueryRow(ctx, "SELECT max_clicks FROM shortener WHERE shortener_id = $1;", shortURL).Scan(&maxClicks)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return &URLNotFoundError{}
+		}
+		return err
+	}
+	if maxClicks <= 0 {
+		return nil
+	}
+	return &ClicksExhaustedError{}
+}
+
+// AddVariantClick increments count of redirects by short url and count of redirects to its variant.
+// Variant clicks are incremented in the same row update, so concurrent clicks are not lost.
+func (db *DBStorage) AddVariantClick(ctx context.Context, shortURL int64, variant int) error {
+	query := "UPDATE shortener SET clicks = clicks + 1, variants = CASE " +
+		"WHEN $2::int >= 0 AND $2::int < jsonb_array_length(variants) THEN jsonb_set(" +
+		"variants, ARRAY[$2::int::text, 'clicks'], to_jsonb(COALESCE((variants->$2::int->>'clicks')::bigint, 0) + 1)) " +
+		"ELSE variants END WHERE shortener_id = $1;"
+	tag, err := db.conn.Exec(ctx, query, shortURL, variant)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return &URLNotFoundError{}
+	}
+	return nil
+}
+
+// AddClicks adds counted redirects to urls by one batch of updates, it implements ClickBatcher.
+// Batch is executed in one implicit transaction, so clicks are saved all or none.
+func (db *DBStorage) AddClicks(ctx context.Context, counts []ClickCount) error {
+	batch := &pgx.Batch{}
+	for _, count := range counts {
+		batch.Queue("UPDATE shortener SET clicks = clicks + $2 WHERE shortener_id = $1;", count.Code, count.Clicks)
+		for variant, clicks := range count.Variants {
+			batch.Queue(
+				"UPDATE shortener SET variants = jsonb_set(variants, ARRAY[$2::int::text, 'clicks'], "+
+					"to_jsonb(COALESCE((variants->$2::int->>'clicks')::bigint, 0) + $3)) "+
+					"WHERE shortener_id = $1 AND $2::int >= 0 AND $2::int < jsonb_array_length(variants);",
+				count.Code,
+				variant,
+				clicks,
+			)
+		}
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	return db.conn.SendBatch(ctx, batch).Close()
+}
+
+// SetURLPreview saves preview of url destination page.
+func (db *DBStorage) SetURLPreview(
+	ctx context.Context,
+	shortURL int64,
+	originalURL string,
+	preview URLPreview,
+) error {
+	tag, err := db.conn.Exec(
+		ctx,
+		"UPDATE shortener SET preview = $3 WHERE shortener_id = $1 AND long_url = $2;",
+		shortURL,
+		originalURL,
+		preview,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return &URLNotFoundError{}
+	}
+	return nil
+}
+
+// GetAllURLs returns page of urls owned specific user, urls shared with him and urls of his workspaces.
+// Urls are filtered in query and paginated by (sort field, shortener_id) keyset.
+// Page is merged from UNION ALL of owned, shared and workspace urls, every part is read by its index
+// with cursor and limit, total is sum of counts of the same parts and it's counted only for the first page.
+func (db *DBStorage) GetAllURLs(
+	ctx context.Context,
+	userID uint32,
+	filter URLFilter,
+) (URLPage, error) {
+	c, err := filter.cursor()
+	if err != nil {
+		return URLPage{}, err
+	}
+	parts, args := visibleURLsQueries(userID, filter)
+
+	var total int
+	if c == nil {
+		counts := make([]string, len(parts))
+		for i, part := range parts {
+			counts[i] = "(SELECT COUNT(*) FROM (" + part + ") AS v)"
+		}
+		if err = db.conn.QueryRow(ctx, "SELECT "+strings.Join(counts, " + ")+";", args...).Scan(&total); err != nil {
+			return URLPage{}, err
+		}
+	}
+
+	sortColumn, order, op := "created_at", "ASC", ">"
+	if filter.sortBy() == SortByClicks {
+		sortColumn = "clicks"
 	}
-	urls := make([]URLInfo, 0)
-	for row.Next() {
-		var shortURL = new(string)
-		var longURL = new(string)
-		err := row.Scan(shortURL, longURL)
+	if filter.Desc {
+		order, op = "DESC", "<"
+	}
+	var after string
+	if c != nil {
+		var cursorValue interface{} = c.Value
+		if filter.sortBy() == SortByCreated {
+			cursorValue = time.Unix(0, c.Value)
+		}
+		args = append(args, cursorValue, c.ID)
+		after = fmt.Sprintf(" AND (s.%s, s.shortener_id) %s ($%d, $%d)", sortColumn, op, len(args)-1, len(args))
+	}
+	var limit string
+	if filter.Limit > 0 {
+		// one more url shows that next page exists
+		args = append(args, filter.Limit+1)
+		limit = fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	for i, part := range parts {
+		parts[i] = fmt.Sprintf("(%s%s ORDER BY s.%s %s, s.shortener_id %s%s)", part, after, sortColumn, order, order, limit)
+	}
+	query := "SELECT * FROM (" + strings.Join(parts, " UNION ALL ") + ") AS visible" +
+		fmt.Sprintf(" ORDER BY %s %s, shortener_id %s", sortColumn, order, order) + limit
+
+	rows, err := db.conn.Query(ctx, query+";", args...)
+	if err != nil {
+		return URLPage{}, err
+	}
+	defer rows.Close()
+	page := URLPage{URLs: make([]URLInfo, 0), Total: total}
+	var lastID int64
+	for rows.Next() {
+		var shortURL int64
+		var isOwner bool
+		var permission string
+		var role string
+		var info URLInfo
+		err = rows.Scan(
+			&shortURL,
+			&info.OriginalURL,
+			&isOwner,
+			&info.WorkspaceID,
+			&permission,
+			&role,
+			&info.CreatedAt,
+			&info.Clicks,
+			&info.Tags,
+			&info.IsDeleted,
+			&info.Title,
+			&info.Description,
+			&info.UpdatedAt,
+			&info.Preview,
+			&info.RedirectType,
+			&info.Passthrough,
+			&info.QueryConflict,
+			&info.Rules,
+			&info.Variants,
+			&info.HasPassword,
+			&info.MaxClicks,
+			&info.ClicksLeft,
+			&info.ActivatesAt,
+			&info.Scheduled,
+			&info.ShortDomain,
+		)
 		if err != nil {
-			log.Printf("Cant scan!")
-			return []URLInfo{}
+			return URLPage{}, err
 		}
-		urls = append(urls, URLInfo{
-			ShortURL:    *shortURL,
-			OriginalURL: *longURL,
-		})
+		if filter.Limit > 0 && len(page.URLs) == filter.Limit {
+			page.NextCursor = filter.encodeCursor(filter.sortValue(page.URLs[len(page.URLs)-1]), lastID)
+			break
+		}
+		info.Code = shortURL
+		info.Ownership = OwnershipOwned
+		switch {
+		case isOwner:
+		case len(role) != 0:
+			info.Ownership = OwnershipWorkspace
+			info.Permission = WorkspaceRole(role).Permission()
+			if Permission(permission).Allows(info.Permission) {
+				info.Permission = Permission(permission)
+			}
+		default:
+			info.Ownership = OwnershipShared
+			info.Permission = Permission(permission)
+		}
+		page.URLs = append(page.URLs, info)
+		lastID = shortURL
 	}
-	return urls
+	return page, rows.Err()
 }
 
-// CreateShortURLs create short urls. Returns slice short urls if operations success or error.
+// CreateShortURLs create short urls. Returns codes of urls if operations success or error.
 func (db *DBStorage) CreateShortURLs(
 	ctx context.Context,
-	beginURL string,
 	urls []URLWithID,
 	userID uint32,
 ) ([]URLWithID, error) {
@@ -144,27 +515,28 @@ func (db *DBStorage) CreateShortURLs(
 	}
 
 	if _, err = tx.Prepare(
-		ctx, "insert", "INSERT INTO shortener (long_url, user_id) VALUES ($1, $2) RETURNING shortener_id;",
+		ctx,
+		"insert",
+		"INSERT INTO shortener "+
+			"(long_url, user_id, workspace_id, tags, title, description, redirect_type, passthrough, query_conflict, rules, variants, "+
+			"password_hash, max_clicks, clicks_left, activates_at, short_domain, shortener_id) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $13, $14, $15, "+codeValue+") RETURNING shortener_id;",
 	); err != nil {
 		return nil, err
 	}
 
 	res := make([]URLWithID, 0, len(urls))
 	for _, url := range urls {
-		var shortEndpoint int64
-		row := tx.QueryRow(ctx, "insert", url.URL, userID)
-		err = row.Scan(&shortEndpoint)
+		shortEndpoint, err := db.insertBatchURL(ctx, tx, url, userID)
 		if err != nil {
-			if err = tx.Rollback(ctx); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
 				log.Printf("update drivers: unable to rollback: %v\n", err)
-				return nil, err
 			}
 			return nil, err
 		}
-		shortURL := beginURL + strconv.FormatInt(shortEndpoint, 10)
 		res = append(res, URLWithID{
 			CorrelationID: url.CorrelationID,
-			URL:           shortURL,
+			Code:          shortEndpoint,
 		})
 	}
 	if err := tx.Commit(ctx); err != nil {
@@ -173,43 +545,877 @@ func (db *DBStorage) CreateShortURLs(
 	return res, nil
 }
 
+// insertBatchURL inserts url by prepared statement "insert" of tx and returns its code.
+// Code from id allocator is inserted in savepoint, so collided code is retried without abort of tx.
+func (db *DBStorage) insertBatchURL(ctx context.Context, tx pgx.Tx, url URLWithID, userID uint32) (int64, error) {
+	for attempt := 1; ; attempt++ {
+		var shortEndpoint int64
+		code, err := db.nextCode(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if code == nil {
+			err = tx.QueryRow(ctx, "insert", urlArgs(url.URL, userID, url.Options, code)...).Scan(&shortEndpoint)
+			return shortEndpoint, err
+		}
+		savepoint, err := tx.Begin(ctx)
+		if err != nil {
+			return 0, err
+		}
+		err = savepoint.QueryRow(ctx, "insert", urlArgs(url.URL, userID, url.Options, code)...).Scan(&shortEndpoint)
+		if err != nil {
+			if rbErr := savepoint.Rollback(ctx); rbErr != nil {
+				return 0, rbErr
+			}
+		} else {
+			err = savepoint.Commit(ctx)
+		}
+		retry, err := retryCode(code, attempt, err)
+		if !retry {
+			return shortEndpoint, err
+		}
+	}
+}
+
+// UpdateURLMeta updates metadata and destination of not deleted url in one transaction and returns updated url.
+// Url row is locked by owner before new destination is added in url history.
+func (db *DBStorage) UpdateURLMeta(
+	ctx context.Context,
+	shortURL int64,
+	userID uint32,
+	update URLMetaUpdate,
+) (URLInfo, error) {
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		return URLInfo{}, err
+	}
+	defer tx.Rollback(ctx)
+	if update.OriginalURL != nil {
+		stored, history, err := db.lockHistory(ctx, tx, shortURL, userID)
+		if err != nil {
+			return URLInfo{}, err
+		}
+		if err = db.addVersion(ctx, tx, shortURL, stored, history, *update.OriginalURL); err != nil {
+			return URLInfo{}, err
+		}
+	}
+	var tags, rules, variants interface{}
+	if update.Tags != nil {
+		tags = notNilTags(*update.Tags)
+	}
+	if update.Rules != nil {
+		rules = notNilRules(*update.Rules)
+	}
+	if update.Variants != nil {
+		variants = notNilVariants(withoutClicks(*update.Variants))
+	}
+	query := "UPDATE shortener SET title = COALESCE($3, title), description = COALESCE($4, description), " +
+		"tags = COALESCE($5, tags), redirect_type = COALESCE($8, redirect_type), " +
+		"passthrough = COALESCE($9, passthrough), query_conflict = COALESCE($10, query_conflict), " +
+		"rules = COALESCE($11, rules), variants = COALESCE($12, variants), " +
+		"password_hash = COALESCE($13, password_hash), max_clicks = COALESCE($14, max_clicks), " +
+		"clicks_left = COALESCE($14, clicks_left), long_url = COALESCE($15, long_url), updated_at = now() " +
+		"WHERE shortener_id = $1 AND NOT is_deleted AND " + accessCondition("shortener", "$2", 6) + " " +
+		"RETURNING long_url, workspace_id, created_at, updated_at, clicks, tags, title, description, preview, " +
+		"redirect_type, passthrough, query_conflict, rules, variants, password_hash <> '', max_clicks, " +
+		"CASE WHEN max_clicks > 0 THEN clicks_left END, activates_at, COALESCE(activates_at > now(), false), short_domain;"
+	row := tx.QueryRow(
+		ctx,
+		query,
+		shortURL,
+		userID,
+		update.Title,
+		update.Description,
+		tags,
+		grantingPermissions(PermissionManage),
+		grantingRoles(PermissionManage),
+		update.RedirectType,
+		update.Passthrough,
+		update.QueryConflict,
+		rules,
+		variants,
+		update.PasswordHash,
+		update.MaxClicks,
+		update.OriginalURL,
+	)
+	info := URLInfo{Code: shortURL}
+	err = row.Scan(
+		&info.OriginalURL,
+		&info.WorkspaceID,
+		&info.CreatedAt,
+		&info.UpdatedAt,
+		&info.Clicks,
+		&info.Tags,
+		&info.Title,
+		&info.Description,
+		&info.Preview,
+		&info.RedirectType,
+		&info.Passthrough,
+		&info.QueryConflict,
+		&info.Rules,
+		&info.Variants,
+		&info.HasPassword,
+		&info.MaxClicks,
+		&info.ClicksLeft,
+		&info.ActivatesAt,
+		&info.Scheduled,
+		&info.ShortDomain,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return URLInfo{}, &URLNotFoundError{}
+		case errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode:
+			return URLInfo{}, &LongURLConflictError{}
+		}
+		return URLInfo{}, err
+	}
+	return info, tx.Commit(ctx)
+}
+
 // DeleteURLs delete url from urlsForDelete.
+// Urls of different users are deleted by one UPDATE with unnest of (short url, user id) pairs,
+// url is deleted if user is owner or has PermissionManage to url or its workspace.
 func (db *DBStorage) DeleteURLs(urlsForDelete []DeleteURL) error {
 	tx, err := db.conn.Begin(db.ctx)
 	if err != nil {
 		log.Printf("tx error - %s", err)
 		return err
 	}
-	q := "UPDATE shortener SET is_deleted = true WHERE shortener_id = ANY ($1) AND user_id = $2;"
-	shortIDs := convertShortIDs(urlsForDelete)
+	q := "UPDATE shortener SET is_deleted = true " +
+		"FROM unnest($1::bigint[], $2::bigint[]) AS d(shortener_id, user_id) " +
+		"WHERE shortener.shortener_id = d.shortener_id AND " + accessCondition("shortener", "d.user_id", 3) + ";"
+	shortIDs, userIDs := convertShortIDs(urlsForDelete)
 
-	_, err = tx.Exec(db.ctx, q, shortIDs, urlsForDelete[0].UserID)
+	_, err = tx.Exec(db.ctx, q, shortIDs, userIDs, grantingPermissions(PermissionManage), grantingRoles(PermissionManage))
 	if err != nil {
 		log.Printf("Exec error - %s", err)
 		e := tx.Rollback(db.ctx)
 		if e != nil {
 			return e
 		}
-		return nil
+		return err
 	}
 	err = tx.Commit(db.ctx)
 	return err
 }
 
+// ChangeURLDestination re-points url owned by ownerID to originalURL and adds it in url history.
+// Returns LongURLConflictError if other url already points to originalURL.
+func (db *DBStorage) ChangeURLDestination(
+	ctx context.Context,
+	shortURL int64,
+	ownerID uint32,
+	originalURL string,
+) error {
+	return db.changeDestination(ctx, shortURL, ownerID, func(history []URLVersion) (string, error) {
+		return originalURL, nil
+	})
+}
+
+// GetURLHistory returns all destinations of url from the first one.
+// User must be owner of url or have PermissionStats to url or its workspace.
+func (db *DBStorage) GetURLHistory(ctx context.Context, shortURL int64, userID uint32) ([]URLVersion, error) {
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+	var longURL string
+	var createdAt time.Time
+	err = tx.QueryRow(
+		ctx,
+		"SELECT long_url, created_at FROM shortener "+
+			"WHERE shortener_id = $1 AND NOT is_deleted AND "+accessCondition("shortener", "$2", 3)+";",
+		shortURL,
+		userID,
+		grantingPermissions(PermissionStats),
+		grantingRoles(PermissionStats),
+	).Scan(&longURL, &createdAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, &URLNotFoundError{}
+		}
+		return nil, err
+	}
+	_, history, err := db.readHistory(ctx, tx, shortURL, longURL, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	return history, tx.Commit(ctx)
+}
+
+// RollbackURL re-points url owned by ownerID to destination of version from url history.
+func (db *DBStorage) RollbackURL(ctx context.Context, shortURL int64, ownerID uint32, version int) error {
+	return db.changeDestination(ctx, shortURL, ownerID, func(history []URLVersion) (string, error) {
+		return findVersion(history, version)
+	})
+}
+
+// changeDestination re-points url owned by ownerID to destination chosen from url history by choose.
+// Url row is locked until new version is saved, so concurrent changes get sequential versions.
+func (db *DBStorage) changeDestination(
+	ctx context.Context,
+	shortURL int64,
+	ownerID uint32,
+	choose func(history []URLVersion) (string, error),
+) error {
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	stored, history, err := db.lockHistory(ctx, tx, shortURL, ownerID)
+	if err != nil {
+		return err
+	}
+	originalURL, err := choose(history)
+	if err != nil {
+		return err
+	}
+	if err = db.addVersion(ctx, tx, shortURL, stored, history, originalURL); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		ctx,
+		"UPDATE shortener SET long_url = $2, updated_at = now() WHERE shortener_id = $1;",
+		shortURL,
+		originalURL,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return &LongURLConflictError{}
+		}
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// addVersion adds originalURL in history of url locked by tx, url row itself is not changed.
+// stored is count of versions saved in db, history is url history with current destination.
+func (db *DBStorage) addVersion(
+	ctx context.Context,
+	tx pgx.Tx,
+	shortURL int64,
+	stored int,
+	history []URLVersion,
+	originalURL string,
+) error {
+	if stored == 0 {
+		// url was never changed, so its first destination is saved with new one
+		_, err := tx.Exec(
+			ctx,
+			"INSERT INTO url_history (shortener_id, version, long_url, changed_at) VALUES ($1, $2, $3, $4);",
+			shortURL,
+			history[0].Version,
+			history[0].OriginalURL,
+			history[0].ChangedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(
+		ctx,
+		"INSERT INTO url_history (shortener_id, version, long_url, changed_at) VALUES ($1, $2, $3, now());",
+		shortURL,
+		len(history)+1,
+		originalURL,
+	)
+	return err
+}
+
+// lockHistory locks url owned by ownerID and returns count of stored versions and url history.
+func (db *DBStorage) lockHistory(
+	ctx context.Context,
+	tx pgx.Tx,
+	shortURL int64,
+	ownerID uint32,
+) (int, []URLVersion, error) {
+	var longURL string
+	var createdAt time.Time
+	err := tx.QueryRow(
+		ctx,
+		"SELECT long_url, created_at FROM shortener WHERE shortener_id = $1 AND user_id = $2 AND NOT is_deleted FOR UPDATE;",
+		shortURL,
+		ownerID,
+	).Scan(&longURL, &createdAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil, &URLNotFoundError{}
+		}
+		return 0, nil, err
+	}
+	return db.readHistory(ctx, tx, shortURL, longURL, createdAt)
+}
+
+// readHistory returns count of stored versions and all destinations of url with current longURL.
+func (db *DBStorage) readHistory(
+	ctx context.Context,
+	tx pgx.Tx,
+	shortURL int64,
+	longURL string,
+	createdAt time.Time,
+) (int, []URLVersion, error) {
+	rows, err := tx.Query(
+		ctx,
+		"SELECT version, long_url, changed_at FROM url_history WHERE shortener_id = $1 ORDER BY version;",
+		shortURL,
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	history := make([]URLVersion, 0)
+	for rows.Next() {
+		var v URLVersion
+		if err = rows.Scan(&v.Version, &v.OriginalURL, &v.ChangedAt); err != nil {
+			return 0, nil, err
+		}
+		history = append(history, v)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	return len(history), currentHistory(history, longURL, createdAt), nil
+}
+
+// TransferURL transfers url owned by ownerID to newOwnerID.
+func (db *DBStorage) TransferURL(ctx context.Context, shortURL int64, ownerID uint32, newOwnerID uint32) error {
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	tag, err := tx.Exec(
+		ctx,
+		"UPDATE shortener SET user_id = $3 WHERE shortener_id = $1 AND user_id = $2 AND NOT is_deleted;",
+		shortURL,
+		ownerID,
+		newOwnerID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return &URLNotFoundError{}
+	}
+	// new owner doesn't need share anymore
+	_, err = tx.Exec(ctx, "DELETE FROM url_shares WHERE shortener_id = $1 AND user_id = $2;", shortURL, newOwnerID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ShareURL grants permission to url owned by ownerID for userID.
+func (db *DBStorage) ShareURL(
+	ctx context.Context,
+	shortURL int64,
+	ownerID uint32,
+	userID uint32,
+	permission Permission,
+) error {
+	query := "INSERT INTO url_shares (shortener_id, user_id, permission) " +
+		"SELECT shortener_id, $3, $4 FROM shortener WHERE shortener_id = $1 AND user_id = $2 AND NOT is_deleted " +
+		"ON CONFLICT (shortener_id, user_id) DO UPDATE SET permission = excluded.permission;"
+	tag, err := db.conn.Exec(ctx, query, shortURL, ownerID, userID, string(permission))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return &URLNotFoundError{}
+	}
+	return nil
+}
+
+// UnshareURL revokes access to url owned by ownerID from userID.
+func (db *DBStorage) UnshareURL(ctx context.Context, shortURL int64, ownerID uint32, userID uint32) error {
+	query := "SELECT EXISTS (SELECT 1 FROM shortener WHERE shortener_id = $1 AND user_id = $2 AND NOT is_deleted);"
+	var isOwner bool
+	if err := db.conn.QueryRow(ctx, query, shortURL, ownerID).Scan(&isOwner); err != nil {
+		return err
+	}
+	if !isOwner {
+		return &URLNotFoundError{}
+	}
+	_, err := db.conn.Exec(ctx, "DELETE FROM url_shares WHERE shortener_id = $1 AND user_id = $2;", shortURL, userID)
+	return err
+}
+
+// CreateWorkspace creates workspace with ownerID as RoleOwner member.
+func (db *DBStorage) CreateWorkspace(ctx context.Context, name string, ownerID uint32) (Workspace, error) {
+	tx, err := db.conn.Begin(ctx)
+	if err != nil {
+		return Workspace{}, err
+	}
+	defer tx.Rollback(ctx)
+	workspace := Workspace{Name: name, Role: RoleOwner}
+	row := tx.QueryRow(ctx, "INSERT INTO workspaces (name) VALUES ($1) RETURNING workspace_id;", name)
+	if err = row.Scan(&workspace.ID); err != nil {
+		return Workspace{}, err
+	}
+	_, err = tx.Exec(
+		ctx,
+		"INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3);",
+		workspace.ID,
+		ownerID,
+		string(RoleOwner),
+	)
+	if err != nil {
+		return Workspace{}, err
+	}
+	return workspace, tx.Commit(ctx)
+}
+
+// GetWorkspaces returns all workspaces where user is member with his role.
+func (db *DBStorage) GetWorkspaces(ctx context.Context, userID uint32) ([]Workspace, error) {
+	query := "SELECT w.workspace_id, w.name, wm.role FROM workspaces w " +
+		"JOIN workspace_members wm ON wm.workspace_id = w.workspace_id WHERE wm.user_id = $1 ORDER BY w.workspace_id;"
+	rows, err := db.conn.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	workspaces := make([]Workspace, 0)
+	for rows.Next() {
+		var workspace Workspace
+		var role string
+		if err = rows.Scan(&workspace.ID, &workspace.Name, &role); err != nil {
+			return nil, err
+		}
+		workspace.Role = WorkspaceRole(role)
+		workspaces = append(workspaces, workspace)
+	}
+	return workspaces, rows.Err()
+}
+
+// GetWorkspaceRole returns role of user in workspace or WorkspaceNotFoundError if user is not member.
+func (db *DBStorage) GetWorkspaceRole(ctx context.Context, workspaceID int64, userID uint32) (WorkspaceRole, error) {
+	query := "SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2;"
+	var role string
+	if err := db.conn.QueryRow(ctx, query, workspaceID, userID).Scan(&role); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", &WorkspaceNotFoundError{}
+		}
+		return "", err
+	}
+	return WorkspaceRole(role), nil
+}
+
+// GetWorkspaceMembers returns all members of workspace.
+func (db *DBStorage) GetWorkspaceMembers(ctx context.Context, workspaceID int64) ([]WorkspaceMember, error) {
+	query := "SELECT user_id, role FROM workspace_members WHERE workspace_id = $1 ORDER BY user_id;"
+	rows, err := db.conn.Query(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := make([]WorkspaceMember, 0)
+	for rows.Next() {
+		var member WorkspaceMember
+		var role string
+		if err = rows.Scan(&member.UserID, &role); err != nil {
+			return nil, err
+		}
+		member.Role = WorkspaceRole(role)
+		members = append(members, member)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, &WorkspaceNotFoundError{}
+	}
+	return members, nil
+}
+
+// SetWorkspaceMember adds member to workspace or changes his role.
+func (db *DBStorage) SetWorkspaceMember(
+	ctx context.Context,
+	workspaceID int64,
+	userID uint32,
+	role WorkspaceRole,
+) error {
+	query := "INSERT INTO workspace_members (workspace_id, user_id, role) " +
+		"SELECT workspace_id, $2, $3 FROM workspaces WHERE workspace_id = $1 " +
+		"ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = excluded.role;"
+	tag, err := db.conn.Exec(ctx, query, workspaceID, userID, string(role))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return &WorkspaceNotFoundError{}
+	}
+	return nil
+}
+
+// RemoveWorkspaceMember removes member from workspace.
+func (db *DBStorage) RemoveWorkspaceMember(ctx context.Context, workspaceID int64, userID uint32) error {
+	query := "DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2;"
+	_, err := db.conn.Exec(ctx, query, workspaceID, userID)
+	return err
+}
+
+// SaveUTMTemplate creates user template or replaces template with the same name.
+func (db *DBStorage) SaveUTMTemplate(ctx context.Context, userID uint32, template UTMTemplate) error {
+	query := "INSERT INTO utm_templates (user_id, name, utm_source, utm_medium, utm_campaign, utm_term, utm_content) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (user_id, name) DO UPDATE SET " +
+		"utm_source = excluded.utm_source, utm_medium = excluded.utm_medium, utm_campaign = excluded.utm_campaign, " +
+		"utm_term = excluded.utm_term, utm_content = excluded.utm_content;"
+	_, err := db.conn.Exec(
+		ctx,
+		query,
+		userID,
+		template.Name,
+		template.Source,
+		template.Medium,
+		template.Campaign,
+		template.Term,
+		template.Content,
+	)
+	return err
+}
+
+// GetUTMTemplates returns all templates of user sorted by name.
+func (db *DBStorage) GetUTMTemplates(ctx context.Context, userID uint32) ([]UTMTemplate, error) {
+	query := "SELECT name, utm_source, utm_medium, utm_campaign, utm_term, utm_content " +
+		"FROM utm_templates WHERE user_id = $1 ORDER BY name;"
+	rows, err := db.conn.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	templates := make([]UTMTemplate, 0)
+	for rows.Next() {
+		template, err := scanUTMTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, rows.Err()
+}
+
+// GetUTMTemplate returns user template by name or UTMTemplateNotFoundError.
+func (db *DBStorage) GetUTMTemplate(ctx context.Context, userID uint32, name string) (UTMTemplate, error) {
+	query := "SELECT name, utm_source, utm_medium, utm_campaign, utm_term, utm_content " +
+		"FROM utm_templates WHERE user_id = $1 AND name = $2;"
+	template, err := scanUTMTemplate(db.conn.QueryRow(ctx, query, userID, name))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return UTMTemplate{}, &UTMTemplateNotFoundError{}
+		}
+		return UTMTemplate{}, err
+	}
+	return template, nil
+}
+
+// DeleteUTMTemplate deletes user template by name or returns UTMTemplateNotFoundError.
+func (db *DBStorage) DeleteUTMTemplate(ctx context.Context, userID uint32, name string) error {
+	tag, err := db.conn.Exec(ctx, "DELETE FROM utm_templates WHERE user_id = $1 AND name = $2;", userID, name)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return &UTMTemplateNotFoundError{}
+	}
+	return nil
+}
+
+// AddDomain registers domain. Returns DomainConflictError if domain is already registered.
+func (db *DBStorage) AddDomain(ctx context.Context, domain Domain) error {
+	tag, err := db.conn.Exec(
+		ctx,
+		"INSERT INTO domains (name, owner_id, created_at) VALUES ($1, $2, $3) ON CONFLICT (name) DO NOTHING;",
+		domain.Name,
+		domain.OwnerID,
+		domain.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return &DomainConflictError{}
+	}
+	return nil
+}
+
+// GetDomain returns registered domain by name or DomainNotFoundError.
+func (db *DBStorage) GetDomain(ctx context.Context, name string) (Domain, error) {
+	domain := Domain{Name: name}
+	err := db.conn.QueryRow(ctx, "SELECT owner_id, created_at FROM domains WHERE name = $1;", name).Scan(
+		&domain.OwnerID,
+		&domain.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Domain{}, &DomainNotFoundError{}
+		}
+		return Domain{}, err
+	}
+	return domain, nil
+}
+
+// GetDomains returns all domains of owner sorted by name.
+func (db *DBStorage) GetDomains(ctx context.Context, ownerID uint32) ([]Domain, error) {
+	rows, err := db.conn.Query(ctx, "SELECT name, created_at FROM domains WHERE owner_id = $1 ORDER BY name;", ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	domains := make([]Domain, 0)
+	for rows.Next() {
+		domain := Domain{OwnerID: ownerID}
+		if err = rows.Scan(&domain.Name, &domain.CreatedAt); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+// RemoveDomain removes domain of owner if it has no urls.
+func (db *DBStorage) RemoveDomain(ctx context.Context, name string, ownerID uint32) error {
+	tag, err := db.conn.Exec(
+		ctx,
+		"DELETE FROM domains WHERE name = $1 AND owner_id = $2 AND NOT EXISTS (SELECT 1 FROM shortener WHERE short_domain = $1);",
+		name,
+		ownerID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() != 0 {
+		return nil
+	}
+	domain, err := db.GetDomain(ctx, name)
+	if err != nil {
+		return err
+	}
+	if domain.OwnerID != ownerID {
+		return &DomainNotFoundError{}
+	}
+	return &DomainInUseError{}
+}
+
+// SaveDeleteJob creates or updates delete job.
+func (db *DBStorage) SaveDeleteJob(ctx context.Context, job DeleteJob) error {
+	query := "INSERT INTO deletion_jobs (job_id, user_id, urls, status, total, processed, attempts, last_error, created_at, updated_at) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) " +
+		"ON CONFLICT (job_id) DO UPDATE SET status = $4, processed = $6, attempts = $7, last_error = $8, updated_at = $10;"
+	_, err := db.conn.Exec(
+		ctx,
+		query,
+		job.ID,
+		job.UserID,
+		job.URLs,
+		string(job.Status),
+		job.Total,
+		job.Processed,
+		job.Attempts,
+		job.LastError,
+		job.CreatedAt,
+		job.UpdatedAt,
+	)
+	return err
+}
+
+// GetDeleteJob returns delete job by id or DeleteJobNotFoundError.
+func (db *DBStorage) GetDeleteJob(ctx context.Context, jobID string) (DeleteJob, error) {
+	query := "SELECT job_id, user_id, urls, status, total, processed, attempts, last_error, created_at, updated_at " +
+		"FROM deletion_jobs WHERE job_id = $1;"
+	row := db.conn.QueryRow(ctx, query, jobID)
+	job, err := scanDeleteJob(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return DeleteJob{}, &DeleteJobNotFoundError{}
+		}
+		return DeleteJob{}, err
+	}
+	return job, nil
+}
+
+// GetPendingDeleteJobs returns all jobs which are not finished yet.
+func (db *DBStorage) GetPendingDeleteJobs(ctx context.Context) ([]DeleteJob, error) {
+	query := "SELECT job_id, user_id, urls, status, total, processed, attempts, last_error, created_at, updated_at " +
+		"FROM deletion_jobs WHERE status = $1 ORDER BY created_at;"
+	rows, err := db.conn.Query(ctx, query, string(DeleteJobPending))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := make([]DeleteJob, 0)
+	for rows.Next() {
+		job, err := scanDeleteJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
 // Close closes everything that should be closed in the context of the repository.
 func (db *DBStorage) Close() error {
 	return db.conn.Close(db.ctx)
 }
 
-// convertShortIDs create array with short ids.
-func convertShortIDs(urlsForDelete []DeleteURL) pq.Int64Array {
+// scanUTMTemplate returns utm template from row.
+func scanUTMTemplate(row pgx.Row) (UTMTemplate, error) {
+	var template UTMTemplate
+	err := row.Scan(
+		&template.Name,
+		&template.Source,
+		&template.Medium,
+		&template.Campaign,
+		&template.Term,
+		&template.Content,
+	)
+	return template, err
+}
+
+// scanDeleteJob scans delete job from row.
+func scanDeleteJob(row pgx.Row) (DeleteJob, error) {
+	var job DeleteJob
+	var status string
+	err := row.Scan(
+		&job.ID,
+		&job.UserID,
+		&job.URLs,
+		&status,
+		&job.Total,
+		&job.Processed,
+		&job.Attempts,
+		&job.LastError,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err != nil {
+		return DeleteJob{}, err
+	}
+	job.Status = DeleteJobStatus(status)
+	return job, nil
+}
+
+// visibleURLsQueries returns queries of urls owned by user, urls shared with him and urls of his workspaces
+// matched filter with their args. Every query reads urls by its index, url is selected by only one query.
+// Queries select the same columns and end with WHERE clause, cursor is not applied.
+func visibleURLsQueries(userID uint32, filter URLFilter) ([]string, []interface{}) {
+	args := []interface{}{userID}
+	var where []string
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+	switch filter.Status {
+	case StatusActive:
+		where = append(where, "NOT s.is_deleted", "(s.activates_at IS NULL OR s.activates_at <= now())")
+	case StatusScheduled:
+		where = append(where, "NOT s.is_deleted", "s.activates_at > now()")
+	case StatusDeleted:
+		where = append(where, "s.is_deleted")
+	}
+	if len(filter.Domain) != 0 {
+		addCondition("s.long_url_host = $%d", strings.ToLower(filter.Domain))
+	}
+	if len(filter.ShortDomain) != 0 {
+		addCondition("s.short_domain = $%d", filter.ShortDomain)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		addCondition("s.created_at >= $%d", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		addCondition("s.created_at < $%d", filter.CreatedTo)
+	}
+	if len(filter.Tag) != 0 {
+		addCondition("$%d = ANY(s.tags)", filter.Tag)
+	}
+	filters := ""
+	if len(where) != 0 {
+		filters = " AND " + strings.Join(where, " AND ")
+	}
+	columns := func(permission string, role string) string {
+		return "SELECT s.shortener_id AS shortener_id, s.long_url, s.user_id = $1, s.workspace_id, " +
+			permission + ", " + role + ", s.created_at AS created_at, s.clicks AS clicks, s.tags, s.is_deleted, " +
+			"s.title, s.description, s.updated_at, s.preview, s.redirect_type, s.passthrough, s.query_conflict, s.rules, s.variants, s.password_hash <> '', " +
+			"s.max_clicks, CASE WHEN s.max_clicks > 0 THEN s.clicks_left END, s.activates_at, " +
+			"NOT s.is_deleted AND COALESCE(s.activates_at > now(), false), s.short_domain "
+	}
+	queries := []string{
+		columns("''", "''") +
+			"FROM shortener s WHERE s.user_id = $1" + filters,
+		columns("sh.permission", "COALESCE(wm.role, '')") +
+			"FROM url_shares sh JOIN shortener s ON s.shortener_id = sh.shortener_id " +
+			"LEFT JOIN workspace_members wm ON wm.workspace_id = s.workspace_id AND wm.user_id = $1 " +
+			"WHERE sh.user_id = $1 AND s.user_id <> $1" + filters,
+		// urls of workspace shared with user are selected by previous query
+		columns("''", "wm.role") +
+			"FROM workspace_members wm JOIN shortener s ON s.workspace_id = wm.workspace_id " +
+			"WHERE wm.user_id = $1 AND s.user_id <> $1 " +
+			"AND NOT EXISTS (SELECT 1 FROM url_shares sh WHERE sh.shortener_id = s.shortener_id AND sh.user_id = $1)" + filters,
+	}
+	return queries, args
+}
+
+// accessCondition returns condition that user is owner of url from table or has permission to it or its workspace.
+// Query args with numbers firstArg and firstArg+1 must be grantingPermissions and grantingRoles of permission.
+func accessCondition(table string, user string, firstArg int) string {
+	return fmt.Sprintf(
+		"(%[1]s.user_id = %[2]s OR EXISTS ("+
+			"SELECT 1 FROM url_shares sh WHERE sh.shortener_id = %[1]s.shortener_id AND sh.user_id = %[2]s AND sh.permission = ANY($%[3]d)"+
+			") OR EXISTS ("+
+			"SELECT 1 FROM workspace_members wm WHERE wm.workspace_id = %[1]s.workspace_id AND wm.user_id = %[2]s AND wm.role = ANY($%[4]d)))",
+		table,
+		user,
+		firstArg,
+		firstArg+1,
+	)
+}
+
+// grantingPermissions returns permissions to url which allow permission.
+func grantingPermissions(permission Permission) []string {
+	res := make([]string, 0, len(permissionLevels))
+	for p := range permissionLevels {
+		if p.Allows(permission) {
+			res = append(res, string(p))
+		}
+	}
+	return res
+}
+
+// grantingRoles returns workspace roles which allow permission to workspace urls.
+func grantingRoles(permission Permission) []string {
+	res := make([]string, 0)
+	for _, role := range []WorkspaceRole{RoleOwner, RoleEditor, RoleViewer} {
+		if role.Permission().Allows(permission) {
+			res = append(res, string(role))
+		}
+	}
+	return res
+}
+
+// notNilTags returns empty tags instead of nil for NOT NULL column.
+func notNilTags(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// convertShortIDs create arrays with short ids and user ids of the same length.
+func convertShortIDs(urlsForDelete []DeleteURL) (pq.Int64Array, pq.Int64Array) {
 	shortIDs := pq.Int64Array{}
+	userIDs := pq.Int64Array{}
 	for _, url := range urlsForDelete {
 		shortID, err := strconv.ParseInt(url.URL, 10, 64)
 		if err != nil {
 			continue
 		}
 		shortIDs = append(shortIDs, shortID)
+		userIDs = append(userIDs, int64(url.UserID))
 	}
-	return shortIDs
+	return shortIDs, userIDs
 }
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// DeleteJobStatus status of delete job.
+type DeleteJobStatus string
+
+// Delete job statuses.
+const (
+	// DeleteJobPending - job waits in queue or waits for retry.
+	DeleteJobPending DeleteJobStatus = "pending"
+	// DeleteJobDone - all urls from job were deleted.
+	DeleteJobDone DeleteJobStatus = "done"
+	// DeleteJobFailed - job exhausted all attempts and moved to dead-letter list.
+	DeleteJobFailed DeleteJobStatus = "failed"
+)
+
+// DeleteJobNotFoundError an error that occurs when delete job does not exist.
+type DeleteJobNotFoundError struct {
+}
+
+// Error return DeleteJobNotFoundError description.
+func (e *DeleteJobNotFoundError) Error() string {
+	return "Delete job not found"
+}
+
+// DeleteJob contains info about request for delete urls.
+type DeleteJob struct {
+	// ID - unique job id.
+	ID string `json:"job_id"`
+	// UserID - id user, who created this job.
+	UserID uint32 `json:"user_id"`
+	// URLs - short urls for delete.
+	URLs []string `json:"urls"`
+	// Status - current job status.
+	Status DeleteJobStatus `json:"status"`
+	// Total - count urls in job.
+	Total int `json:"total"`
+	// Processed - count processed urls.
+	Processed int `json:"processed"`
+	// Attempts - count failed attempts.
+	Attempts int `json:"attempts"`
+	// LastError - error of last failed attempt.
+	LastError string `json:"last_error,omitempty"`
+	// CreatedAt - job creation time.
+	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt - job last update time.
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DeleteJobStore define api for persist delete jobs.
+type DeleteJobStore interface {
+	// SaveDeleteJob creates or updates delete job.
+	SaveDeleteJob(ctx context.Context, job DeleteJob) error
+
+	// GetDeleteJob returns delete job by id or DeleteJobNotFoundError.
+	GetDeleteJob(ctx context.Context, jobID string) (DeleteJob, error)
+
+	// GetPendingDeleteJobs returns all jobs which are not finished yet.
+	GetPendingDeleteJobs(ctx context.Context) ([]DeleteJob, error)
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+)
+
+// DeleteJobJournal contains data for store delete jobs in journal file.
+// Every job change appends new job snapshot in file, the last snapshot wins.
+// If filename is empty jobs are stored only in memory.
+type DeleteJobJournal struct {
+	sync.RWMutex
+	filename string
+	jobs     map[string]DeleteJob
+}
+
+// NewDeleteJobJournal returns new DeleteJobJournal and restores jobs from filename.
+func NewDeleteJobJournal(filename string) (*DeleteJobJournal, error) {
+	j := &DeleteJobJournal{
+		filename: filename,
+		jobs:     make(map[string]DeleteJob),
+	}
+	if len(filename) == 0 {
+		return j, nil
+	}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// SaveDeleteJob creates or updates delete job.
+func (j *DeleteJobJournal) SaveDeleteJob(ctx context.Context, job DeleteJob) error {
+	j.Lock()
+	defer j.Unlock()
+	if len(j.filename) != 0 {
+		file, err := os.OpenFile(j.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(&job)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if _, err = file.Write(append(data, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+		if err = file.Close(); err != nil {
+			return err
+		}
+	}
+	j.jobs[job.ID] = job
+	return nil
+}
+
+// GetDeleteJob returns delete job by id or DeleteJobNotFoundError.
+func (j *DeleteJobJournal) GetDeleteJob(ctx context.Context, jobID string) (DeleteJob, error) {
+	j.RLock()
+	defer j.RUnlock()
+	job, ok := j.jobs[jobID]
+	if !ok {
+		return DeleteJob{}, &DeleteJobNotFoundError{}
+	}
+	return job, nil
+}
+
+// GetPendingDeleteJobs returns all jobs which are not finished yet.
+func (j *DeleteJobJournal) GetPendingDeleteJobs(ctx context.Context) ([]DeleteJob, error) {
+	j.RLock()
+	defer j.RUnlock()
+	jobs := make([]DeleteJob, 0)
+	for _, job := range j.jobs {
+		if job.Status == DeleteJobPending {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(a, b int) bool {
+		return jobs[a].CreatedAt.Before(jobs[b].CreatedAt)
+	})
+	return jobs, nil
+}
+
+// load reads all job snapshots from journal file.
+func (j *DeleteJobJournal) load() error {
+	file, err := os.OpenFile(j.filename, os.O_RDONLY|os.O_CREATE, 0777)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var job DeleteJob
+		if err = json.Unmarshal(scanner.Bytes(), &job); err != nil {
+			// skip partially written row
+			continue
+		}
+		j.jobs[job.ID] = job
+	}
+	return scanner.Err()
+}
+
+// compact rewrites journal file with the last snapshot of every job.
+func (j *DeleteJobJournal) compact() error {
+	tmpName := j.filename + ".tmp"
+	file, err := os.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return err
+	}
+	wr := bufio.NewWriter(file)
+	for _, job := range j.jobs {
+		data, err := json.Marshal(&job)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if _, err = wr.Write(append(data, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err = wr.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, j.filename)
+}
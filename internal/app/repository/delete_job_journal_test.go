@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDeleteJobJournal_SaveDeleteJob(t *testing.T) {
+	journal, err := NewDeleteJobJournal("")
+	require.NoError(t, err)
+	job := DeleteJob{
+		ID:     "1",
+		UserID: 1,
+		URLs:   []string{"1", "2"},
+		Status: DeleteJobPending,
+		Total:  2,
+	}
+	err = journal.SaveDeleteJob(context.TODO(), job)
+	require.NoError(t, err)
+
+	actual, err := journal.GetDeleteJob(context.TODO(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, job, actual)
+
+	_, err = journal.GetDeleteJob(context.TODO(), "2")
+	assert.ErrorIs(t, err, &DeleteJobNotFoundError{})
+}
+
+func TestDeleteJobJournal_restore(t *testing.T) {
+	defer os.Remove("test_jobs")
+	journal, err := NewDeleteJobJournal("test_jobs")
+	require.NoError(t, err)
+	now := time.Now().UTC().Truncate(time.Second)
+	jobs := []DeleteJob{
+		{ID: "1", UserID: 1, URLs: []string{"1"}, Status: DeleteJobPending, Total: 1, CreatedAt: now},
+		{ID: "2", UserID: 1, URLs: []string{"2"}, Status: DeleteJobPending, Total: 1, CreatedAt: now.Add(time.Second)},
+		{ID: "1", UserID: 1, URLs: []string{"1"}, Status: DeleteJobDone, Total: 1, Processed: 1, CreatedAt: now},
+	}
+	for _, job := range jobs {
+		require.NoError(t, journal.SaveDeleteJob(context.TODO(), job))
+	}
+
+	restored, err := NewDeleteJobJournal("test_jobs")
+	require.NoError(t, err)
+	pending, err := restored.GetPendingDeleteJobs(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, []DeleteJob{jobs[1]}, pending)
+
+	done, err := restored.GetDeleteJob(context.TODO(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, DeleteJobDone, done.Status)
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// DomainNotFoundError an error that occurs when domain is not registered or not owned by user.
+type DomainNotFoundError struct {
+}
+
+// Error return DomainNotFoundError description.
+func (e *DomainNotFoundError) Error() string {
+	return "Domain not found"
+}
+
+// DomainConflictError an error that occurs when domain is already registered.
+type DomainConflictError struct {
+}
+
+// Error return DomainConflictError description.
+func (e *DomainConflictError) Error() string {
+	return "Domain already registered"
+}
+
+// DomainInUseError an error that occurs when removed domain has urls.
+type DomainInUseError struct {
+}
+
+// Error return DomainInUseError description.
+func (e *DomainInUseError) Error() string {
+	return "Domain has urls"
+}
+
+// Domain custom domain of short urls, urls without domain are served on host of service base url.
+type Domain struct {
+	// Name - lowercase host of domain without port.
+	Name string `json:"name"`
+	// OwnerID - user who registered domain, only he creates urls on it.
+	OwnerID uint32 `json:"owner_id"`
+	// CreatedAt - domain registration time.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DomainStore define api for work with custom domains of short urls.
+// Ownership is checked by caller, store only keeps it.
+type DomainStore interface {
+	// AddDomain registers domain. Returns DomainConflictError if domain is already registered.
+	AddDomain(ctx context.Context, domain Domain) error
+
+	// GetDomain returns registered domain by name or DomainNotFoundError.
+	GetDomain(ctx context.Context, name string) (Domain, error)
+
+	// GetDomains returns all domains of owner sorted by name.
+	GetDomains(ctx context.Context, ownerID uint32) ([]Domain, error)
+
+	// RemoveDomain removes domain of owner.
+	// Returns DomainNotFoundError if domain is not registered or not owned by ownerID, DomainInUseError if domain has urls.
+	RemoveDomain(ctx context.Context, name string, ownerID uint32) error
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// domainRecord domain change stored in journal.
+type domainRecord struct {
+	Domain    Domain `json:"domain"`
+	IsDeleted bool   `json:"is_deleted,omitempty"`
+}
+
+// domainJournal contains custom domains for in memory and local storages.
+// Every domain change appends record in file, the last record of domain wins.
+// If filename is empty domains are stored only in memory.
+type domainJournal struct {
+	sync.RWMutex
+	filename string
+	domains  map[string]Domain
+}
+
+// newDomainJournal returns new domainJournal and restores domains from filename.
+// File is created on first domain change.
+func newDomainJournal(filename string) (*domainJournal, error) {
+	j := &domainJournal{
+		filename: filename,
+		domains:  make(map[string]Domain),
+	}
+	if len(filename) == 0 {
+		return j, nil
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return j, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record domainRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// skip partially written row
+			continue
+		}
+		j.apply(record)
+	}
+	return j, scanner.Err()
+}
+
+// AddDomain registers domain. Returns DomainConflictError if domain is already registered.
+func (j *domainJournal) AddDomain(ctx context.Context, domain Domain) error {
+	j.Lock()
+	defer j.Unlock()
+	if _, ok := j.domains[domain.Name]; ok {
+		return &DomainConflictError{}
+	}
+	return j.save(domainRecord{Domain: domain})
+}
+
+// GetDomain returns registered domain by name or DomainNotFoundError.
+func (j *domainJournal) GetDomain(ctx context.Context, name string) (Domain, error) {
+	j.RLock()
+	defer j.RUnlock()
+	domain, ok := j.domains[name]
+	if !ok {
+		return Domain{}, &DomainNotFoundError{}
+	}
+	return domain, nil
+}
+
+// GetDomains returns all domains of owner sorted by name.
+func (j *domainJournal) GetDomains(ctx context.Context, ownerID uint32) ([]Domain, error) {
+	j.RLock()
+	defer j.RUnlock()
+	domains := make([]Domain, 0)
+	for _, domain := range j.domains {
+		if domain.OwnerID == ownerID {
+			domains = append(domains, domain)
+		}
+	}
+	sort.Slice(domains, func(a, b int) bool {
+		return domains[a].Name < domains[b].Name
+	})
+	return domains, nil
+}
+
+// removeDomain removes domain of owner, caller checks that domain has no urls.
+func (j *domainJournal) removeDomain(name string, ownerID uint32) error {
+	j.Lock()
+	defer j.Unlock()
+	if domain, ok := j.domains[name]; !ok || domain.OwnerID != ownerID {
+		return &DomainNotFoundError{}
+	}
+	return j.save(domainRecord{Domain: Domain{Name: name}, IsDeleted: true})
+}
+
+// save appends record in file and applies it in memory, j must be locked.
+func (j *domainJournal) save(record domainRecord) error {
+	if len(j.filename) != 0 {
+		file, err := os.OpenFile(j.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(&record)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if _, err = file.Write(append(data, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+		if err = file.Close(); err != nil {
+			return err
+		}
+	}
+	j.apply(record)
+	return nil
+}
+
+// apply applies record to domains in memory.
+func (j *domainJournal) apply(record domainRecord) {
+	if record.IsDeleted {
+		delete(j.domains, record.Domain.Name)
+		return
+	}
+	j.domains[record.Domain.Name] = record.Domain
+}
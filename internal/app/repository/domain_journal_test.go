@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDomainJournal(t *testing.T) {
+	ctx := context.TODO()
+	j, err := newDomainJournal("test_domains")
+	require.NoError(t, err)
+	defer os.Remove("test_domains")
+
+	now := time.Now().UTC().Truncate(time.Second)
+	brand := Domain{Name: "go.brand.com", OwnerID: 1, CreatedAt: now}
+	shop := Domain{Name: "go.shop.com", OwnerID: 1, CreatedAt: now}
+	require.NoError(t, j.AddDomain(ctx, shop))
+	require.NoError(t, j.AddDomain(ctx, brand))
+	require.NoError(t, j.AddDomain(ctx, Domain{Name: "old.com", OwnerID: 1, CreatedAt: now}))
+	assert.ErrorIs(t, j.AddDomain(ctx, Domain{Name: "go.brand.com", OwnerID: 2}), &DomainConflictError{})
+	assert.ErrorIs(t, j.removeDomain("old.com", 2), &DomainNotFoundError{})
+	require.NoError(t, j.removeDomain("old.com", 1))
+
+	// domains are restored from file
+	j, err = newDomainJournal("test_domains")
+	require.NoError(t, err)
+	domains, err := j.GetDomains(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []Domain{brand, shop}, domains)
+	_, err = j.GetDomain(ctx, "old.com")
+	assert.ErrorIs(t, err, &DomainNotFoundError{})
+	domains, err = j.GetDomains(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, domains)
+}
@@ -2,10 +2,10 @@ package repository
 
 import (
 	"context"
-	"fmt"
 	"go-axesthump-shortener/internal/app/generator"
 	"strconv"
 	"sync"
+	"time"
 )
 
 // StorageURL url info.
@@ -13,40 +13,121 @@ type StorageURL struct {
 	url       string
 	userID    uint32
 	isDeleted bool
+	// shares - users who have access to url.
+	shares map[uint32]Permission
+	// workspaceID - workspace which contains url, 0 if url is personal.
+	workspaceID int64
+	createdAt   time.Time
+	updatedAt   time.Time
+	clicks      int64
+	tags        []string
+	title       string
+	description string
+	// history - destinations of url, empty if url was never changed.
+	history []URLVersion
+	// preview - metadata of destination page.
+	preview *URLPreview
+	// redirectType - redirect status of url.
+	redirectType RedirectType
+	// passthrough - parts of request passed to destination.
+	passthrough PassthroughMode
+	// queryConflict - policy of query params merge.
+	queryConflict QueryConflict
+	// rules - ordered conditional destinations.
+	rules []RedirectRule
+	// variants - weighted destinations of A/B split.
+	variants []URLVariant
+	// passwordHash - hash of password required to open url.
+	passwordHash string
+	// maxClicks - count of redirects after which url stops working, 0 if url is unlimited.
+	maxClicks int64
+	// clicksLeft - count of redirects left by url with max clicks.
+	clicksLeft int64
+	// activatesAt - time from which url redirects, nil if url is active at once.
+	activatesAt *time.Time
+	// shortDomain - custom domain of url.
+	shortDomain string
 }
 
 // InMemoryStorage contains data for in memory storage.
 type InMemoryStorage struct {
 	sync.RWMutex
+	*workspaceJournal
+	*utmJournal
+	*domainJournal
 	userURLs    map[int64]*StorageURL
-	idGenerator *generator.IDGenerator
+	idGenerator generator.Allocator
+	// index - urls of users sorted for pagination.
+	index urlIndex
 }
 
 // NewInMemoryStorage returns new InMemoryStorage.
 func NewInMemoryStorage() *InMemoryStorage {
+	workspaces, _ := newWorkspaceJournal("")
+	templates, _ := newUTMJournal("")
+	domains, _ := newDomainJournal("")
 	return &InMemoryStorage{
-		userURLs:    make(map[int64]*StorageURL),
-		idGenerator: generator.NewIDGenerator(0),
+		workspaceJournal: workspaces,
+		utmJournal:       templates,
+		domainJournal:    domains,
+		userURLs:         make(map[int64]*StorageURL),
+		idGenerator:      generator.NewIDGenerator(0),
 	}
 }
 
-// CreateShortURL create short url. Returns short url if operations success or error.
+// CreateShortURL create short url. Returns code of url if operations success or error.
 func (s *InMemoryStorage) CreateShortURL(
 	ctx context.Context,
-	beginURL string,
 	originalURL string,
 	userID uint32,
-) (string, error) {
-	newShortURL := s.idGenerator.GetID()
-	shortEndpoint := strconv.FormatInt(newShortURL, 10)
-	shortURL := beginURL + shortEndpoint
+	opts URLOptions,
+) (int64, error) {
 	s.Lock()
-	s.userURLs[newShortURL] = &StorageURL{
-		url:    originalURL,
-		userID: userID,
+	defer s.Unlock()
+	codes, err := generator.UniqueIDs(ctx, s.idGenerator, 1, s.reserveCode(nil))
+	if err != nil {
+		return 0, err
+	}
+	s.setURL(codes[0], newStorageURL(originalURL, userID, opts, time.Now()))
+	return codes[0], nil
+}
+
+// reserveCode returns reserve function of generator.UniqueIDs for codes which are not used by urls and reserved.
+// Reserved codes are added to reserved, s must be locked.
+func (s *InMemoryStorage) reserveCode(reserved map[int64]bool) func(code int64) bool {
+	return func(code int64) bool {
+		if _, ok := s.userURLs[code]; ok || reserved[code] {
+			return false
+		}
+		if reserved != nil {
+			reserved[code] = true
+		}
+		return true
+	}
+}
+
+// newStorageURL returns new url of user created at now.
+func newStorageURL(originalURL string, userID uint32, opts URLOptions, now time.Time) *StorageURL {
+	return &StorageURL{
+		url:           originalURL,
+		userID:        userID,
+		workspaceID:   opts.WorkspaceID,
+		createdAt:     now,
+		updatedAt:     now,
+		tags:          opts.Tags,
+		title:         opts.Title,
+		description:   opts.Description,
+		redirectType:  opts.RedirectType,
+		passthrough:   opts.Passthrough,
+		queryConflict: opts.QueryConflict,
+		rules:         opts.Rules,
+		variants:      withoutClicks(opts.Variants),
+		passwordHash:  opts.PasswordHash,
+		maxClicks:     opts.MaxClicks,
+		clicksLeft:    opts.MaxClicks,
+		activatesAt:   opts.ActivatesAt,
+		shortDomain:   opts.ShortDomain,
 	}
-	s.Unlock()
-	return shortURL, nil
 }
 
 // GetFullURL returns full url by short url.
@@ -59,70 +140,389 @@ func (s *InMemoryStorage) GetFullURL(ctx context.Context, shortURL int64) (strin
 		}
 		return url.url, nil
 	}
-	return "", fmt.Errorf("URL dont exist")
+	return "", &URLNotFoundError{}
 }
 
-// GetAllURLs returns all urls owned specific user.
-func (s *InMemoryStorage) GetAllURLs(ctx context.Context, beginURL string, userID uint32) []URLInfo {
+// GetRedirect returns data for redirect by not deleted short url.
+func (s *InMemoryStorage) GetRedirect(ctx context.Context, shortURL int64) (Redirect, error) {
 	s.RLock()
 	defer s.RUnlock()
+	url, ok := s.userURLs[shortURL]
+	if !ok {
+		return Redirect{}, &URLNotFoundError{}
+	}
+	if url.isDeleted {
+		return Redirect{}, &DeletedURLError{}
+	}
+	return Redirect{
+		OriginalURL:   url.url,
+		Type:          url.redirectType,
+		Passthrough:   url.passthrough,
+		QueryConflict: url.queryConflict,
+		Rules:         url.rules,
+		Variants:      url.variants,
+		PasswordHash:  url.passwordHash,
+		MaxClicks:     url.maxClicks,
+		ClicksLeft:    url.clicksLeft,
+		ActivatesAt:   url.activatesAt,
+		ShortDomain:   url.shortDomain,
+	}, nil
+}
 
-	urls := make([]URLInfo, 0, len(s.userURLs))
-	for shortURL, urlInfo := range s.userURLs {
-		if urlInfo.userID != userID {
-			continue
-		}
-		short := strconv.FormatInt(shortURL, 10)
-		url := URLInfo{
-			ShortURL:    beginURL + short,
-			OriginalURL: urlInfo.url,
-		}
-		urls = append(urls, url)
+// GetURLInfo returns info of not deleted url without its access settings.
+func (s *InMemoryStorage) GetURLInfo(ctx context.Context, shortURL int64) (URLInfo, error) {
+	s.RLock()
+	defer s.RUnlock()
+	url, ok := s.userURLs[shortURL]
+	if !ok {
+		return URLInfo{}, &URLNotFoundError{}
+	}
+	if url.isDeleted {
+		return URLInfo{}, &DeletedURLError{}
+	}
+	return url.info(shortURL), nil
+}
+
+// AddClick increments count of redirects by short url.
+func (s *InMemoryStorage) AddClick(ctx context.Context, shortURL int64) error {
+	s.Lock()
+	defer s.Unlock()
+	url, ok := s.userURLs[shortURL]
+	if !ok {
+		return &URLNotFoundError{}
+	}
+	url.clicks++
+	s.index.addClicks(shortURL, 1)
+	return nil
+}
+
+// ConsumeClick atomically decrements count of redirects left by url with max clicks.
+func (s *InMemoryStorage) ConsumeClick(ctx context.Context, shortURL int64) error {
+	s.Lock()
+	defer s.Unlock()
+	url, ok := s.userURLs[shortURL]
+	if !ok {
+		return &URLNotFoundError{}
+	}
+	if url.maxClicks <= 0 {
+		return nil
+	}
+	if url.isDeleted || url.clicksLeft <= 0 {
+		return &ClicksExhaustedError{}
+	}
+	url.clicksLeft--
+	return nil
+}
+
+// AddVariantClick increments count of redirects by short url and count of redirects to its variant.
+func (s *InMemoryStorage) AddVariantClick(ctx context.Context, shortURL int64, variant int) error {
+	s.Lock()
+	defer s.Unlock()
+	url, ok := s.userURLs[shortURL]
+	if !ok {
+		return &URLNotFoundError{}
+	}
+	url.clicks++
+	s.index.addClicks(shortURL, 1)
+	if variant >= 0 && variant < len(url.variants) {
+		// variants are copied because GetRedirect returns them without lock
+		url.variants = append([]URLVariant(nil), url.variants...)
+		url.variants[variant].Clicks++
+	}
+	return nil
+}
+
+// SetURLPreview saves preview of url destination page.
+func (s *InMemoryStorage) SetURLPreview(
+	ctx context.Context,
+	shortURL int64,
+	originalURL string,
+	preview URLPreview,
+) error {
+	s.Lock()
+	defer s.Unlock()
+	url, ok := s.userURLs[shortURL]
+	if !ok || url.url != originalURL {
+		return &URLNotFoundError{}
+	}
+	url.preview = &preview
+	return nil
+}
+
+// GetAllURLs returns page of urls owned specific user, urls shared with him and urls of his workspaces.
+func (s *InMemoryStorage) GetAllURLs(
+	ctx context.Context,
+	userID uint32,
+	filter URLFilter,
+) (URLPage, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.index.page(userID, s.memberWorkspaces(userID), filter, func(id int64) (URLInfo, bool, error) {
+		savedURL := s.userURLs[id]
+		info := savedURL.info(id)
+		return info, info.setOwnership(userID, savedURL.userID, savedURL.shares, s.workspaceJournal), nil
+	})
+}
+
+// UpdateURLMeta updates metadata and destination of not deleted url under one lock and returns updated url.
+func (s *InMemoryStorage) UpdateURLMeta(
+	ctx context.Context,
+	shortURL int64,
+	userID uint32,
+	update URLMetaUpdate,
+) (URLInfo, error) {
+	s.Lock()
+	defer s.Unlock()
+	savedURL, ok := s.userURLs[shortURL]
+	if !ok || savedURL.isDeleted ||
+		!s.canManage(userID, savedURL.userID, savedURL.shares, savedURL.workspaceID) ||
+		update.OriginalURL != nil && savedURL.userID != userID {
+		return URLInfo{}, &URLNotFoundError{}
+	}
+	if update.OriginalURL != nil {
+		savedURL.changeDestination(*update.OriginalURL)
+	}
+	info := savedURL.info(shortURL)
+	update.apply(&info)
+	savedURL.title = info.Title
+	savedURL.description = info.Description
+	savedURL.tags = info.Tags
+	savedURL.redirectType = info.RedirectType
+	savedURL.passthrough = info.Passthrough
+	savedURL.queryConflict = info.QueryConflict
+	savedURL.rules = info.Rules
+	savedURL.variants = info.Variants
+	if update.PasswordHash != nil {
+		savedURL.passwordHash = *update.PasswordHash
+	}
+	if update.MaxClicks != nil {
+		savedURL.maxClicks = *update.MaxClicks
+		savedURL.clicksLeft = *update.MaxClicks
+	}
+	savedURL.updatedAt = time.Now()
+	return savedURL.info(shortURL), nil
+}
+
+// ChangeURLDestination re-points url owned by ownerID to originalURL and adds it in url history.
+func (s *InMemoryStorage) ChangeURLDestination(
+	ctx context.Context,
+	shortURL int64,
+	ownerID uint32,
+	originalURL string,
+) error {
+	s.Lock()
+	defer s.Unlock()
+	savedURL, err := s.getOwnedURL(shortURL, ownerID)
+	if err != nil {
+		return err
 	}
-	return urls
+	savedURL.changeDestination(originalURL)
+	return nil
 }
 
-// CreateShortURLs create short urls. Returns slice short urls if operations success or error.
+// GetURLHistory returns all destinations of url from the first one.
+// User must be owner of url or have PermissionStats to url or its workspace.
+func (s *InMemoryStorage) GetURLHistory(ctx context.Context, shortURL int64, userID uint32) ([]URLVersion, error) {
+	s.RLock()
+	defer s.RUnlock()
+	savedURL, ok := s.userURLs[shortURL]
+	if !ok || savedURL.isDeleted ||
+		!s.hasPermission(userID, savedURL.userID, savedURL.shares, savedURL.workspaceID, PermissionStats) {
+		return nil, &URLNotFoundError{}
+	}
+	return currentHistory(savedURL.history, savedURL.url, savedURL.createdAt), nil
+}
+
+// RollbackURL re-points url owned by ownerID to destination of version from url history.
+func (s *InMemoryStorage) RollbackURL(ctx context.Context, shortURL int64, ownerID uint32, version int) error {
+	s.Lock()
+	defer s.Unlock()
+	savedURL, err := s.getOwnedURL(shortURL, ownerID)
+	if err != nil {
+		return err
+	}
+	originalURL, err := findVersion(currentHistory(savedURL.history, savedURL.url, savedURL.createdAt), version)
+	if err != nil {
+		return err
+	}
+	savedURL.changeDestination(originalURL)
+	return nil
+}
+
+// CreateShortURLs create short urls. Returns codes of urls if operations success or error.
 func (s *InMemoryStorage) CreateShortURLs(
 	ctx context.Context,
-	beginURL string,
 	urls []URLWithID,
 	userID uint32,
 ) ([]URLWithID, error) {
+	s.Lock()
+	defer s.Unlock()
+	codes, err := generator.UniqueIDs(ctx, s.idGenerator, len(urls), s.reserveCode(make(map[int64]bool, len(urls))))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
 	res := make([]URLWithID, 0, len(urls))
-	for _, url := range urls {
-		shortURL, err := s.CreateShortURL(ctx, beginURL, url.URL, userID)
-		if err != nil {
-			return nil, err
-		}
+	for i, url := range urls {
+		s.setURL(codes[i], newStorageURL(url.URL, userID, url.Options, now))
 		res = append(res, URLWithID{
 			CorrelationID: url.CorrelationID,
-			URL:           shortURL,
+			Code:          codes[i],
 		})
 	}
 	return res, nil
 }
 
 // DeleteURLs delete url from urlsForDelete.
+// Url is deleted only by its owner or user with PermissionManage to it or its workspace.
 func (s *InMemoryStorage) DeleteURLs(urlsForDelete []DeleteURL) error {
 	s.Lock()
+	defer s.Unlock()
 	for _, urlForDelete := range urlsForDelete {
 		shortURL, err := strconv.ParseInt(urlForDelete.URL, 10, 64)
 		if err != nil {
 			return err
 		}
 		if savedURL, ok := s.userURLs[shortURL]; ok {
-			if savedURL.userID == urlForDelete.UserID {
+			if s.canManage(urlForDelete.UserID, savedURL.userID, savedURL.shares, savedURL.workspaceID) {
 				savedURL.isDeleted = true
 			}
 		}
 	}
-	s.Unlock()
 	return nil
 }
 
+// TransferURL transfers url owned by ownerID to newOwnerID.
+func (s *InMemoryStorage) TransferURL(ctx context.Context, shortURL int64, ownerID uint32, newOwnerID uint32) error {
+	s.Lock()
+	defer s.Unlock()
+	savedURL, err := s.getOwnedURL(shortURL, ownerID)
+	if err != nil {
+		return err
+	}
+	savedURL.userID = newOwnerID
+	delete(savedURL.shares, newOwnerID)
+	s.setURL(shortURL, savedURL)
+	return nil
+}
+
+// ShareURL grants permission to url owned by ownerID for userID.
+func (s *InMemoryStorage) ShareURL(
+	ctx context.Context,
+	shortURL int64,
+	ownerID uint32,
+	userID uint32,
+	permission Permission,
+) error {
+	s.Lock()
+	defer s.Unlock()
+	savedURL, err := s.getOwnedURL(shortURL, ownerID)
+	if err != nil {
+		return err
+	}
+	if savedURL.shares == nil {
+		savedURL.shares = make(map[uint32]Permission)
+	}
+	savedURL.shares[userID] = permission
+	s.setURL(shortURL, savedURL)
+	return nil
+}
+
+// UnshareURL revokes access to url owned by ownerID from userID.
+func (s *InMemoryStorage) UnshareURL(ctx context.Context, shortURL int64, ownerID uint32, userID uint32) error {
+	s.Lock()
+	defer s.Unlock()
+	savedURL, err := s.getOwnedURL(shortURL, ownerID)
+	if err != nil {
+		return err
+	}
+	delete(savedURL.shares, userID)
+	s.setURL(shortURL, savedURL)
+	return nil
+}
+
+// RemoveDomain removes domain of owner if it has no urls.
+func (s *InMemoryStorage) RemoveDomain(ctx context.Context, name string, ownerID uint32) error {
+	domain, err := s.GetDomain(ctx, name)
+	if err != nil || domain.OwnerID != ownerID {
+		return &DomainNotFoundError{}
+	}
+	// lock prevents creation of urls on domain while it's removed
+	s.Lock()
+	defer s.Unlock()
+	for _, url := range s.userURLs {
+		if url.shortDomain == name {
+			return &DomainInUseError{}
+		}
+	}
+	return s.removeDomain(name, ownerID)
+}
+
+// info returns URLInfo of url with shortURL id.
+func (u *StorageURL) info(shortURL int64) URLInfo {
+	return URLInfo{
+		Code:          shortURL,
+		OriginalURL:   u.url,
+		ShortDomain:   u.shortDomain,
+		WorkspaceID:   u.workspaceID,
+		CreatedAt:     u.createdAt,
+		UpdatedAt:     u.updatedAt,
+		Clicks:        u.clicks,
+		Tags:          u.tags,
+		Title:         u.title,
+		Description:   u.description,
+		IsDeleted:     u.isDeleted,
+		Preview:       u.preview,
+		RedirectType:  u.redirectType,
+		Passthrough:   u.passthrough,
+		QueryConflict: u.queryConflict,
+		Rules:         u.rules,
+		Variants:      u.variants,
+		HasPassword:   len(u.passwordHash) != 0,
+		MaxClicks:     u.maxClicks,
+		ClicksLeft:    clicksLeft(u.maxClicks, u.clicksLeft),
+		ActivatesAt:   u.activatesAt,
+		Scheduled:     !u.isDeleted && isScheduled(u.activatesAt, time.Now()),
+	}
+}
+
+// changeDestination re-points url to originalURL and adds it in history.
+func (u *StorageURL) changeDestination(originalURL string) {
+	u.history = addVersion(u.history, u.url, u.createdAt, originalURL)
+	u.url = originalURL
+	u.updatedAt = time.Now()
+}
+
+// setURL saves url with shortURL id and updates it in index, s must be locked.
+func (s *InMemoryStorage) setURL(shortURL int64, savedURL *StorageURL) {
+	s.userURLs[shortURL] = savedURL
+	s.index.put(shortURL, newIndexEntry(
+		savedURL.userID,
+		savedURL.shares,
+		savedURL.workspaceID,
+		savedURL.createdAt,
+		savedURL.clicks,
+	))
+}
+
+// getOwnedURL returns not deleted url owned by ownerID or URLNotFoundError, s must be locked.
+func (s *InMemoryStorage) getOwnedURL(shortURL int64, ownerID uint32) (*StorageURL, error) {
+	savedURL, ok := s.userURLs[shortURL]
+	if !ok || savedURL.isDeleted || savedURL.userID != ownerID {
+		return nil, &URLNotFoundError{}
+	}
+	return savedURL, nil
+}
+
+// SetIDAllocator sets allocator of codes of new urls, previous allocator is closed.
+func (s *InMemoryStorage) SetIDAllocator(ids generator.Allocator) {
+	s.Lock()
+	defer s.Unlock()
+	s.idGenerator.Close()
+	s.idGenerator = ids
+}
+
 // Close closes everything that should be closed in the context of the repository.
 func (s *InMemoryStorage) Close() error {
-	s.idGenerator.Cancel()
-	return nil
+	return s.idGenerator.Close()
 }
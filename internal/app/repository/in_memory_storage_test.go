@@ -3,9 +3,11 @@ package repository
 import (
 	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go-axesthump-shortener/internal/app/generator"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestStorage_CreateShortURL(t *testing.T) {
@@ -15,14 +17,13 @@ func TestStorage_CreateShortURL(t *testing.T) {
 		lastID int64
 	}
 	type args struct {
-		beginURL string
-		url      string
+		url string
 	}
 	tests := []struct {
 		name   string
 		fields fields
 		args   args
-		want   string
+		want   int64
 	}{
 		{
 			name: "check success create",
@@ -32,10 +33,9 @@ func TestStorage_CreateShortURL(t *testing.T) {
 				lastID: 0,
 			},
 			args: args{
-				beginURL: "http://begin:8080/",
-				url:      "http://begin:8080/some/path",
+				url: "http://begin:8080/some/path",
 			},
-			want: "http://begin:8080/0",
+			want: 0,
 		},
 	}
 	for _, tt := range tests {
@@ -45,7 +45,7 @@ func TestStorage_CreateShortURL(t *testing.T) {
 				idGenerator: generator.NewIDGenerator(tt.fields.lastID),
 			}
 			defer s.Close()
-			got, _ := s.CreateShortURL(context.Background(), tt.args.beginURL, tt.args.url, 0)
+			got, _ := s.CreateShortURL(context.Background(), tt.args.url, 0, URLOptions{})
 			if got != tt.want {
 				t.Errorf("CreateShortURL() got = %v, want %v", got, tt.want)
 			}
@@ -145,16 +145,42 @@ func TestStorage_CreateShortURLDoubleCheck(t *testing.T) {
 		idGenerator: generator.NewIDGenerator(0),
 	}
 	defer s.Close()
-	beginURL := "http://begin:8080/"
-	fullURL := beginURL + "some/path"
-	fullURL2 := beginURL + "some/path/path"
-	got, _ := s.CreateShortURL(context.Background(), beginURL, fullURL, 0)
-	assert.Equal(t, beginURL+"0", got)
-	got, _ = s.CreateShortURL(context.Background(), beginURL, fullURL2, 0)
-	assert.Equal(t, beginURL+"1", got)
+	fullURL := "http://begin:8080/some/path"
+	fullURL2 := "http://begin:8080/some/path/path"
+	got, _ := s.CreateShortURL(context.Background(), fullURL, 0, URLOptions{})
+	assert.Equal(t, int64(0), got)
+	got, _ = s.CreateShortURL(context.Background(), fullURL2, 0, URLOptions{})
+	assert.Equal(t, int64(1), got)
 
 }
 
+func TestInMemoryStorage_CreateShortURLCollision(t *testing.T) {
+	ctx := context.TODO()
+	s := NewInMemoryStorage()
+	defer s.Close()
+	for _, u := range []string{"http://google.com/0", "http://google.com/1", "http://google.com/2"} {
+		_, err := s.CreateShortURL(ctx, u, 1, URLOptions{})
+		require.NoError(t, err)
+	}
+
+	// allocator returns codes of stored urls first
+	s.SetIDAllocator(generator.NewIDGenerator(0))
+	got, err := s.CreateShortURLs(ctx, []URLWithID{
+		{CorrelationID: "a", URL: "http://google.com/3"},
+		{CorrelationID: "b", URL: "http://google.com/4"},
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []URLWithID{{CorrelationID: "a", Code: 3}, {CorrelationID: "b", Code: 4}}, got)
+	fullURL, err := s.GetFullURL(ctx, 4)
+	require.NoError(t, err)
+	assert.Equal(t, "http://google.com/4", fullURL)
+
+	// every code of allocator is used
+	s.SetIDAllocator(generator.NewIDGenerator(0))
+	_, err = s.CreateShortURL(ctx, "http://google.com/5", 1, URLOptions{})
+	assert.ErrorIs(t, err, &generator.CodeCollisionError{})
+}
+
 func TestStorage_NewInMemoryStorage(t *testing.T) {
 	s := NewInMemoryStorage()
 	assert.Equal(t, 0, len(s.userURLs))
@@ -298,9 +324,8 @@ func TestInMemoryStorage_GetAllURLs(t *testing.T) {
 		idGenerator *generator.IDGenerator
 	}
 	type args struct {
-		ctx      context.Context
-		beginURL string
-		userID   uint32
+		ctx    context.Context
+		userID uint32
 	}
 	tests := []struct {
 		name   string
@@ -331,21 +356,20 @@ func TestInMemoryStorage_GetAllURLs(t *testing.T) {
 				idGenerator: generator.NewIDGenerator(3),
 			},
 			args: args{
-				ctx:      context.TODO(),
-				beginURL: "http://localhost:8080/",
-				userID:   0,
+				ctx:    context.TODO(),
+				userID: 0,
 			},
 			want: []URLInfo{
 				{
-					ShortURL:    "http://localhost:8080/0",
+					Code:        0,
 					OriginalURL: "fullURL",
 				},
 				{
-					ShortURL:    "http://localhost:8080/1",
+					Code:        1,
 					OriginalURL: "fullURL2",
 				},
 				{
-					ShortURL:    "http://localhost:8080/2",
+					Code:        2,
 					OriginalURL: "fullURL3",
 				},
 			},
@@ -373,17 +397,16 @@ func TestInMemoryStorage_GetAllURLs(t *testing.T) {
 				idGenerator: generator.NewIDGenerator(3),
 			},
 			args: args{
-				ctx:      context.TODO(),
-				beginURL: "http://localhost:8080/",
-				userID:   0,
+				ctx:    context.TODO(),
+				userID: 0,
 			},
 			want: []URLInfo{
 				{
-					ShortURL:    "http://localhost:8080/0",
+					Code:        0,
 					OriginalURL: "fullURL",
 				},
 				{
-					ShortURL:    "http://localhost:8080/1",
+					Code:        1,
 					OriginalURL: "fullURL2",
 				},
 			},
@@ -391,11 +414,15 @@ func TestInMemoryStorage_GetAllURLs(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := &InMemoryStorage{
-				userURLs:    tt.fields.userURLs,
-				idGenerator: tt.fields.idGenerator,
+			s := NewInMemoryStorage()
+			s.SetIDAllocator(tt.fields.idGenerator)
+			for shortURL, url := range tt.fields.userURLs {
+				s.setURL(shortURL, url)
 			}
-			actual := s.GetAllURLs(tt.args.ctx, tt.args.beginURL, tt.args.userID)
+			page, err := s.GetAllURLs(tt.args.ctx, tt.args.userID, URLFilter{})
+			require.NoError(t, err)
+			actual := page.URLs
+			assert.Len(t, actual, len(tt.want))
 			for _, url := range actual {
 				assert.True(t, contains(tt.want, url))
 			}
@@ -409,10 +436,9 @@ func TestInMemoryStorage_CreateShortURLs(t *testing.T) {
 		idGenerator *generator.IDGenerator
 	}
 	type args struct {
-		ctx      context.Context
-		beginURL string
-		urls     []URLWithID
-		userID   uint32
+		ctx    context.Context
+		urls   []URLWithID
+		userID uint32
 	}
 	tests := []struct {
 		name    string
@@ -428,8 +454,7 @@ func TestInMemoryStorage_CreateShortURLs(t *testing.T) {
 				idGenerator: generator.NewIDGenerator(0),
 			},
 			args: args{
-				ctx:      context.TODO(),
-				beginURL: "http://localhost:8080/",
+				ctx: context.TODO(),
 				urls: []URLWithID{
 					{
 						CorrelationID: "0",
@@ -449,15 +474,15 @@ func TestInMemoryStorage_CreateShortURLs(t *testing.T) {
 			want: []URLWithID{
 				{
 					CorrelationID: "0",
-					URL:           "http://localhost:8080/0",
+					Code:          0,
 				},
 				{
 					CorrelationID: "1",
-					URL:           "http://localhost:8080/1",
+					Code:          1,
 				},
 				{
 					CorrelationID: "2",
-					URL:           "http://localhost:8080/2",
+					Code:          2,
 				},
 			},
 			wantErr: false,
@@ -469,7 +494,7 @@ func TestInMemoryStorage_CreateShortURLs(t *testing.T) {
 				userURLs:    tt.fields.userURLs,
 				idGenerator: tt.fields.idGenerator,
 			}
-			got, err := s.CreateShortURLs(tt.args.ctx, tt.args.beginURL, tt.args.urls, tt.args.userID)
+			got, err := s.CreateShortURLs(tt.args.ctx, tt.args.urls, tt.args.userID)
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -484,7 +509,7 @@ func TestInMemoryStorage_CreateShortURLs(t *testing.T) {
 
 func contains(urls []URLInfo, url URLInfo) bool {
 	for _, tURL := range urls {
-		if tURL.OriginalURL == url.OriginalURL && tURL.ShortURL == url.ShortURL {
+		if tURL.OriginalURL == url.OriginalURL && tURL.Code == url.Code {
 			return true
 		}
 	}
@@ -493,9 +518,273 @@ func contains(urls []URLInfo, url URLInfo) bool {
 
 func containsURLWithID(urls []URLWithID, url URLWithID) bool {
 	for _, tURL := range urls {
-		if tURL.CorrelationID == url.CorrelationID && tURL.URL == url.URL {
+		if tURL.CorrelationID == url.CorrelationID && tURL.Code == url.Code {
 			return true
 		}
 	}
 	return false
 }
+
+func TestInMemoryStorage_ShareURL(t *testing.T) {
+	s := NewInMemoryStorage()
+	defer s.Close()
+	ctx := context.TODO()
+	_, err := s.CreateShortURL(ctx, "http://google.com/shared", 1, URLOptions{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.ShareURL(ctx, 0, 1, 2, PermissionRead))
+	assert.NoError(t, s.ShareURL(ctx, 0, 1, 3, PermissionManage))
+	assert.ErrorIs(t, s.ShareURL(ctx, 0, 2, 4, PermissionRead), &URLNotFoundError{})
+	assert.Equal(t, []URLInfo{{
+		Code:        0,
+		OriginalURL: "http://google.com/shared",
+		Ownership:   OwnershipShared,
+		Permission:  PermissionRead,
+	}}, allURLs(t, s, 2))
+
+	// user with read permission can't delete url
+	assert.NoError(t, s.DeleteURLs([]DeleteURL{{URL: "0", UserID: 2}}))
+	_, err = s.GetFullURL(ctx, 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.UnshareURL(ctx, 0, 1, 2))
+	assert.Empty(t, allURLs(t, s, 2))
+
+	assert.NoError(t, s.DeleteURLs([]DeleteURL{{URL: "0", UserID: 3}}))
+	_, err = s.GetFullURL(ctx, 0)
+	assert.ErrorIs(t, err, &DeletedURLError{})
+}
+
+func TestInMemoryStorage_statsPermission(t *testing.T) {
+	s := NewInMemoryStorage()
+	defer s.Close()
+	ctx := context.TODO()
+	workspace, err := s.CreateWorkspace(ctx, "team", 1)
+	require.NoError(t, err)
+	require.NoError(t, s.SetWorkspaceMember(ctx, workspace.ID, 4, RoleViewer))
+	_, err = s.CreateShortURL(ctx, "http://google.com/stats", 1, URLOptions{WorkspaceID: workspace.ID})
+	require.NoError(t, err)
+	require.NoError(t, s.ShareURL(ctx, 0, 1, 2, PermissionRead))
+	require.NoError(t, s.ShareURL(ctx, 0, 1, 3, PermissionStats))
+	require.NoError(t, s.ShareURL(ctx, 0, 1, 4, PermissionStats))
+
+	for _, userID := range []uint32{1, 3, 4} {
+		history, err := s.GetURLHistory(ctx, 0, userID)
+		require.NoError(t, err)
+		assert.Len(t, history, 1)
+	}
+	_, err = s.GetURLHistory(ctx, 0, 2)
+	assert.ErrorIs(t, err, &URLNotFoundError{})
+
+	// share with stats permission extends read permission of workspace viewer
+	urls := allURLs(t, s, 4)
+	require.Len(t, urls, 1)
+	assert.Equal(t, OwnershipWorkspace, urls[0].Ownership)
+	assert.Equal(t, PermissionStats, urls[0].Permission)
+
+	// user with stats permission can't delete url
+	require.NoError(t, s.DeleteURLs([]DeleteURL{{URL: "0", UserID: 3}}))
+	_, err = s.GetFullURL(ctx, 0)
+	assert.NoError(t, err)
+}
+
+func TestInMemoryStorage_TransferURL(t *testing.T) {
+	s := NewInMemoryStorage()
+	defer s.Close()
+	ctx := context.TODO()
+	_, err := s.CreateShortURL(ctx, "http://google.com/transferred", 1, URLOptions{})
+	assert.NoError(t, err)
+	assert.NoError(t, s.ShareURL(ctx, 0, 1, 2, PermissionRead))
+
+	assert.ErrorIs(t, s.TransferURL(ctx, 1, 1, 2), &URLNotFoundError{})
+	assert.NoError(t, s.TransferURL(ctx, 0, 1, 2))
+	assert.ErrorIs(t, s.TransferURL(ctx, 0, 1, 3), &URLNotFoundError{})
+
+	assert.Empty(t, allURLs(t, s, 1))
+	urls := allURLs(t, s, 2)
+	assert.Len(t, urls, 1)
+	assert.Equal(t, OwnershipOwned, urls[0].Ownership)
+	assert.Empty(t, urls[0].Permission)
+}
+
+func TestInMemoryStorage_workspaceURLs(t *testing.T) {
+	ctx := context.TODO()
+	s := NewInMemoryStorage()
+	defer s.Close()
+	workspace, err := s.CreateWorkspace(ctx, "team", 1)
+	require.NoError(t, err)
+	require.NoError(t, s.SetWorkspaceMember(ctx, workspace.ID, 2, RoleViewer))
+	require.NoError(t, s.SetWorkspaceMember(ctx, workspace.ID, 3, RoleEditor))
+	_, err = s.CreateShortURL(ctx, "http://google.com/team", 1, URLOptions{WorkspaceID: workspace.ID})
+	require.NoError(t, err)
+
+	assert.Equal(t, []URLInfo{{
+		Code:        0,
+		OriginalURL: "http://google.com/team",
+		Ownership:   OwnershipWorkspace,
+		Permission:  PermissionRead,
+		WorkspaceID: workspace.ID,
+	}}, allURLs(t, s, 2))
+	assert.Empty(t, allURLs(t, s, 4))
+
+	// viewer can't delete url, editor can
+	require.NoError(t, s.DeleteURLs([]DeleteURL{{URL: "0", UserID: 2}}))
+	_, err = s.GetFullURL(ctx, 0)
+	assert.NoError(t, err)
+	require.NoError(t, s.DeleteURLs([]DeleteURL{{URL: "0", UserID: 3}}))
+	_, err = s.GetFullURL(ctx, 0)
+	assert.ErrorIs(t, err, &DeletedURLError{})
+}
+
+// allURLs returns all urls of user without creation and update time for compare.
+func allURLs(t *testing.T, repo Repository, userID uint32) []URLInfo {
+	page, err := repo.GetAllURLs(context.TODO(), userID, URLFilter{})
+	require.NoError(t, err)
+	for i := range page.URLs {
+		assert.False(t, page.URLs[i].CreatedAt.IsZero())
+		page.URLs[i].CreatedAt = time.Time{}
+		page.URLs[i].UpdatedAt = time.Time{}
+	}
+	return page.URLs
+}
+
+func TestInMemoryStorage_UpdateURLMeta(t *testing.T) {
+	s := NewInMemoryStorage()
+	defer s.Close()
+	ctx := context.TODO()
+	_, err := s.CreateShortURL(ctx, "http://google.com/meta", 1, URLOptions{
+		Title: "Google",
+		Tags:  []string{"search"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.ShareURL(ctx, 0, 1, 2, PermissionRead))
+
+	title := "New title"
+	description := "Flyer link"
+	info, err := s.UpdateURLMeta(ctx, 0, 1, URLMetaUpdate{Title: &title, Description: &description})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), info.Code)
+	assert.Equal(t, title, info.Title)
+	assert.Equal(t, description, info.Description)
+	assert.Equal(t, []string{"search"}, info.Tags)
+	assert.False(t, info.UpdatedAt.Before(info.CreatedAt))
+
+	_, err = s.UpdateURLMeta(ctx, 0, 2, URLMetaUpdate{Title: &title})
+	assert.ErrorIs(t, err, &URLNotFoundError{})
+	_, err = s.UpdateURLMeta(ctx, 1, 1, URLMetaUpdate{Title: &title})
+	assert.ErrorIs(t, err, &URLNotFoundError{})
+
+	// destination is changed only by owner, nothing is changed by rejected update
+	require.NoError(t, s.ShareURL(ctx, 0, 1, 3, PermissionManage))
+	otherTitle := "Other title"
+	destination := "http://yandex.ru/meta"
+	_, err = s.UpdateURLMeta(ctx, 0, 3, URLMetaUpdate{Title: &otherTitle, OriginalURL: &destination})
+	assert.ErrorIs(t, err, &URLNotFoundError{})
+	info, err = s.GetURLInfo(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, title, info.Title)
+	assert.Equal(t, "http://google.com/meta", info.OriginalURL)
+
+	info, err = s.UpdateURLMeta(ctx, 0, 1, URLMetaUpdate{Title: &otherTitle, OriginalURL: &destination})
+	require.NoError(t, err)
+	assert.Equal(t, otherTitle, info.Title)
+	assert.Equal(t, destination, info.OriginalURL)
+	history, err := s.GetURLHistory(ctx, 0, 1)
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestInMemoryStorage_ChangeURLDestination(t *testing.T) {
+	s := NewInMemoryStorage()
+	defer s.Close()
+	ctx := context.TODO()
+	_, err := s.CreateShortURL(ctx, "http://google.com/flyer", 1, URLOptions{})
+	require.NoError(t, err)
+
+	history, err := s.GetURLHistory(ctx, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, "http://google.com/flyer", history[0].OriginalURL)
+
+	require.NoError(t, s.ChangeURLDestination(ctx, 0, 1, "http://yandex.ru/flyer"))
+	fullURL, err := s.GetFullURL(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "http://yandex.ru/flyer", fullURL)
+
+	require.NoError(t, s.RollbackURL(ctx, 0, 1, 1))
+	fullURL, err = s.GetFullURL(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "http://google.com/flyer", fullURL)
+
+	history, err = s.GetURLHistory(ctx, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, 3, history[2].Version)
+	assert.Equal(t, "http://google.com/flyer", history[2].OriginalURL)
+
+	assert.ErrorIs(t, s.RollbackURL(ctx, 0, 1, 4), &URLVersionNotFoundError{})
+	assert.ErrorIs(t, s.ChangeURLDestination(ctx, 0, 2, "http://yandex.ru/other"), &URLNotFoundError{})
+	_, err = s.GetURLHistory(ctx, 0, 2)
+	assert.ErrorIs(t, err, &URLNotFoundError{})
+}
+
+func TestInMemoryStorage_SetURLPreview(t *testing.T) {
+	s := NewInMemoryStorage()
+	defer s.Close()
+	ctx := context.TODO()
+	_, err := s.CreateShortURL(ctx, "http://google.com/preview", 1, URLOptions{})
+	require.NoError(t, err)
+
+	preview := URLPreview{PageTitle: "Google", OGTitle: "Google Search"}
+	require.NoError(t, s.SetURLPreview(ctx, 0, "http://google.com/preview", preview))
+	// preview of old destination is not saved
+	require.NoError(t, s.ChangeURLDestination(ctx, 0, 1, "http://yandex.ru/preview"))
+	err = s.SetURLPreview(ctx, 0, "http://google.com/preview", URLPreview{PageTitle: "Stale"})
+	assert.ErrorIs(t, err, &URLNotFoundError{})
+
+	urls := allURLs(t, s, 1)
+	require.Len(t, urls, 1)
+	assert.Equal(t, &preview, urls[0].Preview)
+}
+
+func TestInMemoryStorage_ConsumeClick(t *testing.T) {
+	s := NewInMemoryStorage()
+	ctx := context.TODO()
+	_, err := s.CreateShortURL(ctx, "http://google.com/once", 1, URLOptions{MaxClicks: 5})
+	require.NoError(t, err)
+	_, err = s.CreateShortURL(ctx, "http://google.com/always", 1, URLOptions{})
+	require.NoError(t, err)
+
+	// concurrent redirects can't exceed max clicks
+	var wg sync.WaitGroup
+	var mx sync.Mutex
+	consumed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.ConsumeClick(ctx, 0) == nil {
+				mx.Lock()
+				consumed++
+				mx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 5, consumed)
+	assert.ErrorIs(t, s.ConsumeClick(ctx, 0), &ClicksExhaustedError{})
+	redirect, err := s.GetRedirect(ctx, 0)
+	require.NoError(t, err)
+	assert.True(t, redirect.IsExhausted())
+
+	assert.NoError(t, s.ConsumeClick(ctx, 1))
+	assert.ErrorIs(t, s.ConsumeClick(ctx, 2), &URLNotFoundError{})
+
+	// new limit restarts count of clicks
+	maxClicks := int64(1)
+	info, err := s.UpdateURLMeta(ctx, 0, 1, URLMetaUpdate{MaxClicks: &maxClicks})
+	require.NoError(t, err)
+	require.NotNil(t, info.ClicksLeft)
+	assert.Equal(t, int64(1), *info.ClicksLeft)
+	assert.NoError(t, s.ConsumeClick(ctx, 0))
+}
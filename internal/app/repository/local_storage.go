@@ -3,34 +3,121 @@ package repository
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
 	"go-axesthump-shortener/internal/app/generator"
 	"io"
+	"log"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Info about store data in file.
 const (
 	splitSeq       = "~s~e~c~" // separator for one row with data
-	countDataInRow = 4         // count data from url in one row
+	countDataInRow = 4         // count required data from url in one row, optional rowMeta is the last
 )
 
-// url delete url info.
+// clicksFlushInterval interval of saving clicks counted in memory to file.
+const clicksFlushInterval = time.Second
+
+// url stored url info.
 type url struct {
 	url       string
 	fullURL   string
 	userID    uint32
 	isDeleted bool
+	meta      rowMeta
+}
+
+// rowMeta optional url data stored in the last column of row as json.
+type rowMeta struct {
+	// Shares - users who have access to url.
+	Shares map[uint32]Permission `json:"shares,omitempty"`
+	// WorkspaceID - workspace which contains url.
+	WorkspaceID int64 `json:"workspace_id,omitempty"`
+	// CreatedAt - url creation time, zero for urls created before it was stored.
+	CreatedAt time.Time `json:"created_at"`
+	// Clicks - count of redirects by url.
+	Clicks int64 `json:"clicks,omitempty"`
+	// Tags - free-form url tags.
+	Tags []string `json:"tags,omitempty"`
+	// Title - url title.
+	Title string `json:"title,omitempty"`
+	// Description - url notes.
+	Description string `json:"description,omitempty"`
+	// UpdatedAt - url metadata last update time.
+	UpdatedAt time.Time `json:"updated_at"`
+	// History - destinations of url, empty if url was never changed.
+	History []URLVersion `json:"history,omitempty"`
+	// Preview - metadata of destination page.
+	Preview *URLPreview `json:"preview,omitempty"`
+	// RedirectType - redirect status of url.
+	RedirectType RedirectType `json:"redirect_type,omitempty"`
+	// Passthrough - parts of request passed to destination.
+	Passthrough PassthroughMode `json:"passthrough,omitempty"`
+	// QueryConflict - policy of query params merge.
+	QueryConflict QueryConflict `json:"query_conflict,omitempty"`
+	// Rules - ordered conditional destinations.
+	Rules []RedirectRule `json:"rules,omitempty"`
+	// Variants - weighted destinations of A/B split.
+	Variants []URLVariant `json:"variants,omitempty"`
+	// PasswordHash - hash of password required to open url.
+	PasswordHash string `json:"password_hash,omitempty"`
+	// MaxClicks - count of redirects after which url stops working.
+	MaxClicks int64 `json:"max_clicks,omitempty"`
+	// ClicksLeft - count of redirects left by url with max clicks.
+	ClicksLeft int64 `json:"clicks_left,omitempty"`
+	// ActivatesAt - time from which url redirects.
+	ActivatesAt *time.Time `json:"activates_at,omitempty"`
+	// ShortDomain - custom domain of url.
+	ShortDomain string `json:"short_domain,omitempty"`
+}
+
+// clickCounter clicks of url counted in memory and not saved in file yet.
+type clickCounter struct {
+	clicks        int64
+	variantClicks map[int]int64
+	consumed      int64
+	// loaded - maxClicks, clicksLeft and isDeleted are read from file by ConsumeClick.
+	loaded     bool
+	isDeleted  bool
+	maxClicks  int64
+	clicksLeft int64
+}
+
+// rowPosition position of row in file.
+type rowPosition struct {
+	offset int64
+	length int
 }
 
 // LocalStorage contains data for local storage.
+// Every url change appends new row in file, the last row of url contains its actual state.
+// Positions of the last rows and index of user urls are read from file once on start and kept in memory.
+// Clicks are counted in memory and saved in file every clicksFlushInterval, on other change of url and on Close,
+// counted clicks are added to urls read from file.
+// Workspaces, utm templates and domains are stored in separate journal files next to storage file.
 type LocalStorage struct {
 	sync.RWMutex
+	*workspaceJournal
+	*utmJournal
+	*domainJournal
 	file        *os.File
-	idGenerator *generator.IDGenerator
+	idGenerator generator.Allocator
+	// counters - clicks of urls by code which are not saved in file yet.
+	counters  map[string]*clickCounter
+	done      chan struct{}
+	closeOnce sync.Once
+	// rows - positions of the last rows of urls by code.
+	rows map[int64]rowPosition
+	// size - size of file, offset of the next row.
+	size int64
+	// index - urls of users sorted for pagination.
+	index urlIndex
 }
 
 // NewLocalStorage returns new LocalStorage.
@@ -39,76 +126,130 @@ func NewLocalStorage(filename string) (*LocalStorage, error) {
 	if err != nil {
 		return nil, err
 	}
+	workspaces, err := newWorkspaceJournal(filename + ".workspaces")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	templates, err := newUTMJournal(filename + ".utm")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	domains, err := newDomainJournal(filename + ".domains")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
 	lastID := getLastID(file)
-	return &LocalStorage{
-		RWMutex:     sync.RWMutex{},
-		file:        file,
-		idGenerator: generator.NewIDGenerator(lastID),
-	}, nil
+	ls := &LocalStorage{
+		RWMutex:          sync.RWMutex{},
+		workspaceJournal: workspaces,
+		utmJournal:       templates,
+		domainJournal:    domains,
+		file:             file,
+		idGenerator:      generator.NewIDGenerator(lastID),
+		counters:         make(map[string]*clickCounter),
+		done:             make(chan struct{}),
+		rows:             make(map[int64]rowPosition),
+	}
+	if err = ls.loadRows(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	go ls.flushClicksLoop()
+	return ls, nil
 }
 
 // GetUserLastID returns last user id contains in local storage.
 func (ls *LocalStorage) GetUserLastID() uint32 {
-	var lastID int64
-	var err error
-	var max int64 = 0
+	var max uint32 = 0
 	scanner := bufio.NewScanner(ls.file)
 	for scanner.Scan() {
-		data := scanner.Text()
-		urlData := strings.Split(data, splitSeq)
-		if len(urlData) != countDataInRow {
-			panic(errors.New("bad data in file"))
+		data, err := parseRow(scanner.Text())
+		if err != nil {
+			panic(err)
 		}
-		if lastID, err = strconv.ParseInt(urlData[0], 10, 64); err != nil {
-			panic(errors.New("bad data in file"))
+		if data.userID > max {
+			max = data.userID
 		}
-		if lastID > max {
-			max = lastID
+		for userID := range data.meta.Shares {
+			if userID > max {
+				max = userID
+			}
 		}
 	}
-	return uint32(max + 1)
+	return max + 1
 }
 
-// CreateShortURL creates short url. Returns short url if operations success or error.
+// CreateShortURL creates short url. Returns code of url if operations success or error.
 func (ls *LocalStorage) CreateShortURL(
 	ctx context.Context,
-	beginURL string,
 	originalURL string,
 	userID uint32,
-) (string, error) {
-	newShortID := ls.idGenerator.GetID()
-	shortEndpoint := strconv.FormatInt(newShortID, 10)
-	shortURL := beginURL + shortEndpoint
-
-	data := createRow(int64(userID), strconv.FormatInt(newShortID, 10), originalURL, "false")
-
+	opts URLOptions,
+) (int64, error) {
 	ls.Lock()
-	wr := bufio.NewWriter(ls.file)
-	if _, err := wr.WriteString(data + "\n"); err != nil {
-		return "", err
+	defer ls.Unlock()
+	codes, err := generator.UniqueIDs(ctx, ls.idGenerator, 1, ls.reserveCode())
+	if err != nil {
+		return 0, err
 	}
-	if err := wr.Flush(); err != nil {
-		return "", err
+	if err = ls.appendURLs(newURL(codes[0], originalURL, userID, opts, time.Now())); err != nil {
+		return 0, err
 	}
-	ls.Unlock()
-	return shortURL, nil
+	return codes[0], nil
 }
 
-// CreateShortURLs creates short urls. Returns slice short urls if operations success or error.
+// newURL returns row of new url of user created at now.
+func newURL(code int64, originalURL string, userID uint32, opts URLOptions, now time.Time) url {
+	return url{
+		url:     strconv.FormatInt(code, 10),
+		fullURL: originalURL,
+		userID:  userID,
+		meta: rowMeta{
+			WorkspaceID:   opts.WorkspaceID,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+			Tags:          opts.Tags,
+			Title:         opts.Title,
+			Description:   opts.Description,
+			RedirectType:  opts.RedirectType,
+			Passthrough:   opts.Passthrough,
+			QueryConflict: opts.QueryConflict,
+			Rules:         opts.Rules,
+			Variants:      withoutClicks(opts.Variants),
+			PasswordHash:  opts.PasswordHash,
+			MaxClicks:     opts.MaxClicks,
+			ClicksLeft:    opts.MaxClicks,
+			ActivatesAt:   opts.ActivatesAt,
+			ShortDomain:   opts.ShortDomain,
+		},
+	}
+}
+
+// CreateShortURLs creates short urls. Returns codes of urls if operations success or error.
 func (ls *LocalStorage) CreateShortURLs(
 	ctx context.Context,
-	beginURL string,
 	urls []URLWithID,
 	userID uint32,
 ) ([]URLWithID, error) {
+	ls.Lock()
+	defer ls.Unlock()
+	codes, err := generator.UniqueIDs(ctx, ls.idGenerator, len(urls), ls.reserveCode())
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	rows := make([]url, len(urls))
 	res := make([]URLWithID, len(urls))
-	for i, url := range urls {
-		shortURL, err := ls.CreateShortURL(ctx, beginURL, url.URL, userID)
-		if err != nil {
-			return nil, err
-		}
-		res[i].URL = shortURL
-		res[i].CorrelationID = url.CorrelationID
+	for i, data := range urls {
+		rows[i] = newURL(codes[i], data.URL, userID, data.Options, now)
+		res[i].Code = codes[i]
+		res[i].CorrelationID = data.CorrelationID
+	}
+	if err = ls.appendURLs(rows...); err != nil {
+		return nil, err
 	}
 	return res, nil
 }
@@ -117,145 +258,674 @@ func (ls *LocalStorage) CreateShortURLs(
 func (ls *LocalStorage) GetFullURL(ctx context.Context, shortURL int64) (string, error) {
 	ls.RLock()
 	defer ls.RUnlock()
-	fileForRead, err := os.OpenFile(ls.file.Name(), os.O_RDONLY, 0777)
+	data, err := ls.findURL(shortURL)
 	if err != nil {
 		return "", err
 	}
-	defer fileForRead.Close()
-	scanner := bufio.NewScanner(fileForRead)
-	var fullURL string
-	var finalErr error
-	for scanner.Scan() {
-		data := scanner.Text()
-		urlData := strings.Split(data, splitSeq)
-		if len(urlData) != countDataInRow {
-			panic(errors.New("bad data in file"))
-		}
-		var elementShortURL int64
-		if elementShortURL, err = strconv.ParseInt(urlData[1], 10, 64); err != nil {
-			return "", err
-		}
-		if elementShortURL == shortURL {
-			if urlData[3] == "true" {
-				finalErr = &DeletedURLError{}
-			} else {
-				finalErr = nil
-				fullURL = urlData[2]
-			}
-		}
+	if data.isDeleted {
+		return "", &DeletedURLError{}
 	}
-	if len(fullURL) == 0 {
-		return "", errors.New("URL nor found")
-	} else {
-		return fullURL, finalErr
+	return data.fullURL, nil
+}
+
+// GetRedirect returns data for redirect by not deleted short url.
+func (ls *LocalStorage) GetRedirect(ctx context.Context, shortURL int64) (Redirect, error) {
+	ls.RLock()
+	defer ls.RUnlock()
+	data, err := ls.findURL(shortURL)
+	if err != nil {
+		return Redirect{}, err
+	}
+	if data.isDeleted {
+		return Redirect{}, &DeletedURLError{}
+	}
+	return Redirect{
+		OriginalURL:   data.fullURL,
+		Type:          data.meta.RedirectType,
+		Passthrough:   data.meta.Passthrough,
+		QueryConflict: data.meta.QueryConflict,
+		Rules:         data.meta.Rules,
+		Variants:      data.meta.Variants,
+		PasswordHash:  data.meta.PasswordHash,
+		MaxClicks:     data.meta.MaxClicks,
+		ClicksLeft:    data.meta.ClicksLeft,
+		ActivatesAt:   data.meta.ActivatesAt,
+		ShortDomain:   data.meta.ShortDomain,
+	}, nil
+}
+
+// GetURLInfo returns info of not deleted url without its access settings.
+func (ls *LocalStorage) GetURLInfo(ctx context.Context, shortURL int64) (URLInfo, error) {
+	ls.RLock()
+	defer ls.RUnlock()
+	data, err := ls.findURL(shortURL)
+	if err != nil {
+		return URLInfo{}, err
+	}
+	if data.isDeleted {
+		return URLInfo{}, &DeletedURLError{}
 	}
+	return data.info(shortURL), nil
 }
 
 // DeleteURLs deletes url from urlsForDelete.
+// Url is deleted only by its owner or user with PermissionManage to it or its workspace.
 func (ls *LocalStorage) DeleteURLs(urlsForDelete []DeleteURL) error {
 	ls.Lock()
 	defer ls.Unlock()
 
-	fileForRead, err := os.OpenFile(ls.file.Name(), os.O_RDONLY, 0777)
+	urls, err := ls.readURLs()
 	if err != nil {
 		return err
 	}
-	scanner := bufio.NewScanner(fileForRead)
 	urlsForDeleteData := make([]url, 0, len(urlsForDelete))
-	for scanner.Scan() {
-		data := scanner.Text()
-		urlData := strings.Split(data, splitSeq)
-		if len(urlData) != countDataInRow {
+	for _, data := range urls {
+		if data.isDeleted {
 			continue
 		}
-		storageUserID, err := strconv.ParseInt(urlData[0], 10, 64)
-		if err != nil || uint32(storageUserID) != urlsForDelete[0].UserID {
-			continue
-		}
-		shortURL := urlData[1]
-		urlForDelete := url{
-			userID:    urlsForDelete[0].UserID,
-			url:       shortURL,
-			fullURL:   urlData[2],
-			isDeleted: true,
+		for _, urlForDelete := range urlsForDelete {
+			if urlForDelete.URL != data.url {
+				continue
+			}
+			if !ls.canManage(urlForDelete.UserID, data.userID, data.meta.Shares, data.meta.WorkspaceID) {
+				continue
+			}
+			data.isDeleted = true
+			urlsForDeleteData = append(urlsForDeleteData, data)
+			break
 		}
-		urlsForDeleteData = append(urlsForDeleteData, urlForDelete)
 	}
+	return ls.appendURLs(urlsForDeleteData...)
+}
+
+// AddClick increments count of redirects by short url in memory.
+// Clicks of unknown url are dropped when clicks are saved in file.
+func (ls *LocalStorage) AddClick(ctx context.Context, shortURL int64) error {
+	ls.Lock()
+	defer ls.Unlock()
+	ls.counter(shortURL).clicks++
+	ls.index.addClicks(shortURL, 1)
+	return nil
+}
 
-	fileForRead.Close()
-	wr := bufio.NewWriter(ls.file)
-	for _, url := range urlsForDeleteData {
-		data := createRow(int64(url.userID), url.url, url.fullURL, "true")
-		if _, err := wr.WriteString(data + "\n"); err != nil {
+// ConsumeClick atomically decrements count of redirects left by url with max clicks.
+// Url is read from file by the first call, next calls count redirects in memory under storage lock.
+func (ls *LocalStorage) ConsumeClick(ctx context.Context, shortURL int64) error {
+	ls.Lock()
+	defer ls.Unlock()
+	c, ok := ls.counters[strconv.FormatInt(shortURL, 10)]
+	if !ok || !c.loaded {
+		data, err := ls.findURL(shortURL)
+		if err != nil {
 			return err
 		}
-		if err := wr.Flush(); err != nil {
-			return err
+		c = ls.counter(shortURL)
+		c.loaded = true
+		c.isDeleted = data.isDeleted
+		c.maxClicks = data.meta.MaxClicks
+		c.clicksLeft = data.meta.ClicksLeft
+	}
+	if c.maxClicks <= 0 {
+		return nil
+	}
+	if c.isDeleted || c.clicksLeft-c.consumed <= 0 {
+		return &ClicksExhaustedError{}
+	}
+	c.consumed++
+	return nil
+}
+
+// AddVariantClick increments count of redirects by short url and count of redirects to its variant in memory.
+func (ls *LocalStorage) AddVariantClick(ctx context.Context, shortURL int64, variant int) error {
+	ls.Lock()
+	defer ls.Unlock()
+	c := ls.counter(shortURL)
+	c.clicks++
+	ls.index.addClicks(shortURL, 1)
+	if variant >= 0 {
+		if c.variantClicks == nil {
+			c.variantClicks = make(map[int]int64)
 		}
+		c.variantClicks[variant]++
 	}
-	if err := wr.Flush(); err != nil {
+	return nil
+}
+
+// SetURLPreview saves preview of url destination page.
+func (ls *LocalStorage) SetURLPreview(
+	ctx context.Context,
+	shortURL int64,
+	originalURL string,
+	preview URLPreview,
+) error {
+	ls.Lock()
+	defer ls.Unlock()
+	data, err := ls.findURL(shortURL)
+	if err != nil {
 		return err
 	}
-	return nil
+	if data.fullURL != originalURL {
+		return &URLNotFoundError{}
+	}
+	data.meta.Preview = &preview
+	return ls.appendURLs(data)
 }
 
-// GetAllURLs returns all urls owned specific user.
-func (ls *LocalStorage) GetAllURLs(ctx context.Context, beginURL string, userID uint32) []URLInfo {
+// GetAllURLs returns page of urls owned specific user, urls shared with him and urls of his workspaces.
+func (ls *LocalStorage) GetAllURLs(
+	ctx context.Context,
+	userID uint32,
+	filter URLFilter,
+) (URLPage, error) {
 	ls.RLock()
 	defer ls.RUnlock()
+	return ls.index.page(userID, ls.memberWorkspaces(userID), filter, func(id int64) (URLInfo, bool, error) {
+		data, err := ls.findURL(id)
+		if err != nil {
+			return URLInfo{}, false, err
+		}
+		info := data.info(id)
+		return info, info.setOwnership(userID, data.userID, data.meta.Shares, ls.workspaceJournal), nil
+	})
+}
+
+// UpdateURLMeta updates metadata and destination of not deleted url by one row and returns updated url.
+func (ls *LocalStorage) UpdateURLMeta(
+	ctx context.Context,
+	shortURL int64,
+	userID uint32,
+	update URLMetaUpdate,
+) (URLInfo, error) {
+	ls.Lock()
+	defer ls.Unlock()
+	data, err := ls.findURL(shortURL)
+	if err != nil {
+		return URLInfo{}, err
+	}
+	if data.isDeleted || !ls.canManage(userID, data.userID, data.meta.Shares, data.meta.WorkspaceID) ||
+		update.OriginalURL != nil && data.userID != userID {
+		return URLInfo{}, &URLNotFoundError{}
+	}
+	if update.OriginalURL != nil {
+		data = data.withDestination(*update.OriginalURL)
+	}
+	info := data.info(shortURL)
+	update.apply(&info)
+	data.meta.Title = info.Title
+	data.meta.Description = info.Description
+	data.meta.Tags = info.Tags
+	data.meta.RedirectType = info.RedirectType
+	data.meta.Passthrough = info.Passthrough
+	data.meta.QueryConflict = info.QueryConflict
+	data.meta.Rules = info.Rules
+	data.meta.Variants = info.Variants
+	if update.PasswordHash != nil {
+		data.meta.PasswordHash = *update.PasswordHash
+	}
+	if update.MaxClicks != nil {
+		data.meta.MaxClicks = *update.MaxClicks
+		data.meta.ClicksLeft = *update.MaxClicks
+	}
+	data.meta.UpdatedAt = time.Now()
+	if err = ls.appendURLs(data); err != nil {
+		return URLInfo{}, err
+	}
+	return data.info(shortURL), nil
+}
+
+// ChangeURLDestination re-points url owned by ownerID to originalURL and adds it in url history.
+func (ls *LocalStorage) ChangeURLDestination(
+	ctx context.Context,
+	shortURL int64,
+	ownerID uint32,
+	originalURL string,
+) error {
+	ls.Lock()
+	defer ls.Unlock()
+	data, err := ls.findOwnedURL(shortURL, ownerID)
+	if err != nil {
+		return err
+	}
+	return ls.appendURLs(data.withDestination(originalURL))
+}
+
+// GetURLHistory returns all destinations of url from the first one.
+// User must be owner of url or have PermissionStats to url or its workspace.
+func (ls *LocalStorage) GetURLHistory(ctx context.Context, shortURL int64, userID uint32) ([]URLVersion, error) {
+	ls.RLock()
+	defer ls.RUnlock()
+	data, err := ls.findURL(shortURL)
+	if err != nil {
+		return nil, err
+	}
+	if data.isDeleted ||
+		!ls.hasPermission(userID, data.userID, data.meta.Shares, data.meta.WorkspaceID, PermissionStats) {
+		return nil, &URLNotFoundError{}
+	}
+	return currentHistory(data.meta.History, data.fullURL, data.meta.CreatedAt), nil
+}
+
+// RollbackURL re-points url owned by ownerID to destination of version from url history.
+func (ls *LocalStorage) RollbackURL(ctx context.Context, shortURL int64, ownerID uint32, version int) error {
+	ls.Lock()
+	defer ls.Unlock()
+	data, err := ls.findOwnedURL(shortURL, ownerID)
+	if err != nil {
+		return err
+	}
+	originalURL, err := findVersion(currentHistory(data.meta.History, data.fullURL, data.meta.CreatedAt), version)
+	if err != nil {
+		return err
+	}
+	return ls.appendURLs(data.withDestination(originalURL))
+}
+
+// TransferURL transfers url owned by ownerID to newOwnerID.
+func (ls *LocalStorage) TransferURL(ctx context.Context, shortURL int64, ownerID uint32, newOwnerID uint32) error {
+	ls.Lock()
+	defer ls.Unlock()
+	data, err := ls.findOwnedURL(shortURL, ownerID)
+	if err != nil {
+		return err
+	}
+	data.userID = newOwnerID
+	delete(data.meta.Shares, newOwnerID)
+	return ls.appendURLs(data)
+}
+
+// ShareURL grants permission to url owned by ownerID for userID.
+func (ls *LocalStorage) ShareURL(
+	ctx context.Context,
+	shortURL int64,
+	ownerID uint32,
+	userID uint32,
+	permission Permission,
+) error {
+	ls.Lock()
+	defer ls.Unlock()
+	data, err := ls.findOwnedURL(shortURL, ownerID)
+	if err != nil {
+		return err
+	}
+	shares := make(map[uint32]Permission, len(data.meta.Shares)+1)
+	for sharedUserID, sharedPermission := range data.meta.Shares {
+		shares[sharedUserID] = sharedPermission
+	}
+	shares[userID] = permission
+	data.meta.Shares = shares
+	return ls.appendURLs(data)
+}
+
+// UnshareURL revokes access to url owned by ownerID from userID.
+func (ls *LocalStorage) UnshareURL(ctx context.Context, shortURL int64, ownerID uint32, userID uint32) error {
+	ls.Lock()
+	defer ls.Unlock()
+	data, err := ls.findOwnedURL(shortURL, ownerID)
+	if err != nil {
+		return err
+	}
+	if _, ok := data.meta.Shares[userID]; !ok {
+		return nil
+	}
+	delete(data.meta.Shares, userID)
+	return ls.appendURLs(data)
+}
+
+// RemoveDomain removes domain of owner if it has no urls.
+func (ls *LocalStorage) RemoveDomain(ctx context.Context, name string, ownerID uint32) error {
+	domain, err := ls.GetDomain(ctx, name)
+	if err != nil || domain.OwnerID != ownerID {
+		return &DomainNotFoundError{}
+	}
+	// lock prevents creation of urls on domain while it's removed
+	ls.Lock()
+	defer ls.Unlock()
+	urls, err := ls.readURLs()
+	if err != nil {
+		return err
+	}
+	for _, data := range urls {
+		if data.meta.ShortDomain == name {
+			return &DomainInUseError{}
+		}
+	}
+	return ls.removeDomain(name, ownerID)
+}
+
+// Close saves counted clicks and closes everything that should be closed in the context of the repository.
+func (ls *LocalStorage) Close() error {
+	ls.closeOnce.Do(func() {
+		if ls.done != nil {
+			close(ls.done)
+		}
+	})
+	ls.Lock()
+	err := ls.flushClicks()
+	ls.Unlock()
+	ls.idGenerator.Close()
+	if closeErr := ls.file.Close(); closeErr != nil {
+		return closeErr
+	}
+	return err
+}
+
+// counter returns counter of clicks of url which are not saved in file, ls must be locked.
+func (ls *LocalStorage) counter(shortURL int64) *clickCounter {
+	code := strconv.FormatInt(shortURL, 10)
+	c, ok := ls.counters[code]
+	if !ok {
+		c = &clickCounter{}
+		ls.counters[code] = c
+	}
+	return c
+}
+
+// flushClicksLoop saves counted clicks every clicksFlushInterval until storage is closed.
+func (ls *LocalStorage) flushClicksLoop() {
+	ticker := time.NewTicker(clicksFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ls.done:
+			return
+		case <-ticker.C:
+			ls.Lock()
+			if err := ls.flushClicks(); err != nil {
+				log.Printf("Cant save clicks: %s", err)
+			}
+			ls.Unlock()
+		}
+	}
+}
+
+// flushClicks appends one row with counted clicks for every clicked url, ls must be locked.
+func (ls *LocalStorage) flushClicks() error {
+	if len(ls.counters) == 0 {
+		return nil
+	}
+	clicked := make([]url, 0, len(ls.counters))
+	for code, c := range ls.counters {
+		shortURL, err := strconv.ParseInt(code, 10, 64)
+		if err != nil || !c.changed() {
+			continue
+		}
+		data, err := ls.findURL(shortURL)
+		if errors.Is(err, &URLNotFoundError{}) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		clicked = append(clicked, data)
+	}
+	if err := ls.appendURLs(clicked...); err != nil {
+		return err
+	}
+	// counters of unknown urls and loaded state of not clicked urls are dropped
+	ls.counters = make(map[string]*clickCounter)
+	return nil
+}
+
+// SetIDAllocator sets allocator of codes of new urls, previous allocator is closed.
+func (ls *LocalStorage) SetIDAllocator(ids generator.Allocator) {
+	ls.Lock()
+	defer ls.Unlock()
+	ls.idGenerator.Close()
+	ls.idGenerator = ids
+}
+
+// reserveCode returns reserve function of generator.UniqueIDs for codes which are not used by urls in file
+// and not reserved by previous calls of function, ls must be locked.
+func (ls *LocalStorage) reserveCode() func(code int64) bool {
+	reserved := make(map[int64]bool)
+	return func(code int64) bool {
+		if _, ok := ls.rows[code]; ok || reserved[code] {
+			return false
+		}
+		reserved[code] = true
+		return true
+	}
+}
+
+// findURL returns the actual state of url read from its last row or URLNotFoundError.
+func (ls *LocalStorage) findURL(shortURL int64) (url, error) {
+	pos, ok := ls.rows[shortURL]
+	if !ok {
+		return url{}, &URLNotFoundError{}
+	}
+	row := make([]byte, pos.length)
+	if _, err := ls.file.ReadAt(row, pos.offset); err != nil {
+		return url{}, err
+	}
+	data, err := parseRow(string(row))
+	if err != nil {
+		return url{}, err
+	}
+	if c, ok := ls.counters[data.url]; ok {
+		data = data.withClicks(c)
+	}
+	return data, nil
+}
+
+// changed returns true if counter has clicks which are not saved in file.
+func (c *clickCounter) changed() bool {
+	return c.clicks != 0 || c.consumed != 0 || len(c.variantClicks) != 0
+}
+
+// findOwnedURL returns not deleted url owned by ownerID or URLNotFoundError.
+func (ls *LocalStorage) findOwnedURL(shortURL int64, ownerID uint32) (url, error) {
+	data, err := ls.findURL(shortURL)
+	if err != nil {
+		return url{}, err
+	}
+	if data.isDeleted || data.userID != ownerID {
+		return url{}, &URLNotFoundError{}
+	}
+	return data, nil
+}
+
+// readURLs returns the actual state of every url in order of url creation.
+func (ls *LocalStorage) readURLs() ([]url, error) {
 	fileForRead, err := os.OpenFile(ls.file.Name(), os.O_RDONLY, 0777)
-	urls := make([]URLInfo, 0)
 	if err != nil {
-		return urls
+		return nil, err
 	}
 	defer fileForRead.Close()
 	scanner := bufio.NewScanner(fileForRead)
+	urls := make([]url, 0)
+	positions := make(map[string]int)
 	for scanner.Scan() {
-		data := scanner.Text()
-		urlData := strings.Split(data, splitSeq)
-		if len(urlData) != countDataInRow {
+		data, err := parseRow(scanner.Text())
+		if err != nil {
 			continue
 		}
-		storageUserID, err := strconv.ParseInt(urlData[0], 10, 64)
-		if err != nil || uint32(storageUserID) != userID {
+		if pos, ok := positions[data.url]; ok {
+			urls[pos] = data
 			continue
 		}
-		shortURL, err := strconv.ParseInt(urlData[1], 10, 64)
+		positions[data.url] = len(urls)
+		urls = append(urls, data)
+	}
+	for i, data := range urls {
+		if c, ok := ls.counters[data.url]; ok {
+			urls[i] = data.withClicks(c)
+		}
+	}
+	return urls, scanner.Err()
+}
+
+// appendURLs appends rows with urls state in file and updates their positions and index, ls must be locked.
+// Urls must be read by findURL or readURLs, so their counted clicks are saved with them.
+func (ls *LocalStorage) appendURLs(urls ...url) error {
+	var rows strings.Builder
+	positions := make([]rowPosition, len(urls))
+	for i, data := range urls {
+		row, err := data.row()
 		if err != nil {
-			continue
+			return err
 		}
-		shortEndpoint := strconv.FormatInt(shortURL, 10)
-		short := beginURL + shortEndpoint
-		url := URLInfo{
-			ShortURL:    short,
-			OriginalURL: urlData[1],
+		positions[i] = rowPosition{offset: ls.size + int64(rows.Len()), length: len(row)}
+		rows.WriteString(row + "\n")
+	}
+	n, err := ls.file.WriteString(rows.String())
+	ls.size += int64(n)
+	if err != nil {
+		return err
+	}
+	for i, data := range urls {
+		delete(ls.counters, data.url)
+		ls.setRow(data, positions[i])
+	}
+	return nil
+}
+
+// loadRows reads positions of the last rows of urls from file and builds index of user urls.
+func (ls *LocalStorage) loadRows() error {
+	fileForRead, err := os.Open(ls.file.Name())
+	if err != nil {
+		return err
+	}
+	defer fileForRead.Close()
+	scanner := bufio.NewScanner(fileForRead)
+	for scanner.Scan() {
+		row := scanner.Text()
+		pos := rowPosition{offset: ls.size, length: len(row)}
+		ls.size += int64(len(row)) + 1
+		if data, err := parseRow(row); err == nil {
+			ls.setRow(data, pos)
 		}
-		urls = append(urls, url)
 	}
-	return urls
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	stat, err := ls.file.Stat()
+	if err != nil {
+		return err
+	}
+	ls.size = stat.Size()
+	return nil
 }
 
-// Close closes everything that should be closed in the context of the repository.
-func (ls *LocalStorage) Close() error {
-	return ls.file.Close()
+// setRow saves position of the last row of url and updates url in index, ls must be locked.
+func (ls *LocalStorage) setRow(data url, pos rowPosition) {
+	shortURL, err := strconv.ParseInt(data.url, 10, 64)
+	if err != nil {
+		return
+	}
+	ls.rows[shortURL] = pos
+	ls.index.put(shortURL, newIndexEntry(
+		data.userID,
+		data.meta.Shares,
+		data.meta.WorkspaceID,
+		data.meta.CreatedAt,
+		data.meta.Clicks,
+	))
+}
+
+// withClicks returns url with clicks counted in memory.
+func (u url) withClicks(c *clickCounter) url {
+	u.meta.Clicks += c.clicks
+	for variant, clicks := range c.variantClicks {
+		if variant < len(u.meta.Variants) {
+			u.meta.Variants[variant].Clicks += clicks
+		}
+	}
+	if u.meta.MaxClicks > 0 {
+		u.meta.ClicksLeft -= c.consumed
+	}
+	return u
+}
+
+// withDestination returns url re-pointed to originalURL with it in history.
+func (u url) withDestination(originalURL string) url {
+	u.meta.History = addVersion(u.meta.History, u.fullURL, u.meta.CreatedAt, originalURL)
+	u.fullURL = originalURL
+	u.meta.UpdatedAt = time.Now()
+	return u
+}
+
+// info returns URLInfo of url with code.
+func (u url) info(code int64) URLInfo {
+	return URLInfo{
+		Code:          code,
+		OriginalURL:   u.fullURL,
+		ShortDomain:   u.meta.ShortDomain,
+		WorkspaceID:   u.meta.WorkspaceID,
+		CreatedAt:     u.meta.CreatedAt,
+		UpdatedAt:     u.meta.UpdatedAt,
+		Clicks:        u.meta.Clicks,
+		Tags:          u.meta.Tags,
+		Title:         u.meta.Title,
+		Description:   u.meta.Description,
+		IsDeleted:     u.isDeleted,
+		Preview:       u.meta.Preview,
+		RedirectType:  u.meta.RedirectType,
+		Passthrough:   u.meta.Passthrough,
+		QueryConflict: u.meta.QueryConflict,
+		Rules:         u.meta.Rules,
+		Variants:      u.meta.Variants,
+		HasPassword:   len(u.meta.PasswordHash) != 0,
+		MaxClicks:     u.meta.MaxClicks,
+		ClicksLeft:    clicksLeft(u.meta.MaxClicks, u.meta.ClicksLeft),
+		ActivatesAt:   u.meta.ActivatesAt,
+		Scheduled:     !u.isDeleted && isScheduled(u.meta.ActivatesAt, time.Now()),
+	}
+}
+
+// row returns row to append in local storage.
+func (u url) row() (string, error) {
+	row := createRow(int64(u.userID), u.url, u.fullURL, strconv.FormatBool(u.isDeleted))
+	meta, err := json.Marshal(&u.meta)
+	if err != nil {
+		return "", err
+	}
+	// "~" is escaped to keep splitSeq out of json
+	return row + splitSeq + strings.ReplaceAll(string(meta), "~", `\u007e`), nil
+}
+
+// parseRow returns url from local storage row.
+func parseRow(row string) (url, error) {
+	urlData := strings.Split(row, splitSeq)
+	if len(urlData) != countDataInRow && len(urlData) != countDataInRow+1 {
+		return url{}, errors.New("bad data in file")
+	}
+	userID, err := strconv.ParseUint(urlData[0], 10, 32)
+	if err != nil {
+		return url{}, errors.New("bad data in file")
+	}
+	data := url{
+		userID:    uint32(userID),
+		url:       urlData[1],
+		fullURL:   urlData[2],
+		isDeleted: urlData[3] == "true",
+	}
+	if len(urlData) > countDataInRow {
+		if err = json.Unmarshal([]byte(urlData[countDataInRow]), &data.meta); err != nil {
+			return url{}, errors.New("bad data in file")
+		}
+	}
+	return data, nil
 }
 
-// getLastID returns last short url in local storage.
+// getLastID returns next sequential short url after urls in local storage.
+// Random codes are skipped, they don't continue sequence.
 func getLastID(file *os.File) int64 {
 	var lastID int64
-	var err error
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
-		data := scanner.Text()
-		urlData := strings.Split(data, splitSeq)
-		if len(urlData) != countDataInRow {
-			panic(errors.New("bad data in file"))
+		data, err := parseRow(scanner.Text())
+		if err != nil {
+			panic(err)
 		}
-		if lastID, err = strconv.ParseInt(urlData[1], 10, 64); err != nil {
+		id, err := strconv.ParseInt(data.url, 10, 64)
+		if err != nil {
 			panic(errors.New("bad data in file"))
 		}
+		if id > lastID && id < generator.RandomCodeBase {
+			lastID = id
+		}
 	}
-	if _, err = file.Seek(0, io.SeekStart); err != nil {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
 		return 0
 	}
 	return lastID + 1
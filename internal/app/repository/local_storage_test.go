@@ -3,9 +3,11 @@ package repository
 import (
 	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go-axesthump-shortener/internal/app/generator"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -31,6 +33,17 @@ func Test_getLastID(t *testing.T) {
 			fileData: "1~s~e~c~1~s~e~c~fullURL~s~e~c~false\n1~s~e~c~2~s~e~c~fullURL~s~e~c~false",
 			expected: 3,
 		},
+		{
+			name:     "Test getLastID with changed url in last row",
+			fileData: "1~s~e~c~1~s~e~c~fullURL~s~e~c~false\n1~s~e~c~2~s~e~c~fullURL~s~e~c~false\n1~s~e~c~1~s~e~c~fullURL~s~e~c~true",
+			expected: 3,
+		},
+		{
+			name: "Test getLastID with random code",
+			fileData: "1~s~e~c~1~s~e~c~fullURL~s~e~c~false\n1~s~e~c~" + strconv.FormatInt(generator.RandomCodeBase+5, 10) +
+				"~s~e~c~fullURL~s~e~c~false",
+			expected: 2,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -91,13 +104,13 @@ func TestLocalStorage_CreateShortURL(t *testing.T) {
 
 	shortURL, err := ls.CreateShortURL(
 		context.TODO(),
-		"http://localhost:8080/",
 		"http://google.com/some/url",
 		12,
+		URLOptions{},
 	)
 	assert.NoError(t, err)
 
-	assert.Equal(t, "http://localhost:8080/1", shortURL)
+	assert.Equal(t, int64(1), shortURL)
 
 	err = os.Remove("test")
 	assert.NoError(t, err)
@@ -125,21 +138,20 @@ func TestLocalStorage_CreateShortURLs(t *testing.T) {
 	expected := []URLWithID{
 		{
 			CorrelationID: "1",
-			URL:           "http://localhost:8080/1",
+			Code:          1,
 		},
 		{
 			CorrelationID: "2",
-			URL:           "http://localhost:8080/2",
+			Code:          2,
 		},
 		{
 			CorrelationID: "3",
-			URL:           "http://localhost:8080/3",
+			Code:          3,
 		},
 	}
 
 	shortURLs, err := ls.CreateShortURLs(
 		context.TODO(),
-		"http://localhost:8080/",
 		urls,
 		12,
 	)
@@ -153,6 +165,35 @@ func TestLocalStorage_CreateShortURLs(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestLocalStorage_CreateShortURLCollision(t *testing.T) {
+	ctx := context.TODO()
+	ls, err := NewLocalStorage("test")
+	require.NoError(t, err)
+	defer os.Remove("test")
+	defer ls.Close()
+	for _, u := range []string{"http://google.com/1", "http://google.com/2"} {
+		_, err = ls.CreateShortURL(ctx, u, 12, URLOptions{})
+		require.NoError(t, err)
+	}
+
+	// allocator returns codes of stored urls first
+	ls.SetIDAllocator(generator.NewIDGenerator(1))
+	code, err := ls.CreateShortURL(ctx, "http://google.com/3", 12, URLOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), code)
+
+	ls.SetIDAllocator(generator.NewIDGenerator(1))
+	shortURLs, err := ls.CreateShortURLs(ctx, []URLWithID{
+		{CorrelationID: "1", URL: "http://google.com/4"},
+		{CorrelationID: "2", URL: "http://google.com/5"},
+	}, 12)
+	require.NoError(t, err)
+	assert.Equal(t, []URLWithID{{CorrelationID: "1", Code: 4}, {CorrelationID: "2", Code: 5}}, shortURLs)
+	fullURL, err := ls.GetFullURL(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, "http://google.com/5", fullURL)
+}
+
 func TestLocalStorage_GetFullURL(t *testing.T) {
 	ls, err := NewLocalStorage("test")
 	assert.NoError(t, err)
@@ -172,16 +213,13 @@ func TestLocalStorage_GetFullURL(t *testing.T) {
 	}
 	shortURLs, err := ls.CreateShortURLs(
 		context.TODO(),
-		"http://localhost:8080/",
 		urls,
 		12,
 	)
 	assert.NoError(t, err)
 
 	for i, shortURL := range shortURLs {
-		shortURLInt, err := strconv.ParseInt(shortURL.URL[len(shortURL.URL)-1:], 10, 64)
-		assert.NoError(t, err)
-		fullURL, err := ls.GetFullURL(context.TODO(), shortURLInt)
+		fullURL, err := ls.GetFullURL(context.TODO(), shortURL.Code)
 		assert.NoError(t, err)
 		assert.Equal(t, urls[i].URL, fullURL)
 	}
@@ -191,3 +229,316 @@ func TestLocalStorage_GetFullURL(t *testing.T) {
 	err = ls.Close()
 	assert.NoError(t, err)
 }
+
+func TestLocalStorage_DeleteURLs(t *testing.T) {
+	ls, err := NewLocalStorage("test")
+	assert.NoError(t, err)
+	ctx := context.TODO()
+	first, err := ls.CreateShortURL(ctx, "http://google.com/first", 1, URLOptions{})
+	assert.NoError(t, err)
+	second, err := ls.CreateShortURL(ctx, "http://google.com/second", 2, URLOptions{})
+	assert.NoError(t, err)
+	third, err := ls.CreateShortURL(ctx, "http://google.com/third", 2, URLOptions{})
+	assert.NoError(t, err)
+
+	err = ls.DeleteURLs([]DeleteURL{
+		{URL: strconv.FormatInt(first, 10), UserID: 1},
+		{URL: strconv.FormatInt(second, 10), UserID: 2},
+		{URL: strconv.FormatInt(third, 10), UserID: 1},
+	})
+	assert.NoError(t, err)
+
+	for _, tt := range []struct {
+		shortURL  int64
+		isDeleted bool
+	}{
+		{shortURL: first, isDeleted: true},
+		{shortURL: second, isDeleted: true},
+		{shortURL: third, isDeleted: false},
+	} {
+		_, err = ls.GetFullURL(ctx, tt.shortURL)
+		if tt.isDeleted {
+			assert.ErrorIs(t, err, &DeletedURLError{})
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+
+	err = os.Remove("test")
+	assert.NoError(t, err)
+	err = ls.Close()
+	assert.NoError(t, err)
+}
+
+func TestLocalStorage_ShareURL(t *testing.T) {
+	ls, err := NewLocalStorage("test")
+	assert.NoError(t, err)
+	ctx := context.TODO()
+	_, err = ls.CreateShortURL(ctx, "http://google.com/~shared", 1, URLOptions{})
+	assert.NoError(t, err)
+	_, err = ls.CreateShortURL(ctx, "http://google.com/owned", 2, URLOptions{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, ls.ShareURL(ctx, 1, 1, 2, PermissionManage))
+	assert.NoError(t, ls.ShareURL(ctx, 1, 1, 3, PermissionRead))
+	assert.NoError(t, ls.ShareURL(ctx, 1, 1, 4, PermissionStats))
+	assert.ErrorIs(t, ls.ShareURL(ctx, 1, 2, 3, PermissionRead), &URLNotFoundError{})
+	_, err = ls.GetURLHistory(ctx, 1, 4)
+	assert.NoError(t, err)
+	_, err = ls.GetURLHistory(ctx, 1, 3)
+	assert.ErrorIs(t, err, &URLNotFoundError{})
+	assert.Equal(t, []URLInfo{
+		{
+			Code:        1,
+			OriginalURL: "http://google.com/~shared",
+			Ownership:   OwnershipShared,
+			Permission:  PermissionManage,
+		},
+		{
+			Code:        2,
+			OriginalURL: "http://google.com/owned",
+			Ownership:   OwnershipOwned,
+		},
+	}, allURLs(t, ls, 2))
+
+	// shares survive reopening of storage
+	assert.NoError(t, ls.Close())
+	ls, err = NewLocalStorage("test")
+	assert.NoError(t, err)
+
+	assert.NoError(t, ls.UnshareURL(ctx, 1, 1, 3))
+	assert.Empty(t, allURLs(t, ls, 3))
+	assert.NoError(t, ls.TransferURL(ctx, 1, 1, 3))
+	assert.Len(t, allURLs(t, ls, 3), 1)
+	assert.Empty(t, allURLs(t, ls, 1))
+
+	assert.NoError(t, ls.DeleteURLs([]DeleteURL{{URL: "1", UserID: 2}}))
+	_, err = ls.GetFullURL(ctx, 1)
+	assert.ErrorIs(t, err, &DeletedURLError{})
+
+	err = os.Remove("test")
+	assert.NoError(t, err)
+	err = ls.Close()
+	assert.NoError(t, err)
+}
+
+func TestLocalStorage_UpdateURLMeta(t *testing.T) {
+	ls, err := NewLocalStorage("test")
+	require.NoError(t, err)
+	ctx := context.TODO()
+	_, err = ls.CreateShortURL(ctx, "http://google.com/meta", 1, URLOptions{Title: "Google"})
+	require.NoError(t, err)
+
+	tags := []string{"search", "flyer"}
+	_, err = ls.UpdateURLMeta(ctx, 1, 1, URLMetaUpdate{Tags: &tags})
+	require.NoError(t, err)
+	_, err = ls.UpdateURLMeta(ctx, 1, 2, URLMetaUpdate{Tags: &tags})
+	assert.ErrorIs(t, err, &URLNotFoundError{})
+	destination := "http://yandex.ru/meta"
+	_, err = ls.UpdateURLMeta(ctx, 1, 2, URLMetaUpdate{OriginalURL: &destination})
+	assert.ErrorIs(t, err, &URLNotFoundError{})
+	title := "Yandex"
+	_, err = ls.UpdateURLMeta(ctx, 1, 1, URLMetaUpdate{Title: &title, OriginalURL: &destination})
+	require.NoError(t, err)
+
+	// metadata and destination survive reopening of storage
+	require.NoError(t, ls.Close())
+	ls, err = NewLocalStorage("test")
+	require.NoError(t, err)
+	urls := allURLs(t, ls, 1)
+	require.Len(t, urls, 1)
+	assert.Equal(t, title, urls[0].Title)
+	assert.Equal(t, tags, urls[0].Tags)
+	assert.Equal(t, destination, urls[0].OriginalURL)
+	history, err := ls.GetURLHistory(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	err = os.Remove("test")
+	assert.NoError(t, err)
+	err = ls.Close()
+	assert.NoError(t, err)
+}
+
+func TestLocalStorage_ChangeURLDestination(t *testing.T) {
+	ls, err := NewLocalStorage("test")
+	require.NoError(t, err)
+	ctx := context.TODO()
+	_, err = ls.CreateShortURL(ctx, "http://google.com/flyer", 1, URLOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, ls.ChangeURLDestination(ctx, 1, 1, "http://yandex.ru/flyer"))
+	assert.ErrorIs(t, ls.ChangeURLDestination(ctx, 1, 2, "http://yandex.ru/other"), &URLNotFoundError{})
+
+	// destination and history survive reopening of storage
+	require.NoError(t, ls.Close())
+	ls, err = NewLocalStorage("test")
+	require.NoError(t, err)
+	fullURL, err := ls.GetFullURL(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "http://yandex.ru/flyer", fullURL)
+
+	require.NoError(t, ls.RollbackURL(ctx, 1, 1, 1))
+	history, err := ls.GetURLHistory(ctx, 1, 1)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, "http://google.com/flyer", history[0].OriginalURL)
+	assert.Equal(t, "http://yandex.ru/flyer", history[1].OriginalURL)
+	assert.Equal(t, "http://google.com/flyer", history[2].OriginalURL)
+	fullURL, err = ls.GetFullURL(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "http://google.com/flyer", fullURL)
+
+	err = os.Remove("test")
+	assert.NoError(t, err)
+	err = ls.Close()
+	assert.NoError(t, err)
+}
+
+func TestLocalStorage_GetRedirect(t *testing.T) {
+	ls, err := NewLocalStorage("test")
+	require.NoError(t, err)
+	ctx := context.TODO()
+	rules := []RedirectRule{{Platforms: []Platform{PlatformIOS}, URL: "https://apps.apple.com/app"}}
+	_, err = ls.CreateShortURL(ctx, "http://google.com/seo", 1, URLOptions{RedirectType: RedirectMoved, Rules: rules})
+	require.NoError(t, err)
+	permanent := RedirectPermanent
+	_, err = ls.UpdateURLMeta(ctx, 1, 1, URLMetaUpdate{RedirectType: &permanent})
+	require.NoError(t, err)
+
+	// redirect type and rules survive reopening of storage
+	require.NoError(t, ls.Close())
+	ls, err = NewLocalStorage("test")
+	require.NoError(t, err)
+	redirect, err := ls.GetRedirect(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, Redirect{OriginalURL: "http://google.com/seo", Type: RedirectPermanent, Rules: rules}, redirect)
+	_, err = ls.GetRedirect(ctx, 2)
+	assert.ErrorIs(t, err, &URLNotFoundError{})
+
+	variants := []URLVariant{{URL: "http://google.com/a", Weight: 1}, {URL: "http://google.com/b", Weight: 3}}
+	_, err = ls.UpdateURLMeta(ctx, 1, 1, URLMetaUpdate{Variants: &variants})
+	require.NoError(t, err)
+	require.NoError(t, ls.AddVariantClick(ctx, 1, 1))
+	require.NoError(t, ls.AddVariantClick(ctx, 1, 1))
+	require.NoError(t, ls.AddVariantClick(ctx, 1, 5))
+	maxClicks := int64(1)
+	_, err = ls.UpdateURLMeta(ctx, 1, 1, URLMetaUpdate{MaxClicks: &maxClicks})
+	require.NoError(t, err)
+	require.NoError(t, ls.ConsumeClick(ctx, 1))
+	assert.ErrorIs(t, ls.ConsumeClick(ctx, 1), &ClicksExhaustedError{})
+	info, err := ls.GetURLInfo(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), info.MaxClicks)
+	assert.Equal(t, int64(0), *info.ClicksLeft)
+	assert.Equal(t, int64(3), info.Clicks)
+	assert.Equal(t, []URLVariant{
+		{URL: "http://google.com/a", Weight: 1},
+		{URL: "http://google.com/b", Weight: 3, Clicks: 2},
+	}, info.Variants)
+
+	// clicks counted in memory are saved on close
+	require.NoError(t, ls.Close())
+	ls, err = NewLocalStorage("test")
+	require.NoError(t, err)
+	info, err = ls.GetURLInfo(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), info.Clicks)
+	assert.Equal(t, int64(0), *info.ClicksLeft)
+	assert.ErrorIs(t, ls.ConsumeClick(ctx, 1), &ClicksExhaustedError{})
+
+	err = ls.Close()
+	assert.NoError(t, err)
+	err = os.Remove("test")
+	assert.NoError(t, err)
+}
+
+func TestLocalStorage_flushClicks(t *testing.T) {
+	ctx := context.TODO()
+	ls, err := NewLocalStorage("test")
+	require.NoError(t, err)
+	defer os.Remove("test")
+	defer ls.Close()
+	_, err = ls.CreateShortURL(ctx, "http://google.com/clicks", 1, URLOptions{})
+	require.NoError(t, err)
+	rows := func() int {
+		data, err := os.ReadFile("test")
+		require.NoError(t, err)
+		return strings.Count(string(data), "\n")
+	}
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, ls.AddClick(ctx, 1))
+	}
+	require.NoError(t, ls.AddClick(ctx, 100))
+	// clicks are not saved in file until flush but they are read with url
+	assert.Equal(t, 1, rows())
+	info, err := ls.GetURLInfo(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), info.Clicks)
+
+	ls.Lock()
+	require.NoError(t, ls.flushClicks())
+	ls.Unlock()
+	// one row for all clicks of url, clicks of unknown url are dropped
+	assert.Equal(t, 2, rows())
+	assert.Empty(t, ls.counters)
+	info, err = ls.GetURLInfo(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), info.Clicks)
+
+	// change of url saves its clicks
+	require.NoError(t, ls.AddClick(ctx, 1))
+	title := "clicks"
+	_, err = ls.UpdateURLMeta(ctx, 1, 1, URLMetaUpdate{Title: &title})
+	require.NoError(t, err)
+	assert.Empty(t, ls.counters)
+	info, err = ls.GetURLInfo(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), info.Clicks)
+}
+
+func TestLocalStorage_GetAllURLs(t *testing.T) {
+	ctx := context.TODO()
+	ls, err := NewLocalStorage("test")
+	require.NoError(t, err)
+	defer os.Remove("test")
+	for _, url := range []string{"http://google.com/1", "http://google.com/2", "http://google.com/3"} {
+		_, err = ls.CreateShortURL(ctx, url, 1, URLOptions{})
+		require.NoError(t, err)
+	}
+	_, err = ls.CreateShortURL(ctx, "http://google.com/other", 2, URLOptions{})
+	require.NoError(t, err)
+	require.NoError(t, ls.ShareURL(ctx, 4, 2, 1, PermissionRead))
+	require.NoError(t, ls.AddClick(ctx, 1))
+	require.NoError(t, ls.AddClick(ctx, 1))
+	require.NoError(t, ls.AddClick(ctx, 3))
+	pages := func() [][]int64 {
+		res := make([][]int64, 0)
+		filter := URLFilter{Limit: 2, SortBy: SortByClicks, Desc: true}
+		for {
+			page, err := ls.GetAllURLs(ctx, 1, filter)
+			require.NoError(t, err)
+			codes := make([]int64, 0, len(page.URLs))
+			for _, url := range page.URLs {
+				codes = append(codes, url.Code)
+			}
+			res = append(res, codes)
+			if len(filter.Cursor) == 0 {
+				assert.Equal(t, 4, page.Total)
+			}
+			if len(page.NextCursor) == 0 {
+				return res
+			}
+			filter.Cursor = page.NextCursor
+		}
+	}
+	assert.Equal(t, [][]int64{{1, 3}, {4, 2}}, pages())
+
+	// index is restored from file
+	require.NoError(t, ls.Close())
+	ls, err = NewLocalStorage("test")
+	require.NoError(t, err)
+	defer ls.Close()
+	assert.Equal(t, [][]int64{{1, 3}, {4, 2}}, pages())
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"net/http"
+	"time"
+)
+
+// RedirectType http status of redirect by short url, RedirectDefault means status from service config.
+type RedirectType int
+
+// Redirect types.
+const (
+	RedirectDefault   RedirectType = 0
+	RedirectMoved     RedirectType = http.StatusMovedPermanently
+	RedirectFound     RedirectType = http.StatusFound
+	RedirectTemporary RedirectType = http.StatusTemporaryRedirect
+	RedirectPermanent RedirectType = http.StatusPermanentRedirect
+)
+
+// IsValid returns true if t is known redirect type.
+func (t RedirectType) IsValid() bool {
+	switch t {
+	case RedirectDefault, RedirectMoved, RedirectFound, RedirectTemporary, RedirectPermanent:
+		return true
+	}
+	return false
+}
+
+// PassthroughMode defines which parts of request to short url are passed to destination.
+// PassthroughDefault means mode from service config.
+type PassthroughMode string
+
+// Passthrough modes.
+const (
+	PassthroughDefault PassthroughMode = ""
+	PassthroughNone    PassthroughMode = "none"
+	PassthroughQuery   PassthroughMode = "query"
+	PassthroughPath    PassthroughMode = "path"
+	PassthroughAll     PassthroughMode = "all"
+)
+
+// IsValid returns true if m is known passthrough mode.
+func (m PassthroughMode) IsValid() bool {
+	switch m {
+	case PassthroughDefault, PassthroughNone, PassthroughQuery, PassthroughPath, PassthroughAll:
+		return true
+	}
+	return false
+}
+
+// PassQuery returns true if query params of request are passed to destination.
+func (m PassthroughMode) PassQuery() bool {
+	return m == PassthroughQuery || m == PassthroughAll
+}
+
+// PassPath returns true if path after short url code is passed to destination.
+func (m PassthroughMode) PassPath() bool {
+	return m == PassthroughPath || m == PassthroughAll
+}
+
+// QueryConflict defines how request query param is merged with destination param with the same name.
+// QueryConflictDefault means policy from service config.
+type QueryConflict string
+
+// Query conflict policies.
+const (
+	QueryConflictDefault  QueryConflict = ""
+	QueryConflictKeep     QueryConflict = "keep"     // destination value is kept
+	QueryConflictOverride QueryConflict = "override" // request value replaces destination value
+	QueryConflictAppend   QueryConflict = "append"   // both values are passed
+)
+
+// IsValid returns true if c is known query conflict policy.
+func (c QueryConflict) IsValid() bool {
+	switch c {
+	case QueryConflictDefault, QueryConflictKeep, QueryConflictOverride, QueryConflictAppend:
+		return true
+	}
+	return false
+}
+
+// Redirect contains data for redirect by short url.
+type Redirect struct {
+	// OriginalURL - destination of redirect.
+	OriginalURL string
+	// Type - redirect status of url.
+	Type RedirectType
+	// Passthrough - parts of request passed to destination.
+	Passthrough PassthroughMode
+	// QueryConflict - policy of query params merge.
+	QueryConflict QueryConflict
+	// Rules - ordered conditional destinations.
+	Rules []RedirectRule
+	// Variants - weighted destinations of A/B split.
+	Variants []URLVariant
+	// PasswordHash - hash of password required to open url, empty if url is public.
+	PasswordHash string
+	// MaxClicks - count of redirects after which url stops working, 0 if url is unlimited.
+	MaxClicks int64
+	// ClicksLeft - count of redirects left by url with max clicks.
+	ClicksLeft int64
+	// ActivatesAt - time from which url redirects, nil if url is active at once.
+	ActivatesAt *time.Time
+	// ShortDomain - custom domain which serves url, empty for domain of service base url.
+	ShortDomain string
+}
+
+// IsActive returns true if url redirects at now.
+func (r Redirect) IsActive(now time.Time) bool {
+	return !isScheduled(r.ActivatesAt, now)
+}
+
+// IsExhausted returns true if url with max clicks has no redirects left.
+func (r Redirect) IsExhausted() bool {
+	return r.MaxClicks > 0 && r.ClicksLeft <= 0
+}
+
+// isScheduled returns true if url with activation time activatesAt is not active at now.
+func isScheduled(activatesAt *time.Time, now time.Time) bool {
+	return activatesAt != nil && now.Before(*activatesAt)
+}
+
+// clicksLeft returns count of redirects left for URLInfo, nil if url is unlimited.
+func clicksLeft(maxClicks int64, left int64) *int64 {
+	if maxClicks <= 0 {
+		return nil
+	}
+	return &left
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"strings"
+	"time"
+)
+
+// Platform operating system of visitor detected by User-Agent.
+type Platform string
+
+// Platforms.
+const (
+	PlatformUnknown Platform = ""
+	PlatformIOS     Platform = "ios"
+	PlatformAndroid Platform = "android"
+	PlatformWindows Platform = "windows"
+	PlatformMacOS   Platform = "macos"
+	PlatformLinux   Platform = "linux"
+)
+
+// IsValid returns true if p is known platform.
+func (p Platform) IsValid() bool {
+	switch p {
+	case PlatformIOS, PlatformAndroid, PlatformWindows, PlatformMacOS, PlatformLinux:
+		return true
+	}
+	return false
+}
+
+// RedirectRule conditional destination of url.
+// Rule matches visitor if all its non-empty conditions match, empty condition matches everyone.
+type RedirectRule struct {
+	// Platforms - platforms of visitor.
+	Platforms []Platform `json:"platforms,omitempty"`
+	// Languages - preferred language of visitor, "de" matches every "de-*" language.
+	Languages []string `json:"languages,omitempty"`
+	// Countries - ISO 3166-1 alpha-2 codes of visitor country.
+	Countries []string `json:"countries,omitempty"`
+	// StartsAt - begin of rule time window.
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	// EndsAt - end of rule time window, excluded.
+	EndsAt *time.Time `json:"ends_at,omitempty"`
+	// URL - destination of visitor matched by rule.
+	URL string `json:"url"`
+}
+
+// Visitor contains properties of request to short url checked by redirect rules.
+type Visitor struct {
+	// Platform - platform of visitor.
+	Platform Platform
+	// Language - preferred language tag of visitor.
+	Language string
+	// Country - ISO 3166-1 alpha-2 code of visitor country, empty if unknown.
+	Country string
+	// Time - time of request.
+	Time time.Time
+}
+
+// Matches returns true if rule conditions match visitor.
+func (r RedirectRule) Matches(v Visitor) bool {
+	if len(r.Platforms) != 0 && !containsPlatform(r.Platforms, v.Platform) {
+		return false
+	}
+	if len(r.Languages) != 0 && !matchesLanguage(r.Languages, v.Language) {
+		return false
+	}
+	if len(r.Countries) != 0 && !containsFold(r.Countries, v.Country) {
+		return false
+	}
+	if r.StartsAt != nil && v.Time.Before(*r.StartsAt) {
+		return false
+	}
+	if r.EndsAt != nil && !v.Time.Before(*r.EndsAt) {
+		return false
+	}
+	return true
+}
+
+// HasConditions returns true if rule has at least one condition.
+func (r RedirectRule) HasConditions() bool {
+	return len(r.Platforms) != 0 || len(r.Languages) != 0 || len(r.Countries) != 0 ||
+		r.StartsAt != nil || r.EndsAt != nil
+}
+
+// MatchRules returns destination of the first rule which matches visitor.
+// Returns false if no rule matches.
+func MatchRules(rules []RedirectRule, v Visitor) (string, bool) {
+	for _, rule := range rules {
+		if rule.Matches(v) {
+			return rule.URL, true
+		}
+	}
+	return "", false
+}
+
+// NeedCountry returns true if some of rules checks country of visitor.
+func NeedCountry(rules []RedirectRule) bool {
+	for _, rule := range rules {
+		if len(rule.Countries) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// notNilRules returns empty slice instead of nil rules.
+func notNilRules(rules []RedirectRule) []RedirectRule {
+	if rules == nil {
+		return []RedirectRule{}
+	}
+	return rules
+}
+
+// containsPlatform returns true if platforms contains p.
+func containsPlatform(platforms []Platform, p Platform) bool {
+	if p == PlatformUnknown {
+		return false
+	}
+	for _, platform := range platforms {
+		if platform == p {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold returns true if values contains s under case-folding.
+func containsFold(values []string, s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, value := range values {
+		if strings.EqualFold(value, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesLanguage returns true if language is equal to one of languages or is its subtag.
+func matchesLanguage(languages []string, language string) bool {
+	if len(language) == 0 {
+		return false
+	}
+	language = strings.ToLower(language)
+	for _, l := range languages {
+		l = strings.ToLower(l)
+		if language == l || strings.HasPrefix(language, l+"-") {
+			return true
+		}
+	}
+	return false
+}
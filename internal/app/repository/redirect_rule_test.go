@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMatchRules(t *testing.T) {
+	startsAt := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	endsAt := time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)
+	rules := []RedirectRule{
+		{Platforms: []Platform{PlatformIOS}, URL: "https://apps.apple.com/app"},
+		{Platforms: []Platform{PlatformAndroid}, URL: "https://play.google.com/app"},
+		{Countries: []string{"DE", "AT"}, Languages: []string{"de"}, URL: "https://example.com/de"},
+		{StartsAt: &startsAt, EndsAt: &endsAt, URL: "https://example.com/sale"},
+	}
+	inWindow := time.Date(2022, 11, 15, 0, 0, 0, 0, time.UTC)
+	afterWindow := endsAt
+
+	tests := []struct {
+		name    string
+		visitor Visitor
+		want    string
+		matched bool
+	}{
+		{
+			name:    "ios",
+			visitor: Visitor{Platform: PlatformIOS, Country: "DE", Language: "de", Time: inWindow},
+			want:    "https://apps.apple.com/app",
+			matched: true,
+		},
+		{
+			name:    "android",
+			visitor: Visitor{Platform: PlatformAndroid, Time: afterWindow},
+			want:    "https://play.google.com/app",
+			matched: true,
+		},
+		{
+			name:    "german regional language",
+			visitor: Visitor{Platform: PlatformWindows, Country: "at", Language: "de-AT", Time: afterWindow},
+			want:    "https://example.com/de",
+			matched: true,
+		},
+		{
+			name:    "german language outside of country",
+			visitor: Visitor{Platform: PlatformWindows, Country: "FR", Language: "de", Time: afterWindow},
+		},
+		{
+			name:    "unknown country",
+			visitor: Visitor{Language: "de", Time: afterWindow},
+		},
+		{
+			name:    "time window",
+			visitor: Visitor{Platform: PlatformLinux, Language: "en", Time: inWindow},
+			want:    "https://example.com/sale",
+			matched: true,
+		},
+		{
+			name:    "end of time window",
+			visitor: Visitor{Platform: PlatformLinux, Language: "deu", Time: afterWindow},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, matched := MatchRules(rules, tt.visitor)
+			assert.Equal(t, tt.matched, matched)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	assert.True(t, NeedCountry(rules))
+	assert.False(t, NeedCountry(rules[:2]))
+}
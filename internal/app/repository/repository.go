@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 )
 
 // DeletedURLError delete url error.
@@ -13,6 +14,118 @@ func (e *DeletedURLError) Error() string {
 	return "URL deleted"
 }
 
+// URLNotFoundError url not found or not owned by user error.
+type URLNotFoundError struct {
+}
+
+// Error return URLNotFoundError description.
+func (e *URLNotFoundError) Error() string {
+	return "URL not found"
+}
+
+// ClicksExhaustedError url with max clicks has no clicks left error.
+type ClicksExhaustedError struct {
+}
+
+// Error return ClicksExhaustedError description.
+func (e *ClicksExhaustedError) Error() string {
+	return "URL clicks exhausted"
+}
+
+// Permission access level to url granted to user by url owner.
+type Permission string
+
+// Permissions to url.
+const (
+	// PermissionRead allows to see url in user urls.
+	PermissionRead Permission = "read"
+	// PermissionStats allows to see url with its clicks and history.
+	PermissionStats Permission = "stats"
+	// PermissionManage allows to see url with its clicks and history and delete url.
+	PermissionManage Permission = "manage"
+)
+
+// permissionLevels levels of permissions, permission includes all permissions with lower level.
+var permissionLevels = map[Permission]int{
+	PermissionRead:   1,
+	PermissionStats:  2,
+	PermissionManage: 3,
+}
+
+// IsValid returns true if p is known permission.
+func (p Permission) IsValid() bool {
+	_, ok := permissionLevels[p]
+	return ok
+}
+
+// Allows returns true if p includes permission.
+func (p Permission) Allows(permission Permission) bool {
+	return p.IsValid() && permissionLevels[p] >= permissionLevels[permission]
+}
+
+// Ownership of url for user in URLInfo.
+const (
+	OwnershipOwned     = "owned"
+	OwnershipShared    = "shared"
+	OwnershipWorkspace = "workspace"
+)
+
+// URLOptions optional url settings set at url creation.
+type URLOptions struct {
+	// WorkspaceID - workspace which contains url, 0 if url is personal.
+	WorkspaceID int64
+	// Tags - free-form url tags.
+	Tags []string
+	// Title - url title.
+	Title string
+	// Description - url notes.
+	Description string
+	// RedirectType - redirect status of url.
+	RedirectType RedirectType
+	// Passthrough - parts of request passed to destination.
+	Passthrough PassthroughMode
+	// QueryConflict - policy of query params merge.
+	QueryConflict QueryConflict
+	// Rules - ordered conditional destinations.
+	Rules []RedirectRule
+	// Variants - weighted destinations of A/B split.
+	Variants []URLVariant
+	// PasswordHash - hash of password required to open url, empty if url is public.
+	PasswordHash string
+	// MaxClicks - count of redirects after which url stops working, 0 if url is unlimited.
+	MaxClicks int64
+	// ActivatesAt - time from which url redirects, nil if url is active at once.
+	ActivatesAt *time.Time
+	// ShortDomain - registered custom domain of url, empty for domain of service base url.
+	ShortDomain string
+}
+
+// URLMetaUpdate contains new url metadata, nil fields are not changed.
+type URLMetaUpdate struct {
+	// Title - new url title.
+	Title *string
+	// Description - new url notes.
+	Description *string
+	// Tags - new url tags.
+	Tags *[]string
+	// RedirectType - new redirect status of url.
+	RedirectType *RedirectType
+	// Passthrough - new parts of request passed to destination.
+	Passthrough *PassthroughMode
+	// QueryConflict - new policy of query params merge.
+	QueryConflict *QueryConflict
+	// Rules - new ordered conditional destinations.
+	Rules *[]RedirectRule
+	// Variants - new weighted destinations of A/B split, clicks of variants are reset.
+	Variants *[]URLVariant
+	// PasswordHash - new hash of url password, empty removes password.
+	PasswordHash *string
+	// MaxClicks - new count of redirects left, 0 removes limit.
+	MaxClicks *int64
+	// OriginalURL - new destination of url, it is added in url history. Only url owner can change it.
+	OriginalURL *string
+}
+
 // DeleteURL contains info about url for delete.
 type DeleteURL struct {
 	// URL - url for delete.
@@ -21,35 +134,196 @@ type DeleteURL struct {
 	UserID uint32
 }
 
+// apply sets non-nil fields of update to url info.
+func (u URLMetaUpdate) apply(info *URLInfo) {
+	if u.Title != nil {
+		info.Title = *u.Title
+	}
+	if u.Description != nil {
+		info.Description = *u.Description
+	}
+	if u.Tags != nil {
+		info.Tags = *u.Tags
+	}
+	if u.RedirectType != nil {
+		info.RedirectType = *u.RedirectType
+	}
+	if u.Passthrough != nil {
+		info.Passthrough = *u.Passthrough
+	}
+	if u.QueryConflict != nil {
+		info.QueryConflict = *u.QueryConflict
+	}
+	if u.Rules != nil {
+		info.Rules = *u.Rules
+	}
+	if u.Variants != nil {
+		info.Variants = withoutClicks(*u.Variants)
+	}
+	if u.PasswordHash != nil {
+		info.HasPassword = len(*u.PasswordHash) != 0
+	}
+	if u.MaxClicks != nil {
+		info.MaxClicks = *u.MaxClicks
+		info.ClicksLeft = clicksLeft(*u.MaxClicks, *u.MaxClicks)
+	}
+}
+
 // URLInfo contains url info.
 type URLInfo struct {
+	// Code - code of short url in storage.
+	Code int64 `json:"-"`
+	// ShortURL - short url of code, it's set by presentation layer.
 	ShortURL    string `json:"short_url"`
 	OriginalURL string `json:"original_url"`
+	// ShortDomain - custom domain of url, empty for domain of service base url.
+	ShortDomain string `json:"short_domain,omitempty"`
+	// Ownership - OwnershipOwned, OwnershipShared or OwnershipWorkspace.
+	Ownership string `json:"ownership,omitempty"`
+	// Permission - permission of user to shared or workspace url.
+	Permission Permission `json:"permission,omitempty"`
+	// WorkspaceID - workspace which contains url.
+	WorkspaceID int64 `json:"workspace_id,omitempty"`
+	// CreatedAt - url creation time.
+	CreatedAt time.Time `json:"created_at"`
+	// Clicks - count of redirects by url.
+	Clicks int64 `json:"clicks"`
+	// Tags - free-form url tags.
+	Tags []string `json:"tags,omitempty"`
+	// IsDeleted - url was deleted.
+	IsDeleted bool `json:"is_deleted,omitempty"`
+	// Title - url title.
+	Title string `json:"title,omitempty"`
+	// Description - url notes.
+	Description string `json:"description,omitempty"`
+	// UpdatedAt - url metadata last update time.
+	UpdatedAt time.Time `json:"updated_at"`
+	// RedirectType - redirect status of url, 0 if status from service config is used.
+	RedirectType RedirectType `json:"redirect_type,omitempty"`
+	// Passthrough - parts of request passed to destination, empty if mode from service config is used.
+	Passthrough PassthroughMode `json:"passthrough,omitempty"`
+	// QueryConflict - policy of query params merge, empty if policy from service config is used.
+	QueryConflict QueryConflict `json:"query_conflict,omitempty"`
+	// Rules - ordered conditional destinations, the first matched rule replaces original url.
+	Rules []RedirectRule `json:"rules,omitempty"`
+	// Variants - weighted destinations of A/B split with their clicks, replace original url if not empty.
+	Variants []URLVariant `json:"variants,omitempty"`
+	// HasPassword - url is opened only with password.
+	HasPassword bool `json:"has_password,omitempty"`
+	// MaxClicks - count of redirects after which url stops working, 0 if url is unlimited.
+	MaxClicks int64 `json:"max_clicks,omitempty"`
+	// ClicksLeft - count of redirects left, nil if url is unlimited.
+	ClicksLeft *int64 `json:"clicks_left,omitempty"`
+	// ActivatesAt - time from which url redirects, nil if url is active at once.
+	ActivatesAt *time.Time `json:"activates_at,omitempty"`
+	// Scheduled - url is not active yet.
+	Scheduled bool `json:"scheduled,omitempty"`
+	// Preview - metadata of destination page, nil until page is fetched.
+	Preview *URLPreview `json:"preview,omitempty"`
 }
 
-// URLWithID contains url (short/original) and correlation id.
+// AllowsStats returns true if user is owner of url or has PermissionStats to it.
+func (u URLInfo) AllowsStats() bool {
+	return u.Ownership == OwnershipOwned || u.Permission.Allows(PermissionStats)
+}
+
+// WithoutStats returns url info without clicks of url and its variants.
+func (u URLInfo) WithoutStats() URLInfo {
+	u.Clicks = 0
+	u.ClicksLeft = nil
+	u.Variants = withoutClicks(u.Variants)
+	return u
+}
+
+// URLWithID contains original url or code of created url and correlation id.
 type URLWithID struct {
 	CorrelationID string
-	URL           string
+	// URL - original url, not used in result.
+	URL string
+	// Options - settings of new url, not used in result.
+	Options URLOptions
+	// Code - code of created url, set only in result.
+	Code int64
 }
 
 // Repository define api for work with storage.
 type Repository interface {
-	// CreateShortURL creates short url. Returns short url if operations success or error.
-	CreateShortURL(ctx context.Context, beginURL string, originalURL string, userID uint32) (string, error)
+	WorkspaceStore
+	UTMTemplateStore
+	DomainStore
+
+	// CreateShortURL creates short url. Returns code of url if operations success or error.
+	// Returns code of existing url with LongURLConflictError if storage keeps one url per original url.
+	CreateShortURL(ctx context.Context, originalURL string, userID uint32, opts URLOptions) (int64, error)
 
-	// CreateShortURLs creates short urls. Returns slice short urls if operations success or error.
-	CreateShortURLs(ctx context.Context, beginURL string, urls []URLWithID, userID uint32) ([]URLWithID, error)
+	// CreateShortURLs creates short urls. Returns codes of urls with their correlation ids if operations success or error.
+	CreateShortURLs(ctx context.Context, urls []URLWithID, userID uint32) ([]URLWithID, error)
 
 	// GetFullURL returns full url by short url.
+	// Returns URLNotFoundError if url doesn't exist or DeletedURLError if url deleted.
 	GetFullURL(ctx context.Context, shortURL int64) (string, error)
 
-	// GetAllURLs returns all urls owned specific user.
-	GetAllURLs(ctx context.Context, beginURL string, userID uint32) []URLInfo
+	// GetRedirect returns data for redirect by not deleted short url.
+	// Returns URLNotFoundError if url doesn't exist or DeletedURLError if url deleted.
+	GetRedirect(ctx context.Context, shortURL int64) (Redirect, error)
+
+	// GetURLInfo returns info of not deleted url without its access settings.
+	// Returns URLNotFoundError if url doesn't exist or DeletedURLError if url deleted.
+	GetURLInfo(ctx context.Context, shortURL int64) (URLInfo, error)
+
+	// AddClick increments count of redirects by short url.
+	AddClick(ctx context.Context, shortURL int64) error
+
+	// ConsumeClick atomically decrements count of redirects left by url with max clicks.
+	// Returns ClicksExhaustedError if no redirects are left, urls without limit are not changed.
+	ConsumeClick(ctx context.Context, shortURL int64) error
+
+	// AddVariantClick increments count of redirects by short url and count of redirects to its variant.
+	AddVariantClick(ctx context.Context, shortURL int64, variant int) error
+
+	// GetAllURLs returns page of urls owned specific user, urls shared with him and urls of his workspaces.
+	// Returns InvalidCursorError if filter contains bad cursor.
+	GetAllURLs(ctx context.Context, userID uint32, filter URLFilter) (URLPage, error)
+
+	// UpdateURLMeta updates metadata and destination of not deleted url at once and returns updated url.
+	// Returns URLNotFoundError if url doesn't exist, deleted or user has no PermissionManage to it,
+	// destination is changed only by owner of url. Nothing is changed if error is returned.
+	UpdateURLMeta(ctx context.Context, shortURL int64, userID uint32, update URLMetaUpdate) (URLInfo, error)
+
+	// ChangeURLDestination re-points url owned by ownerID to originalURL and adds it in url history.
+	// Returns URLNotFoundError if url doesn't exist, deleted or not owned by ownerID.
+	ChangeURLDestination(ctx context.Context, shortURL int64, ownerID uint32, originalURL string) error
+
+	// GetURLHistory returns all destinations of url from the first one.
+	// User must be owner of url or have PermissionStats to url or its workspace.
+	// Returns URLNotFoundError if url doesn't exist, deleted or not owned by ownerID.
+	GetURLHistory(ctx context.Context, shortURL int64, userID uint32) ([]URLVersion, error)
+
+	// RollbackURL re-points url owned by ownerID to destination of version from url history.
+	// Returns URLVersionNotFoundError if history doesn't contain version.
+	RollbackURL(ctx context.Context, shortURL int64, ownerID uint32, version int) error
+
+	// SetURLPreview saves preview of url destination page.
+	// Returns URLNotFoundError if url doesn't exist or doesn't point to originalURL anymore.
+	SetURLPreview(ctx context.Context, shortURL int64, originalURL string, preview URLPreview) error
 
 	// DeleteURLs delete url from urlsForDelete.
+	// urlsForDelete can contain urls of different users,
+	// every url is deleted only by its owner or user with PermissionManage to it or its workspace.
 	DeleteURLs(urlsForDelete []DeleteURL) error
 
+	// TransferURL transfers url owned by ownerID to newOwnerID.
+	// Returns URLNotFoundError if url doesn't exist, deleted or not owned by ownerID.
+	TransferURL(ctx context.Context, shortURL int64, ownerID uint32, newOwnerID uint32) error
+
+	// ShareURL grants permission to url owned by ownerID for userID.
+	// Returns URLNotFoundError if url doesn't exist, deleted or not owned by ownerID.
+	ShareURL(ctx context.Context, shortURL int64, ownerID uint32, userID uint32, permission Permission) error
+
+	// UnshareURL revokes access to url owned by ownerID from userID.
+	// Returns URLNotFoundError if url doesn't exist, deleted or not owned by ownerID.
+	UnshareURL(ctx context.Context, shortURL int64, ownerID uint32, userID uint32) error
+
 	// Close closes everything that should be closed in the context of the repository.
 	Close() error
 }
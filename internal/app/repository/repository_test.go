@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPermission_Allows(t *testing.T) {
+	tests := []struct {
+		permission Permission
+		allowed    []Permission
+		denied     []Permission
+	}{
+		{
+			permission: PermissionRead,
+			allowed:    []Permission{PermissionRead},
+			denied:     []Permission{PermissionStats, PermissionManage},
+		},
+		{
+			permission: PermissionStats,
+			allowed:    []Permission{PermissionRead, PermissionStats},
+			denied:     []Permission{PermissionManage},
+		},
+		{
+			permission: PermissionManage,
+			allowed:    []Permission{PermissionRead, PermissionStats, PermissionManage},
+		},
+		{
+			permission: "",
+			denied:     []Permission{PermissionRead, PermissionStats, PermissionManage},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.permission), func(t *testing.T) {
+			for _, p := range tt.allowed {
+				assert.True(t, tt.permission.Allows(p), p)
+			}
+			for _, p := range tt.denied {
+				assert.False(t, tt.permission.Allows(p), p)
+			}
+		})
+	}
+}
+
+func TestURLInfo_WithoutStats(t *testing.T) {
+	clicksLeft := int64(3)
+	info := URLInfo{
+		Ownership:  OwnershipShared,
+		Permission: PermissionRead,
+		Clicks:     7,
+		MaxClicks:  10,
+		ClicksLeft: &clicksLeft,
+		Variants:   []URLVariant{{URL: "http://google.com", Weight: 1, Clicks: 7}},
+	}
+	assert.False(t, info.AllowsStats())
+	assert.Equal(t, URLInfo{
+		Ownership:  OwnershipShared,
+		Permission: PermissionRead,
+		MaxClicks:  10,
+		Variants:   []URLVariant{{URL: "http://google.com", Weight: 1}},
+	}, info.WithoutStats())
+
+	info.Permission = PermissionStats
+	assert.True(t, info.AllowsStats())
+	assert.True(t, URLInfo{Ownership: OwnershipOwned}.AllowsStats())
+}
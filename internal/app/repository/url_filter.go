@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// URLSort field for sorting user urls.
+type URLSort string
+
+// User urls sort fields.
+const (
+	// SortByCreated sorts urls by creation time.
+	SortByCreated URLSort = "created"
+	// SortByClicks sorts urls by count of redirects.
+	SortByClicks URLSort = "clicks"
+)
+
+// URLStatus state of url for filter.
+type URLStatus string
+
+// Url states.
+const (
+	// StatusAny - active, scheduled and deleted urls.
+	StatusAny URLStatus = ""
+	// StatusActive - not deleted urls which redirect now.
+	StatusActive URLStatus = "active"
+	// StatusScheduled - not deleted urls which are not active yet.
+	StatusScheduled URLStatus = "scheduled"
+	// StatusDeleted - deleted urls.
+	StatusDeleted URLStatus = "deleted"
+)
+
+// InvalidCursorError an error that occurs when cursor is malformed or was created for another sort.
+type InvalidCursorError struct {
+}
+
+// Error return InvalidCursorError description.
+func (e *InvalidCursorError) Error() string {
+	return "Invalid cursor"
+}
+
+// URLFilter contains filter, sort and page settings for user urls.
+type URLFilter struct {
+	// Cursor - NextCursor of previous page, empty for the first page.
+	Cursor string
+	// Limit - max count urls in page, 0 for all urls.
+	Limit int
+	// Domain - host of original url.
+	Domain string
+	// ShortDomain - custom domain of short url.
+	ShortDomain string
+	// CreatedFrom - urls created at this time or later, zero for no limit.
+	CreatedFrom time.Time
+	// CreatedTo - urls created before this time, zero for no limit.
+	CreatedTo time.Time
+	// Status - active, scheduled or deleted urls, StatusAny for all.
+	Status URLStatus
+	// Tag - urls which have this tag.
+	Tag string
+	// SortBy - sort field, SortByCreated by default.
+	SortBy URLSort
+	// Desc - sort in descending order.
+	Desc bool
+}
+
+// URLPage contains one page of user urls.
+type URLPage struct {
+	// URLs - urls of page.
+	URLs []URLInfo
+	// Total - count urls matched filter in all pages, it's counted only for the first page.
+	Total int
+	// NextCursor - cursor of next page, empty if it's the last page.
+	NextCursor string
+}
+
+// urlCursor position of the last url of page.
+type urlCursor struct {
+	SortBy URLSort `json:"s"`
+	Value  int64   `json:"v"`
+	ID     int64   `json:"id"`
+}
+
+// sortBy returns sort field with default.
+func (f URLFilter) sortBy() URLSort {
+	if f.SortBy == SortByClicks {
+		return SortByClicks
+	}
+	return SortByCreated
+}
+
+// cursor decodes Cursor, returns nil for the first page.
+func (f URLFilter) cursor() (*urlCursor, error) {
+	if len(f.Cursor) == 0 {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+	if err != nil {
+		return nil, &InvalidCursorError{}
+	}
+	var c urlCursor
+	if err = json.Unmarshal(data, &c); err != nil || c.SortBy != f.sortBy() {
+		return nil, &InvalidCursorError{}
+	}
+	return &c, nil
+}
+
+// sortValue returns value of sort field for url.
+func (f URLFilter) sortValue(info URLInfo) int64 {
+	if f.sortBy() == SortByClicks {
+		return info.Clicks
+	}
+	return info.CreatedAt.UnixNano()
+}
+
+// encodeCursor returns cursor pointed to url with id.
+func (f URLFilter) encodeCursor(value int64, id int64) string {
+	data, _ := json.Marshal(&urlCursor{SortBy: f.sortBy(), Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// matches returns true if url passes all filters except cursor.
+func (f URLFilter) matches(info URLInfo) bool {
+	switch {
+	case f.Status == StatusActive && (info.IsDeleted || info.Scheduled),
+		f.Status == StatusScheduled && !info.Scheduled,
+		f.Status == StatusDeleted && !info.IsDeleted,
+		!f.CreatedFrom.IsZero() && info.CreatedAt.Before(f.CreatedFrom),
+		!f.CreatedTo.IsZero() && !info.CreatedAt.Before(f.CreatedTo),
+		len(f.Domain) != 0 && !strings.EqualFold(urlDomain(info.OriginalURL), f.Domain),
+		len(f.ShortDomain) != 0 && info.ShortDomain != f.ShortDomain:
+		return false
+	}
+	if len(f.Tag) == 0 {
+		return true
+	}
+	for _, tag := range info.Tags {
+		if tag == f.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// urlDomain returns lowercase host of url without port.
+func urlDomain(rawURL string) string {
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
@@ -0,0 +1,51 @@
+package repository
+
+import "time"
+
+// URLVersionNotFoundError an error that occurs when url history doesn't contain version.
+type URLVersionNotFoundError struct {
+}
+
+// Error return URLVersionNotFoundError description.
+func (e *URLVersionNotFoundError) Error() string {
+	return "URL version not found"
+}
+
+// URLVersion contains one destination from url history.
+type URLVersion struct {
+	// Version - number of destination, the first destination has version 1.
+	Version int `json:"version"`
+	// OriginalURL - destination of url.
+	OriginalURL string `json:"original_url"`
+	// ChangedAt - time when url started to point to destination.
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// addVersion returns history with new destination.
+// History of url which was never changed is empty, so the first destination is added before new one.
+func addVersion(history []URLVersion, firstURL string, createdAt time.Time, originalURL string) []URLVersion {
+	res := make([]URLVersion, 0, len(history)+2)
+	res = append(res, history...)
+	if len(res) == 0 {
+		res = append(res, URLVersion{Version: 1, OriginalURL: firstURL, ChangedAt: createdAt})
+	}
+	return append(res, URLVersion{Version: len(res) + 1, OriginalURL: originalURL, ChangedAt: time.Now()})
+}
+
+// findVersion returns destination of version from history.
+func findVersion(history []URLVersion, version int) (string, error) {
+	for _, v := range history {
+		if v.Version == version {
+			return v.OriginalURL, nil
+		}
+	}
+	return "", &URLVersionNotFoundError{}
+}
+
+// currentHistory returns history of url, url which was never changed has only the first destination.
+func currentHistory(history []URLVersion, originalURL string, createdAt time.Time) []URLVersion {
+	if len(history) != 0 {
+		return history
+	}
+	return []URLVersion{{Version: 1, OriginalURL: originalURL, ChangedAt: createdAt}}
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"sort"
+	"time"
+)
+
+// indexKey position of url in sorted list of index.
+type indexKey struct {
+	value int64
+	id    int64
+}
+
+// less returns true if key is placed before other in ascending order.
+func (k indexKey) less(other indexKey) bool {
+	if k.value == other.value {
+		return k.id < other.id
+	}
+	return k.value < other.value
+}
+
+// indexEntry url fields used by index.
+type indexEntry struct {
+	ownerID     uint32
+	sharees     []uint32
+	workspaceID int64
+	created     int64
+	clicks      int64
+}
+
+// indexLists urls of one owner, sharee or workspace sorted by creation time and by clicks.
+type indexLists struct {
+	created []indexKey
+	clicks  []indexKey
+}
+
+// urlIndex contains codes of urls of every owner, sharee and workspace in sorted lists,
+// so page of user urls is read from cursor without reading all urls.
+// Zero urlIndex is empty index, index is guarded by lock of storage.
+type urlIndex struct {
+	entries    map[int64]indexEntry
+	owners     map[uint32]*indexLists
+	sharees    map[uint32]*indexLists
+	workspaces map[int64]*indexLists
+}
+
+// newIndexEntry returns index entry of url.
+func newIndexEntry(
+	ownerID uint32,
+	shares map[uint32]Permission,
+	workspaceID int64,
+	createdAt time.Time,
+	clicks int64,
+) indexEntry {
+	entry := indexEntry{
+		ownerID:     ownerID,
+		sharees:     make([]uint32, 0, len(shares)),
+		workspaceID: workspaceID,
+		created:     createdAt.UnixNano(),
+		clicks:      clicks,
+	}
+	for userID := range shares {
+		entry.sharees = append(entry.sharees, userID)
+	}
+	return entry
+}
+
+// put adds url with id in index or replaces its previous entry.
+func (x *urlIndex) put(id int64, entry indexEntry) {
+	if x.entries == nil {
+		x.entries = make(map[int64]indexEntry)
+		x.owners = make(map[uint32]*indexLists)
+		x.sharees = make(map[uint32]*indexLists)
+		x.workspaces = make(map[int64]*indexLists)
+	}
+	if old, ok := x.entries[id]; ok {
+		for _, lists := range x.lists(old) {
+			lists.created = removeKey(lists.created, indexKey{value: old.created, id: id})
+			lists.clicks = removeKey(lists.clicks, indexKey{value: old.clicks, id: id})
+		}
+	}
+	x.entries[id] = entry
+	for _, lists := range x.lists(entry) {
+		lists.created = insertKey(lists.created, indexKey{value: entry.created, id: id})
+		lists.clicks = insertKey(lists.clicks, indexKey{value: entry.clicks, id: id})
+	}
+}
+
+// addClicks moves url with id in lists sorted by clicks, unknown urls are skipped.
+func (x *urlIndex) addClicks(id int64, clicks int64) {
+	entry, ok := x.entries[id]
+	if !ok {
+		return
+	}
+	for _, lists := range x.lists(entry) {
+		lists.clicks = removeKey(lists.clicks, indexKey{value: entry.clicks, id: id})
+		lists.clicks = insertKey(lists.clicks, indexKey{value: entry.clicks + clicks, id: id})
+	}
+	entry.clicks += clicks
+	x.entries[id] = entry
+}
+
+// lists returns lists which contain url with entry, missing lists are created.
+func (x *urlIndex) lists(entry indexEntry) []*indexLists {
+	res := []*indexLists{userLists(x.owners, entry.ownerID)}
+	for _, userID := range entry.sharees {
+		res = append(res, userLists(x.sharees, userID))
+	}
+	if entry.workspaceID != 0 {
+		lists, ok := x.workspaces[entry.workspaceID]
+		if !ok {
+			lists = &indexLists{}
+			x.workspaces[entry.workspaceID] = lists
+		}
+		res = append(res, lists)
+	}
+	return res
+}
+
+// userLists returns lists of user from lists, missing lists are created.
+func userLists(lists map[uint32]*indexLists, userID uint32) *indexLists {
+	res, ok := lists[userID]
+	if !ok {
+		res = &indexLists{}
+		lists[userID] = res
+	}
+	return res
+}
+
+// page returns page of urls owned by user, shared with him and urls of workspaces with workspaceIDs.
+// load returns url with ownership of user or false if url isn't available for user.
+// Total is counted only for the first page, because it requires to load all urls of user.
+func (x *urlIndex) page(
+	userID uint32,
+	workspaceIDs []int64,
+	filter URLFilter,
+	load func(id int64) (URLInfo, bool, error),
+) (URLPage, error) {
+	c, err := filter.cursor()
+	if err != nil {
+		return URLPage{}, err
+	}
+	merger := &keyMerger{desc: filter.Desc}
+	merger.add(x.owners[userID], filter, c)
+	merger.add(x.sharees[userID], filter, c)
+	for _, workspaceID := range workspaceIDs {
+		merger.add(x.workspaces[workspaceID], filter, c)
+	}
+	page := URLPage{URLs: make([]URLInfo, 0)}
+	var last indexKey
+	for key, ok := merger.next(); ok; key, ok = merger.next() {
+		info, available, err := load(key.id)
+		if err != nil {
+			return URLPage{}, err
+		}
+		if !available || !filter.matches(info) {
+			continue
+		}
+		if filter.Limit > 0 && len(page.URLs) == filter.Limit {
+			if len(page.NextCursor) == 0 {
+				page.NextCursor = filter.encodeCursor(last.value, last.id)
+			}
+			if c != nil {
+				break
+			}
+		} else {
+			page.URLs = append(page.URLs, info)
+			last = key
+		}
+		page.Total++
+	}
+	if c != nil {
+		page.Total = 0
+	}
+	return page, nil
+}
+
+// keyMerger iterates keys of several sorted lists in sort order without duplicates.
+type keyMerger struct {
+	keys [][]indexKey
+	desc bool
+}
+
+// add adds keys of lists sorted by filter which are placed after cursor.
+func (m *keyMerger) add(lists *indexLists, filter URLFilter, c *urlCursor) {
+	if lists == nil {
+		return
+	}
+	keys := lists.created
+	if filter.sortBy() == SortByClicks {
+		keys = lists.clicks
+	}
+	if c != nil {
+		cursorKey := indexKey{value: c.Value, id: c.ID}
+		if m.desc {
+			keys = keys[:sort.Search(len(keys), func(i int) bool { return !keys[i].less(cursorKey) })]
+		} else {
+			keys = keys[sort.Search(len(keys), func(i int) bool { return cursorKey.less(keys[i]) }):]
+		}
+	}
+	m.keys = append(m.keys, keys)
+}
+
+// next returns next key in sort order, urls from several lists are returned once.
+func (m *keyMerger) next() (indexKey, bool) {
+	var best indexKey
+	found := false
+	for _, keys := range m.keys {
+		if len(keys) == 0 {
+			continue
+		}
+		if head := m.head(keys); !found || (m.desc && best.less(head)) || (!m.desc && head.less(best)) {
+			best = head
+			found = true
+		}
+	}
+	for i, keys := range m.keys {
+		if found && len(keys) != 0 && m.head(keys) == best {
+			if m.desc {
+				m.keys[i] = keys[:len(keys)-1]
+			} else {
+				m.keys[i] = keys[1:]
+			}
+		}
+	}
+	return best, found
+}
+
+// head returns the first key of keys in sort order.
+func (m *keyMerger) head(keys []indexKey) indexKey {
+	if m.desc {
+		return keys[len(keys)-1]
+	}
+	return keys[0]
+}
+
+// insertKey inserts key in sorted keys.
+func insertKey(keys []indexKey, key indexKey) []indexKey {
+	i := sort.Search(len(keys), func(i int) bool { return !keys[i].less(key) })
+	keys = append(keys, indexKey{})
+	copy(keys[i+1:], keys[i:])
+	keys[i] = key
+	return keys
+}
+
+// removeKey removes key from sorted keys.
+func removeKey(keys []indexKey, key indexKey) []indexKey {
+	i := sort.Search(len(keys), func(i int) bool { return !keys[i].less(key) })
+	if i < len(keys) && keys[i] == key {
+		return append(keys[:i], keys[i+1:]...)
+	}
+	return keys
+}
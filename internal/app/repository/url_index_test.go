@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// pageIndex returns page of urls of user 1 from index.
+func pageIndex(index *urlIndex, urls map[int64]URLInfo, filter URLFilter) (URLPage, error) {
+	return index.page(1, nil, filter, func(id int64) (URLInfo, bool, error) {
+		return urls[id], true, nil
+	})
+}
+
+func Test_urlIndexPage(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	index := &urlIndex{}
+	urls := make(map[int64]URLInfo, 5)
+	for i := 0; i < 5; i++ {
+		urls[int64(i)] = URLInfo{
+			Code:        int64(i),
+			OriginalURL: "http://example" + strconv.Itoa(i%2) + ".com/path",
+			CreatedAt:   start.Add(time.Duration(i) * time.Hour),
+			Clicks:      int64(10 - i%3),
+			Tags:        []string{"tag" + strconv.Itoa(i%2)},
+			IsDeleted:   i == 4,
+			Scheduled:   i == 3,
+		}
+		index.put(int64(i), newIndexEntry(1, nil, 0, urls[int64(i)].CreatedAt, 0))
+		index.addClicks(int64(i), urls[int64(i)].Clicks)
+	}
+	tests := []struct {
+		name   string
+		filter URLFilter
+		pages  [][]string
+		total  int
+	}{
+		{
+			name:   "all by created",
+			filter: URLFilter{},
+			pages:  [][]string{{"0", "1", "2", "3", "4"}},
+			total:  5,
+		},
+		{
+			name:   "pages by created desc",
+			filter: URLFilter{Limit: 2, Desc: true},
+			pages:  [][]string{{"4", "3"}, {"2", "1"}, {"0"}},
+			total:  5,
+		},
+		{
+			name:   "pages by clicks",
+			filter: URLFilter{Limit: 2, SortBy: SortByClicks},
+			pages:  [][]string{{"2", "1"}, {"4", "0"}, {"3"}},
+			total:  5,
+		},
+		{
+			name:   "active with tag",
+			filter: URLFilter{Status: StatusActive, Tag: "tag0"},
+			pages:  [][]string{{"0", "2"}},
+			total:  2,
+		},
+		{
+			name:   "active",
+			filter: URLFilter{Status: StatusActive},
+			pages:  [][]string{{"0", "1", "2"}},
+			total:  3,
+		},
+		{
+			name:   "scheduled",
+			filter: URLFilter{Status: StatusScheduled},
+			pages:  [][]string{{"3"}},
+			total:  1,
+		},
+		{
+			name:   "deleted",
+			filter: URLFilter{Status: StatusDeleted},
+			pages:  [][]string{{"4"}},
+			total:  1,
+		},
+		{
+			name:   "domain and created range",
+			filter: URLFilter{Domain: "EXAMPLE1.com", CreatedFrom: start.Add(time.Hour), CreatedTo: start.Add(3 * time.Hour)},
+			pages:  [][]string{{"1"}},
+			total:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.filter
+			for i, want := range tt.pages {
+				page, err := pageIndex(index, urls, filter)
+				require.NoError(t, err)
+				codes := make([]string, 0, len(page.URLs))
+				for _, url := range page.URLs {
+					codes = append(codes, strconv.FormatInt(url.Code, 10))
+				}
+				assert.Equal(t, want, codes)
+				if i == 0 {
+					assert.Equal(t, tt.total, page.Total)
+				} else {
+					assert.Zero(t, page.Total)
+				}
+				if i == len(tt.pages)-1 {
+					assert.Empty(t, page.NextCursor)
+				}
+				filter.Cursor = page.NextCursor
+			}
+		})
+	}
+}
+
+func Test_urlIndexPageWithBadCursor(t *testing.T) {
+	page, err := pageIndex(&urlIndex{}, nil, URLFilter{Limit: 1})
+	require.NoError(t, err)
+	assert.Empty(t, page.NextCursor)
+
+	_, err = pageIndex(&urlIndex{}, nil, URLFilter{Cursor: "not cursor"})
+	assert.ErrorIs(t, err, &InvalidCursorError{})
+
+	cursor := URLFilter{}.encodeCursor(1, 1)
+	_, err = pageIndex(&urlIndex{}, nil, URLFilter{Cursor: cursor, SortBy: SortByClicks})
+	assert.ErrorIs(t, err, &InvalidCursorError{})
+}
+
+func Test_urlIndexPageOfSharedAndWorkspaceURLs(t *testing.T) {
+	index := &urlIndex{}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	shares := map[uint32]Permission{1: PermissionRead}
+	index.put(0, newIndexEntry(1, nil, 0, start, 0))
+	index.put(1, newIndexEntry(2, shares, 0, start.Add(time.Hour), 0))
+	index.put(2, newIndexEntry(2, nil, 7, start.Add(2*time.Hour), 0))
+	index.put(3, newIndexEntry(2, shares, 7, start.Add(3*time.Hour), 0))
+	index.put(4, newIndexEntry(2, nil, 0, start.Add(4*time.Hour), 0))
+	// url 0 is transferred, so it's not owned by user 1 anymore
+	index.put(0, newIndexEntry(2, nil, 0, start, 0))
+
+	codes := make([]int64, 0)
+	filter := URLFilter{Limit: 1, Desc: true}
+	for {
+		page, err := index.page(1, []int64{7}, filter, func(id int64) (URLInfo, bool, error) {
+			return URLInfo{Code: id}, true, nil
+		})
+		require.NoError(t, err)
+		for _, url := range page.URLs {
+			codes = append(codes, url.Code)
+		}
+		if len(page.NextCursor) == 0 {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []int64{3, 2, 1}, codes)
+}
@@ -0,0 +1,17 @@
+package repository
+
+import "time"
+
+// URLPreview contains metadata of url destination page.
+type URLPreview struct {
+	// PageTitle - content of page <title>.
+	PageTitle string `json:"page_title,omitempty"`
+	// OGTitle - og:title of page.
+	OGTitle string `json:"og_title,omitempty"`
+	// OGDescription - og:description of page.
+	OGDescription string `json:"og_description,omitempty"`
+	// OGImage - og:image of page.
+	OGImage string `json:"og_image,omitempty"`
+	// FetchedAt - time when page was fetched.
+	FetchedAt time.Time `json:"fetched_at"`
+}
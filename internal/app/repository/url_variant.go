@@ -0,0 +1,31 @@
+package repository
+
+// URLVariant weighted destination of url in A/B split.
+type URLVariant struct {
+	// URL - destination of variant.
+	URL string `json:"url"`
+	// Weight - relative share of visitors redirected to variant.
+	Weight int `json:"weight"`
+	// Clicks - count of redirects to variant, it is reset when variants are replaced.
+	Clicks int64 `json:"clicks"`
+}
+
+// withoutClicks returns copy of variants with reset clicks.
+func withoutClicks(variants []URLVariant) []URLVariant {
+	if variants == nil {
+		return nil
+	}
+	res := make([]URLVariant, len(variants))
+	for i, variant := range variants {
+		res[i] = URLVariant{URL: variant.URL, Weight: variant.Weight}
+	}
+	return res
+}
+
+// notNilVariants returns empty slice instead of nil variants.
+func notNilVariants(variants []URLVariant) []URLVariant {
+	if variants == nil {
+		return []URLVariant{}
+	}
+	return variants
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// utmRecord utm template change stored in journal.
+type utmRecord struct {
+	UserID    uint32      `json:"user_id"`
+	Template  UTMTemplate `json:"template"`
+	IsDeleted bool        `json:"is_deleted,omitempty"`
+}
+
+// utmJournal contains utm templates for in memory and local storages.
+// Every template change appends record in file, the last record of template wins.
+// If filename is empty templates are stored only in memory.
+type utmJournal struct {
+	sync.RWMutex
+	filename  string
+	templates map[uint32]map[string]UTMTemplate
+}
+
+// newUTMJournal returns new utmJournal and restores templates from filename.
+// File is created on first template change.
+func newUTMJournal(filename string) (*utmJournal, error) {
+	j := &utmJournal{
+		filename:  filename,
+		templates: make(map[uint32]map[string]UTMTemplate),
+	}
+	if len(filename) == 0 {
+		return j, nil
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return j, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record utmRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// skip partially written row
+			continue
+		}
+		j.apply(record)
+	}
+	return j, scanner.Err()
+}
+
+// SaveUTMTemplate creates user template or replaces template with the same name.
+func (j *utmJournal) SaveUTMTemplate(ctx context.Context, userID uint32, template UTMTemplate) error {
+	j.Lock()
+	defer j.Unlock()
+	return j.save(utmRecord{UserID: userID, Template: template})
+}
+
+// GetUTMTemplates returns all templates of user sorted by name.
+func (j *utmJournal) GetUTMTemplates(ctx context.Context, userID uint32) ([]UTMTemplate, error) {
+	j.RLock()
+	defer j.RUnlock()
+	templates := make([]UTMTemplate, 0, len(j.templates[userID]))
+	for _, template := range j.templates[userID] {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(a, b int) bool {
+		return templates[a].Name < templates[b].Name
+	})
+	return templates, nil
+}
+
+// GetUTMTemplate returns user template by name or UTMTemplateNotFoundError.
+func (j *utmJournal) GetUTMTemplate(ctx context.Context, userID uint32, name string) (UTMTemplate, error) {
+	j.RLock()
+	defer j.RUnlock()
+	template, ok := j.templates[userID][name]
+	if !ok {
+		return UTMTemplate{}, &UTMTemplateNotFoundError{}
+	}
+	return template, nil
+}
+
+// DeleteUTMTemplate deletes user template by name or returns UTMTemplateNotFoundError.
+func (j *utmJournal) DeleteUTMTemplate(ctx context.Context, userID uint32, name string) error {
+	j.Lock()
+	defer j.Unlock()
+	if _, ok := j.templates[userID][name]; !ok {
+		return &UTMTemplateNotFoundError{}
+	}
+	return j.save(utmRecord{UserID: userID, Template: UTMTemplate{Name: name}, IsDeleted: true})
+}
+
+// save appends record in file and applies it in memory, j must be locked.
+func (j *utmJournal) save(record utmRecord) error {
+	if len(j.filename) != 0 {
+		file, err := os.OpenFile(j.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(&record)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if _, err = file.Write(append(data, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+		if err = file.Close(); err != nil {
+			return err
+		}
+	}
+	j.apply(record)
+	return nil
+}
+
+// apply applies record to templates in memory.
+func (j *utmJournal) apply(record utmRecord) {
+	if record.IsDeleted {
+		delete(j.templates[record.UserID], record.Template.Name)
+		return
+	}
+	if j.templates[record.UserID] == nil {
+		j.templates[record.UserID] = make(map[string]UTMTemplate)
+	}
+	j.templates[record.UserID][record.Template.Name] = record.Template
+}
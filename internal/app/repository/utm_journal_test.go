@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestUTMJournal(t *testing.T) {
+	ctx := context.TODO()
+	j, err := newUTMJournal("test_utm")
+	require.NoError(t, err)
+	defer os.Remove("test_utm")
+
+	newsletter := UTMTemplate{Name: "newsletter", UTMParams: UTMParams{Source: "mail", Medium: "email"}}
+	ads := UTMTemplate{Name: "ads", UTMParams: UTMParams{Source: "google", Medium: "cpc", Campaign: "sale"}}
+	require.NoError(t, j.SaveUTMTemplate(ctx, 1, newsletter))
+	require.NoError(t, j.SaveUTMTemplate(ctx, 1, UTMTemplate{Name: "old", UTMParams: UTMParams{Source: "x"}}))
+	require.NoError(t, j.SaveUTMTemplate(ctx, 1, ads))
+	require.NoError(t, j.DeleteUTMTemplate(ctx, 1, "old"))
+	assert.ErrorIs(t, j.DeleteUTMTemplate(ctx, 1, "old"), &UTMTemplateNotFoundError{})
+	newsletter.Campaign = "october"
+	require.NoError(t, j.SaveUTMTemplate(ctx, 1, newsletter))
+
+	// templates are restored from file
+	j, err = newUTMJournal("test_utm")
+	require.NoError(t, err)
+	templates, err := j.GetUTMTemplates(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []UTMTemplate{ads, newsletter}, templates)
+
+	_, err = j.GetUTMTemplate(ctx, 2, "ads")
+	assert.ErrorIs(t, err, &UTMTemplateNotFoundError{})
+	templates, err = j.GetUTMTemplates(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, templates)
+}
+
+func TestUTMParams_Merge(t *testing.T) {
+	params := UTMParams{Source: "mail", Content: "button"}
+	merged := params.Merge(UTMParams{Source: "google", Medium: "cpc"})
+	assert.Equal(t, UTMParams{Source: "mail", Medium: "cpc", Content: "button"}, merged)
+	assert.True(t, UTMParams{}.IsEmpty())
+	assert.Equal(t, map[string]string{"utm_source": "mail", "utm_content": "button"}, params.Values())
+}
@@ -0,0 +1,85 @@
+package repository
+
+import "context"
+
+// UTMTemplateNotFoundError an error that occurs when user has no utm template with name.
+type UTMTemplateNotFoundError struct {
+}
+
+// Error return UTMTemplateNotFoundError description.
+func (e *UTMTemplateNotFoundError) Error() string {
+	return "UTM template not found"
+}
+
+// UTMParams contains utm params added to original url, empty params are not added.
+type UTMParams struct {
+	Source   string `json:"utm_source,omitempty"`
+	Medium   string `json:"utm_medium,omitempty"`
+	Campaign string `json:"utm_campaign,omitempty"`
+	Term     string `json:"utm_term,omitempty"`
+	Content  string `json:"utm_content,omitempty"`
+}
+
+// IsEmpty returns true if all params are empty.
+func (p UTMParams) IsEmpty() bool {
+	return p == UTMParams{}
+}
+
+// Values returns not empty params by their query names.
+func (p UTMParams) Values() map[string]string {
+	res := make(map[string]string, 5)
+	for name, value := range map[string]string{
+		"utm_source":   p.Source,
+		"utm_medium":   p.Medium,
+		"utm_campaign": p.Campaign,
+		"utm_term":     p.Term,
+		"utm_content":  p.Content,
+	} {
+		if len(value) != 0 {
+			res[name] = value
+		}
+	}
+	return res
+}
+
+// Merge returns params where empty params are taken from base.
+func (p UTMParams) Merge(base UTMParams) UTMParams {
+	if len(p.Source) == 0 {
+		p.Source = base.Source
+	}
+	if len(p.Medium) == 0 {
+		p.Medium = base.Medium
+	}
+	if len(p.Campaign) == 0 {
+		p.Campaign = base.Campaign
+	}
+	if len(p.Term) == 0 {
+		p.Term = base.Term
+	}
+	if len(p.Content) == 0 {
+		p.Content = base.Content
+	}
+	return p
+}
+
+// UTMTemplate named set of utm params saved by user.
+type UTMTemplate struct {
+	// Name - template name, unique for user.
+	Name string `json:"name"`
+	UTMParams
+}
+
+// UTMTemplateStore define api for work with utm templates of users.
+type UTMTemplateStore interface {
+	// SaveUTMTemplate creates user template or replaces template with the same name.
+	SaveUTMTemplate(ctx context.Context, userID uint32, template UTMTemplate) error
+
+	// GetUTMTemplates returns all templates of user sorted by name.
+	GetUTMTemplates(ctx context.Context, userID uint32) ([]UTMTemplate, error)
+
+	// GetUTMTemplate returns user template by name or UTMTemplateNotFoundError.
+	GetUTMTemplate(ctx context.Context, userID uint32, name string) (UTMTemplate, error)
+
+	// DeleteUTMTemplate deletes user template by name or returns UTMTemplateNotFoundError.
+	DeleteUTMTemplate(ctx context.Context, userID uint32, name string) error
+}
@@ -0,0 +1,78 @@
+package repository
+
+import "context"
+
+// WorkspaceRole role of workspace member.
+type WorkspaceRole string
+
+// Workspace member roles.
+const (
+	// RoleOwner manages members and links of workspace.
+	RoleOwner WorkspaceRole = "owner"
+	// RoleEditor creates and deletes links of workspace.
+	RoleEditor WorkspaceRole = "editor"
+	// RoleViewer sees links of workspace.
+	RoleViewer WorkspaceRole = "viewer"
+)
+
+// IsValid returns true if r is known role.
+func (r WorkspaceRole) IsValid() bool {
+	return r == RoleOwner || r == RoleEditor || r == RoleViewer
+}
+
+// Permission returns permission to workspace links for role.
+func (r WorkspaceRole) Permission() Permission {
+	if r == RoleOwner || r == RoleEditor {
+		return PermissionManage
+	}
+	return PermissionRead
+}
+
+// WorkspaceNotFoundError an error that occurs when workspace does not exist or user is not its member.
+type WorkspaceNotFoundError struct {
+}
+
+// Error return WorkspaceNotFoundError description.
+func (e *WorkspaceNotFoundError) Error() string {
+	return "Workspace not found"
+}
+
+// Workspace contains workspace info.
+type Workspace struct {
+	// ID - unique workspace id.
+	ID int64 `json:"id"`
+	// Name - workspace name.
+	Name string `json:"name"`
+	// Role - role of user who requested workspace.
+	Role WorkspaceRole `json:"role,omitempty"`
+}
+
+// WorkspaceMember contains workspace member info.
+type WorkspaceMember struct {
+	// UserID - member id.
+	UserID uint32 `json:"user_id"`
+	// Role - member role.
+	Role WorkspaceRole `json:"role"`
+}
+
+// WorkspaceStore define api for work with workspaces.
+// Roles are checked by caller, store only keeps them.
+type WorkspaceStore interface {
+	// CreateWorkspace creates workspace with ownerID as RoleOwner member.
+	CreateWorkspace(ctx context.Context, name string, ownerID uint32) (Workspace, error)
+
+	// GetWorkspaces returns all workspaces where user is member with his role.
+	GetWorkspaces(ctx context.Context, userID uint32) ([]Workspace, error)
+
+	// GetWorkspaceRole returns role of user in workspace or WorkspaceNotFoundError if user is not member.
+	GetWorkspaceRole(ctx context.Context, workspaceID int64, userID uint32) (WorkspaceRole, error)
+
+	// GetWorkspaceMembers returns all members of workspace.
+	GetWorkspaceMembers(ctx context.Context, workspaceID int64) ([]WorkspaceMember, error)
+
+	// SetWorkspaceMember adds member to workspace or changes his role.
+	SetWorkspaceMember(ctx context.Context, workspaceID int64, userID uint32, role WorkspaceRole) error
+
+	// RemoveWorkspaceMember removes member from workspace.
+	RemoveWorkspaceMember(ctx context.Context, workspaceID int64, userID uint32) error
+}
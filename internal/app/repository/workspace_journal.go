@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// workspaceRecord workspace snapshot stored in journal.
+type workspaceRecord struct {
+	ID      int64                    `json:"id"`
+	Name    string                   `json:"name"`
+	Members map[uint32]WorkspaceRole `json:"members"`
+}
+
+// workspaceJournal contains workspaces for in memory and local storages.
+// Every workspace change appends new workspace snapshot in file, the last snapshot wins.
+// If filename is empty workspaces are stored only in memory.
+type workspaceJournal struct {
+	sync.RWMutex
+	filename   string
+	lastID     int64
+	workspaces map[int64]workspaceRecord
+}
+
+// newWorkspaceJournal returns new workspaceJournal and restores workspaces from filename.
+// File is created on first workspace change.
+func newWorkspaceJournal(filename string) (*workspaceJournal, error) {
+	j := &workspaceJournal{
+		filename:   filename,
+		workspaces: make(map[int64]workspaceRecord),
+	}
+	if len(filename) == 0 {
+		return j, nil
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return j, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var workspace workspaceRecord
+		if err = json.Unmarshal(scanner.Bytes(), &workspace); err != nil {
+			// skip partially written row
+			continue
+		}
+		j.workspaces[workspace.ID] = workspace
+		if workspace.ID > j.lastID {
+			j.lastID = workspace.ID
+		}
+	}
+	return j, scanner.Err()
+}
+
+// CreateWorkspace creates workspace with ownerID as RoleOwner member.
+func (j *workspaceJournal) CreateWorkspace(ctx context.Context, name string, ownerID uint32) (Workspace, error) {
+	j.Lock()
+	defer j.Unlock()
+	workspace := workspaceRecord{
+		ID:      j.lastID + 1,
+		Name:    name,
+		Members: map[uint32]WorkspaceRole{ownerID: RoleOwner},
+	}
+	if err := j.save(workspace); err != nil {
+		return Workspace{}, err
+	}
+	j.lastID = workspace.ID
+	return Workspace{ID: workspace.ID, Name: name, Role: RoleOwner}, nil
+}
+
+// GetWorkspaces returns all workspaces where user is member with his role.
+func (j *workspaceJournal) GetWorkspaces(ctx context.Context, userID uint32) ([]Workspace, error) {
+	j.RLock()
+	defer j.RUnlock()
+	workspaces := make([]Workspace, 0)
+	for _, workspace := range j.workspaces {
+		if role, ok := workspace.Members[userID]; ok {
+			workspaces = append(workspaces, Workspace{ID: workspace.ID, Name: workspace.Name, Role: role})
+		}
+	}
+	sort.Slice(workspaces, func(a, b int) bool {
+		return workspaces[a].ID < workspaces[b].ID
+	})
+	return workspaces, nil
+}
+
+// GetWorkspaceRole returns role of user in workspace or WorkspaceNotFoundError if user is not member.
+func (j *workspaceJournal) GetWorkspaceRole(ctx context.Context, workspaceID int64, userID uint32) (WorkspaceRole, error) {
+	j.RLock()
+	defer j.RUnlock()
+	role, ok := j.workspaces[workspaceID].Members[userID]
+	if !ok {
+		return "", &WorkspaceNotFoundError{}
+	}
+	return role, nil
+}
+
+// GetWorkspaceMembers returns all members of workspace.
+func (j *workspaceJournal) GetWorkspaceMembers(ctx context.Context, workspaceID int64) ([]WorkspaceMember, error) {
+	j.RLock()
+	defer j.RUnlock()
+	workspace, ok := j.workspaces[workspaceID]
+	if !ok {
+		return nil, &WorkspaceNotFoundError{}
+	}
+	members := make([]WorkspaceMember, 0, len(workspace.Members))
+	for userID, role := range workspace.Members {
+		members = append(members, WorkspaceMember{UserID: userID, Role: role})
+	}
+	sort.Slice(members, func(a, b int) bool {
+		return members[a].UserID < members[b].UserID
+	})
+	return members, nil
+}
+
+// SetWorkspaceMember adds member to workspace or changes his role.
+func (j *workspaceJournal) SetWorkspaceMember(
+	ctx context.Context,
+	workspaceID int64,
+	userID uint32,
+	role WorkspaceRole,
+) error {
+	j.Lock()
+	defer j.Unlock()
+	workspace, ok := j.workspaces[workspaceID]
+	if !ok {
+		return &WorkspaceNotFoundError{}
+	}
+	workspace.Members = copyMembers(workspace.Members)
+	workspace.Members[userID] = role
+	return j.save(workspace)
+}
+
+// RemoveWorkspaceMember removes member from workspace.
+func (j *workspaceJournal) RemoveWorkspaceMember(ctx context.Context, workspaceID int64, userID uint32) error {
+	j.Lock()
+	defer j.Unlock()
+	workspace, ok := j.workspaces[workspaceID]
+	if !ok {
+		return &WorkspaceNotFoundError{}
+	}
+	if _, ok = workspace.Members[userID]; !ok {
+		return nil
+	}
+	workspace.Members = copyMembers(workspace.Members)
+	delete(workspace.Members, userID)
+	return j.save(workspace)
+}
+
+// memberWorkspaces returns ids of workspaces where user is member.
+func (j *workspaceJournal) memberWorkspaces(userID uint32) []int64 {
+	j.RLock()
+	defer j.RUnlock()
+	res := make([]int64, 0)
+	for _, workspace := range j.workspaces {
+		if _, ok := workspace.Members[userID]; ok {
+			res = append(res, workspace.ID)
+		}
+	}
+	return res
+}
+
+// memberPermission returns permission of user to links of workspace.
+func (j *workspaceJournal) memberPermission(workspaceID int64, userID uint32) (Permission, bool) {
+	if workspaceID == 0 {
+		return "", false
+	}
+	j.RLock()
+	defer j.RUnlock()
+	role, ok := j.workspaces[workspaceID].Members[userID]
+	if !ok {
+		return "", false
+	}
+	return role.Permission(), true
+}
+
+// save appends workspace snapshot in file and updates it in memory, j must be locked.
+func (j *workspaceJournal) save(workspace workspaceRecord) error {
+	if len(j.filename) != 0 {
+		file, err := os.OpenFile(j.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(&workspace)
+		if err != nil {
+			file.Close()
+			return err
+		}
+		if _, err = file.Write(append(data, '\n')); err != nil {
+			file.Close()
+			return err
+		}
+		if err = file.Close(); err != nil {
+			return err
+		}
+	}
+	j.workspaces[workspace.ID] = workspace
+	return nil
+}
+
+// copyMembers returns copy of members for new workspace snapshot.
+func copyMembers(members map[uint32]WorkspaceRole) map[uint32]WorkspaceRole {
+	res := make(map[uint32]WorkspaceRole, len(members)+1)
+	for userID, role := range members {
+		res[userID] = role
+	}
+	return res
+}
+
+// setOwnership sets ownership and permission of user to url with ownerID and shares.
+// Returns false if user has no access to url.
+func (u *URLInfo) setOwnership(
+	userID uint32,
+	ownerID uint32,
+	shares map[uint32]Permission,
+	workspaces *workspaceJournal,
+) bool {
+	if ownerID == userID {
+		u.Ownership = OwnershipOwned
+		return true
+	}
+	sharePermission, isShared := shares[userID]
+	workspacePermission, isMember := workspaces.memberPermission(u.WorkspaceID, userID)
+	switch {
+	case isMember:
+		u.Ownership = OwnershipWorkspace
+		u.Permission = workspacePermission
+		if isShared && sharePermission.Allows(workspacePermission) {
+			u.Permission = sharePermission
+		}
+	case isShared:
+		u.Ownership = OwnershipShared
+		u.Permission = sharePermission
+	default:
+		return false
+	}
+	return true
+}
+
+// canManage returns true if user is owner of url or has PermissionManage to it or its workspace.
+func (j *workspaceJournal) canManage(
+	userID uint32,
+	ownerID uint32,
+	shares map[uint32]Permission,
+	workspaceID int64,
+) bool {
+	return j.hasPermission(userID, ownerID, shares, workspaceID, PermissionManage)
+}
+
+// hasPermission returns true if user is owner of url or has permission to it or its workspace.
+func (j *workspaceJournal) hasPermission(
+	userID uint32,
+	ownerID uint32,
+	shares map[uint32]Permission,
+	workspaceID int64,
+	permission Permission,
+) bool {
+	info := URLInfo{WorkspaceID: workspaceID}
+	if !info.setOwnership(userID, ownerID, shares, j) {
+		return false
+	}
+	return info.Ownership == OwnershipOwned || info.Permission.Allows(permission)
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestWorkspaceJournal(t *testing.T) {
+	ctx := context.TODO()
+	j, err := newWorkspaceJournal("test_workspaces")
+	require.NoError(t, err)
+	defer os.Remove("test_workspaces")
+
+	workspace, err := j.CreateWorkspace(ctx, "team", 1)
+	require.NoError(t, err)
+	assert.Equal(t, Workspace{ID: 1, Name: "team", Role: RoleOwner}, workspace)
+	require.NoError(t, j.SetWorkspaceMember(ctx, workspace.ID, 2, RoleViewer))
+	require.NoError(t, j.SetWorkspaceMember(ctx, workspace.ID, 3, RoleEditor))
+	require.NoError(t, j.RemoveWorkspaceMember(ctx, workspace.ID, 3))
+	assert.ErrorIs(t, j.SetWorkspaceMember(ctx, 2, 3, RoleViewer), &WorkspaceNotFoundError{})
+
+	// workspaces are restored from file
+	j, err = newWorkspaceJournal("test_workspaces")
+	require.NoError(t, err)
+	members, err := j.GetWorkspaceMembers(ctx, workspace.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []WorkspaceMember{{UserID: 1, Role: RoleOwner}, {UserID: 2, Role: RoleViewer}}, members)
+
+	workspaces, err := j.GetWorkspaces(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []Workspace{{ID: 1, Name: "team", Role: RoleViewer}}, workspaces)
+	_, err = j.GetWorkspaceRole(ctx, workspace.ID, 3)
+	assert.ErrorIs(t, err, &WorkspaceNotFoundError{})
+
+	next, err := j.CreateWorkspace(ctx, "another team", 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), next.ID)
+}
@@ -1,77 +1,501 @@
-// Package service define service for delete.
+// Package service define services for delete and url previews.
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"go-axesthump-shortener/internal/app/repository"
 	"log"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Retry settings for failed delete jobs.
+const (
+	maxDeleteAttempt = 10                     // after this count of fails job moves to dead-letter list
+	baseRetryDelay   = 100 * time.Millisecond // delay before first retry
+	maxRetryDelay    = 30 * time.Second       // max delay between retries
+)
+
+// Default DeleteConfig settings.
+const (
+	defaultQueueSize     = 100
+	defaultWorkers       = 3
+	defaultFlushSize     = 1000
+	defaultFlushInterval = 100 * time.Millisecond
+)
+
+// DeleteConfig contains settings for DeleteService. Zero values are replaced by defaults.
+type DeleteConfig struct {
+	// QueueSize - max count of jobs waiting in queue.
+	QueueSize int
+	// Workers - count goroutines which delete batches.
+	Workers int
+	// FlushSize - count urls in batch after which batch is deleted without waiting FlushInterval.
+	FlushSize int
+	// FlushInterval - max time jobs wait for coalescing in one batch.
+	FlushInterval time.Duration
+}
+
+// withDefaults returns copy of DeleteConfig where zero values are replaced by defaults.
+func (c DeleteConfig) withDefaults() DeleteConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaultQueueSize
+	}
+	if c.Workers <= 0 {
+		c.Workers = defaultWorkers
+	}
+	if c.FlushSize <= 0 {
+		c.FlushSize = defaultFlushSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaultFlushInterval
+	}
+	return c
+}
+
+// QueueFullError an error that occurs when delete queue has no free space.
+type QueueFullError struct {
+}
+
+// Error return QueueFullError description.
+func (e *QueueFullError) Error() string {
+	return "Delete queue is full"
+}
+
+// ServiceClosedError an error that occurs when service already closed.
+type ServiceClosedError struct {
+}
+
+// Error return ServiceClosedError description.
+func (e *ServiceClosedError) Error() string {
+	return "Delete service closed"
+}
+
+// InvalidURLsError an error that occurs when delete request contains urls which can't be deleted.
+type InvalidURLsError struct {
+	// URLs - offending items from request.
+	URLs []string
+}
+
+// Error return InvalidURLsError description.
+func (e *InvalidURLsError) Error() string {
+	if len(e.URLs) == 0 {
+		return "No urls for delete"
+	}
+	return "Invalid urls: " + strings.Join(e.URLs, ", ")
+}
+
+// Is reports whether target is InvalidURLsError.
+func (e *InvalidURLsError) Is(target error) bool {
+	_, ok := target.(*InvalidURLsError)
+	return ok
+}
+
 // DeleteService contains data for delete service.
+// Jobs of different users from queue are coalesced in batches, every batch is deleted by one repository call.
+// Failed batch is split, so every job is retried and moved to dead-letter list separately.
 type DeleteService struct {
-	// urlsForDelete - take urls for delete.
-	urlsForDelete chan []repository.DeleteURL
-	repo          repository.Repository
-	baseURL       string
+	// mx - guards jobs sending, reserved, retries and deadLetters.
+	mx sync.Mutex
+	// jobs - bounded queue with jobs for delete.
+	jobs chan repository.DeleteJob
+	// reserved - count places in queue taken by jobs which are being saved in store.
+	reserved int
+	// batches - coalesced jobs for workers.
+	batches chan []repository.DeleteJob
+	// retries - scheduled retries of failed jobs.
+	retries map[string]*time.Timer
+	// deadLetters - jobs which exhausted all attempts.
+	deadLetters []repository.DeleteJob
+	closed      bool
+	wg          sync.WaitGroup
+	repo        repository.Repository
+	store       repository.DeleteJobStore
+	conf        DeleteConfig
 }
 
 // NewDeleteService returns new DeleteService and start deleteService logic.
-func NewDeleteService(repo repository.Repository, baseURL string) *DeleteService {
+// Pending jobs from store are added in queue again.
+func NewDeleteService(
+	repo repository.Repository,
+	store repository.DeleteJobStore,
+	conf DeleteConfig,
+) *DeleteService {
+	conf = conf.withDefaults()
 	ds := &DeleteService{
-		urlsForDelete: make(chan []repository.DeleteURL),
-		repo:          repo,
-		baseURL:       baseURL,
-	}
-	for i := 0; i < 3; i++ {
-		go func(ds *DeleteService) {
-			for urlsForDelete := range ds.urlsForDelete {
-				err := ds.repo.DeleteURLs(urlsForDelete)
-				if err != nil {
-					log.Printf("Found err %s", err)
-					ds.reAddURLs(urlsForDelete)
-				} else {
-					log.Printf("Delete success!")
-				}
+		jobs:    make(chan repository.DeleteJob, conf.QueueSize),
+		batches: make(chan []repository.DeleteJob),
+		retries: make(map[string]*time.Timer),
+		repo:    repo,
+		store:   store,
+		conf:    conf,
+	}
+	ds.wg.Add(conf.Workers)
+	for i := 0; i < conf.Workers; i++ {
+		go ds.work()
+	}
+	go ds.coalesce()
+	ds.restorePendingJobs()
+	return ds
+}
 
-			}
+// ParseURLs parses delete request data, json array of short urls or their codes.
+// Short urls are resolved to codes by codeOf.
+// Returns codes or InvalidURLsError with items which are not short urls of this service.
+func (ds *DeleteService) ParseURLs(data []byte, codeOf func(shortURL string) (string, bool)) ([]string, error) {
+	var items []string
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, &InvalidURLsError{}
+	}
+	codes := make([]string, 0, len(items))
+	invalid := make([]string, 0)
+	for _, item := range items {
+		code, ok := getCode(item, codeOf)
+		if !ok {
+			invalid = append(invalid, item)
+			continue
+		}
+		codes = append(codes, code)
+	}
+	if len(invalid) != 0 {
+		return nil, &InvalidURLsError{URLs: invalid}
+	}
+	return codes, nil
+}
 
-		}(ds)
+// AddURLs creates delete job for urls codes and adds it in queue.
+// Returns job id or QueueFullError if queue has no free space.
+// Place in queue is reserved while job is saved in store, so store is not called under lock.
+func (ds *DeleteService) AddURLs(urls []string, userID uint32) (string, error) {
+	jobID, err := newJobID()
+	if err != nil {
+		return "", err
 	}
-	return ds
+	now := time.Now().UTC()
+	job := repository.DeleteJob{
+		ID:        jobID,
+		UserID:    userID,
+		URLs:      urls,
+		Status:    repository.DeleteJobPending,
+		Total:     len(urls),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	ds.mx.Lock()
+	if ds.closed {
+		ds.mx.Unlock()
+		return "", &ServiceClosedError{}
+	}
+	if ds.queueFull() {
+		ds.mx.Unlock()
+		return "", &QueueFullError{}
+	}
+	ds.reserved++
+	ds.mx.Unlock()
+
+	err = ds.store.SaveDeleteJob(context.Background(), job)
+
+	ds.mx.Lock()
+	defer ds.mx.Unlock()
+	ds.reserved--
+	if err != nil {
+		return "", err
+	}
+	if ds.closed {
+		// job stays pending in store and will be restored on next start
+		return jobID, nil
+	}
+	ds.jobs <- job
+	return jobID, nil
 }
 
-// AddURLs adds new urls for delete in chan.
-func (ds *DeleteService) AddURLs(data string, userID uint32) {
-	go func() {
-		ds.urlsForDelete <- getURLsFromArr(data, userID, ds.baseURL)
-	}()
+// GetJob returns delete job created by user.
+func (ds *DeleteService) GetJob(ctx context.Context, jobID string, userID uint32) (repository.DeleteJob, error) {
+	job, err := ds.store.GetDeleteJob(ctx, jobID)
+	if err != nil {
+		return repository.DeleteJob{}, err
+	}
+	if job.UserID != userID {
+		return repository.DeleteJob{}, &repository.DeleteJobNotFoundError{}
+	}
+	return job, nil
 }
 
-// Close closing chan urlsForDelete.
+// DeadLetters returns jobs which exhausted all attempts.
+func (ds *DeleteService) DeadLetters() []repository.DeleteJob {
+	ds.mx.Lock()
+	defer ds.mx.Unlock()
+	res := make([]repository.DeleteJob, len(ds.deadLetters))
+	copy(res, ds.deadLetters)
+	return res
+}
+
+// Close stops accepting new jobs and waits until jobs from queue are processed.
+// Jobs waiting for retry stay pending in store and will be restored on next start.
 func (ds *DeleteService) Close() {
-	close(ds.urlsForDelete)
-}
-
-// reAddURLs if db connection is unstable urls for delete add in chan again.
-func (ds *DeleteService) reAddURLs(urls []repository.DeleteURL) {
-	time.Sleep(time.Millisecond * 100)
-	go func() {
-		ds.urlsForDelete <- urls
-	}()
-}
-
-// getURLsFromArr converts data to slice DeleteURL.
-func getURLsFromArr(data string, userID uint32, baseURL string) []repository.DeleteURL {
-	data = data[1 : len(data)-1]
-	splitData := strings.Split(data, ",")
-	baseURL += "/"
-	urls := make([]repository.DeleteURL, len(splitData))
-	for i, url := range splitData {
-		url = strings.TrimSpace(url)
-		url = url[1 : len(url)-1]
-		url = strings.TrimPrefix(url, baseURL)
-		urls[i] = repository.DeleteURL{URL: url, UserID: userID}
-	}
-	return urls
+	ds.mx.Lock()
+	if ds.closed {
+		ds.mx.Unlock()
+		return
+	}
+	ds.closed = true
+	for jobID, timer := range ds.retries {
+		timer.Stop()
+		delete(ds.retries, jobID)
+	}
+	close(ds.jobs)
+	ds.mx.Unlock()
+	ds.wg.Wait()
+}
+
+// coalesce collects jobs from queue in batches.
+// Batch is sent to workers when it contains FlushSize urls or FlushInterval passed since first job in batch.
+func (ds *DeleteService) coalesce() {
+	defer close(ds.batches)
+	var batch []repository.DeleteJob
+	var size int
+	timer := time.NewTimer(ds.conf.FlushInterval)
+	timer.Stop()
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ds.batches <- batch
+		batch = nil
+		size = 0
+	}
+	for {
+		select {
+		case job, ok := <-ds.jobs:
+			if !ok {
+				timer.Stop()
+				flush()
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(ds.conf.FlushInterval)
+			}
+			batch = append(batch, job)
+			size += len(job.URLs) - job.Processed
+			if size >= ds.conf.FlushSize {
+				timer.Stop()
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// work deletes batches of jobs.
+func (ds *DeleteService) work() {
+	defer ds.wg.Done()
+	for batch := range ds.batches {
+		ds.process(batch)
+	}
+}
+
+// chunkPart urls of one job from delete chunk.
+type chunkPart struct {
+	// job - index of job in batch.
+	job  int
+	urls []string
+}
+
+// process deletes not processed urls of jobs from batch by chunks of FlushSize urls,
+// every chunk is deleted by one repository call. Progress of jobs is saved after every deleted chunk,
+// so failed job is retried from first not deleted url.
+func (ds *DeleteService) process(batch []repository.DeleteJob) {
+	errs := make([]error, len(batch))
+	var chunk []chunkPart
+	var size int
+	flush := func() {
+		ds.deleteChunk(batch, errs, chunk)
+		chunk = nil
+		size = 0
+	}
+	for i, job := range batch {
+		for start := job.Processed; start < len(job.URLs) && errs[i] == nil; {
+			end := start + ds.conf.FlushSize - size
+			if end > len(job.URLs) {
+				end = len(job.URLs)
+			}
+			chunk = append(chunk, chunkPart{job: i, urls: job.URLs[start:end]})
+			size += end - start
+			start = end
+			if size >= ds.conf.FlushSize {
+				flush()
+			}
+		}
+	}
+	flush()
+	for i, job := range batch {
+		ds.finishJob(job, errs[i])
+	}
+}
+
+// deleteChunk deletes urls of chunk by one repository call and saves progress of jobs from chunk.
+// If chunk of several jobs fails, urls of every job are deleted by separate call
+// and error of repository is set in errs only for failed jobs.
+func (ds *DeleteService) deleteChunk(batch []repository.DeleteJob, errs []error, chunk []chunkPart) {
+	if len(chunk) == 0 {
+		return
+	}
+	urlsForDelete := make([]repository.DeleteURL, 0)
+	for _, part := range chunk {
+		for _, url := range part.urls {
+			urlsForDelete = append(urlsForDelete, repository.DeleteURL{URL: url, UserID: batch[part.job].UserID})
+		}
+	}
+	if err := ds.repo.DeleteURLs(urlsForDelete); err != nil {
+		log.Printf("Delete chunk of %d urls found err %s", len(urlsForDelete), err)
+		if len(chunk) == 1 {
+			errs[chunk[0].job] = err
+			return
+		}
+		for _, part := range chunk {
+			ds.deleteChunk(batch, errs, []chunkPart{part})
+		}
+		return
+	}
+	for _, part := range chunk {
+		job := &batch[part.job]
+		job.Processed += len(part.urls)
+		if job.Processed < job.Total {
+			job.UpdatedAt = time.Now().UTC()
+			ds.saveJob(*job)
+		}
+	}
+}
+
+// finishJob saves job result. Failed job is retried with exponential delay
+// or moves to dead-letter list after maxDeleteAttempt fails.
+func (ds *DeleteService) finishJob(job repository.DeleteJob, err error) {
+	job.UpdatedAt = time.Now().UTC()
+	if err == nil {
+		log.Printf("Delete job %s success!", job.ID)
+		job.Status = repository.DeleteJobDone
+		job.Processed = job.Total
+		job.LastError = ""
+		ds.saveJob(job)
+		return
+	}
+
+	job.Attempts++
+	job.LastError = err.Error()
+	if job.Attempts >= maxDeleteAttempt {
+		job.Status = repository.DeleteJobFailed
+		ds.saveJob(job)
+		ds.mx.Lock()
+		ds.deadLetters = append(ds.deadLetters, job)
+		ds.mx.Unlock()
+		return
+	}
+	ds.saveJob(job)
+	ds.scheduleRetry(job, retryDelay(job.Attempts))
+}
+
+// scheduleRetry adds job in queue again after delay.
+func (ds *DeleteService) scheduleRetry(job repository.DeleteJob, delay time.Duration) {
+	ds.mx.Lock()
+	defer ds.mx.Unlock()
+	ds.scheduleRetryLocked(job, delay)
+}
+
+// scheduleRetryLocked adds job in queue again after delay, ds.mx must be held.
+// If queue is full retry is scheduled again.
+func (ds *DeleteService) scheduleRetryLocked(job repository.DeleteJob, delay time.Duration) {
+	if ds.closed {
+		return
+	}
+	ds.retries[job.ID] = time.AfterFunc(delay, func() {
+		ds.mx.Lock()
+		defer ds.mx.Unlock()
+		delete(ds.retries, job.ID)
+		if ds.closed {
+			return
+		}
+		if ds.queueFull() {
+			if delay < baseRetryDelay {
+				delay = baseRetryDelay
+			}
+			ds.scheduleRetryLocked(job, delay)
+			return
+		}
+		ds.jobs <- job
+	})
+}
+
+// queueFull checks that queue has no free place, ds.mx must be held.
+func (ds *DeleteService) queueFull() bool {
+	return len(ds.jobs)+ds.reserved >= cap(ds.jobs)
+}
+
+// restorePendingJobs adds jobs which were not finished before restart in queue.
+func (ds *DeleteService) restorePendingJobs() {
+	jobs, err := ds.store.GetPendingDeleteJobs(context.Background())
+	if err != nil {
+		log.Printf("Cant restore delete jobs: %s", err)
+		return
+	}
+	for _, job := range jobs {
+		log.Printf("Restore delete job %s", job.ID)
+		ds.scheduleRetry(job, 0)
+	}
+}
+
+// saveJob saves job in store and logs error.
+func (ds *DeleteService) saveJob(job repository.DeleteJob) {
+	if err := ds.store.SaveDeleteJob(context.Background(), job); err != nil {
+		log.Printf("Cant save delete job %s: %s", job.ID, err)
+	}
+}
+
+// retryDelay returns exponential delay for attempt.
+func retryDelay(attempt int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
+
+// newJobID returns new random job id.
+func newJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// getCode returns code itself or code of short url resolved by codeOf.
+func getCode(item string, codeOf func(shortURL string) (string, bool)) (string, bool) {
+	item = strings.TrimSpace(item)
+	if isCode(item) {
+		return item, true
+	}
+	code, ok := codeOf(item)
+	if !ok || !isCode(code) {
+		return "", false
+	}
+	return code, true
+}
+
+// isCode checks that s is short url code.
+func isCode(s string) bool {
+	id, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && id >= 0
 }
@@ -1,28 +1,80 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go-axesthump-shortener/internal/app/mocks"
 	"go-axesthump-shortener/internal/app/repository"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
-func TestDeleteService_getURLsFromArr(t *testing.T) {
-	data := `["http://localhost:8080/1", "http://localhost:8080/2"]`
-	expected := []repository.DeleteURL{
+func TestDeleteService_ParseURLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		invalid []string
+		wantErr bool
+	}{
 		{
-			URL:    "1",
-			UserID: 0,
+			name: "short urls",
+			data: `["http://localhost:8080/1", "http://localhost:8080/2"]`,
+			want: []string{"1", "2"},
 		},
 		{
-			URL:    "2",
-			UserID: 0,
+			name: "codes and short urls",
+			data: `["1", " http://localhost:8080/2 "]`,
+			want: []string{"1", "2"},
+		},
+		{
+			name:    "short urls with another base url",
+			data:    `["http://localhost:8080/1", "http://example.com/2", "http://localhost:8080/path/3"]`,
+			invalid: []string{"http://example.com/2", "http://localhost:8080/path/3"},
+			wantErr: true,
+		},
+		{
+			name:    "not json array",
+			data:    `["http://localhost:8080/1"`,
+			wantErr: true,
+		},
+		{
+			name:    "not string items",
+			data:    `[1, 2]`,
+			wantErr: true,
+		},
+		{
+			name:    "empty array",
+			data:    `[]`,
+			wantErr: true,
 		},
 	}
-
-	actual := getURLsFromArr(data, 0, "http://localhost:8080")
-	assert.Equalf(t, expected, actual, "")
+	ds := &DeleteService{}
+	codeOf := func(shortURL string) (string, bool) {
+		code := strings.TrimPrefix(shortURL, "http://localhost:8080/")
+		return code, code != shortURL
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes, err := ds.ParseURLs([]byte(tt.data), codeOf)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, codes)
+			if len(tt.invalid) != 0 {
+				var invalidErr *InvalidURLsError
+				require.ErrorAs(t, err, &invalidErr)
+				assert.Equal(t, tt.invalid, invalidErr.URLs)
+			}
+		})
+	}
 }
 
 func TestDeleteService_Close(t *testing.T) {
@@ -30,9 +82,356 @@ func TestDeleteService_Close(t *testing.T) {
 	repo := mocks.NewMockRepository(ctrl)
 	defer ctrl.Finish()
 
-	ds := NewDeleteService(repo, "http://localhost:8080")
+	ds := NewDeleteService(repo, newJournal(t), DeleteConfig{})
 
 	ds.Close()
-	_, ok := <-ds.urlsForDelete
+	_, ok := <-ds.jobs
 	assert.False(t, ok)
+
+	_, err := ds.AddURLs([]string{"1"}, 1)
+	assert.ErrorIs(t, err, &ServiceClosedError{})
+}
+
+func TestDeleteService_AddURLs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockRepository(ctrl)
+	defer ctrl.Finish()
+	journal := newJournal(t)
+	done := make(chan struct{})
+	repo.EXPECT().DeleteURLs([]repository.DeleteURL{
+		{URL: "1", UserID: 7},
+		{URL: "2", UserID: 7},
+	}).DoAndReturn(func(urls []repository.DeleteURL) error {
+		close(done)
+		return nil
+	})
+
+	ds := NewDeleteService(repo, journal, DeleteConfig{})
+	jobID, err := ds.AddURLs([]string{"1", "2"}, 7)
+	require.NoError(t, err)
+	<-done
+	ds.Close()
+
+	job, err := ds.GetJob(context.Background(), jobID, 7)
+	require.NoError(t, err)
+	assert.Equal(t, repository.DeleteJobDone, job.Status)
+	assert.Equal(t, 2, job.Total)
+	assert.Equal(t, 2, job.Processed)
+
+	_, err = ds.GetJob(context.Background(), jobID, 8)
+	assert.ErrorIs(t, err, &repository.DeleteJobNotFoundError{})
+}
+
+func TestDeleteService_AddURLsWithFullQueue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockRepository(ctrl)
+	defer ctrl.Finish()
+	started := make(chan struct{}, 4)
+	block := make(chan struct{})
+	repo.EXPECT().DeleteURLs(gomock.Any()).DoAndReturn(func(urls []repository.DeleteURL) error {
+		started <- struct{}{}
+		<-block
+		return nil
+	}).AnyTimes()
+
+	ds := NewDeleteService(repo, newJournal(t), DeleteConfig{
+		QueueSize: 1,
+		Workers:   1,
+		FlushSize: 1,
+	})
+	// first job blocks worker
+	_, err := ds.AddURLs([]string{"1"}, 1)
+	require.NoError(t, err)
+	<-started
+	// second job blocks coalescer
+	_, err = ds.AddURLs([]string{"2"}, 1)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(ds.jobs) == 0
+	}, time.Second, time.Millisecond)
+	// third job waits in queue
+	_, err = ds.AddURLs([]string{"3"}, 1)
+	require.NoError(t, err)
+
+	_, err = ds.AddURLs([]string{"4"}, 1)
+	assert.ErrorIs(t, err, &QueueFullError{})
+
+	close(block)
+	ds.Close()
+}
+
+func TestDeleteService_AddURLsWithSlowStore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockRepository(ctrl)
+	defer ctrl.Finish()
+	done := make(chan struct{})
+	repo.EXPECT().DeleteURLs([]repository.DeleteURL{{URL: "1", UserID: 1}}).DoAndReturn(
+		func(urls []repository.DeleteURL) error {
+			close(done)
+			return nil
+		},
+	)
+	store := &slowStore{DeleteJobJournal: newJournal(t), saving: make(chan struct{}), unblock: make(chan struct{})}
+
+	ds := NewDeleteService(repo, store, DeleteConfig{QueueSize: 1})
+	added := make(chan error)
+	go func() {
+		_, err := ds.AddURLs([]string{"1"}, 1)
+		added <- err
+	}()
+	<-store.saving
+	// lock is not held while job is saved and place in queue is reserved
+	assert.Empty(t, ds.DeadLetters())
+	_, err := ds.AddURLs([]string{"2"}, 1)
+	assert.ErrorIs(t, err, &QueueFullError{})
+
+	close(store.unblock)
+	require.NoError(t, <-added)
+	<-done
+	ds.Close()
+}
+
+func TestDeleteService_progress(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockRepository(ctrl)
+	defer ctrl.Finish()
+	journal := newJournal(t)
+	failed := make(chan struct{})
+	done := make(chan struct{})
+	gomock.InOrder(
+		repo.EXPECT().DeleteURLs([]repository.DeleteURL{{URL: "1", UserID: 1}, {URL: "2", UserID: 1}}),
+		repo.EXPECT().DeleteURLs([]repository.DeleteURL{{URL: "3", UserID: 1}, {URL: "4", UserID: 1}}),
+		repo.EXPECT().DeleteURLs([]repository.DeleteURL{{URL: "5", UserID: 1}}).DoAndReturn(
+			func(urls []repository.DeleteURL) error {
+				close(failed)
+				return errors.New("connection lost")
+			},
+		),
+		// retry starts from first not deleted url
+		repo.EXPECT().DeleteURLs([]repository.DeleteURL{{URL: "5", UserID: 1}}).DoAndReturn(
+			func(urls []repository.DeleteURL) error {
+				close(done)
+				return nil
+			},
+		),
+	)
+
+	ds := NewDeleteService(repo, journal, DeleteConfig{Workers: 1, FlushSize: 2})
+	jobID, err := ds.AddURLs([]string{"1", "2", "3", "4", "5"}, 1)
+	require.NoError(t, err)
+	<-failed
+	require.Eventually(t, func() bool {
+		job, err := journal.GetDeleteJob(context.Background(), jobID)
+		return err == nil && job.Attempts == 1
+	}, time.Second, time.Millisecond)
+	job, err := journal.GetDeleteJob(context.Background(), jobID)
+	require.NoError(t, err)
+	assert.Equal(t, repository.DeleteJobPending, job.Status)
+	assert.Equal(t, 4, job.Processed)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("job was not retried")
+	}
+	ds.Close()
+	job, err = journal.GetDeleteJob(context.Background(), jobID)
+	require.NoError(t, err)
+	assert.Equal(t, repository.DeleteJobDone, job.Status)
+	assert.Equal(t, 5, job.Processed)
+}
+
+func TestDeleteService_coalesce(t *testing.T) {
+	tests := []struct {
+		name string
+		conf DeleteConfig
+	}{
+		{
+			name: "flush by size",
+			conf: DeleteConfig{
+				Workers:       1,
+				FlushSize:     3,
+				FlushInterval: time.Hour,
+			},
+		},
+		{
+			name: "flush by interval",
+			conf: DeleteConfig{
+				Workers:       1,
+				FlushSize:     100,
+				FlushInterval: 50 * time.Millisecond,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mocks.NewMockRepository(ctrl)
+			defer ctrl.Finish()
+			done := make(chan struct{})
+			repo.EXPECT().DeleteURLs([]repository.DeleteURL{
+				{URL: "1", UserID: 1},
+				{URL: "2", UserID: 2},
+				{URL: "3", UserID: 3},
+			}).DoAndReturn(func(urls []repository.DeleteURL) error {
+				close(done)
+				return nil
+			})
+
+			ds := NewDeleteService(repo, newJournal(t), tt.conf)
+			var jobIDs []string
+			for i := 1; i <= 3; i++ {
+				jobID, err := ds.AddURLs([]string{strconv.Itoa(i)}, uint32(i))
+				require.NoError(t, err)
+				jobIDs = append(jobIDs, jobID)
+			}
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("batch was not deleted")
+			}
+			ds.Close()
+			for i, jobID := range jobIDs {
+				job, err := ds.GetJob(context.Background(), jobID, uint32(i+1))
+				require.NoError(t, err)
+				assert.Equal(t, repository.DeleteJobDone, job.Status)
+			}
+		})
+	}
+}
+
+func TestDeleteService_Retry(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockRepository(ctrl)
+	defer ctrl.Finish()
+	done := make(chan struct{})
+	gomock.InOrder(
+		repo.EXPECT().DeleteURLs(gomock.Any()).Return(errors.New("connection lost")),
+		repo.EXPECT().DeleteURLs(gomock.Any()).DoAndReturn(func(urls []repository.DeleteURL) error {
+			close(done)
+			return nil
+		}),
+	)
+
+	ds := NewDeleteService(repo, newJournal(t), DeleteConfig{})
+	jobID, err := ds.AddURLs([]string{"1"}, 1)
+	require.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("job was not retried")
+	}
+	ds.Close()
+
+	job, err := ds.GetJob(context.Background(), jobID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, repository.DeleteJobDone, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+}
+
+func TestDeleteService_splitFailedBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockRepository(ctrl)
+	defer ctrl.Finish()
+	journal := newJournal(t)
+	retried := make(chan struct{})
+	gomock.InOrder(
+		repo.EXPECT().DeleteURLs([]repository.DeleteURL{
+			{URL: "1", UserID: 1},
+			{URL: "2", UserID: 2},
+			{URL: "3", UserID: 3},
+		}).Return(errors.New("bad url 2")),
+		repo.EXPECT().DeleteURLs([]repository.DeleteURL{{URL: "1", UserID: 1}}).Return(nil),
+		repo.EXPECT().DeleteURLs([]repository.DeleteURL{{URL: "2", UserID: 2}}).Return(errors.New("bad url 2")),
+		repo.EXPECT().DeleteURLs([]repository.DeleteURL{{URL: "3", UserID: 3}}).Return(nil),
+		// only failed job is retried
+		repo.EXPECT().DeleteURLs([]repository.DeleteURL{{URL: "2", UserID: 2}}).DoAndReturn(
+			func(urls []repository.DeleteURL) error {
+				close(retried)
+				return nil
+			},
+		),
+	)
+
+	ds := NewDeleteService(repo, journal, DeleteConfig{Workers: 1, FlushSize: 3})
+	var jobIDs []string
+	for i := 1; i <= 3; i++ {
+		jobID, err := ds.AddURLs([]string{strconv.Itoa(i)}, uint32(i))
+		require.NoError(t, err)
+		jobIDs = append(jobIDs, jobID)
+	}
+	select {
+	case <-retried:
+	case <-time.After(time.Second):
+		t.Fatal("failed job was not retried")
+	}
+	ds.Close()
+
+	for i, attempts := range []int{0, 1, 0} {
+		job, err := journal.GetDeleteJob(context.Background(), jobIDs[i])
+		require.NoError(t, err)
+		assert.Equal(t, repository.DeleteJobDone, job.Status)
+		assert.Equal(t, attempts, job.Attempts)
+	}
+}
+
+func TestDeleteService_RestorePendingJobs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockRepository(ctrl)
+	defer ctrl.Finish()
+	journal := newJournal(t)
+	err := journal.SaveDeleteJob(context.Background(), repository.DeleteJob{
+		ID:     "pending",
+		UserID: 3,
+		URLs:   []string{"5"},
+		Status: repository.DeleteJobPending,
+		Total:  1,
+	})
+	require.NoError(t, err)
+	done := make(chan struct{})
+	repo.EXPECT().DeleteURLs([]repository.DeleteURL{{URL: "5", UserID: 3}}).DoAndReturn(
+		func(urls []repository.DeleteURL) error {
+			close(done)
+			return nil
+		},
+	)
+
+	ds := NewDeleteService(repo, journal, DeleteConfig{})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("pending job was not restored")
+	}
+	ds.Close()
+}
+
+func Test_retryDelay(t *testing.T) {
+	assert.Equal(t, baseRetryDelay, retryDelay(1))
+	assert.Equal(t, 2*baseRetryDelay, retryDelay(2))
+	assert.Equal(t, 8*baseRetryDelay, retryDelay(4))
+	assert.Equal(t, maxRetryDelay, retryDelay(maxDeleteAttempt))
+}
+
+// slowStore DeleteJobJournal which blocks saving of new jobs until unblock is closed.
+type slowStore struct {
+	*repository.DeleteJobJournal
+	saving  chan struct{}
+	unblock chan struct{}
+}
+
+// SaveDeleteJob notifies about saving of new job and waits unblock.
+func (s *slowStore) SaveDeleteJob(ctx context.Context, job repository.DeleteJob) error {
+	if job.Attempts == 0 && job.Processed == 0 && job.Status == repository.DeleteJobPending {
+		s.saving <- struct{}{}
+		<-s.unblock
+	}
+	return s.DeleteJobJournal.SaveDeleteJob(ctx, job)
+}
+
+func newJournal(t *testing.T) *repository.DeleteJobJournal {
+	journal, err := repository.NewDeleteJobJournal("")
+	require.NoError(t, err)
+	return journal
 }
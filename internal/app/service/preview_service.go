@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-axesthump-shortener/internal/app/repository"
+	"golang.org/x/net/html"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Default PreviewConfig settings.
+const (
+	defaultPreviewQueueSize   = 100
+	defaultPreviewWorkers     = 3
+	defaultPreviewTimeout     = 5 * time.Second
+	defaultPreviewMaxBodySize = 1 << 20
+	maxPreviewRedirects       = 5
+	maxPreviewFieldLength     = 2048
+)
+
+// PreviewConfig contains settings for PreviewService. Zero values are replaced by defaults.
+type PreviewConfig struct {
+	// QueueSize - max count of urls waiting for fetch, new urls are dropped when queue is full.
+	QueueSize int
+	// Workers - count goroutines which fetch pages.
+	Workers int
+	// Timeout - max time of one page fetch including redirects.
+	Timeout time.Duration
+	// MaxBodySize - max count of page bytes which are read.
+	MaxBodySize int64
+}
+
+// withDefaults returns copy of PreviewConfig where zero values are replaced by defaults.
+func (c PreviewConfig) withDefaults() PreviewConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = defaultPreviewQueueSize
+	}
+	if c.Workers <= 0 {
+		c.Workers = defaultPreviewWorkers
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultPreviewTimeout
+	}
+	if c.MaxBodySize <= 0 {
+		c.MaxBodySize = defaultPreviewMaxBodySize
+	}
+	return c
+}
+
+// ForbiddenAddressError an error that occurs when page address is in private network.
+type ForbiddenAddressError struct {
+	// Address - forbidden ip address.
+	Address string
+}
+
+// Error return ForbiddenAddressError description.
+func (e *ForbiddenAddressError) Error() string {
+	return "Forbidden address " + e.Address
+}
+
+// Is reports whether target is ForbiddenAddressError.
+func (e *ForbiddenAddressError) Is(target error) bool {
+	_, ok := target.(*ForbiddenAddressError)
+	return ok
+}
+
+// previewTask url which page should be fetched.
+type previewTask struct {
+	shortURL    int64
+	originalURL string
+}
+
+// PreviewService fetches title and Open Graph metadata of url destinations in background.
+type PreviewService struct {
+	// mx - guards tasks sending and closed.
+	mx     sync.Mutex
+	tasks  chan previewTask
+	closed bool
+	wg     sync.WaitGroup
+	repo   repository.Repository
+	client *http.Client
+	conf   PreviewConfig
+	// allowIP - checks address before connection, private networks are forbidden by default.
+	allowIP func(ip net.IP) bool
+}
+
+// NewPreviewService returns new PreviewService and starts its workers.
+func NewPreviewService(repo repository.Repository, conf PreviewConfig) *PreviewService {
+	conf = conf.withDefaults()
+	ps := &PreviewService{
+		tasks:   make(chan previewTask, conf.QueueSize),
+		repo:    repo,
+		conf:    conf,
+		allowIP: isPublicIP,
+	}
+	dialer := &net.Dialer{
+		Timeout: conf.Timeout,
+		Control: ps.checkAddress,
+	}
+	ps.client = &http.Client{
+		Timeout: conf.Timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   conf.Timeout,
+			ResponseHeaderTimeout: conf.Timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxPreviewRedirects {
+				return errors.New("too many redirects")
+			}
+			if !isHTTPURL(req.URL) {
+				return fmt.Errorf("unsupported redirect scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+	ps.wg.Add(conf.Workers)
+	for i := 0; i < conf.Workers; i++ {
+		go ps.work()
+	}
+	return ps
+}
+
+// AddURL adds url in fetch queue. Url is dropped if queue is full or service closed,
+// preview is optional so creation of url never waits for fetcher.
+func (ps *PreviewService) AddURL(shortURL int64, originalURL string) {
+	ps.mx.Lock()
+	defer ps.mx.Unlock()
+	if ps.closed {
+		return
+	}
+	select {
+	case ps.tasks <- previewTask{shortURL: shortURL, originalURL: originalURL}:
+	default:
+		log.Printf("Preview queue is full, skip url %d", shortURL)
+	}
+}
+
+// Close stops accepting new urls and waits until urls from queue are fetched.
+func (ps *PreviewService) Close() {
+	ps.mx.Lock()
+	if ps.closed {
+		ps.mx.Unlock()
+		return
+	}
+	ps.closed = true
+	close(ps.tasks)
+	ps.mx.Unlock()
+	ps.wg.Wait()
+}
+
+// work fetches pages of urls from queue and saves their previews.
+func (ps *PreviewService) work() {
+	defer ps.wg.Done()
+	for task := range ps.tasks {
+		preview, err := ps.Fetch(context.Background(), task.originalURL)
+		if err != nil {
+			log.Printf("Cant fetch preview of url %d: %s", task.shortURL, err)
+			continue
+		}
+		err = ps.repo.SetURLPreview(context.Background(), task.shortURL, task.originalURL, preview)
+		if err != nil {
+			log.Printf("Cant save preview of url %d: %s", task.shortURL, err)
+		}
+	}
+}
+
+// Fetch downloads html page of rawURL and returns its title and Open Graph metadata.
+// Only first MaxBodySize bytes of page are read.
+func (ps *PreviewService) Fetch(ctx context.Context, rawURL string) (repository.URLPreview, error) {
+	pageURL, err := url.Parse(rawURL)
+	if err != nil {
+		return repository.URLPreview{}, err
+	}
+	if !isHTTPURL(pageURL) {
+		return repository.URLPreview{}, fmt.Errorf("unsupported scheme %q", pageURL.Scheme)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL.String(), nil)
+	if err != nil {
+		return repository.URLPreview{}, err
+	}
+	req.Header.Set("Accept", "text/html")
+	resp, err := ps.client.Do(req)
+	if err != nil {
+		return repository.URLPreview{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return repository.URLPreview{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return repository.URLPreview{}, fmt.Errorf("unsupported content type %q", resp.Header.Get("Content-Type"))
+	}
+	preview := parsePreview(io.LimitReader(resp.Body, ps.conf.MaxBodySize))
+	if len(preview.OGImage) != 0 {
+		// relative image is resolved against final page url
+		if image, err := resp.Request.URL.Parse(preview.OGImage); err == nil && isHTTPURL(image) {
+			preview.OGImage = image.String()
+		} else {
+			preview.OGImage = ""
+		}
+	}
+	preview.FetchedAt = time.Now()
+	return preview, nil
+}
+
+// checkAddress forbids connections to addresses rejected by allowIP.
+// Address is checked after dns resolution, so redirects and dns rebinding can't reach private networks.
+func (ps *PreviewService) checkAddress(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !ps.allowIP(ip) {
+		return &ForbiddenAddressError{Address: host}
+	}
+	return nil
+}
+
+// parsePreview returns title and Open Graph metadata from html page head.
+func parsePreview(r io.Reader) repository.URLPreview {
+	var preview repository.URLPreview
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return preview
+		case html.TextToken:
+			if inTitle && len(preview.PageTitle) == 0 {
+				preview.PageTitle = cleanPreviewField(string(tokenizer.Text()))
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return preview
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = true
+			case "body":
+				return preview
+			case "meta":
+				if hasAttr {
+					setOGField(&preview, tokenizer)
+				}
+			}
+		}
+	}
+}
+
+// setOGField sets Open Graph field of preview from attributes of meta tag.
+func setOGField(preview *repository.URLPreview, tokenizer *html.Tokenizer) {
+	var property, content string
+	for {
+		key, value, more := tokenizer.TagAttr()
+		switch string(key) {
+		case "property", "name":
+			property = strings.ToLower(string(value))
+		case "content":
+			content = cleanPreviewField(string(value))
+		}
+		if !more {
+			break
+		}
+	}
+	switch property {
+	case "og:title":
+		preview.OGTitle = content
+	case "og:description":
+		preview.OGDescription = content
+	case "og:image":
+		preview.OGImage = content
+	}
+}
+
+// cleanPreviewField collapses whitespaces of s and cuts it to maxPreviewFieldLength runes.
+func cleanPreviewField(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > maxPreviewFieldLength {
+		s = string(runes[:maxPreviewFieldLength])
+	}
+	return s
+}
+
+// isHTTPURL checks that u is absolute http or https url.
+func isHTTPURL(u *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) != 0
+}
+
+// isPublicIP checks that ip is not loopback, private, link-local, shared or unspecified address.
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// forbiddenNetworks special purpose networks which are not covered by net.IP checks.
+var forbiddenNetworks = mustParseNetworks(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64 can map private ipv4
+)
+
+// mustParseNetworks returns parsed cidrs, panics on bad cidr.
+func mustParseNetworks(cidrs ...string) []*net.IPNet {
+	res := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		res = append(res, network)
+	}
+	return res
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go-axesthump-shortener/internal/app/mocks"
+	"go-axesthump-shortener/internal/app/repository"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const previewPage = `<!DOCTYPE html>
+<html><head>
+<title>
+  Flyer   page
+</title>
+<meta property="og:title" content="Flyer">
+<meta property="og:description" content="Best flyer">
+<meta property="og:image" content="/images/flyer.png">
+</head><body><title>Not a title</title></body></html>`
+
+func newPreviewServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, previewPage)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head>"+strings.Repeat(" ", 4096)+"<title>Late</title></head></html>")
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"title":"json"}`)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, previewPage)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPreviewService_Fetch(t *testing.T) {
+	server := newPreviewServer(t)
+	ps := NewPreviewService(nil, PreviewConfig{Timeout: 200 * time.Millisecond, MaxBodySize: 1024})
+	defer ps.Close()
+	ps.allowIP = func(ip net.IP) bool { return true }
+
+	tests := []struct {
+		name    string
+		url     string
+		want    repository.URLPreview
+		wantErr bool
+	}{
+		{
+			name: "html page",
+			url:  server.URL + "/page",
+			want: repository.URLPreview{
+				PageTitle:     "Flyer page",
+				OGTitle:       "Flyer",
+				OGDescription: "Best flyer",
+				OGImage:       server.URL + "/images/flyer.png",
+			},
+		},
+		{
+			name: "page larger than limit",
+			url:  server.URL + "/large",
+			want: repository.URLPreview{},
+		},
+		{
+			name:    "not html page",
+			url:     server.URL + "/json",
+			wantErr: true,
+		},
+		{
+			name:    "timeout",
+			url:     server.URL + "/slow",
+			wantErr: true,
+		},
+		{
+			name:    "not http url",
+			url:     "ftp://example.com/page",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview, err := ps.Fetch(context.Background(), tt.url)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.False(t, preview.FetchedAt.IsZero())
+			preview.FetchedAt = time.Time{}
+			assert.Equal(t, tt.want, preview)
+		})
+	}
+}
+
+func TestPreviewService_FetchPrivateAddress(t *testing.T) {
+	server := newPreviewServer(t)
+	ps := NewPreviewService(nil, PreviewConfig{})
+	defer ps.Close()
+
+	_, err := ps.Fetch(context.Background(), server.URL+"/page")
+	assert.ErrorIs(t, err, &ForbiddenAddressError{})
+}
+
+func TestPreviewService_AddURL(t *testing.T) {
+	server := newPreviewServer(t)
+	ctrl := gomock.NewController(t)
+	repo := mocks.NewMockRepository(ctrl)
+	defer ctrl.Finish()
+
+	ps := NewPreviewService(repo, PreviewConfig{Workers: 2})
+	ps.allowIP = func(ip net.IP) bool { return true }
+	repo.EXPECT().
+		SetURLPreview(gomock.Any(), int64(1), server.URL+"/page", gomock.Any()).
+		Do(func(ctx context.Context, shortURL int64, originalURL string, preview repository.URLPreview) {
+			assert.Equal(t, "Flyer", preview.OGTitle)
+		}).
+		Return(nil)
+
+	ps.AddURL(1, server.URL+"/page")
+	// page which can't be fetched is not saved
+	ps.AddURL(2, server.URL+"/json")
+	ps.Close()
+	ps.AddURL(3, server.URL+"/page")
+}
+
+func Test_isPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "8.8.8.8", want: true},
+		{ip: "2001:4860:4860::8888", want: true},
+		{ip: "127.0.0.1", want: false},
+		{ip: "10.1.2.3", want: false},
+		{ip: "172.16.0.1", want: false},
+		{ip: "192.168.1.1", want: false},
+		{ip: "169.254.169.254", want: false},
+		{ip: "100.64.0.1", want: false},
+		{ip: "0.0.0.0", want: false},
+		{ip: "::1", want: false},
+		{ip: "fd00::1", want: false},
+		{ip: "fe80::1", want: false},
+		{ip: "::ffff:127.0.0.1", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.want, isPublicIP(net.ParseIP(tt.ip)))
+		})
+	}
+}
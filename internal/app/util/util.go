@@ -1,7 +1,11 @@
 // Package util define utils for app.
 package util
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"time"
+)
 
 // GetEnvOrDefault returns env or defaultValue
 func GetEnvOrDefault(envName string, defaultValue string) string {
@@ -11,3 +15,30 @@ func GetEnvOrDefault(envName string, defaultValue string) string {
 	}
 	return env
 }
+
+// GetEnvIntOrDefault returns env converted to int or defaultValue if env is empty or not a number.
+func GetEnvIntOrDefault(envName string, defaultValue int) int {
+	env := os.Getenv(envName)
+	if len(env) == 0 {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(env)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// GetEnvDurationOrDefault returns env converted to time.Duration or defaultValue
+// if env is empty or not a duration.
+func GetEnvDurationOrDefault(envName string, defaultValue time.Duration) time.Duration {
+	env := os.Getenv(envName)
+	if len(env) == 0 {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(env)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestGetEnvOrDefault(t *testing.T) {
@@ -44,3 +45,64 @@ func TestGetEnvOrDefault(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEnvIntOrDefault(t *testing.T) {
+	tests := []struct {
+		name     string
+		envValue string
+		want     int
+	}{
+		{
+			name:     "Test with number env",
+			envValue: "12",
+			want:     12,
+		},
+		{
+			name:     "Test with not number env",
+			envValue: "twelve",
+			want:     5,
+		},
+		{
+			name:     "Test with empty env",
+			envValue: "",
+			want:     5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("INT_ENV", tt.envValue)
+			defer os.Unsetenv("INT_ENV")
+			if got := GetEnvIntOrDefault("INT_ENV", 5); got != tt.want {
+				t.Errorf("GetEnvIntOrDefault() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetEnvDurationOrDefault(t *testing.T) {
+	tests := []struct {
+		name     string
+		envValue string
+		want     time.Duration
+	}{
+		{
+			name:     "Test with duration env",
+			envValue: "150ms",
+			want:     150 * time.Millisecond,
+		},
+		{
+			name:     "Test with not duration env",
+			envValue: "150",
+			want:     time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("DURATION_ENV", tt.envValue)
+			defer os.Unsetenv("DURATION_ENV")
+			if got := GetEnvDurationOrDefault("DURATION_ENV", time.Second); got != tt.want {
+				t.Errorf("GetEnvDurationOrDefault() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}